@@ -0,0 +1,257 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// maxFaucetAmount is the most the faucet hands out per request
+const maxFaucetAmount = 10000 * bnm.UnitsPerBNM
+
+// createWallet creates a wallet and announces it to the network
+func (s *Server) createWallet(c *gin.Context) {
+	newWallet, err := wallet.NewWallet()
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	// Announce wallet to the network
+	if err := s.p2pNode.AnnounceWallet(newWallet); err != nil {
+		log.Printf("Failed to announce wallet: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":    newWallet.Address,
+		"privateKey": newWallet.ExportPrivateKey(),
+	})
+
+	// Log wallet creation
+	s.audit.LogEvent(audit.InfoLevel, "WalletCreated", fmt.Sprintf("New wallet created with address %s", newWallet.Address), nil)
+}
+
+// importWallet imports a wallet from its private key and announces it to the network
+func (s *Server) importWallet(c *gin.Context) {
+	var request struct {
+		PrivateKey string `json:"privateKey" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	importedWallet, err := wallet.ImportPrivateKey(request.PrivateKey)
+	if err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	// Announce wallet to the network
+	if err := s.p2pNode.AnnounceWallet(importedWallet); err != nil {
+		log.Printf("Failed to announce wallet: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address": importedWallet.Address,
+	})
+
+	// Log wallet import
+	s.audit.LogEvent(audit.InfoLevel, "WalletImported", fmt.Sprintf("Wallet imported with address %s", importedWallet.Address), nil)
+}
+
+// walletPeer looks up the peer a wallet announced itself at
+func (s *Server) walletPeer(c *gin.Context) {
+	announcement, found := s.p2pNode.GetWalletAnnouncement(c.Param("address"))
+	if !found {
+		abort(c, notFound("No verified announcement for this wallet"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":   announcement.Address,
+		"peerId":    announcement.PeerID,
+		"timestamp": announcement.Timestamp,
+		"expiresAt": announcement.ExpiresAt(s.p2pNode.GetWalletAnnouncementTTL()).Unix(),
+	})
+}
+
+// balance returns the balance of an address and the nonce its next transaction
+// must use, after those still pending
+func (s *Server) balance(c *gin.Context) {
+	address := c.Param("address")
+	if !validAddress(address) {
+		abort(c, invalidRequest("Invalid address format"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address": address,
+		"balance": s.token.GetBalance(address),
+		"nonce":   s.NextNonce(address),
+	})
+}
+
+// faucet hands out test tokens from the treasury
+func (s *Server) faucet(c *gin.Context) {
+	var request struct {
+		Address string     `json:"address" binding:"required"`
+		Amount  bnm.Amount `json:"amount" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if !validAddress(request.Address) {
+		abort(c, invalidRequest("Invalid address format"))
+		return
+	}
+
+	// Limit to reasonable amounts for testing
+	if request.Amount <= 0 || request.Amount > maxFaucetAmount {
+		abort(c, invalidRequest("Amount must be between 0 and %s", maxFaucetAmount))
+		return
+	}
+
+	// Transfer tokens from treasury to the address
+	if err := s.token.Transfer("treasury", request.Address, request.Amount); err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Transferred %s BNM to %s", request.Amount, request.Address),
+		"balance": s.token.GetBalance(request.Address),
+	})
+
+	// Log faucet request
+	s.audit.LogEvent(audit.InfoLevel, "FaucetRequest", fmt.Sprintf("Transferred %s BNM to %s", request.Amount, request.Address), nil)
+}
+
+// percentToBasisPoints converts a percentage to whole basis points for fixed-point math
+func percentToBasisPoints(percent float64) int64 {
+	return int64(math.Round(percent * 100))
+}
+
+// distributeInitialTokens splits the treasury between the founder, a new treasury
+// and the community
+func (s *Server) distributeInitialTokens(c *gin.Context) {
+	var request struct {
+		AdminKey         string  `json:"adminKey" binding:"required"`
+		FounderAddress   string  `json:"founderAddress" binding:"required"`
+		TreasuryAddress  string  `json:"treasuryAddress" binding:"required"`
+		CommunityAddress string  `json:"communityAddress" binding:"required"`
+		FounderPercent   float64 `json:"founderPercent"`
+		TreasuryPercent  float64 `json:"treasuryPercent"`
+		CommunityPercent float64 `json:"communityPercent"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	// SECURITY: Validate admin key
+	if s.adminKey == "" || request.AdminKey != s.adminKey {
+		s.audit.LogEvent(audit.WarningLevel, "UnauthorizedAdminAccess",
+			"Invalid admin key provided for token distribution", map[string]interface{}{
+				"ip":         c.ClientIP(),
+				"user_agent": c.GetHeader("User-Agent"),
+			})
+		abort(c, newError(http.StatusUnauthorized, CodeUnauthorized, "Invalid admin key"))
+		return
+	}
+
+	// Validate addresses format
+	if !validAddress(request.FounderAddress) {
+		abort(c, invalidRequest("Invalid founder address format"))
+		return
+	}
+	if !validAddress(request.TreasuryAddress) {
+		abort(c, invalidRequest("Invalid treasury address format"))
+		return
+	}
+	if !validAddress(request.CommunityAddress) {
+		abort(c, invalidRequest("Invalid community address format"))
+		return
+	}
+
+	// Validate percentages
+	if request.FounderPercent < 0 || request.TreasuryPercent < 0 || request.CommunityPercent < 0 {
+		abort(c, invalidRequest("Percentages cannot be negative"))
+		return
+	}
+	if request.FounderPercent > 100 || request.TreasuryPercent > 100 || request.CommunityPercent > 100 {
+		abort(c, invalidRequest("Individual percentages cannot exceed 100"))
+		return
+	}
+
+	// Verify percentages add up to 100
+	if request.FounderPercent+request.TreasuryPercent+request.CommunityPercent != 100.0 {
+		abort(c, invalidRequest("Percentages must add up to 100"))
+		return
+	}
+
+	// Get total supply from treasury
+	totalSupply := s.token.GetBalance("treasury")
+	if totalSupply <= 0 {
+		abort(c, invalidRequest("No tokens available in treasury"))
+		return
+	}
+
+	// Calculate token amounts in basis points, giving the rounding remainder to the community
+	founderAmount := totalSupply.MulDiv(percentToBasisPoints(request.FounderPercent), 10000)
+	treasuryAmount := totalSupply.MulDiv(percentToBasisPoints(request.TreasuryPercent), 10000)
+	communityAmount := totalSupply - founderAmount - treasuryAmount
+
+	// Transfer tokens to founder
+	if err := s.token.Transfer("treasury", request.FounderAddress, founderAmount); err != nil {
+		abort(c, fmt.Errorf("Failed to transfer to founder: %v", err))
+		return
+	}
+
+	// Transfer tokens to new treasury
+	if err := s.token.Transfer("treasury", request.TreasuryAddress, treasuryAmount); err != nil {
+		abort(c, fmt.Errorf("Failed to transfer to treasury: %v", err))
+		return
+	}
+
+	// Transfer tokens to community
+	if err := s.token.Transfer("treasury", request.CommunityAddress, communityAmount); err != nil {
+		abort(c, fmt.Errorf("Failed to transfer to community: %v", err))
+		return
+	}
+
+	// Log the distribution
+	s.audit.LogEvent(audit.InfoLevel, "InitialTokenDistribution", fmt.Sprintf("Distributed tokens: %s to founder, %s to treasury, %s to community",
+		founderAmount, treasuryAmount, communityAmount), map[string]interface{}{
+		"founder":   request.FounderAddress,
+		"treasury":  request.TreasuryAddress,
+		"community": request.CommunityAddress,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"distribution": map[string]interface{}{
+			"founder": map[string]interface{}{
+				"address": request.FounderAddress,
+				"amount":  founderAmount,
+				"percent": request.FounderPercent,
+			},
+			"treasury": map[string]interface{}{
+				"address": request.TreasuryAddress,
+				"amount":  treasuryAmount,
+				"percent": request.TreasuryPercent,
+			},
+			"community": map[string]interface{}{
+				"address": request.CommunityAddress,
+				"amount":  communityAmount,
+				"percent": request.CommunityPercent,
+			},
+		},
+	})
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+func TestServer_CreatesAndImportsWallets(t *testing.T) {
+	node := newTestNode(t)
+
+	recorder, created := node.request(t, http.MethodPost, "/v1/wallet", nil)
+	expect(t, recorder, created, http.StatusOK, "")
+	if !validAddress(created["address"].(string)) {
+		t.Fatalf("Expected a wallet address, got %v", created)
+	}
+
+	recorder, imported := node.request(t, http.MethodPost, "/v1/wallet/import", map[string]interface{}{"privateKey": created["privateKey"]})
+	expect(t, recorder, imported, http.StatusOK, "")
+	if imported["address"] != created["address"] {
+		t.Errorf("Expected to import %v, got %v", created["address"], imported["address"])
+	}
+
+	recorder, response := node.request(t, http.MethodPost, "/v1/wallet/import", map[string]string{"privateKey": "zz"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	// Only wallets announced by peers are known
+	recorder, response = node.request(t, http.MethodGet, "/v1/wallet/"+node.wallet.Address+"/peer", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+}
+
+func TestServer_ServesBalancesAndFaucet(t *testing.T) {
+	node := newTestNode(t)
+	recipient, _ := wallet.NewWallet()
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/balance/"+node.wallet.Address, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["balance"] != "100000" || response["nonce"] != float64(0) {
+		t.Errorf("Unexpected balance: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/balance/treasury", nil)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/faucet", map[string]string{"address": recipient.Address, "amount": "25"})
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["balance"] != "25" {
+		t.Errorf("Expected the faucet to pay 25 BNM, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/faucet", map[string]string{"address": recipient.Address, "amount": "10001"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	// The faucet allows 3 requests per hour, whatever the path
+	recorder, response = node.request(t, http.MethodPost, "/faucet", map[string]string{"address": recipient.Address, "amount": "1"})
+	expect(t, recorder, response, http.StatusOK, "")
+	recorder, response = node.request(t, http.MethodPost, "/v1/faucet", map[string]string{"address": recipient.Address, "amount": "1"})
+	expect(t, recorder, response, http.StatusTooManyRequests, CodeRateLimited)
+}
+
+func TestServer_DistributesInitialTokens(t *testing.T) {
+	node := newTestNode(t)
+	founder, _ := wallet.NewWallet()
+	treasury, _ := wallet.NewWallet()
+	community, _ := wallet.NewWallet()
+
+	request := map[string]interface{}{
+		"adminKey":         "wrong",
+		"founderAddress":   founder.Address,
+		"treasuryAddress":  treasury.Address,
+		"communityAddress": community.Address,
+		"founderPercent":   40,
+		"treasuryPercent":  35,
+		"communityPercent": 25,
+	}
+	recorder, response := node.request(t, http.MethodPost, "/v1/admin/distribute-initial-tokens", request)
+	expect(t, recorder, response, http.StatusUnauthorized, CodeUnauthorized)
+
+	request["adminKey"] = testAdminKey
+	request["communityPercent"] = 30
+	recorder, response = node.request(t, http.MethodPost, "/v1/admin/distribute-initial-tokens", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	request["communityPercent"] = 25
+	supply := node.token.GetBalance("treasury")
+	recorder, response = node.request(t, http.MethodPost, "/v1/admin/distribute-initial-tokens", request)
+	expect(t, recorder, response, http.StatusOK, "")
+	total := node.token.GetBalance(founder.Address) + node.token.GetBalance(treasury.Address) + node.token.GetBalance(community.Address)
+	if total != supply || node.token.GetBalance(founder.Address) != supply.MulDiv(40, 100) {
+		t.Errorf("Expected the treasury to be split 40/35/25, got %v", response)
+	}
+}
+
+func TestServer_SubmitsTransactions(t *testing.T) {
+	node := newTestNode(t)
+	recipient, _ := wallet.NewWallet()
+
+	request := map[string]string{
+		"from":       node.wallet.Address,
+		"to":         recipient.Address,
+		"amount":     "10",
+		"privateKey": node.wallet.ExportPrivateKey(),
+	}
+	recorder, response := node.request(t, http.MethodPost, "/v1/transaction", request)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["fee"] != "0.01" || response["feeDistribution"] != nil {
+		t.Errorf("Unexpected transaction result: %v", response)
+	}
+	if balance := node.token.GetBalance(recipient.Address); balance != 0 {
+		t.Errorf("Expected no balance to move before inclusion, got %s", balance)
+	}
+	txID := response["txId"].(string)
+
+	request["to"] = request["from"]
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	request["to"] = recipient.Address
+	request["amount"] = "1000000"
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInsufficientBalance)
+	if details := response["details"].(map[string]interface{}); details["fee"] != "1000" {
+		t.Errorf("Expected the fee in the details, got %v", details)
+	}
+
+	// Pending transactions count against the balance until they are included
+	request["amount"] = "99990"
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInsufficientBalance)
+	if details := response["details"].(map[string]interface{}); details["pending"] != "10.01" {
+		t.Errorf("Expected the pending spend in the details, got %v", details)
+	}
+
+	// A full mempool turns transactions away without charging the sender
+	node.blockchain.Mempool().SetConfig(&core.MempoolConfig{MaxSize: 1})
+	request["amount"] = "1"
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction", request)
+	expect(t, recorder, response, http.StatusServiceUnavailable, CodeMempoolFull)
+	if balance := node.token.GetBalance(node.wallet.Address); balance != bnm.FromBNM(100000) {
+		t.Errorf("Expected the sender's balance to be untouched, got %s", balance)
+	}
+
+	// The transaction is pending until a block includes it
+	recorder, response = node.request(t, http.MethodGet, "/v1/transactions/"+txID+"/receipt", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["status"] != string(core.ReceiptPending) {
+		t.Errorf("Expected a pending receipt, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/transactions/"+txID, nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/transactions/AdNeMissing/receipt", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/addresses/"+node.wallet.Address+"/transactions?direction=sent", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["total"] != float64(0) || response["limit"] != float64(core.DefaultTxPageSize) {
+		t.Errorf("Expected no included transactions, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/addresses/"+node.wallet.Address+"/transactions?limit=0", nil)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestServer_SubmitsSignedTransactions(t *testing.T) {
+	node := newTestNode(t)
+	recipient, _ := wallet.NewWallet()
+
+	tx, err := core.NewTransaction(node.wallet.Address, recipient.Address, bnm.FromBNM(5), 0, node.wallet)
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	request := SignedTransactionRequest{
+		ID:        tx.ID,
+		From:      tx.From,
+		To:        tx.To,
+		Amount:    tx.Amount,
+		Nonce:     tx.Nonce,
+		Timestamp: tx.Timestamp,
+		Signature: tx.Signature,
+		PublicKey: tx.PublicKey,
+		Version:   tx.Version,
+	}
+
+	recorder, response := node.request(t, http.MethodPost, "/v1/transaction/signed", request)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["txId"] != tx.ID || node.token.GetBalance(recipient.Address) != 0 {
+		t.Errorf("Unexpected signed transaction result: %v", response)
+	}
+
+	// Replaying the transaction reuses its nonce, which is pending but not yet consumed
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction/signed", request)
+	expect(t, recorder, response, http.StatusConflict, CodeConflict)
+	if details := response["details"].(map[string]interface{}); details["expectedNonce"] != float64(1) {
+		t.Errorf("Expected the next nonce in the details, got %v", details)
+	}
+	if nonce := node.token.GetNonce(node.wallet.Address); nonce != 0 {
+		t.Errorf("Expected the confirmed nonce to wait for inclusion, got %d", nonce)
+	}
+
+	request.Amount = bnm.FromBNM(6)
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction/signed", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	// A legacy ID does not cover the nonce, so legacy transactions are refused even
+	// where legacy hashes still validate
+	defer func(allow bool) { core.AllowLegacyHashes = allow }(core.AllowLegacyHashes)
+	core.AllowLegacyHashes = true
+
+	legacy := core.Transaction{From: tx.From, To: tx.To, Amount: bnm.FromBNM(5), Nonce: 1, Timestamp: tx.Timestamp + 1, PublicKey: tx.PublicKey}
+	legacy.ID = core.CalculateTransactionID(&legacy)
+	signature, _ := node.wallet.Sign([]byte(legacy.ID))
+	legacy.Signature = hex.EncodeToString(signature)
+	if err := core.VerifyTransactionSignature(&legacy); err != nil {
+		t.Fatalf("Expected legacy transaction to carry a valid signature: %v", err)
+	}
+	request = SignedTransactionRequest{
+		ID:        legacy.ID,
+		From:      legacy.From,
+		To:        legacy.To,
+		Amount:    legacy.Amount,
+		Nonce:     legacy.Nonce,
+		Timestamp: legacy.Timestamp,
+		Signature: legacy.Signature,
+		PublicKey: legacy.PublicKey,
+	}
+	recorder, response = node.request(t, http.MethodPost, "/v1/transaction/signed", request)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+	if node.blockchain.Mempool().Contains(legacy.ID) {
+		t.Error("Expected legacy transaction to stay out of the mempool")
+	}
+}
+
+func TestServer_ManagesDelegates(t *testing.T) {
+	node := newTestNode(t)
+	voter, _ := wallet.NewWallet()
+	node.token.Transfer("treasury", voter.Address, bnm.FromBNM(500))
+
+	recorder, response := node.request(t, http.MethodPost, "/v1/delegates/register", map[string]string{
+		"address":    node.wallet.Address,
+		"stake":      "1000000",
+		"privateKey": node.wallet.ExportPrivateKey(),
+	})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInsufficientBalance)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/delegates/register", map[string]string{
+		"address":    node.wallet.Address,
+		"stake":      "50000",
+		"privateKey": voter.ExportPrivateKey(),
+	})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/delegates/register", map[string]string{
+		"address":    node.wallet.Address,
+		"stake":      "50000",
+		"privateKey": node.wallet.ExportPrivateKey(),
+	})
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/delegates/vote", map[string]string{
+		"voterAddress":    voter.Address,
+		"delegateAddress": node.wallet.Address,
+		"amount":          "100",
+		"privateKey":      voter.ExportPrivateKey(),
+	})
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/delegates", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["maxDelegates"] != float64(21) || response["count"].(float64) < 2 {
+		t.Errorf("Expected the founder and the new delegate, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/delegates/"+node.wallet.Address, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["Address"] != node.wallet.Address {
+		t.Errorf("Unexpected delegate: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/delegates/"+voter.Address, nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+)
+
+// auditEvents returns all logged audit events
+func (s *Server) auditEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"events": s.audit.GetEvents(),
+	})
+}
+
+// securityAudit audits the blockchain and reports the critical events logged
+func (s *Server) securityAudit(c *gin.Context) {
+	// Perform a full blockchain audit
+	s.audit.AuditBlockchain()
+
+	// Get critical events
+	criticalEvents := s.audit.GetEventsByLevel(audit.CriticalLevel)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "completed",
+		"issues": len(criticalEvents),
+		"events": criticalEvents,
+	})
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/core"
+)
+
+// peers lists the connected peers and their scores
+func (s *Server) peers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"peers":  s.p2pNode.GetPeers(),
+		"count":  s.p2pNode.GetPeerCount(),
+		"scores": s.p2pNode.GetPeerScores(),
+	})
+}
+
+// connectPeer connects to a peer by multiaddress
+func (s *Server) connectPeer(c *gin.Context) {
+	var request struct {
+		Address string `json:"address" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := s.p2pNode.ConnectToPeer(request.Address); err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "connected",
+		"peer":   request.Address,
+	})
+}
+
+// blocks returns a page of the chain in height order. The page starts at from, or
+// at the cursor of the previous page, and ends at the inclusive to, the limit or
+// the tip; headers=true omits the blocks' transactions.
+func (s *Server) blocks(c *gin.Context) {
+	from, err := queryHeight(c, "from")
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		if _, ok := c.GetQuery("from"); ok {
+			abort(c, invalidRequest("from and cursor cannot be combined"))
+			return
+		}
+		if from, err = strconv.ParseUint(cursor, 10, 63); err != nil {
+			abort(c, invalidRequest("Invalid cursor"))
+			return
+		}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(core.DefaultBlockPageSize)))
+	if err != nil || limit < 1 || limit > core.MaxBlockPageSize {
+		abort(c, invalidRequest("limit must be between 1 and %d", core.MaxBlockPageSize))
+		return
+	}
+
+	_, bounded := c.GetQuery("to")
+	to, err := queryHeight(c, "to")
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if bounded {
+		if to < from {
+			abort(c, invalidRequest("to must not be below from"))
+			return
+		}
+		if span := to - from + 1; span < uint64(limit) {
+			limit = int(span)
+		}
+	}
+
+	headersOnly, err := strconv.ParseBool(c.DefaultQuery("headers", "false"))
+	if err != nil {
+		abort(c, invalidRequest("headers must be true or false"))
+		return
+	}
+
+	blocks, err := s.blockchain.GetBlocks(core.BlockQuery{From: from, Limit: limit})
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	count := s.blockchain.GetBlockCount()
+
+	response := gin.H{
+		"blocks": blocks,
+		"count":  count,
+		"from":   from,
+		"limit":  limit,
+	}
+	if headersOnly {
+		headers := make([]core.BlockHeader, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		response["blocks"] = headers
+	}
+
+	// A full page is followed by the blocks up to the tip or the requested bound
+	if len(blocks) == limit {
+		next := blocks[len(blocks)-1].Index + 1
+		if next < uint64(count) && (!bounded || next <= to) {
+			response["nextCursor"] = strconv.FormatUint(next, 10)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// queryHeight parses an optional block height of the query string. Heights are
+// kept within the range of signed 64-bit integers that SQL backends store.
+func queryHeight(c *gin.Context, name string) (uint64, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return 0, nil
+	}
+	height, err := strconv.ParseUint(value, 10, 63)
+	if err != nil {
+		return 0, invalidRequest("Invalid %s height", name)
+	}
+	return height, nil
+}
+
+// blockIndex parses the block index of a request path
+func blockIndex(c *gin.Context) (uint64, error) {
+	index, err := strconv.ParseUint(c.Param("index"), 10, 64)
+	if err != nil {
+		return 0, invalidRequest("Invalid block index")
+	}
+	return index, nil
+}
+
+// block returns a block by index
+func (s *Server) block(c *gin.Context) {
+	index, err := blockIndex(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	block, err := s.blockchain.GetBlockByIndex(index)
+	if err != nil {
+		abort(c, notFound("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, block)
+}
+
+// blockByHash returns a canonical block by hash
+func (s *Server) blockByHash(c *gin.Context) {
+	block, err := s.blockchain.GetBlockByHash(c.Param("hash"))
+	if errors.Is(err, core.ErrBlockNotFound) {
+		abort(c, notFound("%v", err))
+		return
+	}
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, block)
+}
+
+// finalizedBlock returns the last irreversible block
+func (s *Server) finalizedBlock(c *gin.Context) {
+	irreversible := s.consensus.GetLastIrreversibleBlock()
+	block, err := s.blockchain.GetBlockByIndex(irreversible.Index)
+	if err != nil {
+		abort(c, notFound("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, block)
+}
+
+// merkleProof returns a Merkle inclusion proof for a transaction in a block
+func (s *Server) merkleProof(c *gin.Context) {
+	index, err := blockIndex(c)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	block, err := s.blockchain.GetBlockByIndex(index)
+	if err != nil {
+		abort(c, notFound("%v", err))
+		return
+	}
+	if block.Version < core.MerkleEncodingVersion {
+		abort(c, newError(http.StatusUnprocessableEntity, CodeUnprocessable, "Block does not commit to a Merkle root"))
+		return
+	}
+
+	proof, err := core.BuildMerkleProof(block.Data, c.Param("txId"))
+	if err != nil {
+		abort(c, notFound("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"header": block.Header(),
+		"proof":  proof,
+	})
+}
+
+// sync fetches a peer's chain over HTTP and adopts its blocks
+func (s *Server) sync(c *gin.Context) {
+	var request struct {
+		PeerAddress string `json:"peerAddress" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	peerBlockchain, err := fetchPeerBlocks(request.PeerAddress)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	localBlockCount := s.blockchain.GetBlockCount()
+	if len(peerBlockchain.Blocks) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status":      "no sync needed",
+			"localBlocks": localBlockCount,
+			"peerBlocks":  peerBlockchain.Count,
+		})
+		return
+	}
+
+	// Check if genesis blocks are different
+	localGenesis, _ := s.blockchain.GetBlockByIndex(0)
+	peerGenesis := peerBlockchain.Blocks[0]
+
+	if localGenesis.Hash != peerGenesis.Hash {
+		// Only switch networks to a longer chain
+		if len(peerBlockchain.Blocks) <= localBlockCount {
+			c.JSON(http.StatusOK, gin.H{
+				"status":      "no sync needed",
+				"localBlocks": localBlockCount,
+				"peerBlocks":  peerBlockchain.Count,
+			})
+			return
+		}
+
+		// Genesis blocks are different, we need to replace the entire chain
+		log.Printf("Different genesis blocks detected. Replacing local chain with peer chain.")
+
+		// Create a new blockchain with the peer's genesis block
+		newBlockchain := core.NewBlockchainWithGenesis(peerGenesis)
+
+		// Add all blocks from the peer that consensus accepts
+		for i := 1; i < len(peerBlockchain.Blocks); i++ {
+			block := peerBlockchain.Blocks[i]
+			if !s.consensus.ValidateBlock(block) {
+				abort(c, syncFailed(i, "Block %d rejected by consensus", i))
+				return
+			}
+			if err := newBlockchain.AddBlock(block); err != nil {
+				abort(c, syncFailed(i, "Failed to add block %d: %v", i, err))
+				return
+			}
+		}
+
+		// Replace the blockchain safely
+		if err := s.blockchain.ReplaceChain(newBlockchain.GetChain()); err != nil {
+			abort(c, newError(http.StatusConflict, CodeConflict, "%v", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":        "full chain replacement completed",
+			"blocksAdded":   len(peerBlockchain.Blocks) - 1,
+			"newBlockCount": s.blockchain.GetBlockCount(),
+			"stateVerified": s.stateVerified("after chain replacement"),
+		})
+		return
+	}
+
+	// Genesis blocks are the same: skip the blocks we share with the peer
+	first := 1
+	for ; first < len(peerBlockchain.Blocks); first++ {
+		block := peerBlockchain.Blocks[first]
+		if local, err := s.blockchain.GetBlockByIndex(block.Index); err != nil || local.Hash != block.Hash {
+			break
+		}
+	}
+	for i := first; i < len(peerBlockchain.Blocks); i++ {
+		if !s.consensus.ValidateBlock(peerBlockchain.Blocks[i]) {
+			abort(c, syncFailed(i, "Block %d rejected by consensus", i))
+			return
+		}
+	}
+
+	// A branch forking off below our tip is offered to fork choice as a whole,
+	// reorganizing onto it if it is preferred
+	blocksAdded := 0
+	if first < len(peerBlockchain.Blocks) && first < localBlockCount {
+		branch := peerBlockchain.Blocks[first:]
+		adopted, err := core.AdoptBranch(s.blockchain, branch)
+		if err != nil {
+			abort(c, syncFailed(first, "Failed to adopt peer branch from block %d: %v", first, err))
+			return
+		}
+		if adopted {
+			blocksAdded = len(branch)
+		}
+		first = len(peerBlockchain.Blocks)
+	}
+
+	// Blocks extending our tip are added one by one
+	for i := first; i < len(peerBlockchain.Blocks); i++ {
+		if err := s.blockchain.AddBlock(peerBlockchain.Blocks[i]); err != nil {
+			if errors.Is(err, core.ErrKnownBlock) {
+				continue
+			}
+			abort(c, syncFailed(i, "Failed to add block %d: %v", i, err))
+			return
+		}
+		blocksAdded++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "sync completed",
+		"blocksAdded":   blocksAdded,
+		"newBlockCount": s.blockchain.GetBlockCount(),
+		"stateVerified": s.stateVerified("after sync"),
+	})
+}
+
+// syncFailed reports a peer block that could not be adopted, along with the
+// height synchronization got to before it
+func syncFailed(index int, format string, args ...interface{}) *Error {
+	err := invalidRequest(format, args...)
+	err.Details = map[string]interface{}{"syncedUntil": index - 1}
+	return err
+}
+
+// peerChain is a peer's chain as downloaded for synchronization
+type peerChain struct {
+	Blocks []core.Block
+	Count  int
+}
+
+// fetchPeerBlocks downloads a peer's chain page by page. It reads the unversioned
+// path, which older nodes serve too, returning their whole chain as one page.
+func fetchPeerBlocks(peerAddress string) (peerChain, error) {
+	var chain peerChain
+	cursor := ""
+	for {
+		pageURL := fmt.Sprintf("http://%s/blocks?limit=%d", peerAddress, core.MaxBlockPageSize)
+		if cursor != "" {
+			pageURL += "&cursor=" + url.QueryEscape(cursor)
+		}
+		resp, err := http.Get(pageURL)
+		if err != nil {
+			return peerChain{}, newError(http.StatusBadGateway, CodePeerUnavailable, "Failed to connect to peer: %v", err)
+		}
+
+		var page struct {
+			Blocks     []core.Block `json:"blocks"`
+			Count      int          `json:"count"`
+			NextCursor string       `json:"nextCursor"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return peerChain{}, newError(http.StatusBadGateway, CodePeerUnavailable, "Failed to parse peer blockchain: %v", err)
+		}
+		if len(page.Blocks) > 0 && page.Blocks[0].Index != uint64(len(chain.Blocks)) {
+			return peerChain{}, newError(http.StatusBadGateway, CodePeerUnavailable, "Peer returned block %d, expected block %d", page.Blocks[0].Index, len(chain.Blocks))
+		}
+
+		chain.Blocks = append(chain.Blocks, page.Blocks...)
+		chain.Count = page.Count
+		if page.NextCursor == "" || len(page.Blocks) == 0 {
+			return chain, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// stateVerified checks that replaying synced blocks led to the state the tip
+// committed to
+func (s *Server) stateVerified(stage string) bool {
+	if err := core.CheckStateRoot(s.blockchain.GetLastBlock(), s.stateProviders...); err != nil {
+		log.Printf("Warning: State diverged from peer %s: %v", stage, err)
+		return false
+	}
+	return true
+}
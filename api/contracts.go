@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/smartcontract"
+)
+
+// contractError converts a failed contract request into an API error
+func contractError(err error) error {
+	var requestErr *smartcontract.RequestError
+	switch {
+	case errors.As(err, &requestErr):
+		apiErr := invalidRequest("%s", requestErr.Message)
+		apiErr.Details = requestErr.Details
+		return apiErr
+	case errors.Is(err, smartcontract.ErrContractNotFound), errors.Is(err, smartcontract.ErrStateNotFound):
+		return notFound("%v", err)
+	case errors.Is(err, smartcontract.ErrNotOwner):
+		return newError(http.StatusForbidden, CodeForbidden, "%v", err)
+	}
+	return err
+}
+
+// deployContract deploys a contract
+func (s *Server) deployContract(c *gin.Context) {
+	var request smartcontract.DeployRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	result, err := s.contracts.Deploy(request)
+	if err != nil {
+		abort(c, contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// executeContract calls a contract function
+func (s *Server) executeContract(c *gin.Context) {
+	var request smartcontract.ExecuteRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	result, err := s.contracts.Execute(c.Param("id"), request)
+	if err != nil {
+		abort(c, contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// contract returns a contract's details
+func (s *Server) contract(c *gin.Context) {
+	contract, err := s.contracts.Contract(c.Param("id"))
+	if err != nil {
+		abort(c, contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, contract)
+}
+
+// listContracts lists all contracts
+func (s *Server) listContracts(c *gin.Context) {
+	contracts := s.contracts.Contracts()
+
+	c.JSON(http.StatusOK, gin.H{
+		"contracts": contracts,
+		"count":     len(contracts),
+	})
+}
+
+// contractState returns the value a contract stores under a key
+func (s *Server) contractState(c *gin.Context) {
+	contractID := c.Param("id")
+	key := c.Param("key")
+
+	value, err := s.contracts.State(contractID, key)
+	if err != nil {
+		abort(c, contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"contractId": contractID,
+		"key":        key,
+		"value":      value,
+	})
+}
+
+// setContractState sets contract state on behalf of the contract's owner
+func (s *Server) setContractState(c *gin.Context) {
+	contractID := c.Param("id")
+
+	var request smartcontract.SetStateRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := s.contracts.SetState(contractID, request); err != nil {
+		abort(c, contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"contractId": contractID,
+		"key":        request.Key,
+		"value":      request.Value,
+	})
+}
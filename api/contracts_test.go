@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/igo-used/binomena/wallet"
+)
+
+// emptyModule is the smallest valid WebAssembly module
+var emptyModule = []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+
+func TestServer_ServesContracts(t *testing.T) {
+	node := newTestNode(t)
+	owner := node.wallet
+	stranger, _ := wallet.NewWallet()
+
+	deploy := map[string]string{
+		"owner":      owner.Address,
+		"name":       "empty",
+		"code":       base64.StdEncoding.EncodeToString(emptyModule),
+		"fee":        "1",
+		"privateKey": stranger.ExportPrivateKey(),
+	}
+	recorder, response := node.request(t, http.MethodPost, "/v1/contracts/deploy", deploy)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	deploy["privateKey"] = owner.ExportPrivateKey()
+	recorder, response = node.request(t, http.MethodPost, "/v1/contracts/deploy", deploy)
+	expect(t, recorder, response, http.StatusOK, "")
+	contractID := response["contractId"].(string)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/contracts/"+contractID, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/contracts/AdNeMissing", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/contracts", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["count"] != float64(1) {
+		t.Errorf("Expected the deployed contract, got %v", response)
+	}
+
+	// The empty module exports no functions, which the execution result reports
+	execute := map[string]string{
+		"caller":     owner.Address,
+		"function":   "add",
+		"fee":        "1",
+		"privateKey": owner.ExportPrivateKey(),
+	}
+	recorder, response = node.request(t, http.MethodPost, "/v1/contracts/"+contractID+"/execute", execute)
+	expect(t, recorder, response, http.StatusOK, "")
+	if result := response["result"].(map[string]interface{}); result["success"] != false {
+		t.Errorf("Expected the call to fail, got %v", result)
+	}
+
+	execute["fee"] = "1000000"
+	recorder, response = node.request(t, http.MethodPost, "/v1/contracts/"+contractID+"/execute", execute)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/contracts/"+contractID+"/state/greeting", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	setState := map[string]interface{}{
+		"key":        "greeting",
+		"value":      "hello",
+		"caller":     stranger.Address,
+		"privateKey": stranger.ExportPrivateKey(),
+	}
+	recorder, response = node.request(t, http.MethodPost, "/v1/contracts/"+contractID+"/state", setState)
+	expect(t, recorder, response, http.StatusForbidden, CodeForbidden)
+
+	setState["caller"] = owner.Address
+	setState["privateKey"] = owner.ExportPrivateKey()
+	recorder, response = node.request(t, http.MethodPost, "/v1/contracts/"+contractID+"/state", setState)
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/contracts/"+contractID+"/state/greeting", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["value"] != "hello" {
+		t.Errorf("Expected the stored value, got %v", response)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// authorize checks that a private key belongs to an address holding an amount
+func (s *Server) authorize(address, privateKey string, amount bnm.Amount, purpose string) error {
+	// Verify wallet ownership
+	signer, err := wallet.ImportPrivateKey(privateKey)
+	if err != nil {
+		return invalidRequest("Invalid private key")
+	}
+	if signer.Address != address {
+		return invalidRequest("Private key does not match address")
+	}
+
+	// Check if the address has enough balance
+	if amount <= 0 {
+		return invalidRequest("Amount must be positive")
+	}
+	if balance := s.token.GetBalance(address); balance < amount {
+		return insufficientBalance("Insufficient balance for "+purpose, balance, amount)
+	}
+	return nil
+}
+
+// registerDelegate registers an address as a delegate with a stake
+func (s *Server) registerDelegate(c *gin.Context) {
+	var request struct {
+		Address    string     `json:"address" binding:"required"`
+		Stake      bnm.Amount `json:"stake" binding:"required"`
+		PrivateKey string     `json:"privateKey" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := s.authorize(request.Address, request.PrivateKey, request.Stake, "stake"); err != nil {
+		abort(c, err)
+		return
+	}
+
+	// Register delegate
+	if err := s.consensus.RegisterDelegate(request.Address, request.Stake); err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Delegate registered with %s BNM stake", request.Stake),
+		"address": request.Address,
+	})
+
+	s.audit.LogEvent(audit.InfoLevel, "DelegateRegistered",
+		fmt.Sprintf("New delegate registered: %s with stake %s BNM", request.Address, request.Stake), nil)
+}
+
+// voteForDelegate casts a vote for a delegate
+func (s *Server) voteForDelegate(c *gin.Context) {
+	var request struct {
+		VoterAddress    string     `json:"voterAddress" binding:"required"`
+		DelegateAddress string     `json:"delegateAddress" binding:"required"`
+		Amount          bnm.Amount `json:"amount" binding:"required"`
+		PrivateKey      string     `json:"privateKey" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := s.authorize(request.VoterAddress, request.PrivateKey, request.Amount, "vote"); err != nil {
+		abort(c, err)
+		return
+	}
+
+	// Vote for delegate
+	if err := s.consensus.VoteForDelegate(request.VoterAddress, request.DelegateAddress, request.Amount); err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Voted %s BNM for delegate %s", request.Amount, request.DelegateAddress),
+	})
+
+	s.audit.LogEvent(audit.InfoLevel, "DelegateVote",
+		fmt.Sprintf("Vote cast: %s voted %s BNM for delegate %s", request.VoterAddress, request.Amount, request.DelegateAddress), nil)
+}
+
+// delegates lists the registered delegates
+func (s *Server) delegates(c *gin.Context) {
+	delegates := s.consensus.GetDelegates()
+
+	c.JSON(http.StatusOK, gin.H{
+		"delegates":    delegates,
+		"count":        len(delegates),
+		"maxDelegates": consensus.MaxDelegates,
+	})
+}
+
+// delegate returns a delegate by address
+func (s *Server) delegate(c *gin.Context) {
+	address := c.Param("address")
+
+	for _, delegate := range s.consensus.GetDelegates() {
+		if delegate.Address == address {
+			c.JSON(http.StatusOK, delegate)
+			return
+		}
+	}
+
+	abort(c, notFound("Delegate not found"))
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes reported in the error envelope, letting clients tell failures apart
+// without parsing messages
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeUnprocessable       = "unprocessable"
+	CodeInsufficientBalance = "insufficient_balance"
+	CodeRateLimited         = "rate_limited"
+	CodePeerUnavailable     = "peer_unavailable"
+	CodeMempoolFull         = "mempool_full"
+	CodeInternal            = "internal_error"
+)
+
+// Error is a failed request, reported to clients as a JSON envelope holding the
+// message, a code and optional details
+type Error struct {
+	Status  int                    `json:"-"`
+	Message string                 `json:"error"`
+	Code    string                 `json:"code"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// newError creates an error replied with an HTTP status
+func newError(status int, code, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// invalidRequest creates an error for a request the client must fix
+func invalidRequest(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, CodeInvalidRequest, format, args...)
+}
+
+// notFound creates an error for a missing resource
+func notFound(format string, args ...interface{}) *Error {
+	return newError(http.StatusNotFound, CodeNotFound, format, args...)
+}
+
+// insufficientBalance creates an error for an address unable to pay an amount
+func insufficientBalance(message string, balance, required interface{}) *Error {
+	err := newError(http.StatusBadRequest, CodeInsufficientBalance, "%s", message)
+	err.Details = map[string]interface{}{"balance": balance, "required": required}
+	return err
+}
+
+// abort replies with an error envelope, reporting errors other than *Error as
+// internal errors
+func abort(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("API request %s %s failed: %v", c.Request.Method, c.FullPath(), err)
+		apiErr = newError(http.StatusInternalServerError, CodeInternal, "%s", err.Error())
+	}
+	c.AbortWithStatusJSON(apiErr.Status, apiErr)
+}
+
+// bindJSON decodes and validates a request body, replying with an error and
+// returning false when it is invalid
+func bindJSON(c *gin.Context, request interface{}) bool {
+	err := c.ShouldBindJSON(request)
+	if err == nil {
+		return true
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		abort(c, invalidRequest("invalid request body: %v", err))
+		return false
+	}
+
+	// Report each invalid field by its JSON name
+	fields := make(map[string]interface{}, len(fieldErrors))
+	names := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		name := jsonName(request, fieldError.StructField())
+		fields[name] = fieldError.Tag()
+		names = append(names, name)
+	}
+	apiErr := invalidRequest("missing or invalid fields: %s", strings.Join(names, ", "))
+	apiErr.Details = map[string]interface{}{"fields": fields}
+	abort(c, apiErr)
+	return false
+}
+
+// jsonName returns the JSON name of a field of a request struct
+func jsonName(request interface{}, fieldName string) string {
+	field, ok := reflect.TypeOf(request).Elem().FieldByName(fieldName)
+	if !ok {
+		return fieldName
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return fieldName
+}
+
+// validAddress reports whether an address has the wallet format: "AdNe" followed
+// by 40 hex characters
+func validAddress(address string) bool {
+	if len(address) != 44 || !strings.HasPrefix(address, "AdNe") {
+		return false
+	}
+	_, err := hex.DecodeString(address[4:])
+	return err == nil
+}
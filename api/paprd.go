@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+)
+
+// errPAPRDLedger is replied when the PAPRD ledger cannot be read
+var errPAPRDLedger = newError(http.StatusInternalServerError, CodeInternal, "Failed to read PAPRD ledger")
+
+// readPAPRDLedger reads the PAPRD stablecoin ledger
+func (s *Server) readPAPRDLedger() (map[string]interface{}, error) {
+	data, err := os.ReadFile(s.paprdLedger)
+	if err != nil {
+		return nil, err
+	}
+	var ledger map[string]interface{}
+	err = json.Unmarshal(data, &ledger)
+	return ledger, err
+}
+
+// writePAPRDLedger writes the PAPRD stablecoin ledger
+func (s *Server) writePAPRDLedger(ledger map[string]interface{}) error {
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.paprdLedger, data, 0644)
+}
+
+// fromDecimals converts an amount from 18 decimals
+func fromDecimals(amount string) string {
+	if amount == "" || amount == "0" {
+		return "0"
+	}
+	// Simple conversion for display - divide by 10^18
+	// For production, use big.Int for precision
+	val, _ := strconv.ParseFloat(amount, 64)
+	return fmt.Sprintf("%.0f", val/1e18)
+}
+
+// toDecimals converts an amount to 18 decimals
+func toDecimals(amount float64) string {
+	return fmt.Sprintf("%.0f", amount*1e18)
+}
+
+// paprdAmount parses a positive PAPRD amount
+func paprdAmount(amount string) (float64, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil || value <= 0 {
+		return 0, invalidRequest("Amount must be a positive number")
+	}
+	return value, nil
+}
+
+// paprdBalanceOf returns the balance of an address in the ledger, in 18 decimals
+func paprdBalanceOf(ledger map[string]interface{}, address string) string {
+	balances := ledger["balances"].(map[string]interface{})
+	if balance, exists := balances[address]; exists {
+		return balance.(string)
+	}
+	return "0"
+}
+
+// recordPAPRDTransaction appends a transaction to the ledger
+func recordPAPRDTransaction(ledger map[string]interface{}, txType, from, to, amountWei string) map[string]interface{} {
+	tx := map[string]interface{}{
+		"id":        fmt.Sprintf("tx_%d", time.Now().UnixNano()),
+		"type":      txType,
+		"from":      from,
+		"to":        to,
+		"amount":    amountWei,
+		"timestamp": time.Now().Format(time.RFC3339),
+		"block":     time.Now().Unix(),
+		"status":    "confirmed",
+	}
+	ledger["transactions"] = append(ledger["transactions"].([]interface{}), tx)
+	return tx
+}
+
+// paprdInfo returns the PAPRD token information
+func (s *Server) paprdInfo(c *gin.Context) {
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":        ledger["token_name"],
+		"symbol":      ledger["token_symbol"],
+		"decimals":    ledger["token_decimals"],
+		"totalSupply": fromDecimals(ledger["total_supply"].(string)),
+		"owner":       ledger["owner"],
+		"contract":    ledger["contract_id"],
+		"paused":      ledger["paused"],
+		"status":      "live",
+	})
+}
+
+// paprdBalance returns the PAPRD balance of an address
+func (s *Server) paprdBalance(c *gin.Context) {
+	address := c.Param("address")
+
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	balance := paprdBalanceOf(ledger, address)
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":    address,
+		"balance":    fromDecimals(balance),
+		"balanceWei": balance,
+		"symbol":     "PAPRD",
+	})
+}
+
+// paprdTransfer transfers PAPRD tokens
+func (s *Server) paprdTransfer(c *gin.Context) {
+	var request struct {
+		From       string `json:"from" binding:"required"`
+		To         string `json:"to" binding:"required"`
+		Amount     string `json:"amount" binding:"required"`
+		PrivateKey string `json:"privateKey"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	amount, err := paprdAmount(request.Amount)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	s.paprdMu.Lock()
+	defer s.paprdMu.Unlock()
+
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	// Check if contract is paused
+	if ledger["paused"].(bool) {
+		abort(c, invalidRequest("Contract is paused"))
+		return
+	}
+
+	// Check blacklist
+	for _, addr := range ledger["blacklisted"].([]interface{}) {
+		if addr.(string) == request.From || addr.(string) == request.To {
+			abort(c, newError(http.StatusForbidden, CodeForbidden, "Address is blacklisted"))
+			return
+		}
+	}
+
+	amountWei := toDecimals(amount)
+	amountFloat, _ := strconv.ParseFloat(amountWei, 64)
+	fromBalance, _ := strconv.ParseFloat(paprdBalanceOf(ledger, request.From), 64)
+
+	// Check balance
+	if fromBalance < amountFloat {
+		abort(c, insufficientBalance("Insufficient balance", fromDecimals(paprdBalanceOf(ledger, request.From)), request.Amount))
+		return
+	}
+
+	// Perform transfer
+	toBalance, _ := strconv.ParseFloat(paprdBalanceOf(ledger, request.To), 64)
+	balances := ledger["balances"].(map[string]interface{})
+	balances[request.From] = fmt.Sprintf("%.0f", fromBalance-amountFloat)
+	balances[request.To] = fmt.Sprintf("%.0f", toBalance+amountFloat)
+
+	// Record transaction
+	tx := recordPAPRDTransaction(ledger, "transfer", request.From, request.To, amountWei)
+	if err := s.writePAPRDLedger(ledger); err != nil {
+		abort(c, fmt.Errorf("Failed to save transaction: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"transaction": tx,
+		"newBalance":  fromDecimals(balances[request.From].(string)),
+	})
+
+	// Log the transfer
+	s.audit.LogEvent(audit.InfoLevel, "PAPRDTransfer",
+		fmt.Sprintf("PAPRD transfer: %s PAPRD from %s to %s", request.Amount, request.From, request.To), tx)
+}
+
+// paprdMint mints PAPRD tokens on behalf of the owner
+func (s *Server) paprdMint(c *gin.Context) {
+	var request struct {
+		To         string `json:"to" binding:"required"`
+		Amount     string `json:"amount" binding:"required"`
+		Caller     string `json:"caller" binding:"required"`
+		PrivateKey string `json:"privateKey"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	amount, err := paprdAmount(request.Amount)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	s.paprdMu.Lock()
+	defer s.paprdMu.Unlock()
+
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	// Check if caller is owner
+	if request.Caller != ledger["owner"].(string) {
+		abort(c, newError(http.StatusForbidden, CodeForbidden, "Only owner can mint tokens"))
+		return
+	}
+
+	amountWei := toDecimals(amount)
+	amountFloat, _ := strconv.ParseFloat(amountWei, 64)
+	toBalance, _ := strconv.ParseFloat(paprdBalanceOf(ledger, request.To), 64)
+
+	balances := ledger["balances"].(map[string]interface{})
+	balances[request.To] = fmt.Sprintf("%.0f", toBalance+amountFloat)
+
+	// Update total supply
+	totalSupply, _ := strconv.ParseFloat(ledger["total_supply"].(string), 64)
+	ledger["total_supply"] = fmt.Sprintf("%.0f", totalSupply+amountFloat)
+
+	// Record transaction
+	tx := recordPAPRDTransaction(ledger, "mint", "0x0000000000000000000000000000000000000000", request.To, amountWei)
+	if err := s.writePAPRDLedger(ledger); err != nil {
+		abort(c, fmt.Errorf("Failed to save mint transaction: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"transaction": tx,
+		"newBalance":  fromDecimals(balances[request.To].(string)),
+		"totalSupply": fromDecimals(ledger["total_supply"].(string)),
+	})
+
+	// Log the mint
+	s.audit.LogEvent(audit.InfoLevel, "PAPRDMint",
+		fmt.Sprintf("PAPRD mint: %s PAPRD to %s by %s", request.Amount, request.To, request.Caller), tx)
+}
+
+// paprdTransactions returns the latest PAPRD transactions of an address
+func (s *Server) paprdTransactions(c *gin.Context) {
+	address := c.Param("address")
+
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	userTxs := []interface{}{}
+	for _, txInterface := range ledger["transactions"].([]interface{}) {
+		tx := txInterface.(map[string]interface{})
+		if tx["from"].(string) == address || tx["to"].(string) == address {
+			userTxs = append(userTxs, tx)
+		}
+	}
+
+	// Transactions are kept oldest first: return the latest 50
+	if len(userTxs) > 50 {
+		userTxs = userTxs[len(userTxs)-50:]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":      address,
+		"transactions": userTxs,
+		"count":        len(userTxs),
+	})
+}
+
+// paprdWallet returns the PAPRD and BNM balances and permissions of an address
+func (s *Server) paprdWallet(c *gin.Context) {
+	address := c.Param("address")
+
+	ledger, err := s.readPAPRDLedger()
+	if err != nil {
+		abort(c, errPAPRDLedger)
+		return
+	}
+
+	// Check if address is owner/minter
+	isOwner := address == ledger["owner"].(string)
+	isMinter := false
+	for _, minter := range ledger["minters"].([]interface{}) {
+		if minter.(string) == address {
+			isMinter = true
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":      address,
+		"paprdBalance": fromDecimals(paprdBalanceOf(ledger, address)),
+		"bnmBalance":   s.token.GetBalance(address),
+		"isOwner":      isOwner,
+		"isMinter":     isMinter,
+		"permissions": map[string]bool{
+			"transfer": true,
+			"mint":     isOwner || isMinter,
+			"burn":     isOwner,
+		},
+	})
+}
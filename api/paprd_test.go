@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/igo-used/binomena/wallet"
+)
+
+func TestServer_ServesPAPRD(t *testing.T) {
+	node := newTestNode(t)
+	owner := node.paprdOwner.Address
+	holder, _ := wallet.NewWallet()
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/paprd/info", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["symbol"] != "PAPRD" || response["totalSupply"] != "1000" {
+		t.Errorf("Unexpected PAPRD info: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/transfer", map[string]string{"from": owner, "to": holder.Address, "amount": "40"})
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["newBalance"] != "960" {
+		t.Errorf("Expected the owner to keep 960 PAPRD, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/transfer", map[string]string{"from": holder.Address, "to": owner, "amount": "41"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInsufficientBalance)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/transfer", map[string]string{"from": owner, "to": holder.Address, "amount": "-1"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/transfer", map[string]string{"from": owner, "to": "AdNe0000000000000000000000000000000000000000", "amount": "1"})
+	expect(t, recorder, response, http.StatusForbidden, CodeForbidden)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/mint", map[string]string{"to": holder.Address, "amount": "10", "caller": holder.Address})
+	expect(t, recorder, response, http.StatusForbidden, CodeForbidden)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/paprd/mint", map[string]string{"to": holder.Address, "amount": "10", "caller": owner})
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["newBalance"] != "50" || response["totalSupply"] != "1010" {
+		t.Errorf("Unexpected mint result: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/paprd/balance/"+holder.Address, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["balance"] != "50" || response["balanceWei"] != "50000000000000000000" {
+		t.Errorf("Unexpected PAPRD balance: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/paprd/transactions/"+holder.Address, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["count"] != float64(2) {
+		t.Errorf("Expected the transfer and the mint, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/paprd/wallet/"+owner, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	permissions := response["permissions"].(map[string]interface{})
+	if response["isOwner"] != true || permissions["mint"] != true || response["paprdBalance"] != "960" {
+		t.Errorf("Unexpected PAPRD wallet: %v", response)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter limits the number of requests each client makes in a sliding window
+type RateLimiter struct {
+	requests map[string][]time.Time
+	mutex    sync.RWMutex
+	limit    int
+	window   time.Duration
+	rejected atomic.Uint64
+}
+
+// NewRateLimiter creates a rate limiter allowing limit requests per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		requests: make(map[string][]time.Time),
+		limit:    limit,
+		window:   window,
+	}
+}
+
+// Allow records a request from a client, returning false if it exceeds the limit
+func (rl *RateLimiter) Allow(clientIP string) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+
+	// Clean old requests
+	if requests, exists := rl.requests[clientIP]; exists {
+		var validRequests []time.Time
+		for _, reqTime := range requests {
+			if now.Sub(reqTime) < rl.window {
+				validRequests = append(validRequests, reqTime)
+			}
+		}
+		rl.requests[clientIP] = validRequests
+	}
+
+	// Check if limit exceeded
+	if len(rl.requests[clientIP]) >= rl.limit {
+		rl.rejected.Add(1)
+		return false
+	}
+
+	// Add current request
+	rl.requests[clientIP] = append(rl.requests[clientIP], now)
+	return true
+}
+
+// Rejected returns the number of requests refused for exceeding the limit
+func (rl *RateLimiter) Rejected() uint64 {
+	return rl.rejected.Load()
+}
+
+// errRateLimited is replied to clients over their limit
+var errRateLimited = newError(http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded. Please try again later.")
+
+// rateLimit returns middleware refusing requests over a limiter's limit
+func rateLimit(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			abort(c, errRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,218 @@
+// Package api serves the node's REST API: accounts and transactions, blocks and
+// sync, delegates, audits, contracts and the PAPRD stablecoin.
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/smartcontract"
+)
+
+// Version is the path prefix of the current API version
+const Version = "/v1"
+
+// DefaultPAPRDLedger is where the PAPRD stablecoin ledger is kept
+const DefaultPAPRDLedger = "contracts/stablecoin/paprd-ledger.json"
+
+// Config holds the components served by the API
+type Config struct {
+	NodeID         string
+	Blockchain     core.BlockchainInterface
+	Token          core.TokenInterface
+	Consensus      *consensus.DPoSConsensus
+	Node           *core.Node
+	P2PNode        *p2p.P2PNode
+	Contracts      *smartcontract.ContractAPI // Optional: contract routes are only served when set
+	Audit          audit.Auditor
+	StateProviders []core.StateProvider // Make up the state root checked after a sync
+	AdminKey       string               // Authorizes admin requests
+	PAPRDLedger    string               // Defaults to DefaultPAPRDLedger
+}
+
+// Server serves the node API
+type Server struct {
+	nodeID         string
+	blockchain     core.BlockchainInterface
+	token          core.TokenInterface
+	consensus      *consensus.DPoSConsensus
+	node           *core.Node
+	p2pNode        *p2p.P2PNode
+	contracts      *smartcontract.ContractAPI
+	audit          audit.Auditor
+	stateProviders []core.StateProvider
+	adminKey       string
+	paprdLedger    string
+	paprdMu        sync.Mutex
+
+	generalLimiter     *RateLimiter
+	transactionLimiter *RateLimiter
+	adminLimiter       *RateLimiter
+	faucetLimiter      *RateLimiter
+}
+
+// NewServer creates an API server for a node's components
+func NewServer(config Config) *Server {
+	paprdLedger := config.PAPRDLedger
+	if paprdLedger == "" {
+		paprdLedger = DefaultPAPRDLedger
+	}
+
+	return &Server{
+		nodeID:         config.NodeID,
+		blockchain:     config.Blockchain,
+		token:          config.Token,
+		consensus:      config.Consensus,
+		node:           config.Node,
+		p2pNode:        config.P2PNode,
+		contracts:      config.Contracts,
+		audit:          config.Audit,
+		stateProviders: config.StateProviders,
+		adminKey:       config.AdminKey,
+		paprdLedger:    paprdLedger,
+
+		generalLimiter:     NewRateLimiter(100, time.Minute), // 100 requests per minute for general endpoints
+		transactionLimiter: NewRateLimiter(10, time.Minute),  // 10 transactions per minute
+		adminLimiter:       NewRateLimiter(5, time.Hour),     // 5 admin requests per hour
+		faucetLimiter:      NewRateLimiter(3, time.Hour),     // 3 faucet requests per hour
+	}
+}
+
+// Register mounts the health checks and the API under Version on a router. The
+// unversioned paths of the API remain as deprecated aliases.
+func (s *Server) Register(router *gin.Engine) {
+	// Health check endpoints for Render, which may add a trailing space
+	router.GET("/health", s.health)
+	router.GET("/health ", s.health)
+
+	s.routes(router.Group(Version))
+	s.routes(router.Group("", deprecated))
+
+	router.NoRoute(func(c *gin.Context) {
+		abort(c, notFound("No endpoint %s %s", c.Request.Method, c.Request.URL.Path))
+	})
+}
+
+// RateLimit returns middleware applying the general rate limit, for endpoints
+// served next to the API
+func (s *Server) RateLimit() gin.HandlerFunc {
+	return rateLimit(s.generalLimiter)
+}
+
+// RateLimiters returns the API's rate limiters by name
+func (s *Server) RateLimiters() map[string]*RateLimiter {
+	return map[string]*RateLimiter{
+		"general":     s.generalLimiter,
+		"transaction": s.transactionLimiter,
+		"admin":       s.adminLimiter,
+		"faucet":      s.faucetLimiter,
+	}
+}
+
+// routes registers the API endpoints on a router group
+func (s *Server) routes(router *gin.RouterGroup) {
+	router.GET("/status", s.status)
+
+	// Wallets and balances
+	router.POST("/wallet", rateLimit(s.generalLimiter), s.createWallet)
+	router.POST("/wallet/import", rateLimit(s.generalLimiter), s.importWallet)
+	router.GET("/wallet/:address/peer", s.walletPeer)
+	router.GET("/balance/:address", s.balance)
+	router.POST("/faucet", rateLimit(s.faucetLimiter), s.faucet)
+	router.POST("/admin/distribute-initial-tokens", rateLimit(s.adminLimiter), s.distributeInitialTokens)
+
+	// Transactions
+	router.POST("/transaction", rateLimit(s.transactionLimiter), s.sendTransaction)
+	router.POST("/transaction/signed", rateLimit(s.transactionLimiter), s.sendSignedTransaction)
+	router.GET("/transactions/:id", s.transaction)
+	router.GET("/transactions/:id/receipt", s.receipt)
+	router.GET("/addresses/:address/transactions", s.addressTransactions)
+
+	// Peers
+	router.GET("/peers", s.peers)
+	router.POST("/peers", s.connectPeer)
+
+	// Blocks and synchronization
+	router.GET("/blocks", s.blocks)
+	router.GET("/blocks/finalized", s.finalizedBlock)
+	router.GET("/blocks/hash/:hash", s.blockByHash)
+	router.GET("/blocks/:index", s.block)
+	router.GET("/blocks/:index/proof/:txId", s.merkleProof)
+	router.POST("/sync", s.sync)
+
+	// Audits
+	router.GET("/audit", s.auditEvents)
+	router.GET("/audit/security", s.securityAudit)
+
+	// PAPRD stablecoin
+	router.GET("/paprd/info", s.paprdInfo)
+	router.GET("/paprd/balance/:address", s.paprdBalance)
+	router.POST("/paprd/transfer", s.paprdTransfer)
+	router.POST("/paprd/mint", s.paprdMint)
+	router.GET("/paprd/transactions/:address", s.paprdTransactions)
+	router.GET("/paprd/wallet/:address", s.paprdWallet)
+
+	// DPoS delegates
+	router.POST("/delegates/register", s.registerDelegate)
+	router.POST("/delegates/vote", s.voteForDelegate)
+	router.GET("/delegates", s.delegates)
+	router.GET("/delegates/:address", s.delegate)
+
+	// Smart contracts
+	if s.contracts != nil {
+		router.POST("/contracts/deploy", s.deployContract)
+		router.POST("/contracts/:id/execute", s.executeContract)
+		router.GET("/contracts/:id", s.contract)
+		router.GET("/contracts", s.listContracts)
+		router.GET("/contracts/:id/state/:key", s.contractState)
+		router.POST("/contracts/:id/state", s.setContractState)
+	}
+}
+
+// deprecated marks responses of unversioned paths, pointing clients to the
+// current version
+func deprecated(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+Version+c.Request.URL.Path+">; rel=\"successor-version\"")
+	c.Next()
+}
+
+// SecurityHeaders returns middleware adding security headers to responses
+func SecurityHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("X-Frame-Options", "DENY")
+		c.Header("X-XSS-Protection", "1; mode=block")
+		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+		c.Next()
+	}
+}
+
+// health reports that the node is up
+func (s *Server) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "healthy",
+		"timestamp": time.Now().Unix(),
+		"node":      s.nodeID,
+	})
+}
+
+// status reports the state of the node
+func (s *Server) status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"nodeId":                s.nodeID,
+		"status":                "running",
+		"blocks":                s.blockchain.GetBlockCount(),
+		"peers":                 s.p2pNode.GetPeerCount(),
+		"wallets":               s.p2pNode.GetWalletCount(),
+		"syncing":               s.p2pNode.IsSyncing(),
+		"tokenSupply":           s.token.GetCirculatingSupply(),
+		"lastIrreversibleBlock": s.consensus.GetLastIrreversibleBlock(),
+	})
+}
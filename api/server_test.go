@@ -0,0 +1,393 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+const testAdminKey = "test-admin-key"
+
+// testFounder is the only delegate of test nodes, producing their blocks
+var testFounder, _ = wallet.NewWallet()
+
+// testNode is a node served by the API, with a wallet holding funds
+type testNode struct {
+	router     *gin.Engine
+	blockchain *core.Blockchain
+	token      *token.BinomToken
+	wallet     *wallet.Wallet
+	paprdOwner *wallet.Wallet
+}
+
+// newTestNode creates a file-backed node and serves its API
+func newTestNode(t *testing.T) *testNode {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	dpos := consensus.NewDPoSConsensus(testFounder.Address, "AdNebaefd75d426056bffbc622bd9f334ed89450efae")
+
+	p2pNode, err := p2p.NewP2PNode(blockchain, "/ip4/127.0.0.1/tcp/0")
+	if err != nil {
+		t.Fatalf("Failed to start P2P node: %v", err)
+	}
+	t.Cleanup(func() { p2pNode.Stop() })
+
+	vm, err := smartcontract.NewWasmVM(binomToken, blockchain)
+	if err != nil {
+		t.Fatalf("Failed to create WASM VM: %v", err)
+	}
+	contractDir := t.TempDir()
+	contractStorage, _ := smartcontract.NewContractStorage(contractDir)
+	contractState, _ := smartcontract.NewContractState(contractDir)
+
+	funded, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", funded.Address, bnm.FromBNM(100000)); err != nil {
+		t.Fatalf("Failed to fund wallet: %v", err)
+	}
+
+	paprdOwner, _ := wallet.NewWallet()
+	ledger := map[string]interface{}{
+		"contract_id":    "AdNePAPRD",
+		"token_name":     "Paper Dollar Stablecoin",
+		"token_symbol":   "PAPRD",
+		"token_decimals": 18,
+		"total_supply":   "1000000000000000000000",
+		"owner":          paprdOwner.Address,
+		"balances":       map[string]interface{}{paprdOwner.Address: "1000000000000000000000"},
+		"minters":        []interface{}{},
+		"blacklisted":    []interface{}{"AdNe0000000000000000000000000000000000000000"},
+		"paused":         false,
+		"transactions":   []interface{}{},
+	}
+	ledgerPath := filepath.Join(t.TempDir(), "paprd-ledger.json")
+	data, _ := json.Marshal(ledger)
+	if err := os.WriteFile(ledgerPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write PAPRD ledger: %v", err)
+	}
+
+	server := NewServer(Config{
+		NodeID:      "test-node",
+		Blockchain:  blockchain,
+		Token:       binomToken,
+		Consensus:   dpos,
+		Node:        core.NewNode(blockchain, dpos, binomToken, "genesis"),
+		P2PNode:     p2pNode,
+		Contracts:   smartcontract.NewContractAPI(vm, contractStorage, contractState, binomToken),
+		Audit:       audit.NewAuditService(blockchain),
+		AdminKey:    testAdminKey,
+		PAPRDLedger: ledgerPath,
+	})
+	router := gin.New()
+	server.Register(router)
+
+	return &testNode{
+		router:     router,
+		blockchain: blockchain,
+		token:      binomToken,
+		wallet:     funded,
+		paprdOwner: paprdOwner,
+	}
+}
+
+// request sends a request to the API and decodes the JSON response
+func (n *testNode) request(t *testing.T, method, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	switch body := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(body))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	n.router.ServeHTTP(recorder, req)
+
+	var response map[string]interface{}
+	if recorder.Body.Len() > 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s returned invalid JSON %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder, response
+}
+
+// expect checks the status of a response, and its error code for failures
+func expect(t *testing.T, recorder *httptest.ResponseRecorder, response map[string]interface{}, status int, code string) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, recorder.Code, recorder.Body.String())
+	}
+	if code == "" {
+		return
+	}
+	if response["code"] != code {
+		t.Fatalf("Expected error code %q, got %s", code, recorder.Body.String())
+	}
+	if message, _ := response["error"].(string); message == "" {
+		t.Fatalf("Expected an error message, got %s", recorder.Body.String())
+	}
+}
+
+func TestServer_HealthAndStatus(t *testing.T) {
+	node := newTestNode(t)
+
+	for _, path := range []string{"/health", "/health%20"} {
+		recorder, response := node.request(t, http.MethodGet, path, nil)
+		expect(t, recorder, response, http.StatusOK, "")
+		if response["status"] != "healthy" || response["node"] != "test-node" {
+			t.Errorf("Unexpected health response for %s: %v", path, response)
+		}
+	}
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/status", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["nodeId"] != "test-node" || response["blocks"] != float64(1) {
+		t.Errorf("Unexpected status: %v", response)
+	}
+	if recorder.Header().Get("Deprecation") != "" {
+		t.Error("Versioned paths should not be deprecated")
+	}
+
+	// Unversioned paths still work, pointing to their successor
+	recorder, response = node.request(t, http.MethodGet, "/status", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if recorder.Header().Get("Deprecation") != "true" || !strings.Contains(recorder.Header().Get("Link"), "</v1/status>") {
+		t.Errorf("Expected the unversioned path to be deprecated, got headers %v", recorder.Header())
+	}
+}
+
+func TestServer_ReportsErrorsInEnvelope(t *testing.T) {
+	node := newTestNode(t)
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/missing", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/faucet", `{"address":`)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	// Missing fields are reported by their JSON names
+	recorder, response = node.request(t, http.MethodPost, "/v1/faucet", map[string]interface{}{"amount": "1"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+	fields := response["details"].(map[string]interface{})["fields"].(map[string]interface{})
+	if fields["address"] != "required" {
+		t.Errorf("Expected the missing address to be reported, got %v", response)
+	}
+}
+
+// extend appends empty blocks to the node's chain
+func (n *testNode) extend(t *testing.T, count int) {
+	t.Helper()
+	n.extendBy(t, count, testFounder)
+}
+
+// extendBy appends empty blocks signed by a producer to the node's chain
+func (n *testNode) extendBy(t *testing.T, count int, producer *wallet.Wallet) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		parent := n.blockchain.GetLastBlock()
+		block := core.Block{
+			Index:        parent.Index + 1,
+			PreviousHash: parent.Hash,
+			Timestamp:    parent.Timestamp + 1,
+			Data:         []core.Transaction{},
+			Version:      core.CurrentBlockVersion,
+			MerkleRoot:   core.CalculateMerkleRoot(nil),
+		}
+		if err := core.SignBlock(&block, producer); err != nil {
+			t.Fatalf("Failed to sign block: %v", err)
+		}
+		if err := n.blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+}
+
+func TestServer_ServesBlocks(t *testing.T) {
+	node := newTestNode(t)
+	genesis := node.blockchain.GetLastBlock()
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/blocks", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["count"] != float64(1) || response["nextCursor"] != nil {
+		t.Errorf("Expected the genesis block only, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/hash/"+genesis.Hash, nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["index"] != float64(0) {
+		t.Errorf("Expected the genesis block by hash, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/hash/missing", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/0", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["hash"] != genesis.Hash {
+		t.Errorf("Expected the genesis block, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/finalized", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/first", nil)
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/9", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks/0/proof/AdNeMissing", nil)
+	expect(t, recorder, response, http.StatusNotFound, CodeNotFound)
+}
+
+func TestServer_PaginatesBlocks(t *testing.T) {
+	node := newTestNode(t)
+	node.extend(t, 5)
+
+	// Cursors walk the chain a page at a time
+	recorder, response := node.request(t, http.MethodGet, "/v1/blocks?from=1&limit=2", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	blocks := response["blocks"].([]interface{})
+	if len(blocks) != 2 || response["count"] != float64(6) || response["nextCursor"] != "3" {
+		t.Fatalf("Unexpected first page: %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks?cursor=3&limit=2", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["nextCursor"] != "5" {
+		t.Errorf("Expected a cursor to the last block, got %v", response)
+	}
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks?cursor=5&limit=2", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if len(response["blocks"].([]interface{})) != 1 || response["nextCursor"] != nil {
+		t.Errorf("Expected the last page, got %v", response)
+	}
+
+	// Ranges are inclusive and bound the page
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks?from=2&to=3", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	blocks = response["blocks"].([]interface{})
+	if len(blocks) != 2 || blocks[1].(map[string]interface{})["index"] != float64(3) || response["nextCursor"] != nil {
+		t.Errorf("Expected blocks 2 and 3, got %v", response)
+	}
+
+	// Headers carry the transaction count instead of the transactions
+	recorder, response = node.request(t, http.MethodGet, "/v1/blocks?from=4&limit=1&headers=true", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	header := response["blocks"].([]interface{})[0].(map[string]interface{})
+	if _, ok := header["data"]; ok || header["transactionCount"] != float64(0) {
+		t.Errorf("Expected a block header, got %v", header)
+	}
+
+	for _, query := range []string{"limit=0", "from=-1", "from=3&to=2", "from=1&cursor=2", "cursor=next", "headers=maybe"} {
+		recorder, response = node.request(t, http.MethodGet, "/v1/blocks?"+query, nil)
+		expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+	}
+}
+
+func TestServer_SyncsWithPeers(t *testing.T) {
+	node := newTestNode(t)
+
+	// Syncing with a node serving the same chain adds nothing
+	peer := httptest.NewServer(node.router)
+	defer peer.Close()
+	recorder, response := node.request(t, http.MethodPost, "/v1/sync", map[string]string{"peerAddress": strings.TrimPrefix(peer.URL, "http://")})
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["status"] != "sync completed" || response["blocksAdded"] != float64(0) || response["stateVerified"] != true {
+		t.Errorf("Unexpected sync result: %v", response)
+	}
+
+	// Chains longer than a page are downloaded page by page
+	ahead := newTestNode(t)
+	ahead.extend(t, core.MaxBlockPageSize)
+	longer := httptest.NewServer(ahead.router)
+	defer longer.Close()
+	recorder, response = node.request(t, http.MethodPost, "/v1/sync", map[string]string{"peerAddress": strings.TrimPrefix(longer.URL, "http://")})
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["newBlockCount"] != float64(core.MaxBlockPageSize+1) {
+		t.Errorf("Expected to sync the whole chain, got %v", response)
+	}
+
+	// Blocks produced outside the delegate schedule are refused
+	outsider, _ := wallet.NewWallet()
+	forked := newTestNode(t)
+	forked.extend(t, core.MaxBlockPageSize)
+	forked.extendBy(t, 1, outsider)
+	unscheduled := httptest.NewServer(forked.router)
+	defer unscheduled.Close()
+	recorder, response = node.request(t, http.MethodPost, "/v1/sync", map[string]string{"peerAddress": strings.TrimPrefix(unscheduled.URL, "http://")})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+	if node.blockchain.GetBlockCount() != core.MaxBlockPageSize+1 {
+		t.Errorf("Expected the unscheduled block to be refused, got %d blocks", node.blockchain.GetBlockCount())
+	}
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/sync", map[string]string{"peerAddress": "127.0.0.1:1"})
+	expect(t, recorder, response, http.StatusBadGateway, CodePeerUnavailable)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/sync", map[string]string{})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestServer_ManagesPeers(t *testing.T) {
+	node := newTestNode(t)
+
+	recorder, response := node.request(t, http.MethodGet, "/v1/peers", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["count"] != float64(0) {
+		t.Errorf("Expected no peers, got %v", response)
+	}
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/peers", map[string]string{"address": "not-a-multiaddress"})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+
+	recorder, response = node.request(t, http.MethodPost, "/v1/peers", map[string]string{})
+	expect(t, recorder, response, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestServer_ServesAuditEvents(t *testing.T) {
+	node := newTestNode(t)
+
+	recorder, response := node.request(t, http.MethodPost, "/v1/wallet", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/audit", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	events := response["events"].([]interface{})
+	if len(events) == 0 || events[len(events)-1].(map[string]interface{})["type"] != "WalletCreated" {
+		t.Errorf("Expected the wallet creation to be audited, got %v", events)
+	}
+
+	recorder, response = node.request(t, http.MethodGet, "/v1/audit/security", nil)
+	expect(t, recorder, response, http.StatusOK, "")
+	if response["status"] != "completed" || response["issues"] != float64(0) {
+		t.Errorf("Expected a clean audit, got %v", response)
+	}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// maxTransactionAmount is the most a single transaction may transfer
+const maxTransactionAmount = 1000000000 * bnm.UnitsPerBNM
+
+// SignedTransactionRequest is a transaction signed by the client, which only sends
+// its public key
+type SignedTransactionRequest struct {
+	ID        string     `json:"id" binding:"required"`
+	From      string     `json:"from" binding:"required"`
+	To        string     `json:"to" binding:"required"`
+	Amount    bnm.Amount `json:"amount" binding:"required"`
+	Nonce     uint64     `json:"nonce"`
+	Timestamp int64      `json:"timestamp" binding:"required"`
+	Signature string     `json:"signature" binding:"required"`
+	PublicKey string     `json:"publicKey" binding:"required"`
+	Version   uint32     `json:"version"` // Must be set; legacy (0) transactions are rejected
+}
+
+// validateTransfer checks the addresses and amount of a transfer
+func validateTransfer(from, to string, amount bnm.Amount) error {
+	// Validate addresses format
+	if !validAddress(from) {
+		return invalidRequest("Invalid from address format")
+	}
+	if !validAddress(to) {
+		return invalidRequest("Invalid to address format")
+	}
+
+	// Validate amount
+	if amount <= 0 {
+		return invalidRequest("Amount must be positive")
+	}
+	if amount > maxTransactionAmount {
+		return invalidRequest("Amount exceeds maximum transaction limit")
+	}
+
+	// Prevent self-transfer
+	if from == to {
+		return invalidRequest("Cannot transfer to the same address")
+	}
+	return nil
+}
+
+// NextNonce returns the nonce an address's next transaction must use, following
+// its confirmed and pending transactions
+func (s *Server) NextNonce(address string) uint64 {
+	return s.blockchain.Mempool().NextNonce(address, s.token.GetNonce(address))
+}
+
+// submit admits a transaction the sender can pay for to the mempool and broadcasts
+// it. Its nonce is only consumed, and its amount and fee only move, once a block
+// includes it.
+func (s *Server) submit(tx *core.Transaction) error {
+	var nonceErr *core.NonceError
+	var balanceErr *core.BalanceError
+	err := core.CheckAdmission(s.blockchain.Mempool(), s.token, *tx)
+	switch {
+	case errors.As(err, &nonceErr):
+		apiErr := newError(http.StatusConflict, CodeConflict, "%v", err)
+		apiErr.Details = map[string]interface{}{"expectedNonce": nonceErr.Expected}
+		return apiErr
+	case errors.As(err, &balanceErr):
+		apiErr := insufficientBalance("insufficient balance", balanceErr.Balance, balanceErr.Required())
+		apiErr.Details["amount"] = balanceErr.Amount
+		apiErr.Details["fee"] = balanceErr.Fee
+		apiErr.Details["pending"] = balanceErr.Pending
+		return apiErr
+	case err != nil:
+		return invalidRequest("%v", err)
+	}
+
+	// Submit transaction
+	if err := s.node.SubmitTransaction(*tx); err != nil {
+		if errors.Is(err, core.ErrDuplicateTransaction) {
+			return newError(http.StatusConflict, CodeConflict, "%v", err)
+		}
+		if errors.Is(err, core.ErrMempoolFull) {
+			return newError(http.StatusServiceUnavailable, CodeMempoolFull, "%v", err)
+		}
+		return invalidRequest("%v", err)
+	}
+
+	// Broadcast transaction to the network
+	if err := s.p2pNode.BroadcastTransaction(*tx); err != nil {
+		log.Printf("Failed to broadcast transaction: %v", err)
+	}
+	return nil
+}
+
+// sendTransaction signs a transaction with the sender's private key and submits it
+func (s *Server) sendTransaction(c *gin.Context) {
+	var request struct {
+		From       string     `json:"from" binding:"required"`
+		To         string     `json:"to" binding:"required"`
+		Amount     bnm.Amount `json:"amount" binding:"required"`
+		PrivateKey string     `json:"privateKey" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := validateTransfer(request.From, request.To, request.Amount); err != nil {
+		abort(c, err)
+		return
+	}
+
+	// Import wallet from private key
+	senderWallet, err := wallet.ImportPrivateKey(request.PrivateKey)
+	if err != nil {
+		abort(c, invalidRequest("Invalid private key"))
+		return
+	}
+
+	// Verify wallet address matches
+	if senderWallet.Address != request.From {
+		abort(c, invalidRequest("Private key does not match sender address"))
+		return
+	}
+
+	// Create transaction with the sender's next nonce
+	tx, err := core.NewTransaction(request.From, request.To, request.Amount, s.NextNonce(request.From), senderWallet)
+	if err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	// Calculate fee (0.1% of transaction amount)
+	transactionFee := tx.CalculateFee()
+	if err := s.submit(tx); err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "transaction submitted",
+		"txId":   tx.ID,
+		"amount": tx.Amount,
+		"fee":    transactionFee,
+		"node":   s.nodeID,
+	})
+
+	// Log transaction
+	s.audit.LogEvent(audit.InfoLevel, "TransactionSubmitted",
+		fmt.Sprintf("Transaction %s: %s sent %s BNM to %s (fee: %s BNM)", tx.ID, tx.From, tx.Amount, tx.To, transactionFee), tx)
+}
+
+// sendSignedTransaction submits a transaction the client signed locally
+func (s *Server) sendSignedTransaction(c *gin.Context) {
+	var request SignedTransactionRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	tx, transactionFee, err := s.submitSigned(request, c.ClientIP())
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "transaction submitted",
+		"txId":   tx.ID,
+		"amount": tx.Amount,
+		"fee":    transactionFee,
+		"node":   s.nodeID,
+	})
+}
+
+// SubmitSigned submits a client-signed transaction on behalf of a client outside
+// the REST API, under the same rate limit. It returns the transaction and the fee
+// it will be charged, or an *Error.
+func (s *Server) SubmitSigned(request SignedTransactionRequest, clientIP string) (*core.Transaction, bnm.Amount, error) {
+	if !s.transactionLimiter.Allow(clientIP) {
+		return nil, 0, errRateLimited
+	}
+	return s.submitSigned(request, clientIP)
+}
+
+// submitSigned verifies a client-signed transaction and submits it, returning the
+// transaction and the fee it will be charged
+func (s *Server) submitSigned(request SignedTransactionRequest, clientIP string) (*core.Transaction, bnm.Amount, error) {
+	if err := validateTransfer(request.From, request.To, request.Amount); err != nil {
+		return nil, 0, err
+	}
+
+	publicKey, err := wallet.DecodePublicKey(request.PublicKey)
+	if err != nil {
+		return nil, 0, invalidRequest("%v", err)
+	}
+
+	tx := &core.Transaction{
+		ID:        request.ID,
+		From:      request.From,
+		To:        request.To,
+		Amount:    request.Amount,
+		Nonce:     request.Nonce,
+		Timestamp: request.Timestamp,
+		Signature: request.Signature,
+		PublicKey: request.PublicKey,
+		Version:   request.Version,
+	}
+
+	// Verify ID, sender address and signature before touching any balances
+	if err := core.VerifySignedTransaction(tx, publicKey); err != nil {
+		s.audit.LogEvent(audit.WarningLevel, "InvalidSignedTransaction",
+			fmt.Sprintf("Rejected signed transaction %s: %v", tx.ID, err), map[string]interface{}{
+				"ip":   clientIP,
+				"from": tx.From,
+			})
+		return nil, 0, invalidRequest("%v", err)
+	}
+
+	// Calculate fee (0.1% of transaction amount)
+	transactionFee := tx.CalculateFee()
+	if err := s.submit(tx); err != nil {
+		return nil, 0, err
+	}
+
+	// Log transaction
+	s.audit.LogEvent(audit.InfoLevel, "SignedTransactionSubmitted",
+		fmt.Sprintf("Transaction %s: %s sent %s BNM to %s (fee: %s BNM)", tx.ID, tx.From, tx.Amount, tx.To, transactionFee), tx)
+
+	return tx, transactionFee, nil
+}
+
+// transaction returns an included transaction by ID
+func (s *Server) transaction(c *gin.Context) {
+	tx, err := s.blockchain.GetTransaction(c.Param("id"))
+	if errors.Is(err, core.ErrTransactionNotFound) {
+		abort(c, notFound("%v", err))
+		return
+	}
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+// receipt returns the receipt of a submitted transaction: pending, included,
+// failed or dropped
+func (s *Server) receipt(c *gin.Context) {
+	receipt, err := s.blockchain.GetReceipt(c.Param("id"))
+	if errors.Is(err, core.ErrTransactionNotFound) {
+		abort(c, notFound("%v", err))
+		return
+	}
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
+
+// addressTransactions returns a page of an address's included transactions,
+// newest first
+func (s *Server) addressTransactions(c *gin.Context) {
+	address := c.Param("address")
+
+	direction, err := core.ParseTxDirection(c.Query("direction"))
+	if err != nil {
+		abort(c, invalidRequest("%v", err))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(core.DefaultTxPageSize)))
+	if err != nil || limit < 1 || limit > core.MaxTxPageSize {
+		abort(c, invalidRequest("limit must be between 1 and %d", core.MaxTxPageSize))
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		abort(c, invalidRequest("offset must be a non-negative integer"))
+		return
+	}
+
+	transactions, total, err := s.blockchain.GetAddressTransactions(address, core.TxQuery{
+		Direction: direction,
+		Offset:    offset,
+		Limit:     limit,
+	})
+	if err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"address":      address,
+		"transactions": transactions,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
@@ -35,15 +35,32 @@ type AuditEvent struct {
 	Data      interface{}   `json:"data,omitempty"`
 }
 
+// EventObserver is notified of every logged audit event. It is called while the
+// audit service is locked, so it must neither block nor call back into it.
+type EventObserver interface {
+	AuditEventLogged(event AuditEvent)
+}
+
+// Auditor records security audit events and audits the blockchain, backed by
+// memory or the database
+type Auditor interface {
+	SetObserver(observer EventObserver)
+	LogEvent(level SecurityLevel, eventType, message string, data interface{})
+	GetEvents() []AuditEvent
+	GetEventsByLevel(level SecurityLevel) []AuditEvent
+	AuditBlockchain()
+}
+
 // AuditService provides blockchain security auditing
 type AuditService struct {
 	events     []AuditEvent
-	blockchain *core.Blockchain
+	blockchain core.BlockchainInterface
+	observer   EventObserver
 	mu         sync.RWMutex
 }
 
 // NewAuditService creates a new audit service
-func NewAuditService(blockchain *core.Blockchain) *AuditService {
+func NewAuditService(blockchain core.BlockchainInterface) *AuditService {
 	service := &AuditService{
 		events:     make([]AuditEvent, 0),
 		blockchain: blockchain,
@@ -55,6 +72,14 @@ func NewAuditService(blockchain *core.Blockchain) *AuditService {
 	return service
 }
 
+// SetObserver sets the observer notified of logged audit events
+func (a *AuditService) SetObserver(observer EventObserver) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.observer = observer
+}
+
 // LogEvent logs an audit event
 func (a *AuditService) LogEvent(level SecurityLevel, eventType, message string, data interface{}) {
 	a.mu.Lock()
@@ -76,6 +101,9 @@ func (a *AuditService) LogEvent(level SecurityLevel, eventType, message string,
 
 	// Add event to log
 	a.events = append(a.events, event)
+	if a.observer != nil {
+		a.observer.AuditEventLogged(event)
+	}
 
 	// Log critical events immediately
 	if level == CriticalLevel {
@@ -112,12 +140,10 @@ func (a *AuditService) GetEventsByLevel(level SecurityLevel) []AuditEvent {
 }
 
 // AuditBlockchain performs a full audit of the blockchain
-func (a *AuditService) AuditBlockchain() []AuditEvent {
+func (a *AuditService) AuditBlockchain() {
 	// Get a copy of the blockchain
 	chain := a.blockchain.GetChain()
 
-	auditEvents := make([]AuditEvent, 0)
-
 	// Verify each block
 	for i := 1; i < len(chain); i++ {
 		block := chain[i]
@@ -166,12 +192,18 @@ func (a *AuditService) AuditBlockchain() []AuditEvent {
 				)
 			}
 
-			// Note: Full transaction signature verification would require public keys
-			// which we don't store in this simplified implementation
+			// Verify the signature against the public key carried by the transaction, or
+			// the ID of a legacy transaction that carries none
+			if err := core.VerifyBlockTransaction(block, &tx); err != nil {
+				a.LogEvent(
+					CriticalLevel,
+					"InvalidTransactionSignature",
+					fmt.Sprintf("Block %d contains transaction %s with invalid signature: %v", block.Index, tx.ID, err),
+					tx,
+				)
+			}
 		}
 	}
-
-	return auditEvents
 }
 
 // runPeriodicAudits runs periodic security audits
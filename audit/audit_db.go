@@ -16,6 +16,7 @@ import (
 // AuditServiceDB provides database-backed blockchain security auditing
 type AuditServiceDB struct {
 	blockchain interface{} // Accept any blockchain implementation
+	observer   EventObserver
 	mu         sync.RWMutex
 }
 
@@ -31,6 +32,14 @@ func NewAuditServiceWithDB(blockchain interface{}) *AuditServiceDB {
 	return service
 }
 
+// SetObserver sets the observer notified of logged audit events
+func (a *AuditServiceDB) SetObserver(observer EventObserver) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.observer = observer
+}
+
 // LogEvent logs an audit event to the database
 func (a *AuditServiceDB) LogEvent(level SecurityLevel, eventType, message string, data interface{}) {
 	a.mu.Lock()
@@ -61,7 +70,7 @@ func (a *AuditServiceDB) LogEvent(level SecurityLevel, eventType, message string
 		Level:     int(level),
 		Type:      eventType,
 		Message:   message,
-		Data:      dataJSON,
+		Data:      database.JSON(dataJSON),
 	}
 
 	// Save to database
@@ -69,6 +78,16 @@ func (a *AuditServiceDB) LogEvent(level SecurityLevel, eventType, message string
 		log.Printf("Failed to save audit event: %v", err)
 		return
 	}
+	if a.observer != nil {
+		a.observer.AuditEventLogged(AuditEvent{
+			ID:        id,
+			Timestamp: dbEvent.Timestamp,
+			Level:     level,
+			Type:      eventType,
+			Message:   message,
+			Data:      data,
+		})
+	}
 
 	// Log critical events immediately
 	if level == CriticalLevel {
@@ -211,6 +230,16 @@ func (a *AuditServiceDB) auditBlocks(blockchain interface{ GetChain() []core.Blo
 						"blockIndex":    block.Index,
 					})
 			}
+
+			if err := core.VerifyBlockTransaction(block, &tx); err != nil {
+				a.LogEvent(CriticalLevel, "InvalidTransactionSignature",
+					fmt.Sprintf("Block %d contains transaction %s with invalid signature", block.Index, tx.ID),
+					map[string]interface{}{
+						"transactionId": tx.ID,
+						"blockIndex":    block.Index,
+						"reason":        err.Error(),
+					})
+			}
 		}
 	}
 }
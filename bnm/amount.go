@@ -0,0 +1,208 @@
+// Package bnm provides the fixed-point amount type used for Binom (BNM) token values.
+package bnm
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimals is the number of decimal places carried by an Amount
+const Decimals = 8
+
+// UnitsPerBNM is the number of base units in one BNM
+const UnitsPerBNM Amount = 100000000
+
+// Amount is a token amount held as an integer number of base units (1e-8 BNM).
+// It is encoded as a decimal string in JSON and as a decimal in SQL.
+type Amount int64
+
+// FromBNM converts a whole number of BNM to an Amount
+func FromBNM(n int64) Amount {
+	return Amount(n) * UnitsPerBNM
+}
+
+// FromFloat64 converts a floating point BNM value to the nearest Amount. It is only
+// meant for reading legacy data and display values, never for arithmetic.
+func FromFloat64(f float64) Amount {
+	return Amount(math.Round(f * float64(UnitsPerBNM)))
+}
+
+// Parse parses a decimal BNM string such as "12.5" or "0.00000001"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && (!hasFrac || frac == "") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > Decimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, Decimals)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	wholeUnits, err := parseDigits(whole)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	fracUnits := uint64(0)
+	if frac != "" {
+		fracUnits, err = parseDigits(frac + strings.Repeat("0", Decimals-len(frac)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	if wholeUnits > (math.MaxInt64-fracUnits)/uint64(UnitsPerBNM) {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+	units := int64(wholeUnits)*int64(UnitsPerBNM) + int64(fracUnits)
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is intended for constants.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// String formats the amount as a decimal BNM value without trailing zeros
+func (a Amount) String() string {
+	s := a.fixed()
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Float64 returns the amount in BNM as a float, for display purposes only
+func (a Amount) Float64() float64 {
+	return float64(a) / float64(UnitsPerBNM)
+}
+
+// Bytes returns the amount in base units as 8 big-endian bytes, the form used in
+// hashed encodings
+func (a Amount) Bytes() []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(a))
+	return b
+}
+
+// MulDiv returns a*num/den rounded down, without intermediate overflow
+func (a Amount) MulDiv(num, den int64) Amount {
+	if den == 0 {
+		panic("bnm: division by zero")
+	}
+	result := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num))
+	result.Quo(result, big.NewInt(den))
+	return Amount(result.Int64())
+}
+
+// MarshalJSON encodes the amount as a decimal string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON decodes a decimal string. Bare JSON integers written by older
+// versions are still read as whole BNM; that form is deprecated and will be dropped.
+// Any other JSON number is rejected, since its fractional part may already have
+// been rounded by a float.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		parsed, err := Parse(unquoted)
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	}
+
+	if _, err := parseDigits(strings.TrimPrefix(text, "-")); err != nil {
+		return fmt.Errorf("amount %s must be a decimal string", text)
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal with Decimals places
+func (a Amount) Value() (driver.Value, error) {
+	return a.fixed(), nil
+}
+
+// Scan reads the amount from a decimal, string or numeric column
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = FromBNM(v)
+		return nil
+	case float64:
+		*a = FromFloat64(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into bnm.Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// fixed formats the amount with all Decimals places
+func (a Amount) fixed() string {
+	units := int64(a)
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(units))
+	whole, frac := new(big.Int).QuoRem(abs, big.NewInt(int64(UnitsPerBNM)), new(big.Int))
+	return fmt.Sprintf("%s%s.%08d", sign, whole.String(), frac.Int64())
+}
+
+func parseDigits(s string) (uint64, error) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid digit %q", c)
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
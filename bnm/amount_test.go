@@ -0,0 +1,72 @@
+package bnm
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	cases := map[string]Amount{
+		"0":            0,
+		"1":            UnitsPerBNM,
+		"1.5":          150000000,
+		"0.00000001":   1,
+		"-2.25":        -225000000,
+		"123.45678901": 12345678901,
+	}
+	for input, expected := range cases {
+		got, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", input, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("Parse(%q) = %d, want %d", input, got, expected)
+		}
+		if got.String() != input {
+			t.Errorf("String() = %q, want %q", got.String(), input)
+		}
+	}
+
+	// The largest whole number of BNM only leaves room for part of a fraction
+	if got, err := Parse("92233720368.54775807"); err != nil || got != math.MaxInt64 {
+		t.Errorf("Parse of the largest amount = %d (%v), want %d", got, err, int64(math.MaxInt64))
+	}
+
+	for _, input := range []string{"", "abc", "1.000000001", "1.2.3", "92233720368.54775808", "92233720369"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", input)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	data, err := json.Marshal(MustParse("0.1"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `"0.1"` {
+		t.Errorf("Expected string encoding, got %s", data)
+	}
+
+	// Integers written by older versions are still accepted
+	for input, expected := range map[string]Amount{`"0.1"`: 10000000, `100`: FromBNM(100), `-2`: FromBNM(-2)} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(input), &amount); err != nil {
+			t.Errorf("Unmarshal(%s) failed: %v", input, err)
+			continue
+		}
+		if amount != expected {
+			t.Errorf("Unmarshal(%s) = %s, want %s", input, amount, expected)
+		}
+	}
+
+	// Other numbers may have been rounded by a float on the way
+	for _, input := range []string{`0.1`, `1e3`, `1.5e-7`, `true`} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(input), &amount); err == nil {
+			t.Errorf("Expected Unmarshal(%s) to fail, got %s", input, amount)
+		}
+	}
+}
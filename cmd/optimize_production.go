@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/consensus"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/token"
@@ -290,7 +291,7 @@ func (po *ProductionOptimizer) createTestTransactions(count int) []core.Transact
 			ID:        fmt.Sprintf("AdNeoptimize%054d", i),
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        fmt.Sprintf("AdNetest%058d", i+10000),
-			Amount:    float64(1 + i%100),
+			Amount:    bnm.FromBNM(int64(1 + i%100)),
 			Timestamp: time.Now().Unix(),
 			Signature: fmt.Sprintf("optimize_sig_%d", i),
 		}
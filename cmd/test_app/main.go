@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/smartcontract"
 	"github.com/igo-used/binomena/token"
@@ -36,7 +37,8 @@ func main() {
 	tx, err := core.NewTransaction(
 		aliceWallet.Address,
 		bobWallet.Address,
-		100.0,
+		bnm.FromBNM(100),
+		0,
 		aliceWallet,
 	)
 
@@ -47,8 +49,8 @@ func main() {
 	fmt.Printf("Transaction created: %s\n", tx.ID)
 	fmt.Printf("  From: %s\n", tx.From)
 	fmt.Printf("  To: %s\n", tx.To)
-	fmt.Printf("  Amount: %.2f\n", tx.Amount)
-	fmt.Printf("  Fee: %.4f\n", tx.CalculateFee())
+	fmt.Printf("  Amount: %s\n", tx.Amount)
+	fmt.Printf("  Fee: %s\n", tx.CalculateFee())
 
 	// Verify transaction
 	isValid := core.VerifyTransaction(tx, aliceWallet.PublicKey)
@@ -89,7 +91,7 @@ func main() {
 			aliceWallet.Address,
 			"TestContract",
 			wasmCode,
-			0.5, // fee
+			bnm.MustParse("0.5"), // fee
 		)
 
 		if err != nil {
@@ -104,7 +106,7 @@ func main() {
 				"add",                // function name
 				[]interface{}{40, 2}, // parameters
 				aliceWallet.Address,
-				0.01, // fee
+				bnm.MustParse("0.01"), // fee
 			)
 
 			if err != nil {
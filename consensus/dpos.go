@@ -1,11 +1,13 @@
 package consensus
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/database"
 	"gorm.io/gorm"
@@ -13,54 +15,97 @@ import (
 
 const (
 	// DPoS Configuration
-	MaxDelegates        = 21     // Maximum number of delegates
-	MinDelegateStake    = 5000.0 // Minimum BNM required to become delegate
-	BlockTime           = 3      // Seconds between blocks
-	DelegateRewardRatio = 0.6    // 60% of fees go to delegates
-	BurnRatio           = 0.3    // 30% of fees burned
-	CommunityRatio      = 0.05   // 5% to community
-	FounderRatio        = 0.05   // 5% to founder
+	MaxDelegates                     = 21                     // Maximum number of delegates
+	MinDelegateStake      bnm.Amount = 5000 * bnm.UnitsPerBNM // Minimum BNM required to become delegate
+	BlockTime                        = 3                      // Seconds between blocks
+	DelegateRewardPercent            = 60                     // 60% of fees go to delegates
+	BurnPercent                      = 30                     // 30% of fees burned
+	CommunityPercent                 = 5                      // 5% to community
+	FounderPercent                   = 5                      // 5% to founder, plus rounding remainders
 )
 
 // Delegate represents a DPoS delegate
 type Delegate struct {
-	ID             uint    `gorm:"primaryKey"`
-	Address        string  `gorm:"size:66;uniqueIndex;not null"`
-	Stake          float64 `gorm:"type:decimal(20,8);not null"`
-	VotesReceived  float64 `gorm:"type:decimal(20,8);default:0"`
-	IsActive       bool    `gorm:"default:true"`
-	RegisteredAt   int64   `gorm:"not null"`
-	LastBlockTime  int64   `gorm:"default:0"`
-	BlocksProduced uint64  `gorm:"default:0"`
-	TotalRewards   float64 `gorm:"type:decimal(20,8);default:0"`
-	Commission     float64 `gorm:"type:decimal(5,4);default:0.1"` // 10% default commission
+	ID             uint       `gorm:"primaryKey"`
+	Address        string     `gorm:"size:66;uniqueIndex;not null"`
+	Stake          bnm.Amount `gorm:"type:decimal(20,8);not null"`
+	VotesReceived  bnm.Amount `gorm:"type:decimal(20,8);default:0"`
+	IsActive       bool       `gorm:"default:true"`
+	RegisteredAt   int64      `gorm:"not null"`
+	LastBlockTime  int64      `gorm:"default:0"`
+	BlocksProduced uint64     `gorm:"default:0"`
+	TotalRewards   bnm.Amount `gorm:"type:decimal(20,8);default:0"`
+	Commission     float64    `gorm:"type:decimal(5,4);default:0.1"` // 10% default commission
 }
 
 // Vote represents a vote for a delegate
 type Vote struct {
-	ID           uint    `gorm:"primaryKey"`
-	VoterAddress string  `gorm:"size:66;not null;index"`
-	DelegateID   uint    `gorm:"not null;index"`
-	Amount       float64 `gorm:"type:decimal(20,8);not null"`
-	Timestamp    int64   `gorm:"not null"`
+	ID           uint       `gorm:"primaryKey"`
+	VoterAddress string     `gorm:"size:66;not null;index"`
+	DelegateID   uint       `gorm:"not null;index"`
+	Amount       bnm.Amount `gorm:"type:decimal(20,8);not null"`
+	Timestamp    int64      `gorm:"not null"`
+}
+
+// DelegateEpoch records the delegate set in force from a block height on, so
+// blocks are checked against the schedule they were produced under
+type DelegateEpoch struct {
+	ID         uint   `gorm:"primaryKey"`
+	FromHeight uint64 `gorm:"uniqueIndex;not null"`
+	Delegates  string `gorm:"type:text;not null"` // JSON delegate set
+}
+
+// ChainHeight reports the height of the chain delegate changes take effect on
+type ChainHeight interface {
+	GetBlockCount() int
+}
+
+// DelegateChangeType names a kind of delegate change
+type DelegateChangeType string
+
+const (
+	// DelegateRegistered is reported when a new delegate registers
+	DelegateRegistered DelegateChangeType = "registered"
+	// DelegateVoted is reported when a delegate receives votes
+	DelegateVoted DelegateChangeType = "voted"
+)
+
+// DelegateChange describes a change to a delegate
+type DelegateChange struct {
+	Type     DelegateChangeType `json:"type"`
+	Delegate Delegate           `json:"delegate"`
+	Voter    string             `json:"voter,omitempty"`
+	Amount   bnm.Amount         `json:"amount"`
+}
+
+// DelegateObserver is notified of delegate changes. It is called while the
+// consensus is locked, so it must neither block nor call back into it.
+type DelegateObserver interface {
+	DelegateChanged(change DelegateChange)
 }
 
 // DPoSConsensus implements Delegated Proof of Stake
 type DPoSConsensus struct {
 	delegates        []Delegate
-	currentProducer  int
 	mu               sync.RWMutex
-	lastBlockTime    int64
 	founderAddress   string
 	communityAddress string
+	lastIrreversible core.BlockHeader
+	observer         DelegateObserver
+	chain            ChainHeight
+	epochs           []epoch // Delegate sets by the height they took effect at, oldest first
+}
+
+// epoch is a delegate set in force from a block height on
+type epoch struct {
+	from      uint64
+	delegates []Delegate
 }
 
 // NewDPoSConsensus creates a new DPoS consensus mechanism
 func NewDPoSConsensus(founderAddress, communityAddress string) *DPoSConsensus {
 	dpos := &DPoSConsensus{
 		delegates:        []Delegate{},
-		currentProducer:  0,
-		lastBlockTime:    time.Now().Unix(),
 		founderAddress:   founderAddress,
 		communityAddress: communityAddress,
 	}
@@ -68,39 +113,137 @@ func NewDPoSConsensus(founderAddress, communityAddress string) *DPoSConsensus {
 	// Only migrate tables if database is available
 	if database.DB != nil {
 		// Migrate delegate tables
-		if err := database.DB.AutoMigrate(&Delegate{}, &Vote{}); err != nil {
+		if err := database.DB.AutoMigrate(&Delegate{}, &Vote{}, &DelegateEpoch{}); err != nil {
 			log.Printf("Failed to migrate DPoS tables: %v", err)
 		}
 
-		// Load existing delegates
+		// Load existing delegates and the sets that were in force before them
 		dpos.loadDelegates()
+		dpos.loadEpochs()
 	} else {
 		log.Println("Database not available, using in-memory DPoS consensus")
 		// Initialize with founder as the only delegate for file-based mode
 		founderDelegate := Delegate{
 			ID:            1,
 			Address:       founderAddress,
-			Stake:         400000000.0, // 400M BNM
-			VotesReceived: 400000000.0,
+			Stake:         bnm.FromBNM(400000000), // 400M BNM
+			VotesReceived: bnm.FromBNM(400000000),
 			IsActive:      true,
 			RegisteredAt:  time.Now().Unix(),
 			Commission:    0.0, // No commission for founder
 		}
 		dpos.delegates = []Delegate{founderDelegate}
+		dpos.recordEpoch()
 		log.Printf("Initialized founder as delegate: %s with 400M BNM stake", founderAddress)
 	}
 
 	return dpos
 }
 
+// SetChain sets the chain whose next block delegate changes take effect at. Without
+// a chain, changes apply from genesis.
+func (d *DPoSConsensus) SetChain(chain ChainHeight) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.chain = chain
+}
+
+// recordEpoch records the current delegates as the set in force from the next
+// block on. Callers must hold d.mu or own the consensus.
+func (d *DPoSConsensus) recordEpoch() {
+	from := uint64(0)
+	if d.chain != nil {
+		from = uint64(d.chain.GetBlockCount())
+	}
+
+	delegates := make([]Delegate, len(d.delegates))
+	copy(delegates, d.delegates)
+
+	// Changes made before the same block replace each other, and sets recorded
+	// past a shorter chain never took effect
+	for n := len(d.epochs); n > 0 && d.epochs[n-1].from >= from; n-- {
+		d.epochs = d.epochs[:n-1]
+	}
+	d.epochs = append(d.epochs, epoch{from: from, delegates: delegates})
+
+	if database.DB != nil {
+		data, err := json.Marshal(delegates)
+		if err != nil {
+			log.Printf("Failed to serialize delegate set: %v", err)
+			return
+		}
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("from_height >= ?", from).Delete(&DelegateEpoch{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&DelegateEpoch{FromHeight: from, Delegates: string(data)}).Error
+		})
+		if err != nil {
+			log.Printf("Failed to save delegate set: %v", err)
+		}
+	}
+}
+
+// loadEpochs loads the recorded delegate sets from database, starting the history
+// with the current delegates if there is none
+func (d *DPoSConsensus) loadEpochs() {
+	var records []DelegateEpoch
+	if err := database.DB.Order("from_height").Find(&records).Error; err != nil {
+		log.Printf("Failed to load delegate sets: %v", err)
+	}
+
+	d.epochs = nil
+	for _, record := range records {
+		var delegates []Delegate
+		if err := json.Unmarshal([]byte(record.Delegates), &delegates); err != nil {
+			log.Printf("Failed to parse delegate set from height %d: %v", record.FromHeight, err)
+			continue
+		}
+		d.epochs = append(d.epochs, epoch{from: record.FromHeight, delegates: delegates})
+	}
+	if len(d.epochs) == 0 {
+		d.recordEpoch()
+	}
+}
+
+// delegatesAt returns the delegate set in force at a block height. Callers must
+// hold d.mu.
+func (d *DPoSConsensus) delegatesAt(height uint64) []Delegate {
+	for i := len(d.epochs) - 1; i >= 0; i-- {
+		if d.epochs[i].from <= height {
+			return d.epochs[i].delegates
+		}
+	}
+	if len(d.epochs) > 0 {
+		return d.epochs[0].delegates
+	}
+	return d.delegates
+}
+
+// SetDelegateObserver sets the observer notified of delegate registrations and votes
+func (d *DPoSConsensus) SetDelegateObserver(observer DelegateObserver) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.observer = observer
+}
+
+// notify reports a delegate change to the observer, if any
+func (d *DPoSConsensus) notify(change DelegateChange) {
+	if d.observer != nil {
+		d.observer.DelegateChanged(change)
+	}
+}
+
 // RegisterDelegate registers a new delegate
-func (d *DPoSConsensus) RegisterDelegate(address string, stake float64) error {
+func (d *DPoSConsensus) RegisterDelegate(address string, stake bnm.Amount) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	// Check minimum stake requirement
 	if stake < MinDelegateStake {
-		return fmt.Errorf("minimum stake required: %.2f BNM", MinDelegateStake)
+		return fmt.Errorf("minimum stake required: %s BNM", MinDelegateStake)
 	}
 
 	// If database is available, use database operations
@@ -147,6 +290,8 @@ func (d *DPoSConsensus) RegisterDelegate(address string, stake float64) error {
 
 		// Reload delegates
 		d.loadDelegates()
+		d.recordEpoch()
+		d.notify(DelegateChange{Type: DelegateRegistered, Delegate: delegate, Voter: address, Amount: stake})
 	} else {
 		// File-based mode: use in-memory operations
 		// Check if already registered
@@ -173,17 +318,33 @@ func (d *DPoSConsensus) RegisterDelegate(address string, stake float64) error {
 		}
 
 		d.delegates = append(d.delegates, newDelegate)
+		d.recordEpoch()
+		d.notify(DelegateChange{Type: DelegateRegistered, Delegate: newDelegate, Voter: address, Amount: stake})
 	}
 
-	log.Printf("Delegate registered: %s with stake %.2f BNM", address, stake)
+	log.Printf("Delegate registered: %s with stake %s BNM", address, stake)
 	return nil
 }
 
 // VoteForDelegate allows voting for a delegate
-func (d *DPoSConsensus) VoteForDelegate(voterAddress, delegateAddress string, amount float64) error {
+func (d *DPoSConsensus) VoteForDelegate(voterAddress, delegateAddress string, amount bnm.Amount) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	// Without a database, votes are only tallied in memory
+	if database.DB == nil {
+		for i := range d.delegates {
+			if d.delegates[i].Address == delegateAddress && d.delegates[i].IsActive {
+				d.delegates[i].VotesReceived += amount
+				d.recordEpoch()
+				d.notify(DelegateChange{Type: DelegateVoted, Delegate: d.delegates[i], Voter: voterAddress, Amount: amount})
+				log.Printf("Vote recorded: %s voted %s BNM for delegate %s", voterAddress, amount, delegateAddress)
+				return nil
+			}
+		}
+		return fmt.Errorf("delegate not found or inactive")
+	}
+
 	// Find delegate
 	var delegate Delegate
 	result := database.DB.Where("address = ? AND is_active = ?", delegateAddress, true).First(&delegate)
@@ -222,82 +383,309 @@ func (d *DPoSConsensus) VoteForDelegate(voterAddress, delegateAddress string, am
 
 	// Reload delegates
 	d.loadDelegates()
+	d.recordEpoch()
+	d.notify(DelegateChange{Type: DelegateVoted, Delegate: delegate, Voter: voterAddress, Amount: amount})
 
-	log.Printf("Vote recorded: %s voted %.2f BNM for delegate %s", voterAddress, amount, delegateAddress)
+	log.Printf("Vote recorded: %s voted %s BNM for delegate %s", voterAddress, amount, delegateAddress)
 	return nil
 }
 
 // GetActiveProducer returns the current block producer
 func (d *DPoSConsensus) GetActiveProducer() string {
+	return d.GetScheduledProducer(time.Now().Unix())
+}
+
+// GetScheduledProducer returns the delegate owning the block slot of the given
+// timestamp under the current delegates, rotating round-robin over the top
+// delegates every BlockTime seconds
+func (d *DPoSConsensus) GetScheduledProducer(timestamp int64) string {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	if len(d.delegates) == 0 {
-		return d.founderAddress // Fallback to founder if no delegates
+	return scheduledProducer(d.activeProducers(), timestamp)
+}
+
+// GetScheduledProducerAt returns the delegate owning the block slot of the given
+// timestamp under the delegates in force at a block height
+func (d *DPoSConsensus) GetScheduledProducerAt(height uint64, timestamp int64) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return scheduledProducer(d.producersOf(d.delegatesAt(height)), timestamp)
+}
+
+// scheduledProducer returns the producer owning the slot of a timestamp
+func scheduledProducer(producers []string, timestamp int64) string {
+	slot := timestamp / BlockTime
+	if slot < 0 {
+		slot = 0
 	}
+	return producers[slot%int64(len(producers))]
+}
 
-	currentTime := time.Now().Unix()
-	timeSinceLastBlock := currentTime - d.lastBlockTime
+// activeProducers returns the delegates taking part in the production schedule.
+// Callers must hold d.mu.
+func (d *DPoSConsensus) activeProducers() []string {
+	return d.producersOf(d.delegates)
+}
 
-	// If enough time has passed, move to next producer
-	if timeSinceLastBlock >= BlockTime {
-		d.mu.RUnlock()
-		d.mu.Lock()
-		d.currentProducer = (d.currentProducer + 1) % len(d.delegates)
-		d.lastBlockTime = currentTime
-		d.mu.Unlock()
-		d.mu.RLock()
+// producersOf returns the delegates of a set taking part in the production schedule
+func (d *DPoSConsensus) producersOf(delegates []Delegate) []string {
+	producers := make([]string, 0, len(delegates))
+	for _, delegate := range delegates {
+		if delegate.IsActive {
+			producers = append(producers, delegate.Address)
+		}
+		if len(producers) == MaxDelegates {
+			break
+		}
 	}
 
-	return d.delegates[d.currentProducer].Address
+	if len(producers) == 0 {
+		return []string{d.founderAddress} // Fallback to founder if no delegates
+	}
+	return producers
 }
 
-// DistributeFees distributes transaction fees according to DPoS rules
-func (d *DPoSConsensus) DistributeFees(totalFees float64, tokenSystem interface{}) error {
-	if totalFees <= 0 {
-		return nil
+// UpdateIrreversibleBlock advances the last irreversible block given the canonical
+// chain from the current last irreversible block to the tip. A block becomes
+// irreversible once two-thirds plus one of the producers in force at its height
+// have produced blocks on top of it. A block only counts as a confirmation if its
+// producer was in force at the height of that block.
+func (d *DPoSConsensus) UpdateIrreversibleBlock(head []core.Block) core.BlockHeader {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	confirmations := make(map[string]bool)
+	for i := len(head) - 1; i > 0; i-- {
+		for _, address := range d.producersOf(d.delegatesAt(head[i].Index)) {
+			if address == head[i].Validator {
+				confirmations[address] = true
+				break
+			}
+		}
+
+		producers := d.producersOf(d.delegatesAt(head[i-1].Index))
+		confirmed := 0
+		for _, address := range producers {
+			if confirmations[address] {
+				confirmed++
+			}
+		}
+		if confirmed >= len(producers)*2/3+1 {
+			if head[i-1].Index > d.lastIrreversible.Index {
+				d.lastIrreversible = head[i-1].Header()
+			}
+			break
+		}
 	}
 
-	// Calculate fee distribution
-	delegateReward := totalFees * DelegateRewardRatio // 60%
-	burnAmount := totalFees * BurnRatio               // 30%
-	communityReward := totalFees * CommunityRatio     // 5%
-	founderReward := totalFees * FounderRatio         // 5%
+	return d.lastIrreversible
+}
 
-	// Distribute to active delegates
-	if len(d.delegates) > 0 {
-		rewardPerDelegate := delegateReward / float64(len(d.delegates))
-		for _, delegate := range d.delegates {
+// GetLastIrreversibleBlock returns the header of the last irreversible block
+func (d *DPoSConsensus) GetLastIrreversibleBlock() core.BlockHeader {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.lastIrreversible
+}
+
+// SplitFees returns how DistributeFees splits transaction fees. The shares always
+// add up to exactly totalFees: rounding remainders go to the founder share.
+func (d *DPoSConsensus) SplitFees(totalFees bnm.Amount) core.FeeDistribution {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, distribution := splitFees(d.delegates, totalFees)
+	return distribution
+}
+
+// FeePayouts returns how the fee of a transaction included at a block height is
+// paid out of the treasury to the delegates in force at that height, the community
+// and the founder, along with the split including the burned share
+func (d *DPoSConsensus) FeePayouts(fee bnm.Amount, height uint64) ([]core.FeePayout, core.FeeDistribution) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	delegates := d.delegatesAt(height)
+	rewardPerDelegate, distribution := splitFees(delegates, fee)
+
+	var payouts []core.FeePayout
+	if rewardPerDelegate > 0 {
+		for _, delegate := range delegates {
 			if delegate.IsActive {
-				// Transfer reward to delegate
-				if err := d.transferReward(delegate.Address, rewardPerDelegate, tokenSystem); err != nil {
-					log.Printf("Failed to reward delegate %s: %v", delegate.Address, err)
-				} else {
-					// Update delegate stats
-					d.updateDelegateRewards(delegate.ID, rewardPerDelegate)
-				}
+				payouts = append(payouts, core.FeePayout{Address: delegate.Address, Amount: rewardPerDelegate})
 			}
 		}
 	}
+	payouts = append(payouts,
+		core.FeePayout{Address: d.communityAddress, Amount: distribution.Community},
+		core.FeePayout{Address: d.founderAddress, Amount: distribution.Founder},
+	)
+	return payouts, distribution
+}
 
-	// Burn tokens
-	if burner, ok := tokenSystem.(interface{ Burn(float64) }); ok {
-		burner.Burn(burnAmount)
+// splitFees returns the reward of each active delegate of a set and the total shares
+func splitFees(delegates []Delegate, totalFees bnm.Amount) (bnm.Amount, core.FeeDistribution) {
+	delegateReward := totalFees.MulDiv(DelegateRewardPercent, 100) // 60%
+	burnAmount := totalFees.MulDiv(BurnPercent, 100)               // 30%
+	communityReward := totalFees.MulDiv(CommunityPercent, 100)     // 5%
+
+	// Split the delegate share evenly across active delegates
+	activeDelegates := 0
+	for _, delegate := range delegates {
+		if delegate.IsActive {
+			activeDelegates++
+		}
+	}
+	rewardPerDelegate := bnm.Amount(0)
+	if activeDelegates > 0 {
+		rewardPerDelegate = delegateReward / bnm.Amount(activeDelegates)
+	}
+	delegatesTotal := rewardPerDelegate * bnm.Amount(activeDelegates)
+
+	return rewardPerDelegate, core.FeeDistribution{
+		Delegates: delegatesTotal,
+		Burned:    burnAmount,
+		Community: communityReward,
+		Founder:   totalFees - delegatesTotal - burnAmount - communityReward, // 5% + remainders
+	}
+}
+
+// DistributeFees pays transaction fees out of the treasury and burns its share as
+// FeePayouts splits them at the current height. Either every share moves or none
+// does; the split actually paid is returned.
+func (d *DPoSConsensus) DistributeFees(totalFees bnm.Amount, tokenSystem interface{}) (core.FeeDistribution, error) {
+	if totalFees <= 0 {
+		return core.FeeDistribution{}, nil
+	}
+
+	store, ok := tokenSystem.(interface {
+		ApplyStateChanges([]core.StateChange) error
+	})
+	if !ok {
+		return core.FeeDistribution{}, fmt.Errorf("token system cannot apply state changes atomically")
 	}
 
-	// Reward community
-	if err := d.transferReward(d.communityAddress, communityReward, tokenSystem); err != nil {
-		log.Printf("Failed to reward community: %v", err)
+	d.mu.RLock()
+	chain := d.chain
+	d.mu.RUnlock()
+	height := uint64(0)
+	if chain != nil {
+		height = uint64(chain.GetBlockCount())
+	}
+
+	payouts, distribution := d.FeePayouts(totalFees, height)
+	if err := store.ApplyStateChanges(core.FeeChanges(payouts, distribution.Burned)); err != nil {
+		return core.FeeDistribution{}, fmt.Errorf("failed to distribute fees: %v", err)
 	}
 
-	// Reward founder
-	if err := d.transferReward(d.founderAddress, founderReward, tokenSystem); err != nil {
-		log.Printf("Failed to reward founder: %v", err)
+	// Update delegate stats for the rewards paid
+	d.mu.Lock()
+	var rewarded []Delegate
+	for _, delegate := range d.delegatesAt(height) {
+		if delegate.IsActive {
+			rewarded = append(rewarded, delegate)
+		}
 	}
+	for _, delegate := range rewarded {
+		d.updateDelegateRewards(delegate.ID, distribution.Delegates/bnm.Amount(len(rewarded)))
+	}
+	d.mu.Unlock()
+
+	log.Printf("Fees distributed: %s to delegates, %s burned, %s to community, %s to founder",
+		distribution.Delegates, distribution.Burned, distribution.Community, distribution.Founder)
 
-	log.Printf("Fees distributed: %.6f to delegates, %.6f burned, %.6f to community, %.6f to founder",
-		delegateReward, burnAmount, communityReward, founderReward)
+	return distribution, nil
+}
 
+// StateEntries returns the delegate stakes and votes committed to by the state root
+func (d *DPoSConsensus) StateEntries() (map[string][]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return DelegateStateEntries(d.delegates), nil
+}
+
+// StateEntriesAt returns the stakes and votes of the delegates in force at a block
+// height, which the state root of that block commits to
+func (d *DPoSConsensus) StateEntriesAt(height uint64) (map[string][]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return DelegateStateEntries(d.delegatesAt(height)), nil
+}
+
+// DelegatesAt returns the delegates in force at a block height
+func (d *DPoSConsensus) DelegatesAt(height uint64) []Delegate {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	delegates := d.delegatesAt(height)
+	result := make([]Delegate, len(delegates))
+	copy(result, delegates)
+	return result
+}
+
+// DelegateStateEntries returns the state root entries of a set of delegates
+func DelegateStateEntries(delegates []Delegate) map[string][]byte {
+	entries := make(map[string][]byte, len(delegates))
+	for _, delegate := range delegates {
+		active := byte(0)
+		if delegate.IsActive {
+			active = 1
+		}
+		value := append(delegate.Stake.Bytes(), delegate.VotesReceived.Bytes()...)
+		entries["delegate/"+delegate.Address] = append(value, active)
+	}
+	return entries
+}
+
+// RestoreDelegates replaces all delegates, such as when importing a chain snapshot.
+// Votes are not carried over: each delegate keeps its received vote total.
+func (d *DPoSConsensus) RestoreDelegates(delegates []Delegate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(delegates) > MaxDelegates {
+		return fmt.Errorf("maximum delegates exceeded (%d)", MaxDelegates)
+	}
+
+	if database.DB != nil {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM votes").Error; err != nil {
+				return fmt.Errorf("failed to clear votes: %v", err)
+			}
+			if err := tx.Exec("DELETE FROM delegates").Error; err != nil {
+				return fmt.Errorf("failed to clear delegates: %v", err)
+			}
+			for i, delegate := range delegates {
+				// Select every column so inactive delegates are not stored with the defaults
+				delegate.ID = uint(i + 1)
+				if err := tx.Select("*").Create(&delegate).Error; err != nil {
+					return fmt.Errorf("failed to restore delegate %s: %v", delegate.Address, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Reload delegates
+		d.loadDelegates()
+	} else {
+		// File-based mode: replace the in-memory delegates
+		d.delegates = make([]Delegate, len(delegates))
+		for i, delegate := range delegates {
+			delegate.ID = uint(i + 1)
+			d.delegates[i] = delegate
+		}
+	}
+	d.recordEpoch()
+
+	log.Printf("Restored %d delegates", len(delegates))
 	return nil
 }
 
@@ -353,18 +741,8 @@ func (d *DPoSConsensus) loadDelegates() {
 	}
 }
 
-// transferReward transfers reward tokens
-func (d *DPoSConsensus) transferReward(address string, amount float64, tokenSystem interface{}) error {
-	if transferer, ok := tokenSystem.(interface {
-		Transfer(string, string, float64) error
-	}); ok {
-		return transferer.Transfer("treasury", address, amount)
-	}
-	return fmt.Errorf("token system does not support transfers")
-}
-
 // updateDelegateRewards updates delegate reward statistics
-func (d *DPoSConsensus) updateDelegateRewards(delegateID uint, reward float64) {
+func (d *DPoSConsensus) updateDelegateRewards(delegateID uint, reward bnm.Amount) {
 	if database.DB != nil {
 		var delegate Delegate
 		if err := database.DB.First(&delegate, delegateID).Error; err != nil {
@@ -388,21 +766,21 @@ func (d *DPoSConsensus) updateDelegateRewards(delegateID uint, reward float64) {
 
 // ValidateBlock validates a block (satisfies core.Consensus interface)
 func (d *DPoSConsensus) ValidateBlock(block core.Block) bool {
-	// Basic validation - in production, add more sophisticated checks
-	// Check if block producer is an active delegate
-	producer := block.Validator
-	for _, delegate := range d.delegates {
-		if delegate.Address == producer && delegate.IsActive {
-			return true
-		}
+	// The block must be signed by the key behind its validator address
+	if err := core.VerifyBlockSignature(block); err != nil {
+		log.Printf("Block %d rejected: %v", block.Index, err)
+		return false
 	}
 
-	// Allow founder to produce blocks if no delegates
-	if len(d.delegates) == 0 && producer == d.founderAddress {
-		return true
+	// The validator must be the scheduled producer for the block's slot under the
+	// delegates in force at its height
+	scheduled := d.GetScheduledProducerAt(block.Index, block.Timestamp)
+	if block.Validator != scheduled {
+		log.Printf("Block %d rejected: produced by %s, slot belongs to %s", block.Index, block.Validator, scheduled)
+		return false
 	}
 
-	return false
+	return true
 }
 
 // SelectValidator selects next validator (satisfies core.Consensus interface)
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+// ErrNonPositiveAmount is returned for transactions that transfer nothing or a
+// negative amount
+var ErrNonPositiveAmount = errors.New("transaction amount must be positive")
+
+// ErrLegacyTransaction is returned for new transactions using the legacy encoding,
+// whose ID does not cover the nonce and could be replayed under any nonce
+var ErrLegacyTransaction = errors.New("legacy transactions are no longer accepted")
+
+// NonceError is returned for a transaction that does not use its sender's next
+// nonce, either replaying a used one or skipping ahead
+type NonceError struct {
+	Address  string
+	Expected uint64
+	Got      uint64
+}
+
+func (e *NonceError) Error() string {
+	return fmt.Sprintf("invalid nonce for %s: expected %d, got %d", e.Address, e.Expected, e.Got)
+}
+
+// BalanceError is returned for a transaction its sender cannot pay for on top of
+// what the sender's pending transactions will spend
+type BalanceError struct {
+	Address string
+	Balance bnm.Amount
+	Amount  bnm.Amount
+	Fee     bnm.Amount
+	Pending bnm.Amount
+}
+
+// Required returns what the sender's balance must cover
+func (e *BalanceError) Required() bnm.Amount {
+	return e.Pending + e.Amount + e.Fee
+}
+
+func (e *BalanceError) Error() string {
+	return fmt.Sprintf("insufficient balance for %s: %s required, %s available", e.Address, e.Required(), e.Balance)
+}
+
+// CheckAdmission checks that a transaction whose signature has been verified may
+// join the mempool: it is not a legacy transaction, transfers a positive amount,
+// uses its sender's next nonce
+// and its sender can pay its amount and fee on top of what the sender's pending
+// transactions will spend. Nothing is charged until a block includes it.
+func CheckAdmission(mempool *Mempool, state AccountState, tx Transaction) error {
+	if tx.Version == LegacyEncodingVersion {
+		return ErrLegacyTransaction
+	}
+	if tx.Amount <= 0 {
+		return ErrNonPositiveAmount
+	}
+
+	// Reject replayed (stale) and out-of-order (future) nonces
+	if expected := mempool.NextNonce(tx.From, state.GetNonce(tx.From)); tx.Nonce != expected {
+		return &NonceError{Address: tx.From, Expected: expected, Got: tx.Nonce}
+	}
+
+	balance := state.GetBalance(tx.From)
+	pending := mempool.PendingSpend(tx.From)
+	fee := tx.CalculateFee()
+	if balance < pending+tx.Amount+fee {
+		return &BalanceError{Address: tx.From, Balance: balance, Amount: tx.Amount, Fee: fee, Pending: pending}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import "errors"
+
+const (
+	// DefaultBlockPageSize is the number of blocks returned when no limit is given
+	DefaultBlockPageSize = 100
+
+	// MaxBlockPageSize is the largest page of blocks a query may request
+	MaxBlockPageSize = 500
+)
+
+// ErrBlockNotFound is returned for blocks that are not in the canonical chain
+var ErrBlockNotFound = errors.New("block not found")
+
+// BlockQuery selects a page of consecutive canonical blocks, in height order
+type BlockQuery struct {
+	From  uint64 // Height of the first block
+	Limit int
+}
+
+// limit returns the page size, applying the default and maximum
+func (q BlockQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultBlockPageSize
+	}
+	if q.Limit > MaxBlockPageSize {
+		return MaxBlockPageSize
+	}
+	return q.Limit
+}
+
+// BlockReader reads blocks of the canonical chain without loading all of it
+type BlockReader interface {
+	// GetBlocks returns a page of blocks starting at the queried height, which is
+	// empty past the tip
+	GetBlocks(query BlockQuery) ([]Block, error)
+	// GetBlockByHash returns a canonical block by hash
+	GetBlockByHash(hash string) (Block, error)
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/igo-used/binomena/wallet"
+)
+
+// SignBlock sets the block's validator to the producer's address, computes the block
+// hash and signs it with the producer's key
+func SignBlock(block *Block, producer *wallet.Wallet) error {
+	if producer == nil {
+		return fmt.Errorf("block producer wallet is required")
+	}
+
+	block.Validator = producer.Address
+	block.PublicKey = producer.ExportPublicKey()
+	block.Hash = CalculateHash(*block)
+
+	signature, err := producer.Sign([]byte(block.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to sign block: %v", err)
+	}
+	block.Signature = hex.EncodeToString(signature)
+
+	return nil
+}
+
+// VerifyBlockSignature checks that the block hash was signed by the key behind the
+// block's validator address
+func VerifyBlockSignature(block Block) error {
+	return verifyProducerSignature(block.Header(), CalculateHash(block))
+}
+
+// VerifyBlockHeader checks a header's hash and producer signature without its body.
+// Headers of blocks older than MerkleEncodingVersion hash the full transaction list,
+// so they can only be checked once the body arrives and are accepted here.
+func VerifyBlockHeader(header BlockHeader) error {
+	if header.Version < MerkleEncodingVersion {
+		return nil
+	}
+
+	hashed := sha256.Sum256(EncodeBlockHeader(header))
+	return verifyProducerSignature(header, hex.EncodeToString(hashed[:]))
+}
+
+// verifyProducerSignature checks a header against its recomputed hash and the key
+// behind its validator address
+func verifyProducerSignature(header BlockHeader, hash string) error {
+	if header.PublicKey == "" {
+		return fmt.Errorf("block %d has no producer public key", header.Index)
+	}
+
+	publicKey, err := wallet.DecodePublicKey(header.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	address, err := wallet.AddressFromPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if address != header.Validator {
+		return fmt.Errorf("producer public key does not match validator %s", header.Validator)
+	}
+
+	if hash != header.Hash {
+		return fmt.Errorf("invalid block hash")
+	}
+
+	signature, err := hex.DecodeString(header.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid block signature encoding: %v", err)
+	}
+	if !wallet.VerifySignature(publicKey, []byte(header.Hash), signature) {
+		return fmt.Errorf("invalid block signature")
+	}
+
+	return nil
+}
@@ -8,7 +8,6 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 )
 
 // Block represents a block in the blockchain
@@ -20,35 +19,79 @@ type Block struct {
 	Hash         string        `json:"hash"`
 	Validator    string        `json:"validator"`
 	Signature    string        `json:"signature"`
+	PublicKey    string        `json:"publicKey,omitempty"`  // Producer's public key for signature verification
+	Version      uint32        `json:"version,omitempty"`    // Hash encoding version, 0 for legacy blocks
+	MerkleRoot   string        `json:"merkleRoot,omitempty"` // Merkle root of the block's transactions
+	StateRoot    string        `json:"stateRoot,omitempty"`  // State root after applying the block
 }
 
-// Blockchain represents the blockchain
+// BlockHeader holds the fields of a block that are covered by its hash, without the
+// transactions themselves
+type BlockHeader struct {
+	Index            uint64 `json:"index"`
+	PreviousHash     string `json:"previousHash"`
+	Timestamp        int64  `json:"timestamp"`
+	Validator        string `json:"validator"`
+	TransactionCount uint32 `json:"transactionCount"`
+	MerkleRoot       string `json:"merkleRoot"`
+	StateRoot        string `json:"stateRoot,omitempty"`
+	Version          uint32 `json:"version"`
+	Hash             string `json:"hash"`
+	Signature        string `json:"signature"`
+	PublicKey        string `json:"publicKey,omitempty"`
+}
+
+// Blockchain represents the blockchain. Besides the canonical chain it keeps every
+// valid block it has seen, so competing branches can take over when fork choice
+// prefers them.
 type Blockchain struct {
 	chain        []Block
-	transactions []Transaction
+	blocks       map[string]Block   // All known blocks by hash, including side branches
+	txIndex      *TxIndex           // Transactions of the canonical chain
+	receipts     map[string]Receipt // Receipts of included, failed and dropped transactions
+	mempool      *Mempool
+	stateApplier StateApplier
+	finality     FinalityGadget
+	observer     ChainObserver
 	mu           sync.RWMutex
 }
 
-// NewBlockchain creates a new blockchain with a genesis block
-func NewBlockchain() *Blockchain {
-	bc := &Blockchain{
-		chain:        []Block{},
-		transactions: []Transaction{},
-	}
+// GenesisTimestamp is the fixed timestamp of the genesis block
+const GenesisTimestamp int64 = 1735689600
 
-	// Create genesis block
+// GenesisBlock returns the genesis block every node starts from. Its contents are
+// fixed so that nodes on any storage backend agree on the genesis hash.
+func GenesisBlock() Block {
 	genesisBlock := Block{
 		Index:        0,
 		PreviousHash: "0",
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    GenesisTimestamp,
 		Data:         []Transaction{},
 		Hash:         "",
 		Validator:    "genesis",
 		Signature:    "genesis",
+		Version:      CurrentBlockVersion,
 	}
 
+	genesisBlock.MerkleRoot = CalculateMerkleRoot(genesisBlock.Data)
 	genesisBlock.Hash = CalculateHash(genesisBlock)
+	return genesisBlock
+}
+
+// NewBlockchain creates a new blockchain with a genesis block
+func NewBlockchain() *Blockchain {
+	bc := &Blockchain{
+		chain:    []Block{},
+		blocks:   make(map[string]Block),
+		txIndex:  NewTxIndex(),
+		receipts: make(map[string]Receipt),
+		mempool:  NewMempool(nil),
+	}
+
+	genesisBlock := GenesisBlock()
 	bc.chain = append(bc.chain, genesisBlock)
+	bc.blocks[genesisBlock.Hash] = genesisBlock
+	bc.indexBlock(genesisBlock)
 
 	return bc
 }
@@ -56,64 +99,388 @@ func NewBlockchain() *Blockchain {
 // NewBlockchainWithGenesis creates a new blockchain with a specific genesis block
 func NewBlockchainWithGenesis(genesisBlock Block) *Blockchain {
 	bc := &Blockchain{
-		chain:        []Block{},
-		transactions: []Transaction{},
+		chain:    []Block{},
+		blocks:   make(map[string]Block),
+		txIndex:  NewTxIndex(),
+		receipts: make(map[string]Receipt),
+		mempool:  NewMempool(nil),
 	}
 
 	// Add the genesis block
 	bc.chain = append(bc.chain, genesisBlock)
+	bc.blocks[genesisBlock.Hash] = genesisBlock
+	bc.indexBlock(genesisBlock)
 
 	return bc
 }
 
-// ReplaceChain safely replaces the blockchain's chain with a new one
-func (bc *Blockchain) ReplaceChain(newChain []Block) {
+// ReplaceChain safely replaces the blockchain's chain with a new one, reverting the
+// state changes of the blocks it drops and applying those of the blocks it adds.
+// Chains that do not contain the last irreversible block or fail validation are
+// refused.
+func (bc *Blockchain) ReplaceChain(newChain []Block) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	if err := checkIrreversible(bc.finality, newChain); err != nil {
+		return err
+	}
+	if err := verifyReplacement(bc.chain, newChain); err != nil {
+		return err
+	}
+	dropped, added := chainDivergence(bc.chain, newChain)
+	if err := switchState(bc.stateApplier, dropped, added); err != nil {
+		return err
+	}
+
+	previous := make(map[string]bool, len(bc.chain))
+	for _, block := range bc.chain {
+		previous[block.Hash] = true
+	}
+
 	bc.chain = make([]Block, len(newChain))
 	copy(bc.chain, newChain)
-	bc.transactions = []Transaction{} // Clear pending transactions
+	bc.indexChain()
+	for _, block := range newChain {
+		bc.mempool.RemoveIncluded(block.Data)
+	}
+	notifyNewBlocks(bc.observer, newChain, previous)
+	bc.updateFinality()
+
+	return nil
+}
+
+// SetStateApplier sets the state applier settling the transactions of blocks as
+// they join or leave the canonical chain
+func (bc *Blockchain) SetStateApplier(applier StateApplier) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.stateApplier = applier
+}
+
+// SetFinalityGadget sets the consensus component tracking the last irreversible
+// block, which reorganizations may never revert
+func (bc *Blockchain) SetFinalityGadget(finality FinalityGadget) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.finality = finality
+	bc.updateFinality()
 }
 
-// AddBlock adds a new block to the blockchain
+// SetChainObserver sets the observer notified of new canonical blocks and
+// accepted transactions
+func (bc *Blockchain) SetChainObserver(observer ChainObserver) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.observer = observer
+}
+
+// AddBlock adds a new block to the block tree. Blocks extending the canonical tip
+// are appended; blocks extending any other known block start or grow a side branch,
+// which becomes canonical through a reorganization once fork choice prefers it.
 func (bc *Blockchain) AddBlock(block Block) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
-	lastBlock := bc.chain[len(bc.chain)-1]
+	if _, ok := bc.blocks[block.Hash]; ok {
+		return ErrKnownBlock
+	}
 
-	// Validate block
-	if block.Index != lastBlock.Index+1 {
+	// Validate block against its parent
+	parent, ok := bc.blocks[block.PreviousHash]
+	if !ok {
+		return fmt.Errorf("invalid previous hash")
+	}
+
+	if block.Index != parent.Index+1 {
 		return fmt.Errorf("invalid block index")
 	}
 
-	if block.PreviousHash != lastBlock.Hash {
-		return fmt.Errorf("invalid previous hash")
+	if err := validateBlockContents(block); err != nil {
+		return err
+	}
+
+	// Extend the canonical chain once the block's transactions are settled
+	if block.PreviousHash == bc.chain[len(bc.chain)-1].Hash {
+		if err := checkTransactionReplay(parent.Index, []Block{block}, bc.txIndex.Lookup); err != nil {
+			return err
+		}
+		if bc.stateApplier != nil {
+			if err := bc.stateApplier.ApplyBlock(block); err != nil {
+				return err
+			}
+		}
+		bc.blocks[block.Hash] = block
+		bc.chain = append(bc.chain, block)
+		bc.indexBlock(block)
+
+		// Remove transactions that are now in the block
+		bc.mempool.RemoveIncluded(block.Data)
+		if bc.observer != nil {
+			bc.observer.BlockAdded(block)
+		}
+
+		bc.updateFinality()
+		return nil
+	}
+
+	// Otherwise the block is on a side branch, which may not fork off below the
+	// last irreversible block
+	forkIndex, branch := bc.branchFrom(block)
+	if irreversible := bc.irreversibleIndex(); forkIndex < irreversible {
+		return fmt.Errorf("block forks below the last irreversible block #%d", irreversible)
+	}
+	if err := checkTransactionReplay(forkIndex, branch, bc.txIndex.Lookup); err != nil {
+		return err
 	}
 
+	bc.blocks[block.Hash] = block
+	if !preferBranch(branch, bc.chain[forkIndex+1:]) {
+		return nil
+	}
+	if err := bc.reorganize(forkIndex, branch); err != nil {
+		return err
+	}
+
+	bc.updateFinality()
+	return nil
+}
+
+// validateBlockContents checks a block's encoding version, hash, Merkle root,
+// transaction amounts and transaction signatures
+func validateBlockContents(block Block) error {
 	// Verify block hash
+	if err := checkBlockVersion(block); err != nil {
+		return err
+	}
 	calculatedHash := CalculateHash(block)
 	if calculatedHash != block.Hash {
 		return fmt.Errorf("invalid block hash")
 	}
+	if err := verifyMerkleRoot(block); err != nil {
+		return err
+	}
 
-	// Verify transaction prefixes
+	// Verify transaction prefixes and signatures
 	for _, tx := range block.Data {
-		if tx.ID[:4] != "AdNe" {
+		if len(tx.ID) < 4 || tx.ID[:4] != "AdNe" {
 			return fmt.Errorf("transaction ID must start with 'AdNe'")
 		}
+		if tx.Amount <= 0 {
+			return fmt.Errorf("transaction %s has a non-positive amount", tx.ID)
+		}
+		if err := VerifyBlockTransaction(block, &tx); err != nil {
+			return fmt.Errorf("invalid transaction %s: %v", tx.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// checkTransactionReplay rejects a branch that includes a transaction twice, or one
+// the canonical chain already includes at or below the fork height, so that a
+// transaction settles at most once
+func checkTransactionReplay(forkIndex uint64, branch []Block, lookup func(id string) (TxLocation, bool)) error {
+	seen := make(map[string]bool)
+	for _, block := range branch {
+		for _, tx := range block.Data {
+			if seen[tx.ID] {
+				return fmt.Errorf("transaction %s is included twice", tx.ID)
+			}
+			seen[tx.ID] = true
+			if location, ok := lookup(tx.ID); ok && location.BlockIndex <= forkIndex {
+				return fmt.Errorf("transaction %s is already included in block #%d", tx.ID, location.BlockIndex)
+			}
+		}
+	}
+	return nil
+}
+
+// notIncluded is the transaction lookup of a chain checked on its own
+func notIncluded(string) (TxLocation, bool) {
+	return TxLocation{}, false
+}
+
+// VerifyChain checks that blocks form a chain from a genesis block: indexes follow
+// each other, each block links to the hash of the previous one and every block's
+// hash, Merkle root and transaction signatures are valid, with no transaction
+// included twice
+func VerifyChain(blocks []Block) error {
+	return verifyChainFrom(blocks, 0)
+}
+
+// verifyReplacement checks a chain replacing the current one before any state is
+// switched to it. Blocks shared with the current chain were validated when they
+// joined it, so only the contents of the blocks after them are checked again.
+func verifyReplacement(current, replacement []Block) error {
+	common := 0
+	for common < len(current) && common < len(replacement) && current[common].Hash == replacement[common].Hash {
+		common++
+	}
+	return verifyChainFrom(replacement, common)
+}
+
+// verifyChainFrom checks the links and transaction uniqueness of a whole chain and
+// the contents of its blocks from a height on
+func verifyChainFrom(blocks []Block, from int) error {
+	if len(blocks) == 0 {
+		return fmt.Errorf("chain is empty")
+	}
+	for i, block := range blocks {
+		if block.Index != uint64(i) {
+			return fmt.Errorf("block #%d found at height %d", block.Index, i)
+		}
+		if i > 0 && block.PreviousHash != blocks[i-1].Hash {
+			return fmt.Errorf("block #%d does not link to the previous block", block.Index)
+		}
+		if i < from {
+			continue
+		}
+		if err := validateBlockContents(block); err != nil {
+			return fmt.Errorf("block #%d: %v", block.Index, err)
+		}
+	}
+	return checkTransactionReplay(0, blocks, notIncluded)
+}
+
+// irreversibleIndex returns the index of the last irreversible block
+func (bc *Blockchain) irreversibleIndex() uint64 {
+	if bc.finality == nil {
+		return 0
+	}
+	return bc.finality.GetLastIrreversibleBlock().Index
+}
+
+// updateFinality lets the finality gadget advance over the canonical chain
+func (bc *Blockchain) updateFinality() {
+	if bc.finality == nil {
+		return
+	}
+	irreversible := bc.irreversibleIndex()
+	if irreversible >= uint64(len(bc.chain)) {
+		return
+	}
+	bc.finality.UpdateIrreversibleBlock(bc.chain[irreversible:])
+}
+
+// checkIrreversible rejects a replacement chain that does not contain the last
+// irreversible block
+func checkIrreversible(finality FinalityGadget, newChain []Block) error {
+	if finality == nil {
+		return nil
+	}
+	irreversible := finality.GetLastIrreversibleBlock()
+	if irreversible.Hash == "" {
+		return nil
+	}
+	if irreversible.Index >= uint64(len(newChain)) || newChain[irreversible.Index].Hash != irreversible.Hash {
+		return fmt.Errorf("new chain does not contain the last irreversible block #%d", irreversible.Index)
+	}
+	return nil
+}
+
+// branchFrom walks back from a block to the canonical chain, returning the index of
+// the common ancestor and the branch blocks after it in chain order
+func (bc *Blockchain) branchFrom(tip Block) (uint64, []Block) {
+	branch := []Block{}
+	current := tip
+	for current.Index >= uint64(len(bc.chain)) || bc.chain[current.Index].Hash != current.Hash {
+		branch = append([]Block{current}, branch...)
+		current = bc.blocks[current.PreviousHash]
 	}
+	return current.Index, branch
+}
 
-	// Add block to chain
-	bc.chain = append(bc.chain, block)
+// reorganize replaces the canonical blocks after forkIndex with a branch, reverting
+// the state changes of the old blocks and applying those of the new ones. If the
+// branch cannot be applied the old chain is restored.
+func (bc *Blockchain) reorganize(forkIndex uint64, branch []Block) error {
+	oldBlocks := append([]Block{}, bc.chain[forkIndex+1:]...)
 
-	// Remove transactions that are now in the block
-	bc.transactions = []Transaction{}
+	if err := switchState(bc.stateApplier, oldBlocks, branch); err != nil {
+		return err
+	}
+
+	bc.chain = append(bc.chain[:forkIndex+1:forkIndex+1], branch...)
+	for i := len(oldBlocks) - 1; i >= 0; i-- {
+		bc.unindexBlock(oldBlocks[i])
+	}
+	for _, block := range branch {
+		bc.indexBlock(block)
+	}
+
+	// Transactions dropped with the old branch go back to the mempool
+	included := make(map[string]bool)
+	for _, block := range branch {
+		bc.mempool.RemoveIncluded(block.Data)
+		for _, tx := range block.Data {
+			included[tx.ID] = true
+		}
+	}
+	for _, block := range oldBlocks {
+		for _, tx := range block.Data {
+			if !included[tx.ID] {
+				bc.mempool.Add(tx)
+			}
+		}
+	}
 
+	if bc.observer != nil {
+		for _, block := range branch {
+			bc.observer.BlockAdded(block)
+		}
+	}
+
+	fmt.Printf("Reorganized chain at block #%d: %d blocks replaced by %d\n", forkIndex, len(oldBlocks), len(branch))
 	return nil
 }
 
+// indexChain rebuilds the block and transaction indexes from the canonical chain.
+// Receipts of transactions still included in the same block are kept.
+func (bc *Blockchain) indexChain() {
+	previous := bc.receipts
+	bc.blocks = make(map[string]Block, len(bc.chain))
+	bc.txIndex = NewTxIndex()
+	bc.receipts = make(map[string]Receipt, len(previous))
+	for id, receipt := range previous {
+		if receipt.Status != ReceiptIncluded {
+			bc.receipts[id] = receipt
+		}
+	}
+	for _, block := range bc.chain {
+		bc.blocks[block.Hash] = block
+		bc.indexBlock(block)
+		for _, tx := range block.Data {
+			if receipt, ok := previous[tx.ID]; ok && receipt.Status == ReceiptIncluded && receipt.BlockHash == block.Hash {
+				bc.receipts[tx.ID] = receipt
+			}
+		}
+	}
+}
+
+// indexBlock indexes a block appended to the canonical chain and records the
+// receipts of its transactions
+func (bc *Blockchain) indexBlock(block Block) {
+	bc.txIndex.AddBlock(block)
+	fees := paidFees(bc.stateApplier, block)
+	for position := range block.Data {
+		bc.receipts[block.Data[position].ID] = newIncludedReceipt(block, position, fees)
+	}
+}
+
+// unindexBlock drops a block removed from the tip of the canonical chain
+func (bc *Blockchain) unindexBlock(block Block) {
+	bc.txIndex.RemoveBlock(block)
+	for _, tx := range block.Data {
+		if receipt, ok := bc.receipts[tx.ID]; ok && receipt.BlockHash == block.Hash {
+			delete(bc.receipts, tx.ID)
+		}
+	}
+}
+
 // GetLastBlock returns the last block in the blockchain
 func (bc *Blockchain) GetLastBlock() Block {
 	bc.mu.RLock()
@@ -129,19 +496,29 @@ func (bc *Blockchain) AddTransaction(tx Transaction) error {
 		return fmt.Errorf("transaction ID must start with 'AdNe'")
 	}
 
-	bc.mu.Lock()
-	defer bc.mu.Unlock()
+	// Reject replays of a transaction that is already waiting
+	err := bc.mempool.Add(tx)
+	bc.recordDropped()
+	if err != nil {
+		return err
+	}
 
-	bc.transactions = append(bc.transactions, tx)
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.observer != nil {
+		bc.observer.TransactionAdded(tx)
+	}
 	return nil
 }
 
-// GetPendingTransactions returns all pending transactions
+// GetPendingTransactions returns all pending transactions, each sender's in nonce order
 func (bc *Blockchain) GetPendingTransactions() []Transaction {
-	bc.mu.RLock()
-	defer bc.mu.RUnlock()
+	return bc.mempool.Pending()
+}
 
-	return bc.transactions
+// Mempool returns the pool of pending transactions
+func (bc *Blockchain) Mempool() *Mempool {
+	return bc.mempool
 }
 
 // GetBlockCount returns the number of blocks in the blockchain
@@ -176,13 +553,136 @@ func (bc *Blockchain) GetBlockByIndex(index uint64) (Block, error) {
 	return bc.chain[index], nil
 }
 
-// CalculateHash calculates the hash of a block
+// GetBlocks returns a page of canonical blocks starting at the queried height
+func (bc *Blockchain) GetBlocks(query BlockQuery) ([]Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	height := uint64(len(bc.chain))
+	if query.From >= height {
+		return []Block{}, nil
+	}
+	end := query.From + uint64(query.limit())
+	if end > height {
+		end = height
+	}
+
+	page := make([]Block, end-query.From)
+	copy(page, bc.chain[query.From:end])
+	return page, nil
+}
+
+// GetBlockByHash returns a canonical block by hash
+func (bc *Blockchain) GetBlockByHash(hash string) (Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	// Known blocks include side branches, which are not served
+	block, ok := bc.blocks[hash]
+	if !ok || block.Index >= uint64(len(bc.chain)) || bc.chain[block.Index].Hash != hash {
+		return Block{}, ErrBlockNotFound
+	}
+	return block, nil
+}
+
+// GetTransaction returns a transaction included in the canonical chain by ID
+func (bc *Blockchain) GetTransaction(id string) (IndexedTransaction, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	location, ok := bc.txIndex.Lookup(id)
+	if !ok {
+		return IndexedTransaction{}, ErrTransactionNotFound
+	}
+	return newIndexedTransaction(bc.chain[location.BlockIndex], location.Position), nil
+}
+
+// GetAddressTransactions returns a page of an address's included transactions,
+// newest first, and the number of transactions matching the query
+func (bc *Blockchain) GetAddressTransactions(address string, query TxQuery) ([]IndexedTransaction, int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	locations := bc.txIndex.AddressLocations(address)
+	page := []IndexedTransaction{}
+	total := 0
+	for i := len(locations) - 1; i >= 0; i-- {
+		block := bc.chain[locations[i].BlockIndex]
+		if !query.matches(block.Data[locations[i].Position], address) {
+			continue
+		}
+		if total >= query.Offset && len(page) < query.limit() {
+			page = append(page, newIndexedTransaction(block, locations[i].Position))
+		}
+		total++
+	}
+	return page, total, nil
+}
+
+// GetReceipt returns the receipt of a transaction that is included, pending, failed
+// or dropped
+func (bc *Blockchain) GetReceipt(id string) (Receipt, error) {
+	bc.recordDropped()
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	receipt, ok := bc.receipts[id]
+	if ok && receipt.Status == ReceiptIncluded {
+		return receipt.withConfirmations(bc.chain[len(bc.chain)-1]), nil
+	}
+	if tx, pending := bc.mempool.Get(id); pending {
+		return newPendingReceipt(tx), nil
+	}
+	if ok {
+		return receipt, nil
+	}
+	return Receipt{}, ErrTransactionNotFound
+}
+
+// RecordReceipt stores the receipt of a failed or dropped transaction
+func (bc *Blockchain) RecordReceipt(receipt Receipt) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if existing, ok := bc.receipts[receipt.TxID]; ok && existing.Status == ReceiptIncluded {
+		return nil
+	}
+	bc.receipts[receipt.TxID] = receipt
+	return nil
+}
+
+// recordDropped stores receipts for the transactions the mempool dropped
+func (bc *Blockchain) recordDropped() {
+	for _, dropped := range bc.mempool.TakeDropped() {
+		bc.RecordReceipt(newDroppedReceipt(dropped))
+	}
+}
+
+// Header returns the block's header
+func (b Block) Header() BlockHeader {
+	return BlockHeader{
+		Index:            b.Index,
+		PreviousHash:     b.PreviousHash,
+		Timestamp:        b.Timestamp,
+		Validator:        b.Validator,
+		TransactionCount: uint32(len(b.Data)),
+		MerkleRoot:       b.MerkleRoot,
+		StateRoot:        b.StateRoot,
+		Version:          b.Version,
+		Hash:             b.Hash,
+		Signature:        b.Signature,
+		PublicKey:        b.PublicKey,
+	}
+}
+
+// CalculateHash calculates the hash of a block using the encoding named by its version
 func CalculateHash(block Block) string {
-	record := fmt.Sprintf("%d%s%d%v%s", block.Index, block.PreviousHash, block.Timestamp, block.Data, block.Validator)
-	h := sha256.New()
-	h.Write([]byte(record))
-	hashed := h.Sum(nil)
-	return hex.EncodeToString(hashed)
+	if block.Version == LegacyEncodingVersion {
+		return legacyBlockHash(block)
+	}
+	hashed := sha256.Sum256(EncodeBlock(block))
+	return hex.EncodeToString(hashed[:])
 }
 
 // SaveChain saves the blockchain to disk
@@ -209,7 +709,7 @@ func (bc *Blockchain) SaveChain(dataDir string) error {
 	}
 
 	// Save pending transactions
-	txData, err := json.MarshalIndent(bc.transactions, "", "  ")
+	txData, err := json.MarshalIndent(bc.mempool.Pending(), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal transactions: %v", err)
 	}
@@ -219,6 +719,17 @@ func (bc *Blockchain) SaveChain(dataDir string) error {
 		return fmt.Errorf("failed to write transactions file: %v", err)
 	}
 
+	// Save receipts
+	receiptData, err := json.MarshalIndent(bc.receipts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipts: %v", err)
+	}
+
+	receiptFile := filepath.Join(blockchainDir, "receipts.json")
+	if err := os.WriteFile(receiptFile, receiptData, 0644); err != nil {
+		return fmt.Errorf("failed to write receipts file: %v", err)
+	}
+
 	fmt.Printf("Saved blockchain with %d blocks\n", len(bc.chain))
 	return nil
 }
@@ -248,17 +759,30 @@ func (bc *Blockchain) LoadChain(dataDir string) error {
 		return fmt.Errorf("failed to unmarshal blockchain: %v", err)
 	}
 
+	// Load receipts, which indexing keeps for transactions still in their block
+	receiptFile := filepath.Join(blockchainDir, "receipts.json")
+	if receiptData, err := os.ReadFile(receiptFile); err == nil {
+		var receipts map[string]Receipt
+		if json.Unmarshal(receiptData, &receipts) == nil && receipts != nil {
+			bc.receipts = receipts
+		}
+	}
+
 	// Only replace chain if it's valid
 	if len(chain) > 0 {
 		bc.chain = chain
+		bc.indexChain()
 	}
 
 	// Load pending transactions
 	txFile := filepath.Join(blockchainDir, "pending_tx.json")
 	if _, err := os.Stat(txFile); !os.IsNotExist(err) {
 		txData, err := os.ReadFile(txFile)
-		if err == nil {
-			json.Unmarshal(txData, &bc.transactions)
+		var pending []Transaction
+		if err == nil && json.Unmarshal(txData, &pending) == nil {
+			for _, tx := range pending {
+				bc.mempool.Add(tx)
+			}
 		}
 	}
 
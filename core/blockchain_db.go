@@ -5,22 +5,25 @@ import (
 	"fmt"
 	"log"
 	"sync"
-	"time"
 
 	"github.com/igo-used/binomena/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BlockchainDB represents the database-backed blockchain
 type BlockchainDB struct {
-	transactions []Transaction
+	mempool      *Mempool
+	stateApplier StateApplier
+	finality     FinalityGadget
+	observer     ChainObserver
 	mu           sync.RWMutex
 }
 
 // NewBlockchainWithDB creates a new database-backed blockchain with a genesis block
 func NewBlockchainWithDB() *BlockchainDB {
 	bc := &BlockchainDB{
-		transactions: []Transaction{},
+		mempool: NewMempool(nil),
 	}
 
 	// Check if genesis block exists
@@ -28,18 +31,7 @@ func NewBlockchainWithDB() *BlockchainDB {
 	database.DB.Model(&database.Block{}).Count(&count)
 
 	if count == 0 {
-		// Create genesis block
-		genesisBlock := Block{
-			Index:        0,
-			PreviousHash: "0",
-			Timestamp:    time.Now().Unix(),
-			Data:         []Transaction{},
-			Hash:         "",
-			Validator:    "genesis",
-			Signature:    "genesis",
-		}
-
-		genesisBlock.Hash = CalculateHash(genesisBlock)
+		genesisBlock := GenesisBlock()
 
 		// Save genesis block to database
 		if err := bc.saveBlockToDB(genesisBlock); err != nil {
@@ -64,10 +56,14 @@ func (bc *BlockchainDB) saveBlockToDB(block Block) error {
 		Index:        block.Index,
 		PreviousHash: block.PreviousHash,
 		Timestamp:    block.Timestamp,
-		Data:         string(transactionsJSON),
+		Data:         database.JSON(transactionsJSON),
 		Hash:         block.Hash,
 		Validator:    block.Validator,
 		Signature:    block.Signature,
+		PublicKey:    block.PublicKey,
+		Version:      block.Version,
+		MerkleRoot:   block.MerkleRoot,
+		StateRoot:    block.StateRoot,
 	}
 
 	if err := database.DB.Create(&dbBlock).Error; err != nil {
@@ -81,8 +77,11 @@ func (bc *BlockchainDB) saveBlockToDB(block Block) error {
 			FromAddr:  tx.From,
 			ToAddr:    tx.To,
 			Amount:    tx.Amount,
+			Nonce:     tx.Nonce,
 			Timestamp: tx.Timestamp,
 			Signature: tx.Signature,
+			PublicKey: tx.PublicKey,
+			Version:   tx.Version,
 			BlockID:   &dbBlock.ID,
 		}
 
@@ -91,9 +90,65 @@ func (bc *BlockchainDB) saveBlockToDB(block Block) error {
 		}
 	}
 
+	// Save receipts of the included transactions
+	fees := paidFees(bc.stateApplier, block)
+	for position := range block.Data {
+		if err := saveReceipt(database.DB, newIncludedReceipt(block, position, fees)); err != nil {
+			log.Printf("Warning: Failed to save receipt of %s: %v", block.Data[position].ID, err)
+		}
+	}
+
 	return nil
 }
 
+// saveReceipt inserts a receipt or replaces the stored receipt of its transaction
+func saveReceipt(db *gorm.DB, receipt Receipt) error {
+	dbReceipt := database.Receipt{
+		TxID:       receipt.TxID,
+		Status:     string(receipt.Status),
+		BlockIndex: receipt.BlockIndex,
+		BlockHash:  receipt.BlockHash,
+		Position:   receipt.Position,
+		Fee:        receipt.Fee,
+		Error:      receipt.Error,
+		Timestamp:  receipt.Timestamp,
+	}
+	if receipt.FeeDistribution != nil {
+		distributionJSON, err := json.Marshal(receipt.FeeDistribution)
+		if err != nil {
+			return fmt.Errorf("failed to serialize fee distribution: %v", err)
+		}
+		dbReceipt.FeeDistribution = database.JSON(distributionJSON)
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "block_index", "block_hash", "position", "fee", "fee_distribution", "error", "timestamp"}),
+	}).Create(&dbReceipt).Error
+}
+
+// loadReceiptFromDB converts a stored receipt
+func loadReceiptFromDB(dbReceipt database.Receipt) (Receipt, error) {
+	receipt := Receipt{
+		TxID:       dbReceipt.TxID,
+		Status:     ReceiptStatus(dbReceipt.Status),
+		BlockIndex: dbReceipt.BlockIndex,
+		BlockHash:  dbReceipt.BlockHash,
+		Position:   dbReceipt.Position,
+		Fee:        dbReceipt.Fee,
+		Error:      dbReceipt.Error,
+		Timestamp:  dbReceipt.Timestamp,
+	}
+	if dbReceipt.FeeDistribution != "" {
+		var distribution FeeDistribution
+		if err := json.Unmarshal([]byte(dbReceipt.FeeDistribution), &distribution); err != nil {
+			return Receipt{}, fmt.Errorf("failed to deserialize fee distribution: %v", err)
+		}
+		receipt.FeeDistribution = &distribution
+	}
+	return receipt, nil
+}
+
 // loadBlockFromDB loads a block from the database
 func (bc *BlockchainDB) loadBlockFromDB(dbBlock database.Block) (Block, error) {
 	// Deserialize transactions from JSON
@@ -110,17 +165,21 @@ func (bc *BlockchainDB) loadBlockFromDB(dbBlock database.Block) (Block, error) {
 		Hash:         dbBlock.Hash,
 		Validator:    dbBlock.Validator,
 		Signature:    dbBlock.Signature,
+		PublicKey:    dbBlock.PublicKey,
+		Version:      dbBlock.Version,
+		MerkleRoot:   dbBlock.MerkleRoot,
+		StateRoot:    dbBlock.StateRoot,
 	}, nil
 }
 
-// AddBlock adds a new block to the blockchain database
+// AddBlock adds a new block to the blockchain database once its transactions are settled
 func (bc *BlockchainDB) AddBlock(block Block) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
-	// Get the last block from database
+	// Get the last block from database, quoting index as it is a keyword in SQLite
 	var lastDBBlock database.Block
-	result := database.DB.Order("index desc").First(&lastDBBlock)
+	result := database.DB.Order(`"index" desc`).First(&lastDBBlock)
 	if result.Error != nil {
 		return fmt.Errorf("failed to get last block: %v", result.Error)
 	}
@@ -134,64 +193,104 @@ func (bc *BlockchainDB) AddBlock(block Block) error {
 		return fmt.Errorf("invalid previous hash")
 	}
 
-	// Verify block hash
-	calculatedHash := CalculateHash(block)
-	if calculatedHash != block.Hash {
-		return fmt.Errorf("invalid block hash")
+	if err := validateBlockContents(block); err != nil {
+		return err
 	}
-
-	// Verify transaction prefixes
-	for _, tx := range block.Data {
-		if len(tx.ID) < 4 || tx.ID[:4] != "AdNe" {
-			return fmt.Errorf("transaction ID must start with 'AdNe'")
-		}
+	if err := checkTransactionReplay(lastDBBlock.Index, []Block{block}, bc.lookupTransaction); err != nil {
+		return err
 	}
 
-	// Save block to database
+	// Settle the block's transactions, reverting them if the block cannot be saved
+	if err := switchState(bc.stateApplier, nil, []Block{block}); err != nil {
+		return err
+	}
 	if err := bc.saveBlockToDB(block); err != nil {
+		restoreState(bc.stateApplier, []Block{block}, nil)
 		return err
 	}
 
-	// Clear pending transactions
-	bc.transactions = []Transaction{}
+	// Remove transactions that are now in the block
+	bc.mempool.RemoveIncluded(block.Data)
+	if bc.observer != nil {
+		bc.observer.BlockAdded(block)
+	}
 
+	bc.updateFinality()
 	return nil
 }
 
+// SetStateApplier sets the state applier settling the transactions of blocks as
+// they join or leave the chain
+func (bc *BlockchainDB) SetStateApplier(applier StateApplier) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.stateApplier = applier
+}
+
+// SetFinalityGadget sets the consensus component tracking the last irreversible
+// block, which chain replacements may never revert
+func (bc *BlockchainDB) SetFinalityGadget(finality FinalityGadget) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.finality = finality
+	bc.updateFinality()
+}
+
+// SetChainObserver sets the observer notified of new canonical blocks and
+// accepted transactions
+func (bc *BlockchainDB) SetChainObserver(observer ChainObserver) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.observer = observer
+}
+
+// updateFinality lets the finality gadget advance over the blocks after the last
+// irreversible block
+func (bc *BlockchainDB) updateFinality() {
+	if bc.finality == nil {
+		return
+	}
+
+	var dbBlocks []database.Block
+	irreversible := bc.finality.GetLastIrreversibleBlock().Index
+	if err := database.DB.Where(`"index" >= ?`, irreversible).Order(`"index" asc`).Find(&dbBlocks).Error; err != nil {
+		log.Printf("Error loading blocks for finality: %v", err)
+		return
+	}
+
+	head := make([]Block, 0, len(dbBlocks))
+	for _, dbBlock := range dbBlocks {
+		block, err := bc.loadBlockFromDB(dbBlock)
+		if err != nil {
+			log.Printf("Error loading block %d: %v", dbBlock.Index, err)
+			return
+		}
+		head = append(head, block)
+	}
+	bc.finality.UpdateIrreversibleBlock(head)
+}
+
 // GetLastBlock returns the last block in the blockchain
 func (bc *BlockchainDB) GetLastBlock() Block {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
 	var dbBlock database.Block
-	result := database.DB.Order("index desc").First(&dbBlock)
+	result := database.DB.Order(`"index" desc`).First(&dbBlock)
 	if result.Error != nil {
 		log.Printf("Error getting last block: %v", result.Error)
 		// Return genesis block as fallback
-		return Block{
-			Index:        0,
-			PreviousHash: "0",
-			Timestamp:    time.Now().Unix(),
-			Data:         []Transaction{},
-			Hash:         "genesis",
-			Validator:    "genesis",
-			Signature:    "genesis",
-		}
+		return GenesisBlock()
 	}
 
 	block, err := bc.loadBlockFromDB(dbBlock)
 	if err != nil {
 		log.Printf("Error loading block from DB: %v", err)
 		// Return genesis block as fallback
-		return Block{
-			Index:        0,
-			PreviousHash: "0",
-			Timestamp:    time.Now().Unix(),
-			Data:         []Transaction{},
-			Hash:         "genesis",
-			Validator:    "genesis",
-			Signature:    "genesis",
-		}
+		return GenesisBlock()
 	}
 
 	return block
@@ -204,19 +303,92 @@ func (bc *BlockchainDB) AddTransaction(tx Transaction) error {
 		return fmt.Errorf("transaction ID must start with 'AdNe'")
 	}
 
-	bc.mu.Lock()
-	defer bc.mu.Unlock()
+	// Reject replays of a transaction that is already waiting
+	err := bc.mempool.Add(tx)
+	bc.recordDropped()
+	if err != nil {
+		return err
+	}
 
-	bc.transactions = append(bc.transactions, tx)
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.observer != nil {
+		bc.observer.TransactionAdded(tx)
+	}
 	return nil
 }
 
-// GetPendingTransactions returns all pending transactions
-func (bc *BlockchainDB) GetPendingTransactions() []Transaction {
+// GetReceipt returns the receipt of a transaction that is included, pending, failed
+// or dropped
+func (bc *BlockchainDB) GetReceipt(id string) (Receipt, error) {
+	bc.recordDropped()
+
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	return bc.transactions
+	var dbReceipt database.Receipt
+	result := database.DB.Where("tx_id = ?", id).First(&dbReceipt)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return Receipt{}, fmt.Errorf("failed to get receipt: %v", result.Error)
+	}
+	stored := result.Error == nil
+
+	var receipt Receipt
+	if stored {
+		var err error
+		if receipt, err = loadReceiptFromDB(dbReceipt); err != nil {
+			return Receipt{}, err
+		}
+	}
+
+	if stored && receipt.Status == ReceiptIncluded {
+		var tip database.Block
+		if err := database.DB.Order(`"index" desc`).First(&tip).Error; err != nil {
+			return Receipt{}, fmt.Errorf("failed to get last block: %v", err)
+		}
+		return receipt.withConfirmations(Block{Index: tip.Index}), nil
+	}
+	if tx, pending := bc.mempool.Get(id); pending {
+		return newPendingReceipt(tx), nil
+	}
+	if stored {
+		return receipt, nil
+	}
+	return Receipt{}, ErrTransactionNotFound
+}
+
+// RecordReceipt stores the receipt of a failed or dropped transaction
+func (bc *BlockchainDB) RecordReceipt(receipt Receipt) error {
+	var included int64
+	if err := database.DB.Model(&database.Receipt{}).Where("tx_id = ? AND status = ?", receipt.TxID, string(ReceiptIncluded)).Count(&included).Error; err != nil {
+		return fmt.Errorf("failed to get receipt: %v", err)
+	}
+	if included > 0 {
+		return nil
+	}
+	if err := saveReceipt(database.DB, receipt); err != nil {
+		return fmt.Errorf("failed to save receipt: %v", err)
+	}
+	return nil
+}
+
+// recordDropped stores receipts for the transactions the mempool dropped
+func (bc *BlockchainDB) recordDropped() {
+	for _, dropped := range bc.mempool.TakeDropped() {
+		if err := bc.RecordReceipt(newDroppedReceipt(dropped)); err != nil {
+			log.Printf("Error recording dropped transaction %s: %v", dropped.Transaction.ID, err)
+		}
+	}
+}
+
+// GetPendingTransactions returns all pending transactions, each sender's in nonce order
+func (bc *BlockchainDB) GetPendingTransactions() []Transaction {
+	return bc.mempool.Pending()
+}
+
+// Mempool returns the pool of pending transactions
+func (bc *BlockchainDB) Mempool() *Mempool {
+	return bc.mempool
 }
 
 // GetBlockCount returns the number of blocks in the blockchain
@@ -234,8 +406,13 @@ func (bc *BlockchainDB) GetChain() []Block {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
+	return bc.readChain()
+}
+
+// readChain reads every block from database. Callers must hold bc.mu.
+func (bc *BlockchainDB) readChain() []Block {
 	var dbBlocks []database.Block
-	database.DB.Order("index asc").Find(&dbBlocks)
+	database.DB.Order(`"index" asc`).Find(&dbBlocks)
 
 	blocks := make([]Block, len(dbBlocks))
 	for i, dbBlock := range dbBlocks {
@@ -256,7 +433,7 @@ func (bc *BlockchainDB) GetBlockByIndex(index uint64) (Block, error) {
 	defer bc.mu.RUnlock()
 
 	var dbBlock database.Block
-	result := database.DB.Where("index = ?", index).First(&dbBlock)
+	result := database.DB.Where(`"index" = ?`, index).First(&dbBlock)
 	if result.Error == gorm.ErrRecordNotFound {
 		return Block{}, fmt.Errorf("block index out of range")
 	}
@@ -267,11 +444,194 @@ func (bc *BlockchainDB) GetBlockByIndex(index uint64) (Block, error) {
 	return bc.loadBlockFromDB(dbBlock)
 }
 
-// ReplaceChain safely replaces the blockchain's chain with a new one
-func (bc *BlockchainDB) ReplaceChain(newChain []Block) {
+// GetBlocks returns a page of blocks starting at the queried height, reading only
+// the rows of the page
+func (bc *BlockchainDB) GetBlocks(query BlockQuery) ([]Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var dbBlocks []database.Block
+	result := database.DB.Where(`"index" >= ?`, query.From).Order(`"index" asc`).Limit(query.limit()).Find(&dbBlocks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get blocks: %v", result.Error)
+	}
+
+	blocks := make([]Block, 0, len(dbBlocks))
+	for _, dbBlock := range dbBlocks {
+		block, err := bc.loadBlockFromDB(dbBlock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load block %d: %v", dbBlock.Index, err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// GetBlockByHash returns a block by its hash
+func (bc *BlockchainDB) GetBlockByHash(hash string) (Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var dbBlock database.Block
+	result := database.DB.Where("hash = ?", hash).First(&dbBlock)
+	if result.Error == gorm.ErrRecordNotFound {
+		return Block{}, ErrBlockNotFound
+	}
+	if result.Error != nil {
+		return Block{}, fmt.Errorf("failed to get block: %v", result.Error)
+	}
+
+	return bc.loadBlockFromDB(dbBlock)
+}
+
+// lookupTransaction finds the height and position of a transaction included in the
+// canonical chain
+func (bc *BlockchainDB) lookupTransaction(id string) (TxLocation, bool) {
+	var dbTx database.Transaction
+	if err := database.DB.Where("tx_id = ? AND block_id IS NOT NULL", id).First(&dbTx).Error; err != nil {
+		return TxLocation{}, false
+	}
+	indexed, err := bc.loadIndexedTransactions([]database.Transaction{dbTx})
+	if err != nil {
+		return TxLocation{}, false
+	}
+	return TxLocation{BlockIndex: indexed[0].BlockIndex, Position: indexed[0].Position}, true
+}
+
+// GetTransaction returns a transaction included in the canonical chain by ID
+func (bc *BlockchainDB) GetTransaction(id string) (IndexedTransaction, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var dbTx database.Transaction
+	result := database.DB.Where("tx_id = ? AND block_id IS NOT NULL", id).First(&dbTx)
+	if result.Error == gorm.ErrRecordNotFound {
+		return IndexedTransaction{}, ErrTransactionNotFound
+	}
+	if result.Error != nil {
+		return IndexedTransaction{}, fmt.Errorf("failed to get transaction: %v", result.Error)
+	}
+
+	indexed, err := bc.loadIndexedTransactions([]database.Transaction{dbTx})
+	if err != nil {
+		return IndexedTransaction{}, err
+	}
+	return indexed[0], nil
+}
+
+// GetAddressTransactions returns a page of an address's included transactions,
+// newest first, and the number of transactions matching the query. Rows are
+// inserted in chain order, so their IDs order them by height and position.
+func (bc *BlockchainDB) GetAddressTransactions(address string, query TxQuery) ([]IndexedTransaction, int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	rows := database.DB.Model(&database.Transaction{}).Where("block_id IS NOT NULL")
+	switch query.Direction {
+	case TxDirectionSent:
+		rows = rows.Where("from_addr = ?", address)
+	case TxDirectionReceived:
+		rows = rows.Where("to_addr = ?", address)
+	default:
+		rows = rows.Where("from_addr = ? OR to_addr = ?", address, address)
+	}
+	// Share the conditions between the count and the page query
+	rows = rows.Session(&gorm.Session{})
+
+	var total int64
+	if err := rows.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count transactions: %v", err)
+	}
+
+	var dbTxs []database.Transaction
+	if err := rows.Order("id desc").Offset(query.Offset).Limit(query.limit()).Find(&dbTxs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get transactions: %v", err)
+	}
+
+	page, err := bc.loadIndexedTransactions(dbTxs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, int(total), nil
+}
+
+// loadIndexedTransactions resolves transaction rows to the transactions stored in
+// their blocks
+func (bc *BlockchainDB) loadIndexedTransactions(dbTxs []database.Transaction) ([]IndexedTransaction, error) {
+	blockIDs := make([]uint, 0, len(dbTxs))
+	for _, dbTx := range dbTxs {
+		blockIDs = append(blockIDs, *dbTx.BlockID)
+	}
+
+	var dbBlocks []database.Block
+	if len(blockIDs) > 0 {
+		if err := database.DB.Where("id IN ?", blockIDs).Find(&dbBlocks).Error; err != nil {
+			return nil, fmt.Errorf("failed to get blocks: %v", err)
+		}
+	}
+	blocks := make(map[uint]Block, len(dbBlocks))
+	for _, dbBlock := range dbBlocks {
+		block, err := bc.loadBlockFromDB(dbBlock)
+		if err != nil {
+			return nil, err
+		}
+		blocks[dbBlock.ID] = block
+	}
+
+	indexed := make([]IndexedTransaction, 0, len(dbTxs))
+	for _, dbTx := range dbTxs {
+		block, ok := blocks[*dbTx.BlockID]
+		if !ok {
+			return nil, fmt.Errorf("block of transaction %s not found", dbTx.TxID)
+		}
+		for position, tx := range block.Data {
+			if tx.ID == dbTx.TxID {
+				indexed = append(indexed, newIndexedTransaction(block, position))
+				break
+			}
+		}
+	}
+	return indexed, nil
+}
+
+// ReplaceChain safely replaces the blockchain's chain with a new one. Chains that do
+// not contain the last irreversible block or fail validation are refused.
+func (bc *BlockchainDB) ReplaceChain(newChain []Block) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	if err := checkIrreversible(bc.finality, newChain); err != nil {
+		return err
+	}
+
+	current := bc.readChain()
+	if err := verifyReplacement(current, newChain); err != nil {
+		return err
+	}
+	dropped, added := chainDivergence(current, newChain)
+	if err := switchState(bc.stateApplier, dropped, added); err != nil {
+		return err
+	}
+
+	replaced, err := bc.replaceBlocks(newChain)
+	if err != nil {
+		restoreState(bc.stateApplier, added, dropped)
+		return err
+	}
+
+	// Remove transactions that are now in the chain
+	for _, block := range newChain {
+		bc.mempool.RemoveIncluded(block.Data)
+	}
+	notifyNewBlocks(bc.observer, newChain, replaced)
+
+	bc.updateFinality()
+	return nil
+}
+
+// replaceBlocks replaces the stored blocks, transactions and receipts with those of
+// a new chain in one database transaction, returning the hashes of the replaced blocks
+func (bc *BlockchainDB) replaceBlocks(newChain []Block) (map[string]bool, error) {
 	// Start database transaction
 	tx := database.DB.Begin()
 	defer func() {
@@ -280,18 +640,42 @@ func (bc *BlockchainDB) ReplaceChain(newChain []Block) {
 		}
 	}()
 
+	// Remember the replaced blocks so only new ones are reported
+	var previousHashes []string
+	if err := tx.Model(&database.Block{}).Pluck("hash", &previousHashes).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load blocks: %v", err)
+	}
+	replaced := make(map[string]bool, len(previousHashes))
+	for _, hash := range previousHashes {
+		replaced[hash] = true
+	}
+
 	// Delete all existing blocks
 	if err := tx.Exec("DELETE FROM blocks").Error; err != nil {
 		tx.Rollback()
-		log.Printf("Error deleting blocks: %v", err)
-		return
+		return nil, fmt.Errorf("failed to delete blocks: %v", err)
 	}
 
 	// Delete all existing transactions
 	if err := tx.Exec("DELETE FROM transactions").Error; err != nil {
 		tx.Rollback()
-		log.Printf("Error deleting transactions: %v", err)
-		return
+		return nil, fmt.Errorf("failed to delete transactions: %v", err)
+	}
+
+	// Delete the receipts of included transactions, keeping those still in the same block
+	var includedReceipts []database.Receipt
+	if err := tx.Where("status = ?", string(ReceiptIncluded)).Find(&includedReceipts).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load receipts: %v", err)
+	}
+	previous := make(map[string]database.Receipt, len(includedReceipts))
+	for _, dbReceipt := range includedReceipts {
+		previous[dbReceipt.TxID] = dbReceipt
+	}
+	if err := tx.Exec("DELETE FROM receipts WHERE status = ?", string(ReceiptIncluded)).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to delete receipts: %v", err)
 	}
 
 	// Insert new blocks
@@ -300,24 +684,26 @@ func (bc *BlockchainDB) ReplaceChain(newChain []Block) {
 		transactionsJSON, err := json.Marshal(block.Data)
 		if err != nil {
 			tx.Rollback()
-			log.Printf("Error serializing transactions: %v", err)
-			return
+			return nil, fmt.Errorf("failed to serialize transactions: %v", err)
 		}
 
 		dbBlock := database.Block{
 			Index:        block.Index,
 			PreviousHash: block.PreviousHash,
 			Timestamp:    block.Timestamp,
-			Data:         string(transactionsJSON),
+			Data:         database.JSON(transactionsJSON),
 			Hash:         block.Hash,
 			Validator:    block.Validator,
 			Signature:    block.Signature,
+			PublicKey:    block.PublicKey,
+			Version:      block.Version,
+			MerkleRoot:   block.MerkleRoot,
+			StateRoot:    block.StateRoot,
 		}
 
 		if err := tx.Create(&dbBlock).Error; err != nil {
 			tx.Rollback()
-			log.Printf("Error creating block: %v", err)
-			return
+			return nil, fmt.Errorf("failed to create block: %v", err)
 		}
 
 		// Save individual transactions
@@ -327,8 +713,11 @@ func (bc *BlockchainDB) ReplaceChain(newChain []Block) {
 				FromAddr:  txData.From,
 				ToAddr:    txData.To,
 				Amount:    txData.Amount,
+				Nonce:     txData.Nonce,
 				Timestamp: txData.Timestamp,
 				Signature: txData.Signature,
+				PublicKey: txData.PublicKey,
+				Version:   txData.Version,
 				BlockID:   &dbBlock.ID,
 			}
 
@@ -336,14 +725,26 @@ func (bc *BlockchainDB) ReplaceChain(newChain []Block) {
 				log.Printf("Warning: Failed to save transaction %s: %v", txData.ID, err)
 			}
 		}
+
+		// Save receipts of the included transactions
+		fees := paidFees(bc.stateApplier, block)
+		for position, txData := range block.Data {
+			receipt := newIncludedReceipt(block, position, fees)
+			if dbReceipt, ok := previous[txData.ID]; ok && dbReceipt.BlockHash == block.Hash {
+				if kept, err := loadReceiptFromDB(dbReceipt); err == nil {
+					receipt = kept
+				}
+			}
+			if err := saveReceipt(tx, receipt); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to save receipt: %v", err)
+			}
+		}
 	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		log.Printf("Error committing chain replacement: %v", err)
-		return
+		return nil, fmt.Errorf("failed to commit chain replacement: %v", err)
 	}
-
-	// Clear pending transactions
-	bc.transactions = []Transaction{}
+	return replaced, nil
 }
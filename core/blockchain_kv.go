@@ -0,0 +1,770 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/igo-used/binomena/database"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BlockchainKV represents the blockchain stored in the embedded key-value store.
+// Each block and the pending transactions it confirms are written in one atomic batch.
+type BlockchainKV struct {
+	mempool      *Mempool
+	stateApplier StateApplier
+	finality     FinalityGadget
+	observer     ChainObserver
+	mu           sync.RWMutex
+}
+
+// KVStateApplier is a StateApplier able to write the settlement of a block into a
+// key-value batch, so a block and its state changes commit together
+type KVStateApplier interface {
+	StateApplier
+	KeepsStateInKV() bool
+	PrepareBlock(block Block) (*Execution, error)
+	ApplyPreparedTx(tx *bolt.Tx, block Block, execution *Execution) error
+}
+
+// KVStateChangeStore is a StateChangeStore kept in the key-value store, which can
+// apply the state changes of a block within a batch opened by the caller
+type KVStateChangeStore interface {
+	ApplyBlockChangesTx(tx *bolt.Tx, blockHash string, changes []StateChange) error
+}
+
+// KeepsStateInKV reports whether the token state is kept in the key-value store
+func (a *TokenStateApplier) KeepsStateInKV() bool {
+	_, ok := a.token.(KVStateChangeStore)
+	return ok
+}
+
+// ApplyPreparedTx writes the changes of a block prepared with PrepareBlock into a
+// key-value batch
+func (a *TokenStateApplier) ApplyPreparedTx(tx *bolt.Tx, block Block, execution *Execution) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	store, ok := a.token.(KVStateChangeStore)
+	if !ok {
+		return fmt.Errorf("token state is not kept in the key-value store")
+	}
+	if err := store.ApplyBlockChangesTx(tx, block.Hash, execution.Changes); err != nil {
+		return err
+	}
+	a.paid[block.Hash] = execution.Fees
+	return nil
+}
+
+// NewBlockchainWithKV creates a new key-value backed blockchain with a genesis block
+// and restores the pending transactions saved in the store
+func NewBlockchainWithKV() *BlockchainKV {
+	bc := &BlockchainKV{
+		mempool: NewMempool(nil),
+	}
+
+	if bc.GetBlockCount() == 0 {
+		genesisBlock := GenesisBlock()
+
+		err := database.KV.Update(func(tx *bolt.Tx) error {
+			return bc.putBlock(tx, genesisBlock)
+		})
+		if err != nil {
+			log.Printf("Error creating genesis block: %v", err)
+		} else {
+			log.Println("Genesis block created successfully")
+		}
+	}
+
+	// Restore the pending transactions
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(database.PendingBucket).ForEach(func(_, value []byte) error {
+			var pending Transaction
+			if err := json.Unmarshal(value, &pending); err != nil {
+				return fmt.Errorf("failed to deserialize pending transaction: %v", err)
+			}
+			if err := bc.mempool.Add(pending); err != nil {
+				log.Printf("Dropped pending transaction %s: %v", pending.ID, err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Error loading pending transactions: %v", err)
+	}
+
+	return bc
+}
+
+// txLocationKey encodes the height and position of an included transaction
+func txLocationKey(blockIndex uint64, position int) []byte {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key, blockIndex)
+	binary.BigEndian.PutUint32(key[8:], uint32(position))
+	return key
+}
+
+// decodeTxLocation decodes the height and position of an included transaction
+func decodeTxLocation(key []byte) TxLocation {
+	return TxLocation{
+		BlockIndex: binary.BigEndian.Uint64(key[:8]),
+		Position:   int(binary.BigEndian.Uint32(key[8:12])),
+	}
+}
+
+// addressTxsPrefix returns the prefix of an address's keys in the address index
+func addressTxsPrefix(address string) []byte {
+	return append([]byte(address), 0)
+}
+
+// putReceipt writes the receipt of a transaction
+func putReceipt(tx *bolt.Tx, receipt Receipt) error {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to serialize receipt: %v", err)
+	}
+	if err := tx.Bucket(database.ReceiptsBucket).Put([]byte(receipt.TxID), data); err != nil {
+		return fmt.Errorf("failed to save receipt: %v", err)
+	}
+	return nil
+}
+
+// getReceipt reads the stored receipt of a transaction
+func getReceipt(tx *bolt.Tx, id string) (Receipt, bool, error) {
+	data := tx.Bucket(database.ReceiptsBucket).Get([]byte(id))
+	if data == nil {
+		return Receipt{}, false, nil
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return Receipt{}, false, fmt.Errorf("failed to deserialize receipt: %v", err)
+	}
+	return receipt, true, nil
+}
+
+// putBlock writes a block by height and indexes its hash, transactions and receipts.
+// Receipts already recording the transaction in this block are kept.
+func (bc *BlockchainKV) putBlock(tx *bolt.Tx, block Block) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to serialize block: %v", err)
+	}
+
+	key := database.HeightKey(block.Index)
+	if err := tx.Bucket(database.BlocksBucket).Put(key, data); err != nil {
+		return fmt.Errorf("failed to save block: %v", err)
+	}
+	if err := tx.Bucket(database.BlockHashesBucket).Put([]byte(block.Hash), key); err != nil {
+		return fmt.Errorf("failed to index block hash: %v", err)
+	}
+
+	txIndex := tx.Bucket(database.TxIndexBucket)
+	addressTxs := tx.Bucket(database.AddressTxsBucket)
+	fees := paidFees(bc.stateApplier, block)
+	for position, included := range block.Data {
+		location := txLocationKey(block.Index, position)
+		if err := txIndex.Put([]byte(included.ID), location); err != nil {
+			return fmt.Errorf("failed to index transaction: %v", err)
+		}
+		for _, address := range []string{included.From, included.To} {
+			if err := addressTxs.Put(append(addressTxsPrefix(address), location...), []byte(included.ID)); err != nil {
+				return fmt.Errorf("failed to index address transaction: %v", err)
+			}
+		}
+
+		existing, ok, err := getReceipt(tx, included.ID)
+		if err != nil {
+			return err
+		}
+		if ok && existing.Status == ReceiptIncluded && existing.BlockHash == block.Hash {
+			continue
+		}
+		if err := putReceipt(tx, newIncludedReceipt(block, position, fees)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeBlock deserializes a block stored in the blocks bucket
+func decodeBlock(data []byte) (Block, error) {
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return Block{}, fmt.Errorf("failed to deserialize block: %v", err)
+	}
+	return block, nil
+}
+
+// lastBlock returns the highest block in the store
+func lastBlock(tx *bolt.Tx) (Block, error) {
+	_, data := tx.Bucket(database.BlocksBucket).Cursor().Last()
+	if data == nil {
+		return Block{}, fmt.Errorf("blockchain is empty")
+	}
+	return decodeBlock(data)
+}
+
+// writePending rewrites the pending bucket from the mempool, leaving out the
+// transactions of blocks being written. The mempool itself only drops them once
+// the batch has been committed.
+func (bc *BlockchainKV) writePending(tx *bolt.Tx, blocks ...Block) error {
+	included := make(map[string]bool)
+	for _, block := range blocks {
+		for _, transaction := range block.Data {
+			included[transaction.ID] = true
+		}
+	}
+
+	if err := tx.DeleteBucket(database.PendingBucket); err != nil {
+		return fmt.Errorf("failed to clear pending transactions: %v", err)
+	}
+	bucket, err := tx.CreateBucket(database.PendingBucket)
+	if err != nil {
+		return fmt.Errorf("failed to clear pending transactions: %v", err)
+	}
+
+	for _, pending := range bc.mempool.Pending() {
+		if included[pending.ID] {
+			continue
+		}
+		data, err := json.Marshal(pending)
+		if err != nil {
+			return fmt.Errorf("failed to serialize pending transaction: %v", err)
+		}
+		if err := bucket.Put([]byte(pending.ID), data); err != nil {
+			return fmt.Errorf("failed to save pending transaction: %v", err)
+		}
+	}
+	return nil
+}
+
+// AddBlock adds a new block to the store once its transactions are settled,
+// removing the transactions it includes from the stored pending set in the same
+// batch and from the mempool once it commits
+func (bc *BlockchainKV) AddBlock(block Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		last, err := lastBlock(tx)
+		if err != nil {
+			return fmt.Errorf("failed to get last block: %v", err)
+		}
+
+		// Validate block
+		if block.Index != last.Index+1 {
+			return fmt.Errorf("invalid block index")
+		}
+
+		if block.PreviousHash != last.Hash {
+			return fmt.Errorf("invalid previous hash")
+		}
+
+		if err := validateBlockContents(block); err != nil {
+			return err
+		}
+		return checkTransactionReplay(last.Index, []Block{block}, func(id string) (TxLocation, bool) {
+			key := tx.Bucket(database.TxIndexBucket).Get([]byte(id))
+			if key == nil {
+				return TxLocation{}, false
+			}
+			return decodeTxLocation(key), true
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := bc.storeBlock(block); err != nil {
+		return err
+	}
+	bc.mempool.RemoveIncluded(block.Data)
+	if bc.observer != nil {
+		bc.observer.BlockAdded(block)
+	}
+
+	bc.updateFinality()
+	return nil
+}
+
+// storeBlock settles a block's transactions and stores the block. A token state kept
+// in the same store is written in the block's batch; any other state is settled
+// first and reverted if the block cannot be stored.
+func (bc *BlockchainKV) storeBlock(block Block) error {
+	applier, ok := bc.stateApplier.(KVStateApplier)
+	if !ok || !applier.KeepsStateInKV() {
+		if err := switchState(bc.stateApplier, nil, []Block{block}); err != nil {
+			return err
+		}
+		err := database.KV.Update(func(tx *bolt.Tx) error {
+			if err := bc.putBlock(tx, block); err != nil {
+				return err
+			}
+			return bc.writePending(tx, block)
+		})
+		if err != nil {
+			restoreState(bc.stateApplier, []Block{block}, nil)
+		}
+		return err
+	}
+
+	// Transactions are settled before the batch opens, as reading the state
+	// inside it could wait on the batch itself
+	execution, err := applier.PrepareBlock(block)
+	if err != nil {
+		return err
+	}
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		if err := applier.ApplyPreparedTx(tx, block, execution); err != nil {
+			return err
+		}
+		if err := bc.putBlock(tx, block); err != nil {
+			return err
+		}
+		return bc.writePending(tx, block)
+	})
+}
+
+// SetStateApplier sets the state applier settling the transactions of blocks as
+// they join or leave the chain
+func (bc *BlockchainKV) SetStateApplier(applier StateApplier) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.stateApplier = applier
+}
+
+// SetFinalityGadget sets the consensus component tracking the last irreversible
+// block, which chain replacements may never revert
+func (bc *BlockchainKV) SetFinalityGadget(finality FinalityGadget) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.finality = finality
+	bc.updateFinality()
+}
+
+// SetChainObserver sets the observer notified of new canonical blocks and
+// accepted transactions
+func (bc *BlockchainKV) SetChainObserver(observer ChainObserver) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.observer = observer
+}
+
+// updateFinality lets the finality gadget advance over the blocks after the last
+// irreversible block
+func (bc *BlockchainKV) updateFinality() {
+	if bc.finality == nil {
+		return
+	}
+
+	var head []Block
+	irreversible := bc.finality.GetLastIrreversibleBlock().Index
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(database.BlocksBucket).Cursor()
+		for key, data := cursor.Seek(database.HeightKey(irreversible)); key != nil; key, data = cursor.Next() {
+			block, err := decodeBlock(data)
+			if err != nil {
+				return err
+			}
+			head = append(head, block)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error loading blocks for finality: %v", err)
+		return
+	}
+	bc.finality.UpdateIrreversibleBlock(head)
+}
+
+// GetLastBlock returns the last block in the blockchain
+func (bc *BlockchainKV) GetLastBlock() Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var block Block
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		var err error
+		block, err = lastBlock(tx)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error getting last block: %v", err)
+		// Return genesis block as fallback
+		return GenesisBlock()
+	}
+
+	return block
+}
+
+// AddTransaction adds a new transaction to the pending transactions and persists it
+func (bc *BlockchainKV) AddTransaction(tx Transaction) error {
+	// Validate transaction prefix
+	if len(tx.ID) < 4 || tx.ID[:4] != "AdNe" {
+		return fmt.Errorf("transaction ID must start with 'AdNe'")
+	}
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to serialize transaction: %v", err)
+	}
+
+	// Reject replays of a transaction that is already waiting
+	err = bc.mempool.Add(tx)
+	bc.recordDropped()
+	if err != nil {
+		return err
+	}
+
+	err = database.KV.Update(func(btx *bolt.Tx) error {
+		return btx.Bucket(database.PendingBucket).Put([]byte(tx.ID), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.observer != nil {
+		bc.observer.TransactionAdded(tx)
+	}
+	return nil
+}
+
+// GetReceipt returns the receipt of a transaction that is included, pending, failed
+// or dropped
+func (bc *BlockchainKV) GetReceipt(id string) (Receipt, error) {
+	bc.recordDropped()
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var receipt Receipt
+	var stored bool
+	var tip Block
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		var err error
+		if receipt, stored, err = getReceipt(tx, id); err != nil || !stored || receipt.Status != ReceiptIncluded {
+			return err
+		}
+		tip, err = lastBlock(tx)
+		return err
+	})
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	if stored && receipt.Status == ReceiptIncluded {
+		return receipt.withConfirmations(tip), nil
+	}
+	if tx, pending := bc.mempool.Get(id); pending {
+		return newPendingReceipt(tx), nil
+	}
+	if stored {
+		return receipt, nil
+	}
+	return Receipt{}, ErrTransactionNotFound
+}
+
+// RecordReceipt stores the receipt of a failed or dropped transaction
+func (bc *BlockchainKV) RecordReceipt(receipt Receipt) error {
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		existing, ok, err := getReceipt(tx, receipt.TxID)
+		if err != nil || (ok && existing.Status == ReceiptIncluded) {
+			return err
+		}
+		return putReceipt(tx, receipt)
+	})
+}
+
+// recordDropped stores receipts for the transactions the mempool dropped, and
+// removes them from the pending bucket
+func (bc *BlockchainKV) recordDropped() {
+	dropped := bc.mempool.TakeDropped()
+	if len(dropped) == 0 {
+		return
+	}
+
+	err := database.KV.Update(func(tx *bolt.Tx) error {
+		for _, entry := range dropped {
+			if err := tx.Bucket(database.PendingBucket).Delete([]byte(entry.Transaction.ID)); err != nil {
+				return fmt.Errorf("failed to remove pending transaction: %v", err)
+			}
+			existing, ok, err := getReceipt(tx, entry.Transaction.ID)
+			if err != nil {
+				return err
+			}
+			if ok && existing.Status == ReceiptIncluded {
+				continue
+			}
+			if err := putReceipt(tx, newDroppedReceipt(entry)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recording dropped transactions: %v", err)
+	}
+}
+
+// GetPendingTransactions returns all pending transactions, each sender's in nonce order
+func (bc *BlockchainKV) GetPendingTransactions() []Transaction {
+	return bc.mempool.Pending()
+}
+
+// Mempool returns the pool of pending transactions
+func (bc *BlockchainKV) Mempool() *Mempool {
+	return bc.mempool
+}
+
+// GetBlockCount returns the number of blocks in the blockchain
+func (bc *BlockchainKV) GetBlockCount() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	// Blocks are keyed by height, so the last key counts them
+	var count int
+	database.KV.View(func(tx *bolt.Tx) error {
+		if key, _ := tx.Bucket(database.BlocksBucket).Cursor().Last(); key != nil {
+			count = int(database.DecodeUint64(key)) + 1
+		}
+		return nil
+	})
+	return count
+}
+
+// GetChain returns a copy of the blockchain
+func (bc *BlockchainKV) GetChain() []Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.readChain()
+}
+
+// readChain reads every block from the store. Callers must hold bc.mu.
+func (bc *BlockchainKV) readChain() []Block {
+	var blocks []Block
+	database.KV.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(database.BlocksBucket).ForEach(func(key, data []byte) error {
+			block, err := decodeBlock(data)
+			if err != nil {
+				log.Printf("Error loading block %d: %v", database.DecodeUint64(key), err)
+				return nil
+			}
+			blocks = append(blocks, block)
+			return nil
+		})
+	})
+
+	return blocks
+}
+
+// GetBlockByIndex returns a block by its index
+func (bc *BlockchainKV) GetBlockByIndex(index uint64) (Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var block Block
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(database.BlocksBucket).Get(database.HeightKey(index))
+		if data == nil {
+			return fmt.Errorf("block index out of range")
+		}
+		var err error
+		block, err = decodeBlock(data)
+		return err
+	})
+	return block, err
+}
+
+// GetBlocks returns a page of blocks starting at the queried height
+func (bc *BlockchainKV) GetBlocks(query BlockQuery) ([]Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	blocks := []Block{}
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(database.BlocksBucket).Cursor()
+		for key, data := cursor.Seek(database.HeightKey(query.From)); key != nil && len(blocks) < query.limit(); key, data = cursor.Next() {
+			block, err := decodeBlock(data)
+			if err != nil {
+				return fmt.Errorf("failed to load block %d: %v", database.DecodeUint64(key), err)
+			}
+			blocks = append(blocks, block)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// GetBlockByHash returns a block by its hash
+func (bc *BlockchainKV) GetBlockByHash(hash string) (Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var block Block
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(database.BlockHashesBucket).Get([]byte(hash))
+		if key == nil {
+			return ErrBlockNotFound
+		}
+		var err error
+		block, err = decodeBlock(tx.Bucket(database.BlocksBucket).Get(key))
+		return err
+	})
+	return block, err
+}
+
+// GetTransaction returns a transaction included in the canonical chain by ID
+func (bc *BlockchainKV) GetTransaction(id string) (IndexedTransaction, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var indexed IndexedTransaction
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(database.TxIndexBucket).Get([]byte(id))
+		if key == nil {
+			return ErrTransactionNotFound
+		}
+		location := decodeTxLocation(key)
+		block, err := decodeBlock(tx.Bucket(database.BlocksBucket).Get(database.HeightKey(location.BlockIndex)))
+		if err != nil {
+			return err
+		}
+		indexed = newIndexedTransaction(block, location.Position)
+		return nil
+	})
+	return indexed, err
+}
+
+// GetAddressTransactions returns a page of an address's included transactions,
+// newest first, and the number of transactions matching the query
+func (bc *BlockchainKV) GetAddressTransactions(address string, query TxQuery) ([]IndexedTransaction, int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	page := []IndexedTransaction{}
+	total := 0
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(database.BlocksBucket)
+		prefix := addressTxsPrefix(address)
+
+		// Walk the address's keys backwards from the first key after the prefix
+		cursor := tx.Bucket(database.AddressTxsBucket).Cursor()
+		key, _ := cursor.Seek(append([]byte(address), 1))
+		if key == nil {
+			key, _ = cursor.Last()
+		} else {
+			key, _ = cursor.Prev()
+		}
+
+		var block Block
+		for ; key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Prev() {
+			location := decodeTxLocation(key[len(prefix):])
+			if block.Hash == "" || block.Index != location.BlockIndex {
+				var err error
+				if block, err = decodeBlock(blocks.Get(database.HeightKey(location.BlockIndex))); err != nil {
+					return err
+				}
+			}
+			if !query.matches(block.Data[location.Position], address) {
+				continue
+			}
+			if total >= query.Offset && len(page) < query.limit() {
+				page = append(page, newIndexedTransaction(block, location.Position))
+			}
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
+}
+
+// ReplaceChain safely replaces the blockchain's chain with a new one in a single
+// batch, reverting the state changes of the blocks it drops and applying those of
+// the blocks it adds. Chains that do not contain the last irreversible block or fail
+// validation are refused.
+func (bc *BlockchainKV) ReplaceChain(newChain []Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if err := checkIrreversible(bc.finality, newChain); err != nil {
+		return err
+	}
+	current := bc.readChain()
+	if err := verifyReplacement(current, newChain); err != nil {
+		return err
+	}
+	dropped, added := chainDivergence(current, newChain)
+	if err := switchState(bc.stateApplier, dropped, added); err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool)
+	err := database.KV.Update(func(tx *bolt.Tx) error {
+		// Remember the replaced blocks so only new ones are reported
+		tx.Bucket(database.BlockHashesBucket).ForEach(func(hash, _ []byte) error {
+			replaced[string(hash)] = true
+			return nil
+		})
+
+		// Remember the included transactions to drop receipts of those left out
+		var previous [][]byte
+		tx.Bucket(database.TxIndexBucket).ForEach(func(id, _ []byte) error {
+			previous = append(previous, append([]byte{}, id...))
+			return nil
+		})
+
+		// Delete all existing blocks
+		for _, bucket := range [][]byte{database.BlocksBucket, database.BlockHashesBucket, database.TxIndexBucket, database.AddressTxsBucket} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return fmt.Errorf("failed to delete blocks: %v", err)
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return fmt.Errorf("failed to delete blocks: %v", err)
+			}
+		}
+
+		for _, block := range newChain {
+			if err := bc.putBlock(tx, block); err != nil {
+				return err
+			}
+		}
+		for _, id := range previous {
+			if tx.Bucket(database.TxIndexBucket).Get(id) == nil {
+				if err := tx.Bucket(database.ReceiptsBucket).Delete(id); err != nil {
+					return fmt.Errorf("failed to delete receipt: %v", err)
+				}
+			}
+		}
+
+		return bc.writePending(tx, newChain...)
+	})
+	if err != nil {
+		restoreState(bc.stateApplier, added, dropped)
+		return fmt.Errorf("failed to replace chain: %v", err)
+	}
+
+	// Remove transactions that are now in the chain
+	for _, block := range newChain {
+		bc.mempool.RemoveIncluded(block.Data)
+	}
+	notifyNewBlocks(bc.observer, newChain, replaced)
+
+	bc.updateFinality()
+	return nil
+}
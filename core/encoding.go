@@ -0,0 +1,194 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// LegacyEncodingVersion marks blocks and transactions hashed with the original
+	// fmt.Sprintf based records
+	LegacyEncodingVersion uint32 = 0
+	// CanonicalEncodingVersion marks blocks and transactions hashed with the
+	// canonical binary encoding
+	CanonicalEncodingVersion uint32 = 1
+	// MerkleEncodingVersion marks blocks whose hash covers a header committing to the
+	// Merkle root of their transactions instead of the full transaction list
+	MerkleEncodingVersion uint32 = 2
+	// StateRootEncodingVersion marks blocks whose header also commits to the state root
+	StateRootEncodingVersion uint32 = 3
+	// CurrentEncodingVersion is the encoding used for newly created transactions
+	CurrentEncodingVersion = CanonicalEncodingVersion
+	// CurrentBlockVersion is the encoding used for newly created blocks
+	CurrentBlockVersion = StateRootEncodingVersion
+)
+
+// AllowLegacyHashes controls whether blocks and transactions using the legacy
+// encoding are still accepted. It exists so chains created before the canonical
+// encoding keep validating.
+var AllowLegacyHashes = false
+
+// LegacyCutoffHeight is the height of the last legacy block of a chain created before
+// the canonical encoding. Legacy blocks above it are rejected even while legacy
+// hashes are allowed.
+var LegacyCutoffHeight uint64
+
+// The canonical encoding is a plain concatenation of big-endian fixed-width
+// integers and length-prefixed strings, written in field order:
+//
+//	string:           uint32 byte length, followed by the UTF-8 bytes
+//	transaction body: uint32 version, string from, string to, int64 amount in
+//	                  base units (1 BNM = 1e8 units), uint64 nonce, int64 timestamp
+//	transaction:      transaction body, string id, string signature, string publicKey
+//	block:            uint32 version, uint64 index, string previousHash,
+//	                  int64 timestamp, string validator, uint32 transaction count,
+//	                  each transaction
+//	block header:     uint32 version, uint64 index, string previousHash,
+//	                  int64 timestamp, string validator, uint32 transaction count,
+//	                  string merkleRoot, and from StateRootEncodingVersion on
+//	                  string stateRoot
+//
+// Transaction IDs are "AdNe" + the first 60 hex characters of SHA-256 over the
+// transaction body. Block hashes are the hex SHA-256 over the block header encoding
+// for MerkleEncodingVersion blocks and over the full block encoding before that.
+// Transactions themselves still use the version 1 encoding.
+
+// EncodeTransactionBody returns the canonical encoding of the signed fields of a transaction
+func EncodeTransactionBody(tx *Transaction) []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, tx.Version)
+	writeString(&buf, tx.From)
+	writeString(&buf, tx.To)
+	writeUint64(&buf, uint64(tx.Amount))
+	writeUint64(&buf, tx.Nonce)
+	writeUint64(&buf, uint64(tx.Timestamp))
+	return buf.Bytes()
+}
+
+// EncodeTransaction returns the canonical encoding of a transaction including its
+// ID, signature and public key
+func EncodeTransaction(tx *Transaction) []byte {
+	var buf bytes.Buffer
+	buf.Write(EncodeTransactionBody(tx))
+	writeString(&buf, tx.ID)
+	writeString(&buf, tx.Signature)
+	writeString(&buf, tx.PublicKey)
+	return buf.Bytes()
+}
+
+// EncodeBlock returns the canonical encoding of the hashed fields of a block
+func EncodeBlock(block Block) []byte {
+	if block.Version >= MerkleEncodingVersion {
+		return EncodeBlockHeader(block.Header())
+	}
+
+	var buf bytes.Buffer
+	writeUint32(&buf, block.Version)
+	writeUint64(&buf, block.Index)
+	writeString(&buf, block.PreviousHash)
+	writeUint64(&buf, uint64(block.Timestamp))
+	writeString(&buf, block.Validator)
+	writeUint32(&buf, uint32(len(block.Data)))
+	for i := range block.Data {
+		buf.Write(EncodeTransaction(&block.Data[i]))
+	}
+	return buf.Bytes()
+}
+
+// EncodeBlockHeader returns the canonical encoding of a block header
+func EncodeBlockHeader(header BlockHeader) []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, header.Version)
+	writeUint64(&buf, header.Index)
+	writeString(&buf, header.PreviousHash)
+	writeUint64(&buf, uint64(header.Timestamp))
+	writeString(&buf, header.Validator)
+	writeUint32(&buf, header.TransactionCount)
+	writeString(&buf, header.MerkleRoot)
+	if header.Version >= StateRootEncodingVersion {
+		writeString(&buf, header.StateRoot)
+	}
+	return buf.Bytes()
+}
+
+// checkEncodingVersion rejects unknown encodings and legacy encodings when they are disabled
+func checkEncodingVersion(version uint32) error {
+	switch version {
+	case LegacyEncodingVersion:
+		if !AllowLegacyHashes {
+			return fmt.Errorf("legacy hash encoding is not allowed")
+		}
+		return nil
+	case CanonicalEncodingVersion, MerkleEncodingVersion, StateRootEncodingVersion:
+		return nil
+	default:
+		return fmt.Errorf("unsupported encoding version %d", version)
+	}
+}
+
+// checkBlockVersion rejects blocks whose encoding is not accepted at their height
+func checkBlockVersion(block Block) error {
+	if err := checkEncodingVersion(block.Version); err != nil {
+		return err
+	}
+	if block.Version == LegacyEncodingVersion && block.Index > LegacyCutoffHeight {
+		return fmt.Errorf("legacy block above the legacy cutoff height %d", LegacyCutoffHeight)
+	}
+	return nil
+}
+
+// legacyTransaction mirrors the transaction layout hashed by legacy blocks
+type legacyTransaction struct {
+	ID        string
+	From      string
+	To        string
+	Amount    float64
+	Timestamp int64
+	Signature string
+}
+
+// legacyBlockHash calculates a block hash with the original formatted record
+func legacyBlockHash(block Block) string {
+	transactions := make([]legacyTransaction, len(block.Data))
+	for i, tx := range block.Data {
+		transactions[i] = legacyTransaction{
+			ID:        tx.ID,
+			From:      tx.From,
+			To:        tx.To,
+			Amount:    tx.Amount.Float64(),
+			Timestamp: tx.Timestamp,
+			Signature: tx.Signature,
+		}
+	}
+
+	record := fmt.Sprintf("%d%s%d%v%s", block.Index, block.PreviousHash, block.Timestamp, transactions, block.Validator)
+	hashed := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hashed[:])
+}
+
+// legacyTransactionID calculates a transaction ID with the original formatted record,
+// which predates nonces
+func legacyTransactionID(tx *Transaction) string {
+	txHash := sha256.Sum256([]byte(fmt.Sprintf("%s%s%f%d", tx.From, tx.To, tx.Amount.Float64(), tx.Timestamp)))
+	return "AdNe" + hex.EncodeToString(txHash[:])[:60]
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUint32(buf, uint32(len(s)))
+	buf.WriteString(s)
+}
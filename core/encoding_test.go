@@ -0,0 +1,99 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+func TestCanonicalTransactionEncoding(t *testing.T) {
+	tx := Transaction{
+		From:      "AdNeA",
+		To:        "AdNeB",
+		Amount:    bnm.MustParse("1.5"),
+		Nonce:     2,
+		Timestamp: 1700000000,
+		Version:   CanonicalEncodingVersion,
+	}
+
+	// Fixed vector so other implementations can check their encoder
+	expected := "00000001" + // version
+		"00000005" + "41644e6541" + // from
+		"00000005" + "41644e6542" + // to
+		"0000000008f0d180" + // amount in base units
+		"0000000000000002" + // nonce
+		"000000006553f100" // timestamp
+	if got := hex.EncodeToString(EncodeTransactionBody(&tx)); got != expected {
+		t.Fatalf("Unexpected transaction encoding:\n got %s\nwant %s", got, expected)
+	}
+
+	if id := CalculateTransactionID(&tx); id != "AdNe9b541f314b112679b08f16227e080e1b46fa28cb7b5b5fbad27deff97663" {
+		t.Errorf("Unexpected transaction ID: %s", id)
+	}
+}
+
+func TestLegacyBlockHashes(t *testing.T) {
+	defer func(allow bool, cutoff uint64) {
+		AllowLegacyHashes, LegacyCutoffHeight = allow, cutoff
+	}(AllowLegacyHashes, LegacyCutoffHeight)
+	LegacyCutoffHeight = 3
+
+	bc := NewBlockchain()
+	genesis := bc.GetLastBlock()
+	if genesis.Version != CurrentBlockVersion {
+		t.Fatalf("Expected new genesis block to use encoding %d, got %d", CurrentBlockVersion, genesis.Version)
+	}
+
+	// A block stored before the canonical encoding has no version
+	legacy := Block{Index: 1, PreviousHash: genesis.Hash, Timestamp: 1700000000, Data: []Transaction{}, Validator: "genesis"}
+	legacy.Hash = CalculateHash(legacy)
+
+	canonical := legacy
+	canonical.Version = CanonicalEncodingVersion
+	if CalculateHash(canonical) == legacy.Hash {
+		t.Fatal("Expected canonical and legacy hashes to differ")
+	}
+
+	AllowLegacyHashes = false
+	if err := bc.AddBlock(legacy); err == nil {
+		t.Error("Expected legacy block to be rejected when legacy hashes are disabled")
+	}
+
+	AllowLegacyHashes = true
+	if err := bc.AddBlock(legacy); err != nil {
+		t.Errorf("Expected legacy block to validate under the legacy-hash flag: %v", err)
+	}
+
+	// Legacy transactions hash from, to, amount and timestamp and carry no public key
+	tx := Transaction{From: "AdNeA", To: "AdNeB", Amount: bnm.MustParse("1.5"), Timestamp: 1700000001, Signature: "3045"}
+	tx.ID = CalculateTransactionID(&tx)
+	baseline := sha256.Sum256([]byte(fmt.Sprintf("%s%s%f%d", "AdNeA", "AdNeB", 1.5, int64(1700000001))))
+	if expected := "AdNe" + hex.EncodeToString(baseline[:])[:60]; tx.ID != expected {
+		t.Fatalf("Expected legacy transaction ID %s, got %s", expected, tx.ID)
+	}
+
+	withTransactions := Block{Index: 2, PreviousHash: legacy.Hash, Timestamp: 1700000003, Data: []Transaction{tx}, Validator: "genesis"}
+	withTransactions.Hash = CalculateHash(withTransactions)
+	if err := bc.AddBlock(withTransactions); err != nil {
+		t.Fatalf("Expected legacy block with transactions to validate: %v", err)
+	}
+
+	tampered := tx
+	tampered.Amount = bnm.MustParse("2")
+	forged := Block{Index: 3, PreviousHash: withTransactions.Hash, Timestamp: 1700000006, Data: []Transaction{tampered}, Validator: "genesis"}
+	forged.Hash = CalculateHash(forged)
+	if err := bc.AddBlock(forged); err == nil {
+		t.Error("Expected legacy transaction whose ID does not match its contents to be rejected")
+	}
+
+	// Legacy blocks end at the recorded cutoff height
+	LegacyCutoffHeight = 2
+	late := Block{Index: 3, PreviousHash: withTransactions.Hash, Timestamp: 1700000006, Data: []Transaction{}, Validator: "genesis"}
+	late.Hash = CalculateHash(late)
+	if err := bc.AddBlock(late); err == nil {
+		t.Error("Expected legacy block above the legacy cutoff height to be rejected")
+	}
+}
@@ -2,11 +2,14 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"runtime"
 	"sync"
 	"time"
+
+	"github.com/igo-used/binomena/bnm"
 )
 
 // ExecutionMode represents the transaction execution mode
@@ -106,8 +109,13 @@ type ExecutionEngine struct {
 	cancel      context.CancelFunc
 	stateLocker sync.RWMutex // Protects global state during execution
 
+	// State committed to by block state roots
+	stateProviders []StateProvider
+
 	// Performance monitoring
 	executionCount   uint64
+	transactionCount uint64 // Transactions executed across all batches
+	failedCount      uint64 // Transactions that failed to execute
 	averageExecTime  time.Duration
 	lastOptimization time.Time
 	performanceLevel int // 0=conservative, 1=optimized, 2=aggressive
@@ -162,6 +170,14 @@ func (e *ExecutionEngine) GetMode() ExecutionMode {
 	return e.mode
 }
 
+// SetStateProviders sets the state hashed after each transaction and checked against
+// block state roots
+func (e *ExecutionEngine) SetStateProviders(providers ...StateProvider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stateProviders = providers
+}
+
 // ExecuteTransactions executes a batch of transactions based on the current mode
 func (e *ExecutionEngine) ExecuteTransactions(transactions []Transaction, blockchain BlockchainInterface, tokenSystem interface{}) ([]TransactionResult, error) {
 	if len(transactions) == 0 {
@@ -189,6 +205,8 @@ func (e *ExecutionEngine) ExecuteTransactions(transactions []Transaction, blockc
 	// Update performance tracking
 	e.mu.Lock()
 	e.executionCount++
+	e.transactionCount += uint64(len(results))
+	e.failedCount += uint64(e.countFailed(results))
 	e.averageExecTime = (e.averageExecTime + duration) / 2
 	e.mu.Unlock()
 
@@ -240,11 +258,21 @@ func (e *ExecutionEngine) executeParallel(transactions []Transaction, blockchain
 		batch := transactions[i:end]
 		batchResults := make([]TransactionResult, len(batch))
 
-		// Process batch in parallel
+		// Group the batch into per-sender lanes so each sender's nonces are applied in order
+		lanes := make(map[string][]int)
+		var laneOrder []string
 		for j, tx := range batch {
+			if _, exists := lanes[tx.From]; !exists {
+				laneOrder = append(laneOrder, tx.From)
+			}
+			lanes[tx.From] = append(lanes[tx.From], j)
+		}
+
+		// Process lanes in parallel, transactions within a lane sequentially
+		for _, sender := range laneOrder {
 			wg.Add(1)
 
-			go func(index int, transaction *Transaction, batchIndex int) {
+			go func(batchStart int, laneIndexes []int) {
 				defer wg.Done()
 
 				// Acquire worker slot
@@ -252,18 +280,22 @@ func (e *ExecutionEngine) executeParallel(transactions []Transaction, blockchain
 				case e.workerPool <- struct{}{}:
 					defer func() { <-e.workerPool }()
 				case <-e.ctx.Done():
-					batchResults[batchIndex] = TransactionResult{
-						Transaction: transaction,
-						Success:     false,
-						Error:       fmt.Errorf("execution cancelled"),
+					for _, batchIndex := range laneIndexes {
+						batchResults[batchIndex] = TransactionResult{
+							Transaction: &batch[batchIndex],
+							Success:     false,
+							Error:       fmt.Errorf("execution cancelled"),
+						}
 					}
 					return
 				}
 
-				// Execute transaction with state locking for critical sections
-				result := e.executeTransactionWithLocking(transaction, blockchain, tokenSystem, fmt.Sprintf("par-%d-%d", index, batchIndex))
-				batchResults[batchIndex] = result
-			}(i+j, &tx, j)
+				// Execute transactions with state locking for critical sections
+				for _, batchIndex := range laneIndexes {
+					result := e.executeTransactionWithLocking(&batch[batchIndex], blockchain, tokenSystem, fmt.Sprintf("par-%d-%d", batchStart+batchIndex, batchIndex))
+					batchResults[batchIndex] = result
+				}
+			}(i, lanes[sender])
 		}
 
 		wg.Wait()
@@ -290,7 +322,7 @@ func (e *ExecutionEngine) executeParallel(transactions []Transaction, blockchain
 
 // executeTransaction executes a single transaction
 func (e *ExecutionEngine) executeTransaction(tx *Transaction, blockchain BlockchainInterface, tokenSystem interface{}, executionID string) TransactionResult {
-	log.Printf("[%s] Executing transaction %s: %s -> %s (%.6f)", executionID, tx.ID, tx.From, tx.To, tx.Amount)
+	log.Printf("[%s] Executing transaction %s: %s -> %s (%s)", executionID, tx.ID, tx.From, tx.To, tx.Amount)
 
 	result := TransactionResult{
 		Transaction: tx,
@@ -298,7 +330,7 @@ func (e *ExecutionEngine) executeTransaction(tx *Transaction, blockchain Blockch
 	}
 
 	// Validate transaction
-	if err := e.validateTransaction(tx); err != nil {
+	if err := e.validateTransaction(tx, tokenSystem); err != nil {
 		result.Error = fmt.Errorf("validation failed: %v", err)
 		return result
 	}
@@ -328,8 +360,9 @@ func (e *ExecutionEngine) executeTransactionWithLocking(tx *Transaction, blockch
 	return e.executeTransaction(tx, blockchain, tokenSystem, executionID)
 }
 
-// validateTransaction performs basic transaction validation
-func (e *ExecutionEngine) validateTransaction(tx *Transaction) error {
+// validateTransaction performs basic transaction validation, including the sender's
+// nonce when the token system tracks nonces
+func (e *ExecutionEngine) validateTransaction(tx *Transaction, tokenSystem interface{}) error {
 	if tx == nil {
 		return fmt.Errorf("transaction is nil")
 	}
@@ -343,26 +376,50 @@ func (e *ExecutionEngine) validateTransaction(tx *Transaction) error {
 	}
 
 	if tx.Amount <= 0 {
-		return fmt.Errorf("invalid transaction amount: %f", tx.Amount)
+		return fmt.Errorf("invalid transaction amount: %s", tx.Amount)
 	}
 
 	if tx.From[:4] != "AdNe" || tx.To[:4] != "AdNe" {
 		return fmt.Errorf("addresses must start with 'AdNe'")
 	}
 
+	// Reject replayed (stale) and out-of-order (future) nonces
+	if tracker, ok := tokenSystem.(NonceTracker); ok {
+		expected := tracker.GetNonce(tx.From)
+		if tx.Nonce < expected {
+			return fmt.Errorf("stale nonce %d for %s: already used (next is %d)", tx.Nonce, tx.From, expected)
+		}
+		if tx.Nonce > expected {
+			return fmt.Errorf("nonce gap for %s: expected %d, got %d", tx.From, expected, tx.Nonce)
+		}
+	}
+
 	return nil
 }
 
 // applyTransaction applies a transaction to the blockchain and token system
 func (e *ExecutionEngine) applyTransaction(tx *Transaction, blockchain BlockchainInterface, tokenSystem interface{}) error {
-	// Add transaction to blockchain
-	if err := blockchain.AddTransaction(*tx); err != nil {
+	// Add transaction to blockchain (it may already be pending when executing the mempool)
+	if err := blockchain.AddTransaction(*tx); err != nil && !errors.Is(err, ErrDuplicateTransaction) {
 		return fmt.Errorf("failed to add transaction to blockchain: %v", err)
 	}
 
+	// Move the amount and consume the sender's nonce together, or do neither
+	if store, ok := tokenSystem.(interface {
+		ApplyStateChanges([]StateChange) error
+	}); ok {
+		if err := store.ApplyStateChanges(TransferChanges(*tx)); err != nil {
+			return fmt.Errorf("failed to transfer tokens: %v", err)
+		}
+		return nil
+	}
+	if _, ok := tokenSystem.(NonceTracker); ok {
+		return fmt.Errorf("token system tracks nonces but cannot apply state changes atomically")
+	}
+
 	// Apply token transfer if token system supports it
 	if transferer, ok := tokenSystem.(interface {
-		Transfer(string, string, float64) error
+		Transfer(string, string, bnm.Amount) error
 	}); ok {
 		if err := transferer.Transfer(tx.From, tx.To, tx.Amount); err != nil {
 			return fmt.Errorf("failed to transfer tokens: %v", err)
@@ -372,8 +429,17 @@ func (e *ExecutionEngine) applyTransaction(tx *Transaction, blockchain Blockchai
 	return nil
 }
 
-// calculateStateHash calculates a hash of the current state for integrity checking
+// calculateStateHash calculates a hash of the current state for integrity checking.
+// It is the state root when the state is known to the engine.
 func (e *ExecutionEngine) calculateStateHash(blockchain BlockchainInterface, tokenSystem interface{}) string {
+	if providers := e.getStateProviders(tokenSystem); len(providers) > 0 {
+		stateRoot, err := CalculateStateRoot(providers...)
+		if err == nil {
+			return stateRoot
+		}
+		log.Printf("Failed to calculate state root: %v", err)
+	}
+
 	// Get blockchain state
 	lastBlock := blockchain.GetLastBlock()
 	pendingCount := len(blockchain.GetPendingTransactions())
@@ -381,10 +447,10 @@ func (e *ExecutionEngine) calculateStateHash(blockchain BlockchainInterface, tok
 	// Include token system state if available
 	tokenState := "no-token-system"
 	if tokenGetter, ok := tokenSystem.(interface {
-		GetTotalSupply() float64
+		GetTotalSupply() bnm.Amount
 	}); ok {
 		totalSupply := tokenGetter.GetTotalSupply()
-		tokenState = fmt.Sprintf("supply-%.2f", totalSupply)
+		tokenState = fmt.Sprintf("supply-%s", totalSupply)
 	}
 
 	return fmt.Sprintf("%s-%d-%d-%s", lastBlock.Hash, lastBlock.Index, pendingCount, tokenState)
@@ -404,6 +470,17 @@ func (e *ExecutionEngine) performIntegrityCheck(blockchain BlockchainInterface,
 		}
 	}
 
+	// With nothing applied on top of the last block, the state must still match the
+	// root the block committed to
+	e.mu.RLock()
+	providers := e.stateProviders
+	e.mu.RUnlock()
+	if len(providers) > 0 && len(chain) > 0 && len(blockchain.GetPendingTransactions()) == 0 {
+		if err := CheckStateRoot(chain[len(chain)-1], providers...); err != nil {
+			return fmt.Errorf("state integrity violation: %v", err)
+		}
+	}
+
 	// Verify token system integrity if available
 	if tokenValidator, ok := tokenSystem.(interface {
 		ValidateBalances() error
@@ -416,6 +493,21 @@ func (e *ExecutionEngine) performIntegrityCheck(blockchain BlockchainInterface,
 	return nil
 }
 
+// getStateProviders returns the configured state providers, falling back to the
+// token system when it can provide state itself
+func (e *ExecutionEngine) getStateProviders(tokenSystem interface{}) []StateProvider {
+	e.mu.RLock()
+	providers := e.stateProviders
+	e.mu.RUnlock()
+
+	if len(providers) == 0 {
+		if provider, ok := tokenSystem.(StateProvider); ok {
+			providers = []StateProvider{provider}
+		}
+	}
+	return providers
+}
+
 // countSuccessful counts successful transaction results
 func (e *ExecutionEngine) countSuccessful(results []TransactionResult) int {
 	count := 0
@@ -496,6 +588,31 @@ func (e *ExecutionEngine) GetStats() map[string]interface{} {
 	}
 }
 
+// ExecutionMetrics is a snapshot of the execution engine's performance counters
+type ExecutionMetrics struct {
+	Mode         string
+	Batches      uint64
+	Transactions uint64
+	Failed       uint64
+	TPS          float64
+	ErrorRate    float64
+}
+
+// Metrics returns the execution engine's current performance counters
+func (e *ExecutionEngine) Metrics() ExecutionMetrics {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return ExecutionMetrics{
+		Mode:         e.getModeString(),
+		Batches:      e.executionCount,
+		Transactions: e.transactionCount,
+		Failed:       e.failedCount,
+		TPS:          e.calculateCurrentTPS(),
+		ErrorRate:    e.getRecentErrorRate(),
+	}
+}
+
 // SafeOptimizePerformance safely adjusts configuration based on current performance
 func (e *ExecutionEngine) SafeOptimizePerformance() {
 	e.mu.Lock()
@@ -561,9 +678,10 @@ func (e *ExecutionEngine) estimateCPUUsage() float64 {
 	return float64(activeWorkers) / float64(maxWorkers) * 100
 }
 
-// getRecentErrorRate calculates recent error rate (simplified)
+// getRecentErrorRate returns the share of executed transactions that failed
 func (e *ExecutionEngine) getRecentErrorRate() float64 {
-	// This would need actual error tracking in production
-	// For now, return a safe default
-	return 0.001 // 0.1% default error rate
+	if e.transactionCount == 0 {
+		return 0
+	}
+	return float64(e.failedCount) / float64(e.transactionCount)
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/wallet"
 )
 
 // MockDPoSConsensus implements DelegateCounter for testing
@@ -21,16 +24,16 @@ func (m *MockDPoSConsensus) SetActiveDelegateCount(count int) {
 
 // MockTokenSystem implements basic token transfer for testing
 type MockTokenSystem struct {
-	balances map[string]float64
+	balances map[string]bnm.Amount
 }
 
 func NewMockTokenSystem() *MockTokenSystem {
 	return &MockTokenSystem{
-		balances: make(map[string]float64),
+		balances: make(map[string]bnm.Amount),
 	}
 }
 
-func (m *MockTokenSystem) Transfer(from, to string, amount float64) error {
+func (m *MockTokenSystem) Transfer(from, to string, amount bnm.Amount) error {
 	if m.balances[from] < amount {
 		return fmt.Errorf("insufficient balance")
 	}
@@ -39,17 +42,56 @@ func (m *MockTokenSystem) Transfer(from, to string, amount float64) error {
 	return nil
 }
 
-func (m *MockTokenSystem) SetBalance(address string, balance float64) {
+func (m *MockTokenSystem) SetBalance(address string, balance bnm.Amount) {
 	m.balances[address] = balance
 }
 
+// MockNonceTokenSystem adds per-account nonce tracking to MockTokenSystem
+type MockNonceTokenSystem struct {
+	*MockTokenSystem
+	nonces map[string]uint64
+}
+
+func NewMockNonceTokenSystem() *MockNonceTokenSystem {
+	return &MockNonceTokenSystem{
+		MockTokenSystem: NewMockTokenSystem(),
+		nonces:          make(map[string]uint64),
+	}
+}
+
+func (m *MockNonceTokenSystem) GetNonce(address string) uint64 {
+	return m.nonces[address]
+}
+
+func (m *MockNonceTokenSystem) ApplyStateChanges(changes []StateChange) error {
+	batch := NewStateOverlay(m)
+	if err := ApplyStateChanges(batch, changes); err != nil {
+		return err
+	}
+	for address, balance := range batch.balances {
+		m.balances[address] = balance
+	}
+	for address, nonce := range batch.nonces {
+		m.nonces[address] = nonce
+	}
+	return nil
+}
+
+func (m *MockNonceTokenSystem) GetBalance(address string) bnm.Amount {
+	return m.balances[address]
+}
+
+func (m *MockNonceTokenSystem) GetCirculatingSupply() bnm.Amount {
+	return 0
+}
+
 func TestExecutionEngine_SingleThreadedMode(t *testing.T) {
 	// Create test blockchain
 	blockchain := NewBlockchain()
 
 	// Create test token system
 	tokenSystem := NewMockTokenSystem()
-	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", 1000.0)
+	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", bnm.FromBNM(1000))
 
 	// Create execution engine with default config
 	engine := NewExecutionEngine(nil)
@@ -60,14 +102,14 @@ func TestExecutionEngine_SingleThreadedMode(t *testing.T) {
 			ID:        "AdNetest1234567890abcdef1234567890abcdef12345678901234567890",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest9876543210fedcba9876543210fedcba98765432",
-			Amount:    100.0,
+			Amount:    bnm.FromBNM(100),
 			Timestamp: time.Now().Unix(),
 		},
 		{
 			ID:        "AdNetest9876543210fedcba9876543210fedcba98765432109876543210",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest1111111111111111111111111111111111111111",
-			Amount:    50.0,
+			Amount:    bnm.FromBNM(50),
 			Timestamp: time.Now().Unix(),
 		},
 	}
@@ -103,7 +145,7 @@ func TestExecutionEngine_MultiThreadedMode(t *testing.T) {
 
 	// Create test token system
 	tokenSystem := NewMockTokenSystem()
-	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", 1000.0)
+	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", bnm.FromBNM(1000))
 
 	// Create execution engine with custom config for testing
 	config := &ExecutionConfig{
@@ -121,14 +163,14 @@ func TestExecutionEngine_MultiThreadedMode(t *testing.T) {
 			ID:        "AdNetest1234567890abcdef1234567890abcdef12345678901234567890",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest9876543210fedcba9876543210fedcba98765432",
-			Amount:    100.0,
+			Amount:    bnm.FromBNM(100),
 			Timestamp: time.Now().Unix(),
 		},
 		{
 			ID:        "AdNetest9876543210fedcba9876543210fedcba98765432109876543210",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest1111111111111111111111111111111111111111",
-			Amount:    50.0,
+			Amount:    bnm.FromBNM(50),
 			Timestamp: time.Now().Unix(),
 		},
 	}
@@ -158,6 +200,77 @@ func TestExecutionEngine_MultiThreadedMode(t *testing.T) {
 	}
 }
 
+func TestExecutionEngine_NonceReplayProtection(t *testing.T) {
+	sender := "AdNetest1234567890abcdef1234567890abcdef12345678"
+
+	for _, delegates := range []int{5, 15} {
+		blockchain := NewBlockchain()
+		tokenSystem := NewMockNonceTokenSystem()
+		tokenSystem.SetBalance(sender, bnm.FromBNM(1000))
+
+		engine := NewExecutionEngine(&ExecutionConfig{
+			DelegateThreshold: 10,
+			MaxWorkers:        4,
+			BatchSize:         10,
+			Timeout:           10 * time.Second,
+		})
+		engine.UpdateMode(delegates)
+
+		first := Transaction{From: sender, To: "AdNetest9876543210fedcba9876543210fedcba98765432", Amount: bnm.FromBNM(10), Nonce: 0, Timestamp: 1, Version: CurrentEncodingVersion}
+		second := Transaction{From: sender, To: "AdNetest9876543210fedcba9876543210fedcba98765432", Amount: bnm.FromBNM(10), Nonce: 1, Timestamp: 1, Version: CurrentEncodingVersion}
+		first.ID = CalculateTransactionID(&first)
+		second.ID = CalculateTransactionID(&second)
+
+		// Identical transfers in the same second no longer collide once nonces differ
+		if first.ID == second.ID {
+			t.Fatal("Expected transactions with different nonces to have different IDs")
+		}
+
+		// The replayed first transaction must be rejected as stale
+		results, err := engine.ExecuteTransactions([]Transaction{first, second, first}, blockchain, tokenSystem)
+		if err != nil {
+			t.Fatalf("Failed to execute transactions: %v", err)
+		}
+
+		if !results[0].Success || !results[1].Success {
+			t.Errorf("[%s] Expected in-order nonces to succeed: %v, %v", engine.getModeString(), results[0].Error, results[1].Error)
+		}
+		if results[2].Success {
+			t.Errorf("[%s] Expected replayed transaction to be rejected", engine.getModeString())
+		}
+		if balance := tokenSystem.balances[sender]; balance != bnm.FromBNM(980) {
+			t.Errorf("[%s] Expected sender balance 980, got %s", engine.getModeString(), balance)
+		}
+	}
+}
+
+func TestBlockchain_PendingTransactionsOrderedByNonce(t *testing.T) {
+	blockchain := NewBlockchain()
+
+	later := Transaction{ID: "AdNe-later", From: "AdNe-alice", To: "AdNe-bob", Amount: bnm.FromBNM(1), Nonce: 1}
+	other := Transaction{ID: "AdNe-other", From: "AdNe-carol", To: "AdNe-bob", Amount: bnm.FromBNM(1), Nonce: 7}
+	earlier := Transaction{ID: "AdNe-earlier", From: "AdNe-alice", To: "AdNe-bob", Amount: bnm.FromBNM(1), Nonce: 0}
+
+	for _, tx := range []Transaction{later, other, earlier} {
+		if err := blockchain.AddTransaction(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	// Rebroadcasting the same transaction is rejected
+	if err := blockchain.AddTransaction(later); err != ErrDuplicateTransaction {
+		t.Errorf("Expected ErrDuplicateTransaction, got %v", err)
+	}
+
+	pending := blockchain.GetPendingTransactions()
+	expected := []string{"AdNe-earlier", "AdNe-other", "AdNe-later"}
+	for i, id := range expected {
+		if pending[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s", i, id, pending[i].ID)
+		}
+	}
+}
+
 func TestExecutionEngine_ModeSwitch(t *testing.T) {
 	engine := NewExecutionEngine(nil)
 
@@ -185,7 +298,7 @@ func TestProtocol_Integration(t *testing.T) {
 	blockchain := NewBlockchain()
 	consensus := &MockDPoSConsensus{activeDelegateCount: 5}
 	tokenSystem := NewMockTokenSystem()
-	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", 1000.0)
+	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", bnm.FromBNM(1000))
 
 	// Create protocol with custom config
 	config := &ProtocolConfig{
@@ -220,7 +333,7 @@ func TestProtocol_Integration(t *testing.T) {
 			ID:        "AdNetest1234567890abcdef1234567890abcdef12345678901234567890",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest9876543210fedcba9876543210fedcba98765432",
-			Amount:    100.0,
+			Amount:    bnm.FromBNM(100),
 			Timestamp: time.Now().Unix(),
 		},
 	}
@@ -252,7 +365,7 @@ func TestProtocol_Integration(t *testing.T) {
 			ID:        "AdNetest9999999999999999999999999999999999999999999999999999",
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        "AdNetest2222222222222222222222222222222222222222",
-			Amount:    25.0,
+			Amount:    bnm.FromBNM(25),
 			Timestamp: time.Now().Unix(),
 		},
 	}
@@ -307,7 +420,7 @@ func TestExecutionEngine_Stats(t *testing.T) {
 func BenchmarkExecutionEngine_Sequential(b *testing.B) {
 	blockchain := NewBlockchain()
 	tokenSystem := NewMockTokenSystem()
-	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", 100000.0)
+	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", bnm.FromBNM(100000))
 
 	engine := NewExecutionEngine(nil)
 	engine.UpdateMode(5) // Force single-threaded mode
@@ -319,7 +432,7 @@ func BenchmarkExecutionEngine_Sequential(b *testing.B) {
 			ID:        fmt.Sprintf("AdNe%058d", i),
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        fmt.Sprintf("AdNe%058d", i+1000),
-			Amount:    1.0,
+			Amount:    bnm.FromBNM(1),
 			Timestamp: time.Now().Unix(),
 		}
 	}
@@ -337,7 +450,7 @@ func BenchmarkExecutionEngine_Sequential(b *testing.B) {
 func BenchmarkExecutionEngine_Parallel(b *testing.B) {
 	blockchain := NewBlockchain()
 	tokenSystem := NewMockTokenSystem()
-	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", 100000.0)
+	tokenSystem.SetBalance("AdNetest1234567890abcdef1234567890abcdef12345678", bnm.FromBNM(100000))
 
 	config := &ExecutionConfig{
 		DelegateThreshold:     5,
@@ -357,7 +470,7 @@ func BenchmarkExecutionEngine_Parallel(b *testing.B) {
 			ID:        fmt.Sprintf("AdNe%058d", i),
 			From:      "AdNetest1234567890abcdef1234567890abcdef12345678",
 			To:        fmt.Sprintf("AdNe%058d", i+1000),
-			Amount:    1.0,
+			Amount:    bnm.FromBNM(1),
 			Timestamp: time.Now().Unix(),
 		}
 	}
@@ -371,3 +484,34 @@ func BenchmarkExecutionEngine_Parallel(b *testing.B) {
 		}
 	}
 }
+
+func TestProtocol_CreateBlockRecordsFailedReceipts(t *testing.T) {
+	blockchain := NewBlockchain()
+	protocol := NewProtocol(blockchain, &MockDPoSConsensus{activeDelegateCount: 1}, NewMockTokenSystem(), nil)
+	producer, _ := wallet.NewWallet()
+
+	// The sender has no balance, so execution fails
+	tx := Transaction{ID: "AdNe-unfunded", From: "AdNe-alice", To: "AdNe-bob", Amount: bnm.FromBNM(10), Timestamp: time.Now().Unix()}
+	if err := blockchain.AddTransaction(tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	block, err := protocol.CreateBlock(producer)
+	if err != nil {
+		t.Fatalf("Failed to create block: %v", err)
+	}
+	if len(block.Data) != 0 {
+		t.Errorf("Expected the failed transaction to be left out, got %d transactions", len(block.Data))
+	}
+	if blockchain.Mempool().Contains(tx.ID) {
+		t.Error("Expected the failed transaction to leave the mempool")
+	}
+
+	receipt, err := blockchain.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatalf("Failed to get receipt: %v", err)
+	}
+	if receipt.Status != ReceiptFailed || receipt.Error == "" || receipt.Fee != 0 {
+		t.Errorf("Expected a failed receipt with its error, got %+v", receipt)
+	}
+}
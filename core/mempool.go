@@ -0,0 +1,362 @@
+package core
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+// ErrMempoolFull is returned when the mempool is full and a transaction does not pay
+// a higher fee rate than the cheapest one it could evict
+var ErrMempoolFull = errors.New("mempool is full")
+
+// DefaultMaxBlockTransactions is the default number of transactions taken from the
+// mempool for one block
+const DefaultMaxBlockTransactions = 1000
+
+// MempoolConfig holds configuration for the mempool
+type MempoolConfig struct {
+	// MaxSize is the maximum number of pending transactions
+	MaxSize int
+	// TTL is how long a transaction may wait before it expires
+	TTL time.Duration
+}
+
+// DefaultMempoolConfig returns default mempool configuration
+func DefaultMempoolConfig() *MempoolConfig {
+	return &MempoolConfig{
+		MaxSize: 10000,
+		TTL:     time.Hour,
+	}
+}
+
+// DroppedTransaction is a transaction that left the mempool without being included
+type DroppedTransaction struct {
+	Transaction Transaction
+	Reason      string
+	DroppedAt   time.Time
+}
+
+// mempoolEntry is a pending transaction with its admission metadata
+type mempoolEntry struct {
+	tx       Transaction
+	fee      int64 // Fee in base units
+	size     int64 // Canonical encoding size in bytes
+	sequence uint64
+	addedAt  time.Time
+}
+
+// higherFeeRate reports whether e pays more fee per byte than other, preferring
+// the earlier arrival on ties
+func (e *mempoolEntry) higherFeeRate(other *mempoolEntry) bool {
+	left, right := e.fee*other.size, other.fee*e.size
+	if left != right {
+		return left > right
+	}
+	return e.sequence < other.sequence
+}
+
+// Mempool holds pending transactions in per-sender queues ordered by nonce. Blocks
+// take transactions by fee rate, evictions drop the cheapest queue tails and
+// transactions expire after the configured TTL.
+type Mempool struct {
+	config   MempoolConfig
+	senders  map[string][]*mempoolEntry
+	byID     map[string]*mempoolEntry
+	sequence uint64
+	dropped  []DroppedTransaction // Expired and evicted transactions not yet taken
+	now      func() time.Time
+	mu       sync.RWMutex
+}
+
+// NewMempool creates a new mempool
+func NewMempool(config *MempoolConfig) *Mempool {
+	if config == nil {
+		config = DefaultMempoolConfig()
+	}
+
+	return &Mempool{
+		config:  *config,
+		senders: make(map[string][]*mempoolEntry),
+		byID:    make(map[string]*mempoolEntry),
+		now:     time.Now,
+	}
+}
+
+// SetConfig changes the mempool limits, evicting transactions over the new size
+func (m *Mempool) SetConfig(config *MempoolConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = *config
+	m.expire()
+	for m.config.MaxSize > 0 && len(m.byID) > m.config.MaxSize {
+		m.drop(m.cheapestTail(), "evicted: mempool is full")
+	}
+}
+
+// Add admits a transaction, evicting the cheapest transaction when the mempool is full
+func (m *Mempool) Add(tx Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	if _, exists := m.byID[tx.ID]; exists {
+		return ErrDuplicateTransaction
+	}
+
+	m.sequence++
+	entry := &mempoolEntry{
+		tx:       tx,
+		fee:      int64(tx.CalculateFee()),
+		size:     int64(len(EncodeTransaction(&tx))),
+		sequence: m.sequence,
+		addedAt:  m.now(),
+	}
+
+	if m.config.MaxSize > 0 && len(m.byID) >= m.config.MaxSize {
+		cheapest := m.cheapestTail()
+		if !entry.higherFeeRate(cheapest) {
+			return ErrMempoolFull
+		}
+		m.drop(cheapest, "evicted by a transaction paying a higher fee rate")
+	}
+
+	queue := append(m.senders[tx.From], entry)
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].tx.Nonce < queue[j].tx.Nonce
+	})
+	m.senders[tx.From] = queue
+	m.byID[tx.ID] = entry
+
+	return nil
+}
+
+// Contains reports whether a transaction is pending
+func (m *Mempool) Contains(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.byID[id]
+	return exists
+}
+
+// Get returns a pending transaction by ID
+func (m *Mempool) Get(id string) (Transaction, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.byID[id]
+	if !exists {
+		return Transaction{}, false
+	}
+	return entry.tx, true
+}
+
+// TakeDropped returns the transactions expired or evicted since the last call
+func (m *Mempool) TakeDropped() []DroppedTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dropped := m.dropped
+	m.dropped = nil
+	return dropped
+}
+
+// NextNonce returns the nonce following an address's pending transactions that
+// continue on from its confirmed next nonce
+func (m *Mempool) NextNonce(address string, confirmed uint64) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	next := confirmed
+	for _, entry := range m.senders[address] {
+		if entry.tx.Nonce == next {
+			next++
+		}
+	}
+	return next
+}
+
+// PendingSpend returns the amounts and fees of an address's pending transactions,
+// which its balance must still cover when they are included
+func (m *Mempool) PendingSpend(address string) bnm.Amount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	var spend bnm.Amount
+	for _, entry := range m.senders[address] {
+		spend += entry.tx.Amount + bnm.Amount(entry.fee)
+	}
+	return spend
+}
+
+// Size returns the number of pending transactions
+func (m *Mempool) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.byID)
+}
+
+// Pending returns all pending transactions in arrival order, with each sender's
+// transactions sorted by nonce
+func (m *Mempool) Pending() []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	entries := make([]*mempoolEntry, 0, len(m.byID))
+	for _, entry := range m.byID {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].sequence < entries[j].sequence
+	})
+
+	transactions := make([]Transaction, len(entries))
+	for i, entry := range entries {
+		transactions[i] = entry.tx
+	}
+	return orderByNonce(transactions)
+}
+
+// SelectForBlock returns up to limit transactions by descending fee rate, keeping
+// each sender's transactions in nonce order. A limit of 0 selects everything.
+func (m *Mempool) SelectForBlock(limit int) []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	if limit <= 0 || limit > len(m.byID) {
+		limit = len(m.byID)
+	}
+
+	// Only the head of each sender queue is eligible at a time
+	candidates := &mempoolHeap{}
+	for _, queue := range m.senders {
+		heap.Push(candidates, mempoolCursor{queue: queue})
+	}
+
+	selected := make([]Transaction, 0, limit)
+	for len(selected) < limit && candidates.Len() > 0 {
+		cursor := heap.Pop(candidates).(mempoolCursor)
+		selected = append(selected, cursor.queue[cursor.position].tx)
+		if cursor.position+1 < len(cursor.queue) {
+			cursor.position++
+			heap.Push(candidates, cursor)
+		}
+	}
+	return selected
+}
+
+// RemoveIncluded removes the transactions of a block from the mempool
+func (m *Mempool) RemoveIncluded(transactions []Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tx := range transactions {
+		if entry, exists := m.byID[tx.ID]; exists {
+			m.remove(entry)
+		}
+	}
+}
+
+// Clear removes all pending transactions
+func (m *Mempool) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.senders = make(map[string][]*mempoolEntry)
+	m.byID = make(map[string]*mempoolEntry)
+}
+
+// expire drops transactions older than the TTL together with the later transactions
+// of the same sender, which can no longer execute without them. Callers must hold m.mu.
+func (m *Mempool) expire() {
+	if m.config.TTL <= 0 {
+		return
+	}
+
+	cutoff := m.now().Add(-m.config.TTL)
+	for _, queue := range m.senders {
+		for i, entry := range queue {
+			if entry.addedAt.Before(cutoff) {
+				for _, stale := range queue[i:] {
+					m.drop(stale, "expired: not included within the mempool TTL")
+				}
+				break
+			}
+		}
+	}
+}
+
+// cheapestTail returns the last queued transaction with the lowest fee rate. Only
+// queue tails are evicted so no sender is left with a nonce gap. Callers must hold m.mu.
+func (m *Mempool) cheapestTail() *mempoolEntry {
+	var cheapest *mempoolEntry
+	for _, queue := range m.senders {
+		tail := queue[len(queue)-1]
+		if cheapest == nil || cheapest.higherFeeRate(tail) {
+			cheapest = tail
+		}
+	}
+	return cheapest
+}
+
+// drop removes an entry that will not be included and records why. Callers must hold m.mu.
+func (m *Mempool) drop(entry *mempoolEntry, reason string) {
+	m.remove(entry)
+	m.dropped = append(m.dropped, DroppedTransaction{Transaction: entry.tx, Reason: reason, DroppedAt: m.now()})
+}
+
+// remove deletes an entry from its sender queue. Callers must hold m.mu.
+func (m *Mempool) remove(entry *mempoolEntry) {
+	delete(m.byID, entry.tx.ID)
+
+	queue := m.senders[entry.tx.From]
+	for i, pending := range queue {
+		if pending == entry {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(m.senders, entry.tx.From)
+	} else {
+		m.senders[entry.tx.From] = queue
+	}
+}
+
+// mempoolCursor points at the next transaction of a sender queue
+type mempoolCursor struct {
+	queue    []*mempoolEntry
+	position int
+}
+
+// mempoolHeap orders sender queues by the fee rate of their next transaction
+type mempoolHeap []mempoolCursor
+
+func (h mempoolHeap) Len() int { return len(h) }
+func (h mempoolHeap) Less(i, j int) bool {
+	return h[i].queue[h[i].position].higherFeeRate(h[j].queue[h[j].position])
+}
+func (h mempoolHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mempoolHeap) Push(x interface{}) { *h = append(*h, x.(mempoolCursor)) }
+func (h *mempoolHeap) Pop() interface{} {
+	old := *h
+	cursor := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return cursor
+}
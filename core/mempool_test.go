@@ -0,0 +1,177 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/wallet"
+)
+
+func mempoolTx(id, from string, nonce uint64, amount int64) Transaction {
+	return Transaction{ID: id, From: from, To: "AdNe-bob", Amount: bnm.FromBNM(amount), Nonce: nonce}
+}
+
+func TestMempool_SelectForBlockByFeeRate(t *testing.T) {
+	mempool := NewMempool(nil)
+
+	// Alice's expensive second transaction waits behind her cheap first one
+	for _, tx := range []Transaction{
+		mempoolTx("AdNe-alice-1", "AdNe-alice", 1, 5000),
+		mempoolTx("AdNe-alice-0", "AdNe-alice", 0, 10),
+		mempoolTx("AdNe-carol-0", "AdNe-carol", 0, 1000),
+	} {
+		if err := mempool.Add(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	selected := mempool.SelectForBlock(0)
+	expected := []string{"AdNe-carol-0", "AdNe-alice-0", "AdNe-alice-1"}
+	if len(selected) != len(expected) {
+		t.Fatalf("Expected %d transactions, got %d", len(expected), len(selected))
+	}
+	for i, id := range expected {
+		if selected[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s", i, id, selected[i].ID)
+		}
+	}
+
+	if limited := mempool.SelectForBlock(1); len(limited) != 1 || limited[0].ID != "AdNe-carol-0" {
+		t.Errorf("Expected limit to keep only the highest fee transaction, got %v", limited)
+	}
+}
+
+func TestMempool_EvictsLowestFee(t *testing.T) {
+	mempool := NewMempool(&MempoolConfig{MaxSize: 2})
+
+	mempool.Add(mempoolTx("AdNe-cheap", "AdNe-alice", 0, 10))
+	mempool.Add(mempoolTx("AdNe-mid", "AdNe-carol", 0, 100))
+
+	// A cheaper transaction is refused when full
+	if err := mempool.Add(mempoolTx("AdNe-cheaper", "AdNe-dave", 0, 1)); err != ErrMempoolFull {
+		t.Errorf("Expected ErrMempoolFull, got %v", err)
+	}
+
+	// A higher paying one replaces the cheapest
+	if err := mempool.Add(mempoolTx("AdNe-rich", "AdNe-erin", 0, 1000)); err != nil {
+		t.Fatalf("Expected higher fee transaction to be admitted: %v", err)
+	}
+	if mempool.Contains("AdNe-cheap") {
+		t.Error("Expected the lowest fee transaction to be evicted")
+	}
+	if mempool.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", mempool.Size())
+	}
+}
+
+func TestMempool_ExpiresAfterTTL(t *testing.T) {
+	mempool := NewMempool(&MempoolConfig{MaxSize: 10, TTL: time.Minute})
+	now := time.Unix(1700000000, 0)
+	mempool.now = func() time.Time { return now }
+
+	mempool.Add(mempoolTx("AdNe-old", "AdNe-alice", 0, 10))
+	now = now.Add(30 * time.Second)
+	mempool.Add(mempoolTx("AdNe-old-next", "AdNe-alice", 1, 10))
+	mempool.Add(mempoolTx("AdNe-fresh", "AdNe-carol", 0, 10))
+	if spend := mempool.PendingSpend("AdNe-alice"); spend != bnm.FromBNM(20)+bnm.FromBNM(20).MulDiv(1, 1000) {
+		t.Errorf("Expected alice's two transactions and fees to be pending, got %s", spend)
+	}
+
+	// The first transaction expires and takes the sender's later nonce with it
+	now = now.Add(45 * time.Second)
+	pending := mempool.Pending()
+	if len(pending) != 1 || pending[0].ID != "AdNe-fresh" {
+		t.Errorf("Expected only the fresh transaction to remain, got %v", pending)
+	}
+	if spend := mempool.PendingSpend("AdNe-alice"); spend != 0 {
+		t.Errorf("Expected expired transactions to stop counting against alice, got %s", spend)
+	}
+}
+
+func TestMempool_RejectsDuplicates(t *testing.T) {
+	mempool := NewMempool(nil)
+
+	tx := mempoolTx("AdNe-tx", "AdNe-alice", 0, 10)
+	if err := mempool.Add(tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	if err := mempool.Add(tx); err != ErrDuplicateTransaction {
+		t.Errorf("Expected ErrDuplicateTransaction, got %v", err)
+	}
+}
+
+func TestBlockchain_AddBlockKeepsUnincludedTransactions(t *testing.T) {
+	blockchain := NewBlockchain()
+
+	senderWallet, _ := wallet.NewWallet()
+	receiverWallet, _ := wallet.NewWallet()
+	signed, err := NewTransaction(senderWallet.Address, receiverWallet.Address, bnm.FromBNM(10), 0, senderWallet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	included := *signed
+	waiting := mempoolTx("AdNe-waiting", "AdNe-carol", 0, 10)
+	blockchain.AddTransaction(included)
+	blockchain.AddTransaction(waiting)
+
+	lastBlock := blockchain.GetLastBlock()
+	block := Block{
+		Index:        lastBlock.Index + 1,
+		PreviousHash: lastBlock.Hash,
+		Timestamp:    lastBlock.Timestamp + 1,
+		Data:         []Transaction{included},
+		Validator:    "genesis",
+		Version:      CurrentBlockVersion,
+		MerkleRoot:   CalculateMerkleRoot([]Transaction{included}),
+	}
+	block.Hash = CalculateHash(block)
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	pending := blockchain.GetPendingTransactions()
+	if len(pending) != 1 || pending[0].ID != waiting.ID {
+		t.Errorf("Expected only the unincluded transaction to stay pending, got %v", pending)
+	}
+}
+
+func TestMempool_TakeDroppedReportsEvictionsAndExpiry(t *testing.T) {
+	mempool := NewMempool(&MempoolConfig{MaxSize: 1, TTL: time.Minute})
+	now := time.Unix(1700000000, 0)
+	mempool.now = func() time.Time { return now }
+
+	mempool.Add(mempoolTx("AdNe-cheap", "AdNe-alice", 0, 10))
+	mempool.Add(mempoolTx("AdNe-rich", "AdNe-carol", 0, 1000))
+	now = now.Add(2 * time.Minute)
+	mempool.Pending()
+
+	dropped := mempool.TakeDropped()
+	if len(dropped) != 2 || dropped[0].Transaction.ID != "AdNe-cheap" || dropped[1].Transaction.ID != "AdNe-rich" {
+		t.Fatalf("Expected the evicted then the expired transaction, got %v", dropped)
+	}
+	if dropped[0].Reason == "" || dropped[1].Reason == dropped[0].Reason {
+		t.Errorf("Expected distinct drop reasons, got %q and %q", dropped[0].Reason, dropped[1].Reason)
+	}
+	if again := mempool.TakeDropped(); len(again) != 0 {
+		t.Errorf("Expected dropped transactions to be taken once, got %v", again)
+	}
+}
+
+func TestMempool_NextNonceFollowsPendingTransactions(t *testing.T) {
+	mempool := NewMempool(nil)
+	mempool.Add(mempoolTx("AdNe-alice-3", "AdNe-alice", 3, 10))
+	mempool.Add(mempoolTx("AdNe-alice-4", "AdNe-alice", 4, 10))
+	mempool.Add(mempoolTx("AdNe-alice-6", "AdNe-alice", 6, 10))
+
+	// Only transactions continuing on from the confirmed nonce count
+	if next := mempool.NextNonce("AdNe-alice", 3); next != 5 {
+		t.Errorf("Expected next nonce 5, got %d", next)
+	}
+	if next := mempool.NextNonce("AdNe-alice", 2); next != 2 {
+		t.Errorf("Expected a gap to leave the confirmed nonce, got %d", next)
+	}
+	if next := mempool.NextNonce("AdNe-carol", 7); next != 7 {
+		t.Errorf("Expected the confirmed nonce without pending transactions, got %d", next)
+	}
+}
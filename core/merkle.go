@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Merkle tree nodes are domain separated so a leaf can never be replayed as an
+// inner node. Odd nodes are promoted to the next level unchanged rather than
+// paired with a copy of themselves.
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+// MerkleProofNode is one sibling on the path from a transaction to the Merkle root
+type MerkleProofNode struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // Sibling is hashed on the left of the running hash
+}
+
+// MerkleProof proves that a transaction is included under a block's Merkle root
+type MerkleProof struct {
+	TxID     string            `json:"txId"`
+	LeafHash string            `json:"leafHash"`
+	Index    int               `json:"index"`
+	Siblings []MerkleProofNode `json:"siblings"`
+	Root     string            `json:"root"`
+}
+
+// TransactionLeafHash returns the Merkle leaf hash of a transaction's canonical encoding
+func TransactionLeafHash(tx *Transaction) []byte {
+	return hashMerkleLeaf(EncodeTransaction(tx))
+}
+
+// CalculateMerkleRoot returns the hex Merkle root over the given transactions
+func CalculateMerkleRoot(transactions []Transaction) string {
+	leaves := make([][]byte, len(transactions))
+	for i := range transactions {
+		leaves[i] = TransactionLeafHash(&transactions[i])
+	}
+	return hex.EncodeToString(merkleRoot(leaves))
+}
+
+// BuildMerkleProof returns the inclusion proof of a transaction among a block's transactions
+func BuildMerkleProof(transactions []Transaction, txID string) (*MerkleProof, error) {
+	index := -1
+	for i := range transactions {
+		if transactions[i].ID == txID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("transaction %s not found in block", txID)
+	}
+
+	level := make([][]byte, len(transactions))
+	for i := range transactions {
+		level[i] = TransactionLeafHash(&transactions[i])
+	}
+
+	proof := &MerkleProof{
+		TxID:     txID,
+		LeafHash: hex.EncodeToString(level[index]),
+		Index:    index,
+		Siblings: []MerkleProofNode{},
+	}
+
+	position := index
+	for len(level) > 1 {
+		sibling := position ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, MerkleProofNode{
+				Hash: hex.EncodeToString(level[sibling]),
+				Left: sibling < position,
+			})
+		}
+		level = nextMerkleLevel(level)
+		position /= 2
+	}
+	proof.Root = hex.EncodeToString(level[0])
+
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that the proof links the transaction to the given Merkle root
+func VerifyMerkleProof(tx *Transaction, proof *MerkleProof, root string) error {
+	if tx.ID != proof.TxID {
+		return fmt.Errorf("proof is for transaction %s, not %s", proof.TxID, tx.ID)
+	}
+
+	current := TransactionLeafHash(tx)
+	if hex.EncodeToString(current) != proof.LeafHash {
+		return fmt.Errorf("transaction does not match proof leaf")
+	}
+
+	for _, node := range proof.Siblings {
+		sibling, err := hex.DecodeString(node.Hash)
+		if err != nil {
+			return fmt.Errorf("invalid proof node encoding: %v", err)
+		}
+		if node.Left {
+			current = hashMerkleNode(sibling, current)
+		} else {
+			current = hashMerkleNode(current, sibling)
+		}
+	}
+
+	expected, err := hex.DecodeString(root)
+	if err != nil {
+		return fmt.Errorf("invalid Merkle root encoding: %v", err)
+	}
+	if !bytes.Equal(current, expected) {
+		return fmt.Errorf("proof does not lead to Merkle root %s", root)
+	}
+
+	return nil
+}
+
+// verifyMerkleRoot checks that a block's Merkle root matches its transactions. Blocks
+// from before MerkleEncodingVersion hash their full transaction list instead.
+func verifyMerkleRoot(block Block) error {
+	if block.Version < MerkleEncodingVersion {
+		return nil
+	}
+	if block.MerkleRoot != CalculateMerkleRoot(block.Data) {
+		return fmt.Errorf("invalid Merkle root")
+	}
+	return nil
+}
+
+// merkleRoot folds leaf hashes into a Merkle root, hashing nothing for an empty tree
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+// hashMerkleLeaf hashes leaf data with the leaf domain prefix
+func hashMerkleLeaf(data []byte) []byte {
+	hashed := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+	return hashed[:]
+}
+
+// nextMerkleLevel hashes pairs of nodes, promoting a trailing odd node
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashMerkleNode(level[i], level[i+1]))
+	}
+	return next
+}
+
+func hashMerkleNode(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+	hashed := sha256.Sum256(data)
+	return hashed[:]
+}
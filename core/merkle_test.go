@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	// Cover even and odd transaction counts, including promoted nodes
+	for count := 1; count <= 7; count++ {
+		transactions := make([]Transaction, count)
+		for i := range transactions {
+			transactions[i] = Transaction{
+				ID:      fmt.Sprintf("AdNe%d", i),
+				From:    "AdNeA",
+				To:      "AdNeB",
+				Amount:  bnm.FromBNM(int64(i + 1)),
+				Nonce:   uint64(i),
+				Version: CurrentEncodingVersion,
+			}
+		}
+		root := CalculateMerkleRoot(transactions)
+
+		for i := range transactions {
+			proof, err := BuildMerkleProof(transactions, transactions[i].ID)
+			if err != nil {
+				t.Fatalf("Failed to build proof for %d of %d: %v", i, count, err)
+			}
+			if proof.Root != root {
+				t.Errorf("Proof root %s does not match %s", proof.Root, root)
+			}
+			if err := VerifyMerkleProof(&transactions[i], proof, root); err != nil {
+				t.Errorf("Expected proof for %d of %d to verify: %v", i, count, err)
+			}
+
+			tampered := transactions[i]
+			tampered.Amount++
+			if err := VerifyMerkleProof(&tampered, proof, root); err == nil {
+				t.Errorf("Expected proof for a tampered transaction to fail")
+			}
+		}
+	}
+
+	if _, err := BuildMerkleProof([]Transaction{{ID: "AdNe0"}}, "AdNe1"); err == nil {
+		t.Error("Expected proof for a missing transaction to fail")
+	}
+}
+
+func TestBlockMerkleRootValidation(t *testing.T) {
+	bc := NewBlockchain()
+	genesis := bc.GetLastBlock()
+
+	block := Block{Index: 1, PreviousHash: genesis.Hash, Timestamp: 1700000000, Data: []Transaction{}, Validator: "genesis", Version: CurrentBlockVersion}
+	block.MerkleRoot = CalculateMerkleRoot(block.Data)
+	block.Hash = CalculateHash(block)
+
+	// The header hash covers the Merkle root, so a wrong root cannot hide behind a valid hash
+	wrongRoot := block
+	wrongRoot.MerkleRoot = CalculateMerkleRoot([]Transaction{{ID: "AdNe0"}})
+	wrongRoot.Hash = CalculateHash(wrongRoot)
+	if err := bc.AddBlock(wrongRoot); err == nil {
+		t.Error("Expected block with a mismatched Merkle root to be rejected")
+	}
+
+	if err := bc.AddBlock(block); err != nil {
+		t.Errorf("Expected block with a valid Merkle root to be accepted: %v", err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/wallet"
 )
 
 // BlockchainInterface defines the interface for blockchain implementations
@@ -15,15 +18,20 @@ type BlockchainInterface interface {
 	GetBlockByIndex(index uint64) (Block, error)
 	AddTransaction(tx Transaction) error
 	GetPendingTransactions() []Transaction
-	ReplaceChain(newChain []Block)
+	Mempool() *Mempool
+	ReplaceChain(newChain []Block) error
+	BlockReader
+	TransactionIndexer
+	ReceiptStore
 }
 
 // TokenInterface defines the interface for token implementations
 type TokenInterface interface {
-	Transfer(from, to string, amount float64) error
-	GetBalance(address string) float64
-	GetCirculatingSupply() float64
-	Burn(amount float64)
+	Transfer(from, to string, amount bnm.Amount) error
+	GetBalance(address string) bnm.Amount
+	GetCirculatingSupply() bnm.Amount
+	Burn(amount bnm.Amount)
+	NonceTracker
 }
 
 // Node represents a node in the Binomena network
@@ -36,6 +44,10 @@ type Node struct {
 	mu               sync.RWMutex
 	stopChan         chan struct{}
 	validatorAddress string
+	validatorWallet  *wallet.Wallet
+	executor         BlockExecutor
+	production       ProductionObserver
+	broadcaster      BlockBroadcaster
 }
 
 // Consensus interface for consensus mechanisms
@@ -44,12 +56,38 @@ type Consensus interface {
 	SelectValidator(validators []string, stakes map[string]float64) string
 }
 
+// ProducerScheduler is implemented by consensus mechanisms with a fixed block
+// production schedule, such as DPoS round-robin slots
+type ProducerScheduler interface {
+	GetScheduledProducer(timestamp int64) string
+}
+
+// FinalityGadget is implemented by consensus mechanisms that make blocks irreversible
+type FinalityGadget interface {
+	// UpdateIrreversibleBlock takes the canonical chain from the current last
+	// irreversible block to the tip and returns the new last irreversible block
+	UpdateIrreversibleBlock(head []Block) BlockHeader
+	GetLastIrreversibleBlock() BlockHeader
+}
+
+// BlockExecutor settles transactions for a block at a height on top of the current
+// state without applying them, and computes the state root they leave behind
+type BlockExecutor interface {
+	ExecuteTransactions(transactions []Transaction, height uint64) *Execution
+	StateRoot(execution *Execution, height uint64) (string, error)
+}
+
+// BlockBroadcaster publishes the blocks a node produces to its peers
+type BlockBroadcaster interface {
+	BroadcastBlock(block Block) error
+}
+
 // Token interface for token operations (deprecated, use TokenInterface)
 type Token interface {
-	Transfer(from, to string, amount float64) error
-	GetBalance(address string) float64
-	GetCirculatingSupply() float64
-	Burn(amount float64)
+	Transfer(from, to string, amount bnm.Amount) error
+	GetBalance(address string) bnm.Amount
+	GetCirculatingSupply() bnm.Amount
+	Burn(amount bnm.Amount)
 }
 
 // Peer represents a peer node in the network
@@ -71,6 +109,41 @@ func NewNode(blockchain BlockchainInterface, consensus Consensus, token TokenInt
 	}
 }
 
+// SetValidatorWallet sets the key used to sign blocks produced by this node
+func (n *Node) SetValidatorWallet(validatorWallet *wallet.Wallet) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.validatorWallet = validatorWallet
+	n.validatorAddress = validatorWallet.Address
+}
+
+// SetBlockExecutor sets the executor settling pending transactions before they go
+// into a produced block, so transactions that would fail are left out and the block
+// commits to the state root they leave behind
+func (n *Node) SetBlockExecutor(executor BlockExecutor) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.executor = executor
+}
+
+// SetBlockBroadcaster sets where blocks produced by this node are published
+func (n *Node) SetBlockBroadcaster(broadcaster BlockBroadcaster) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.broadcaster = broadcaster
+}
+
+// SetProductionObserver sets the observer notified of blocks produced by this node
+func (n *Node) SetProductionObserver(observer ProductionObserver) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.production = observer
+}
+
 // Start starts the node
 func (n *Node) Start() {
 	n.mu.Lock()
@@ -106,9 +179,7 @@ func (n *Node) SubmitTransaction(tx Transaction) error {
 	}
 
 	// Add transaction to blockchain (fee handling is done at API level)
-	n.blockchain.AddTransaction(tx)
-
-	return nil
+	return n.blockchain.AddTransaction(tx)
 }
 
 // GetPeerCount returns the number of peers
@@ -136,34 +207,99 @@ func (n *Node) blockCreationLoop() {
 
 // createNewBlock creates a new block and adds it to the blockchain
 func (n *Node) createNewBlock() {
-	// Get pending transactions
-	transactions := n.blockchain.GetPendingTransactions()
+	start := time.Now()
+
+	// Take the highest paying pending transactions
+	transactions := n.blockchain.Mempool().SelectForBlock(DefaultMaxBlockTransactions)
 	if len(transactions) == 0 {
 		return // No transactions to process
 	}
 
+	n.mu.RLock()
+	producer := n.validatorWallet
+	executor := n.executor
+	production := n.production
+	broadcaster := n.broadcaster
+	n.mu.RUnlock()
+
+	// Only validators holding a signing key produce blocks
+	if producer == nil {
+		return
+	}
+
 	// Get the last block
 	lastBlock := n.blockchain.GetLastBlock()
+	timestamp := time.Now().Unix()
+
+	// Get the scheduled validator for this slot from consensus
+	var validator string
+	if scheduler, ok := n.consensus.(ProducerScheduler); ok {
+		validator = scheduler.GetScheduledProducer(timestamp)
+	} else {
+		validator = n.consensus.SelectValidator([]string{}, map[string]float64{})
+	}
+
+	// Skip slots that belong to other delegates
+	if validator != producer.Address {
+		return
+	}
 
-	// Get the current validator from consensus
-	validator := n.consensus.SelectValidator([]string{}, map[string]float64{})
+	// Leave out transactions the sender can no longer pay for. They leave the
+	// mempool with a receipt recording why.
+	var execution *Execution
+	if executor != nil {
+		execution = executor.ExecuteTransactions(transactions, lastBlock.Index+1)
+		dropFailed(n.blockchain, execution.Failed)
+		transactions = execution.Transactions
+		if len(transactions) == 0 {
+			return
+		}
+	}
 
 	// Create new block
 	newBlock := Block{
 		Index:        lastBlock.Index + 1,
 		PreviousHash: lastBlock.Hash,
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    timestamp,
 		Data:         transactions,
-		Validator:    validator,
+		Version:      CurrentBlockVersion,
+		MerkleRoot:   CalculateMerkleRoot(transactions),
+	}
+
+	// Commit to the state the block's transactions leave behind
+	if execution != nil {
+		stateRoot, err := executor.StateRoot(execution, newBlock.Index)
+		if err != nil {
+			fmt.Printf("Error calculating state root: %v\n", err)
+			return
+		}
+		newBlock.StateRoot = stateRoot
 	}
 
-	// Calculate hash
-	newBlock.Hash = CalculateHash(newBlock)
+	// Sign the block hash with the producer's key
+	if err := SignBlock(&newBlock, producer); err != nil {
+		fmt.Printf("Error signing block: %v\n", err)
+		return
+	}
+
+	// Check the block against consensus rules before adding it
+	if !n.consensus.ValidateBlock(newBlock) {
+		fmt.Printf("Block #%d rejected by consensus\n", newBlock.Index)
+		return
+	}
 
 	// Add block to blockchain
 	if err := n.blockchain.AddBlock(newBlock); err != nil {
 		fmt.Printf("Error adding block: %v\n", err)
 	} else {
 		fmt.Printf("Block #%d produced by validator %s\n", newBlock.Index, validator)
+		if production != nil {
+			production.BlockProduced(newBlock, time.Since(start))
+		}
+		if broadcaster != nil {
+			if err := broadcaster.BroadcastBlock(newBlock); err != nil {
+				fmt.Printf("Error broadcasting block #%d: %v\n", newBlock.Index, err)
+			}
+		}
 	}
 }
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// stubExecutor settles every pending transaction except those it is told fail
+type stubExecutor struct {
+	failing map[string]bool
+}
+
+func (e *stubExecutor) ExecuteTransactions(transactions []Transaction, height uint64) *Execution {
+	execution := &Execution{}
+	for i := range transactions {
+		tx := transactions[i]
+		if e.failing[tx.ID] {
+			execution.Failed = append(execution.Failed, TransactionResult{Transaction: &tx, Error: errors.New("insufficient balance")})
+			continue
+		}
+		execution.Transactions = append(execution.Transactions, tx)
+	}
+	return execution
+}
+
+func (e *stubExecutor) StateRoot(execution *Execution, height uint64) (string, error) {
+	return "stub-root", nil
+}
+
+// soloConsensus schedules a single producer and accepts every block
+type soloConsensus struct {
+	producer string
+}
+
+func (c *soloConsensus) ValidateBlock(block Block) bool { return true }
+
+func (c *soloConsensus) SelectValidator(validators []string, stakes map[string]float64) string {
+	return c.producer
+}
+
+// recordingBroadcaster remembers the blocks it was asked to publish
+type recordingBroadcaster struct {
+	blocks []Block
+}
+
+func (b *recordingBroadcaster) BroadcastBlock(block Block) error {
+	b.blocks = append(b.blocks, block)
+	return nil
+}
+
+// pendingTransfers adds a transfer that settles and one that fails to a mempool
+func pendingTransfers(t *testing.T, blockchain BlockchainInterface) (Transaction, Transaction) {
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	carol, _ := wallet.NewWallet()
+	settles, _ := NewTransaction(alice.Address, bob.Address, bnm.FromBNM(10), 0, alice)
+	fails, _ := NewTransaction(carol.Address, bob.Address, bnm.FromBNM(10), 0, carol)
+	for _, tx := range []*Transaction{settles, fails} {
+		if err := blockchain.AddTransaction(*tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	return *settles, *fails
+}
+
+// checkFailedReceipt checks a transaction left the mempool with a failed receipt
+func checkFailedReceipt(t *testing.T, blockchain BlockchainInterface, tx Transaction) {
+	if blockchain.Mempool().Contains(tx.ID) {
+		t.Error("Expected the failed transaction to leave the mempool")
+	}
+	receipt, err := blockchain.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatalf("Failed to get receipt: %v", err)
+	}
+	if receipt.Status != ReceiptFailed || receipt.Error != "insufficient balance" {
+		t.Errorf("Expected a failed receipt with its error, got %+v", receipt)
+	}
+}
+
+func TestNode_ProducedBlockRecordsFailedReceiptsAndIsBroadcast(t *testing.T) {
+	blockchain := NewBlockchain()
+	producer, _ := wallet.NewWallet()
+	settles, fails := pendingTransfers(t, blockchain)
+
+	node := NewNode(blockchain, &soloConsensus{producer: producer.Address}, nil, producer.Address)
+	node.SetValidatorWallet(producer)
+	node.SetBlockExecutor(&stubExecutor{failing: map[string]bool{fails.ID: true}})
+	broadcaster := &recordingBroadcaster{}
+	node.SetBlockBroadcaster(broadcaster)
+	node.createNewBlock()
+
+	block := blockchain.GetLastBlock()
+	if block.Index != 1 || len(block.Data) != 1 || block.Data[0].ID != settles.ID {
+		t.Fatalf("Expected a block of the settling transaction, got %+v", block)
+	}
+	if block.StateRoot != "stub-root" {
+		t.Errorf("Expected the executor's state root, got %q", block.StateRoot)
+	}
+	checkFailedReceipt(t, blockchain, fails)
+
+	if len(broadcaster.blocks) != 1 || broadcaster.blocks[0].Hash != block.Hash {
+		t.Errorf("Expected the produced block to be broadcast, got %d blocks", len(broadcaster.blocks))
+	}
+}
+
+func TestProtocol_CreateBlockUsesBlockExecutor(t *testing.T) {
+	blockchain := NewBlockchain()
+	protocol := NewProtocol(blockchain, &MockDPoSConsensus{activeDelegateCount: 1}, NewMockTokenSystem(), nil)
+	producer, _ := wallet.NewWallet()
+	settles, fails := pendingTransfers(t, blockchain)
+
+	protocol.SetBlockExecutor(&stubExecutor{failing: map[string]bool{fails.ID: true}})
+	block, err := protocol.CreateBlock(producer)
+	if err != nil {
+		t.Fatalf("Failed to create block: %v", err)
+	}
+	if len(block.Data) != 1 || block.Data[0].ID != settles.ID {
+		t.Errorf("Expected a block of the settling transaction, got %d transactions", len(block.Data))
+	}
+	if block.StateRoot != "stub-root" {
+		t.Errorf("Expected the executor's state root, got %q", block.StateRoot)
+	}
+	checkFailedReceipt(t, blockchain, fails)
+}
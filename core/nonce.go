@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrDuplicateTransaction is returned when a transaction ID is already pending
+var ErrDuplicateTransaction = errors.New("transaction already pending")
+
+// NonceTracker is implemented by token systems that track per-account nonces. A
+// nonce is only consumed by a NonceChange applied together with its transfer.
+type NonceTracker interface {
+	// GetNonce returns the next nonce expected from an address
+	GetNonce(address string) uint64
+}
+
+// orderByNonce returns the transactions with each sender's transactions sorted by
+// nonce while keeping the positions each sender occupies in arrival order
+func orderByNonce(transactions []Transaction) []Transaction {
+	ordered := make([]Transaction, len(transactions))
+	copy(ordered, transactions)
+
+	positions := make(map[string][]int)
+	for i, tx := range ordered {
+		positions[tx.From] = append(positions[tx.From], i)
+	}
+
+	for _, slots := range positions {
+		if len(slots) < 2 {
+			continue
+		}
+
+		senderTxs := make([]Transaction, len(slots))
+		for i, slot := range slots {
+			senderTxs[i] = ordered[slot]
+		}
+		sort.SliceStable(senderTxs, func(i, j int) bool {
+			return senderTxs[i].Nonce < senderTxs[j].Nonce
+		})
+		for i, slot := range slots {
+			ordered[slot] = senderTxs[i]
+		}
+	}
+
+	return ordered
+}
@@ -0,0 +1,31 @@
+package core
+
+import "time"
+
+// ChainObserver is notified of blocks joining the canonical chain, including the
+// blocks of a branch taking over, and of transactions accepted into the mempool.
+// It is called while the blockchain is locked, so it must neither block nor call
+// back into the blockchain.
+type ChainObserver interface {
+	BlockAdded(block Block)
+	TransactionAdded(tx Transaction)
+}
+
+// notifyNewBlocks tells an observer about the blocks of a replacement chain that
+// were not part of the chain it replaced
+func notifyNewBlocks(observer ChainObserver, chain []Block, previous map[string]bool) {
+	if observer == nil {
+		return
+	}
+	for _, block := range chain {
+		if !previous[block.Hash] {
+			observer.BlockAdded(block)
+		}
+	}
+}
+
+// ProductionObserver is notified of the blocks a node produces and how long
+// producing each took, from selecting its transactions to adding it to the chain
+type ProductionObserver interface {
+	BlockProduced(block Block, elapsed time.Duration)
+}
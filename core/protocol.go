@@ -5,6 +5,8 @@ import (
 	"log"
 	"sync"
 	"time"
+
+	"github.com/igo-used/binomena/wallet"
 )
 
 // DelegateCounter interface for counting active delegates
@@ -22,6 +24,9 @@ type Protocol struct {
 	isRunning             bool
 	delegateCheckInterval time.Duration
 	lastDelegateCount     int
+	stateProviders        []StateProvider
+	executor              BlockExecutor
+	maxBlockTransactions  int
 }
 
 // ProtocolConfig holds configuration for the protocol layer
@@ -32,6 +37,8 @@ type ProtocolConfig struct {
 	DelegateCheckInterval time.Duration
 	// EnableAutoMode enables automatic switching between execution modes
 	EnableAutoMode bool
+	// MaxBlockTransactions limits how many mempool transactions go into a block
+	MaxBlockTransactions int
 }
 
 // DefaultProtocolConfig returns default protocol configuration
@@ -40,6 +47,7 @@ func DefaultProtocolConfig() *ProtocolConfig {
 		ExecutionConfig:       DefaultExecutionConfig(),
 		DelegateCheckInterval: 10 * time.Second, // Check every 10 seconds
 		EnableAutoMode:        true,             // Enable automatic mode switching
+		MaxBlockTransactions:  DefaultMaxBlockTransactions,
 	}
 }
 
@@ -58,6 +66,7 @@ func NewProtocol(blockchain BlockchainInterface, consensus DelegateCounter, toke
 		tokenSystem:           tokenSystem,
 		delegateCheckInterval: config.DelegateCheckInterval,
 		lastDelegateCount:     0,
+		maxBlockTransactions:  config.MaxBlockTransactions,
 	}
 
 	// Initial delegate count check
@@ -69,6 +78,38 @@ func NewProtocol(blockchain BlockchainInterface, consensus DelegateCounter, toke
 	return protocol
 }
 
+// SetStateProviders sets the state committed to by the state root of created blocks
+// and checked by the execution engine's integrity checks
+func (p *Protocol) SetStateProviders(providers ...StateProvider) {
+	p.mu.Lock()
+	p.stateProviders = providers
+	p.mu.Unlock()
+
+	p.executionEngine.SetStateProviders(providers...)
+}
+
+// SetBlockExecutor sets the executor settling pending transactions before they go
+// into a created block, with the same rules blocks are applied with. Created blocks
+// then commit to the state root their transactions leave behind.
+func (p *Protocol) SetBlockExecutor(executor BlockExecutor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.executor = executor
+}
+
+// stateRoot returns the current state root, or no root when no state is tracked
+func (p *Protocol) stateRoot() (string, error) {
+	p.mu.RLock()
+	providers := p.stateProviders
+	p.mu.RUnlock()
+
+	if len(providers) == 0 {
+		return "", nil
+	}
+	return CalculateStateRoot(providers...)
+}
+
 // Start starts the protocol layer services
 func (p *Protocol) Start() error {
 	p.mu.Lock()
@@ -125,13 +166,21 @@ func (p *Protocol) ProcessTransactions(transactions []Transaction) ([]Transactio
 	return results, nil
 }
 
-// CreateBlock creates a new block with processed transactions
-func (p *Protocol) CreateBlock(validator string, signature string) (*Block, error) {
-	// Get pending transactions
-	pendingTxs := p.blockchain.GetPendingTransactions()
+// CreateBlock creates a new block with processed transactions, signed by the producer
+func (p *Protocol) CreateBlock(producer *wallet.Wallet) (*Block, error) {
+	// Take the highest paying pending transactions
+	pendingTxs := p.blockchain.Mempool().SelectForBlock(p.maxBlockTransactions)
+
+	p.mu.RLock()
+	executor := p.executor
+	p.mu.RUnlock()
+	if executor != nil {
+		return p.createExecutedBlock(producer, executor, pendingTxs)
+	}
+
 	if len(pendingTxs) == 0 {
 		// Create empty block if no pending transactions
-		return p.createEmptyBlock(validator, signature), nil
+		return p.createEmptyBlock(producer)
 	}
 
 	// Process transactions
@@ -140,13 +189,21 @@ func (p *Protocol) CreateBlock(validator string, signature string) (*Block, erro
 		return nil, err
 	}
 
-	// Filter successful transactions for the block
+	// Filter successful transactions for the block. Failed transactions leave the
+	// mempool with a receipt recording why.
 	var successfulTxs []Transaction
+	var failed []TransactionResult
 	for _, result := range results {
-		if result.Success && result.Transaction != nil {
+		if result.Transaction == nil {
+			continue
+		}
+		if result.Success {
 			successfulTxs = append(successfulTxs, *result.Transaction)
+		} else {
+			failed = append(failed, result)
 		}
 	}
+	dropFailed(p.blockchain, failed)
 
 	// Create block with successful transactions
 	lastBlock := p.blockchain.GetLastBlock()
@@ -155,16 +212,59 @@ func (p *Protocol) CreateBlock(validator string, signature string) (*Block, erro
 		PreviousHash: lastBlock.Hash,
 		Timestamp:    time.Now().Unix(),
 		Data:         successfulTxs,
-		Validator:    validator,
-		Signature:    signature,
+		Version:      CurrentBlockVersion,
+		MerkleRoot:   CalculateMerkleRoot(successfulTxs),
 	}
 
-	// Calculate block hash
-	newBlock.Hash = CalculateHash(newBlock)
+	// Commit to the state left after executing the transactions
+	stateRoot, err := p.stateRoot()
+	if err != nil {
+		return nil, err
+	}
+	newBlock.StateRoot = stateRoot
+
+	// Calculate and sign block hash
+	if err := SignBlock(&newBlock, producer); err != nil {
+		return nil, err
+	}
 
 	return &newBlock, nil
 }
 
+// createExecutedBlock creates a block of the pending transactions that settle on top
+// of the current state, committing to the state root they leave behind. Failed
+// transactions leave the mempool with a receipt recording why.
+func (p *Protocol) createExecutedBlock(producer *wallet.Wallet, executor BlockExecutor, pendingTxs []Transaction) (*Block, error) {
+	lastBlock := p.blockchain.GetLastBlock()
+	height := lastBlock.Index + 1
+
+	execution := executor.ExecuteTransactions(pendingTxs, height)
+	dropFailed(p.blockchain, execution.Failed)
+
+	newBlock := Block{
+		Index:        height,
+		PreviousHash: lastBlock.Hash,
+		Timestamp:    time.Now().Unix(),
+		Data:         execution.Transactions,
+		Version:      CurrentBlockVersion,
+		MerkleRoot:   CalculateMerkleRoot(execution.Transactions),
+	}
+	if newBlock.Data == nil {
+		newBlock.Data = []Transaction{}
+	}
+
+	stateRoot, err := executor.StateRoot(execution, height)
+	if err != nil {
+		return nil, err
+	}
+	newBlock.StateRoot = stateRoot
+
+	if err := SignBlock(&newBlock, producer); err != nil {
+		return nil, err
+	}
+	return &newBlock, nil
+}
+
 // GetExecutionStats returns current execution engine statistics
 func (p *Protocol) GetExecutionStats() map[string]interface{} {
 	stats := p.executionEngine.GetStats()
@@ -292,7 +392,7 @@ func (p *Protocol) updateExecutionMode() {
 }
 
 // createEmptyBlock creates an empty block for cases with no pending transactions
-func (p *Protocol) createEmptyBlock(validator string, signature string) *Block {
+func (p *Protocol) createEmptyBlock(producer *wallet.Wallet) (*Block, error) {
 	lastBlock := p.blockchain.GetLastBlock()
 
 	emptyBlock := Block{
@@ -300,12 +400,20 @@ func (p *Protocol) createEmptyBlock(validator string, signature string) *Block {
 		PreviousHash: lastBlock.Hash,
 		Timestamp:    time.Now().Unix(),
 		Data:         []Transaction{},
-		Validator:    validator,
-		Signature:    signature,
+		Version:      CurrentBlockVersion,
+		MerkleRoot:   CalculateMerkleRoot(nil),
 	}
 
-	emptyBlock.Hash = CalculateHash(emptyBlock)
-	return &emptyBlock
+	stateRoot, err := p.stateRoot()
+	if err != nil {
+		return nil, err
+	}
+	emptyBlock.StateRoot = stateRoot
+
+	if err := SignBlock(&emptyBlock, producer); err != nil {
+		return nil, err
+	}
+	return &emptyBlock, nil
 }
 
 // logExecutionStats logs execution statistics
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+// ReceiptStatus is where a submitted transaction stands
+type ReceiptStatus string
+
+const (
+	// ReceiptPending marks a transaction waiting in the mempool
+	ReceiptPending ReceiptStatus = "pending"
+	// ReceiptIncluded marks a transaction included in the canonical chain
+	ReceiptIncluded ReceiptStatus = "included"
+	// ReceiptFailed marks a transaction the execution engine rejected
+	ReceiptFailed ReceiptStatus = "failed"
+	// ReceiptDropped marks a transaction that expired or was evicted from the mempool
+	ReceiptDropped ReceiptStatus = "dropped"
+)
+
+// FeeDistribution is how a transaction fee was split between the DPoS recipients
+type FeeDistribution struct {
+	Delegates bnm.Amount `json:"delegates"`
+	Burned    bnm.Amount `json:"burned"`
+	Community bnm.Amount `json:"community"`
+	Founder   bnm.Amount `json:"founder"`
+}
+
+// FeeRecorder is a state applier that records the fee split it paid for each
+// transaction of the blocks it applies
+type FeeRecorder interface {
+	// TakePaidFees returns the fee splits paid for the transactions of an applied
+	// block in order, and forgets them
+	TakePaidFees(blockHash string) []FeeDistribution
+}
+
+// paidFees returns the fee splits a state applier paid for the transactions of a
+// block, or none if it does not record them
+func paidFees(applier StateApplier, block Block) []FeeDistribution {
+	recorder, ok := applier.(FeeRecorder)
+	if !ok {
+		return nil
+	}
+	return recorder.TakePaidFees(block.Hash)
+}
+
+// Receipt records the outcome of a submitted transaction. Block fields are set
+// once the transaction is included; confirmations count the blocks from its
+// block to the tip, inclusive.
+type Receipt struct {
+	TxID            string           `json:"txId"`
+	Status          ReceiptStatus    `json:"status"`
+	BlockIndex      uint64           `json:"blockIndex,omitempty"`
+	BlockHash       string           `json:"blockHash,omitempty"`
+	Position        int              `json:"position"`
+	Fee             bnm.Amount       `json:"fee"`
+	FeeDistribution *FeeDistribution `json:"feeDistribution,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	Confirmations   uint64           `json:"confirmations"`
+	Timestamp       int64            `json:"timestamp"`
+}
+
+// ReceiptStore keeps the receipts of submitted transactions
+type ReceiptStore interface {
+	// GetReceipt returns the current receipt of a transaction
+	GetReceipt(id string) (Receipt, error)
+	// RecordReceipt stores the receipt of a transaction that failed or was dropped.
+	// Receipts of included transactions are kept.
+	RecordReceipt(receipt Receipt) error
+}
+
+// NewFailedReceipt returns the receipt of a transaction the execution engine rejected.
+// No fee is charged for it.
+func NewFailedReceipt(result TransactionResult) Receipt {
+	receipt := Receipt{
+		TxID:      result.Transaction.ID,
+		Status:    ReceiptFailed,
+		Timestamp: time.Now().Unix(),
+	}
+	if result.Error != nil {
+		receipt.Error = result.Error.Error()
+	}
+	return receipt
+}
+
+// newDroppedReceipt returns the receipt of a transaction that left the mempool
+func newDroppedReceipt(dropped DroppedTransaction) Receipt {
+	return Receipt{
+		TxID:      dropped.Transaction.ID,
+		Status:    ReceiptDropped,
+		Fee:       dropped.Transaction.CalculateFee(),
+		Error:     dropped.Reason,
+		Timestamp: dropped.DroppedAt.Unix(),
+	}
+}
+
+// newPendingReceipt returns the receipt of a transaction waiting in the mempool
+func newPendingReceipt(tx Transaction) Receipt {
+	return Receipt{
+		TxID:      tx.ID,
+		Status:    ReceiptPending,
+		Fee:       tx.CalculateFee(),
+		Timestamp: tx.Timestamp,
+	}
+}
+
+// newIncludedReceipt returns the receipt of the transaction at a position of a block,
+// with the fee split paid for it if known
+func newIncludedReceipt(block Block, position int, fees []FeeDistribution) Receipt {
+	tx := block.Data[position]
+	receipt := Receipt{
+		TxID:       tx.ID,
+		Status:     ReceiptIncluded,
+		BlockIndex: block.Index,
+		BlockHash:  block.Hash,
+		Position:   position,
+		Fee:        tx.CalculateFee(),
+		Timestamp:  block.Timestamp,
+	}
+	if position < len(fees) {
+		distribution := fees[position]
+		receipt.FeeDistribution = &distribution
+	}
+	return receipt
+}
+
+// withConfirmations sets the confirmations of an included receipt below a tip
+func (r Receipt) withConfirmations(tip Block) Receipt {
+	if r.Status == ReceiptIncluded && tip.Index >= r.BlockIndex {
+		r.Confirmations = tip.Index - r.BlockIndex + 1
+	}
+	return r
+}
+
+// dropFailed records the receipts of transactions that failed to execute for a block
+// and removes them from the mempool
+func dropFailed(blockchain BlockchainInterface, failed []TransactionResult) {
+	for _, result := range failed {
+		if result.Transaction == nil {
+			continue
+		}
+		if err := blockchain.RecordReceipt(NewFailedReceipt(result)); err != nil {
+			fmt.Printf("Error recording receipt of %s: %v\n", result.Transaction.ID, err)
+		}
+		blockchain.Mempool().RemoveIncluded([]Transaction{*result.Transaction})
+	}
+}
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrKnownBlock is returned when a block that is already part of the block tree is added again
+var ErrKnownBlock = errors.New("block already known")
+
+// StateApplier applies and reverts the state changes of blocks as they join or leave
+// the canonical chain
+type StateApplier interface {
+	ApplyBlock(block Block) error
+	RevertBlock(block Block) error
+}
+
+// TokenStateApplier settles the transactions of canonical blocks on a token system.
+// Every block is settled with SettleTransaction, whichever way it arrived, and the
+// token system keeps the changes of each block so it can be reverted later.
+type TokenStateApplier struct {
+	token     TokenInterface
+	fees      FeeSchedule
+	providers []StateProvider              // State committed to by the state root beside the token's
+	paid      map[string][]FeeDistribution // Fee splits paid by applied blocks until their receipts take them
+	mu        sync.Mutex
+}
+
+// NewTokenStateApplier creates a state applier for a token system paying out fees
+// as the fee schedule decides. Without a schedule, fees are burned.
+func NewTokenStateApplier(token TokenInterface, fees FeeSchedule) *TokenStateApplier {
+	return &TokenStateApplier{
+		token: token,
+		fees:  fees,
+		paid:  make(map[string][]FeeDistribution),
+	}
+}
+
+// SetStateProviders sets the state committed to by the state root beside the token
+// balances, such as delegates and contract storage
+func (a *TokenStateApplier) SetStateProviders(providers ...StateProvider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.providers = providers
+}
+
+// StateRoot returns the state root of a block at a height whose transactions were
+// executed, the same root CheckStateRoot verifies once the block is applied
+func (a *TokenStateApplier) StateRoot(execution *Execution, height uint64) (string, error) {
+	a.mu.Lock()
+	providers := append([]StateProvider{execution.State}, a.providers...)
+	a.mu.Unlock()
+
+	return CalculateStateRootAt(height, providers...)
+}
+
+// Execution is the outcome of settling transactions on top of the current state
+// without applying them
+type Execution struct {
+	Transactions []Transaction       // Transactions that settled, in order
+	Fees         []FeeDistribution   // Fee split of each settled transaction
+	Failed       []TransactionResult // Transactions that could not be settled
+	Changes      []StateChange       // State changes of the settled transactions
+	State        *StateOverlay       // State after the settled transactions
+}
+
+// ExecuteTransactions settles transactions for a block at a height on top of the
+// current state without applying them. Transactions that cannot be settled are
+// left out.
+func (a *TokenStateApplier) ExecuteTransactions(transactions []Transaction, height uint64) *Execution {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.execute(transactions, height)
+}
+
+// execute settles transactions on an overlay of the token state. Callers must hold a.mu.
+func (a *TokenStateApplier) execute(transactions []Transaction, height uint64) *Execution {
+	execution := &Execution{State: NewStateOverlay(a.token)}
+	for i := range transactions {
+		tx := transactions[i]
+		changes, fees, err := SettleTransaction(execution.State, tx, height, a.fees)
+		if err != nil {
+			execution.Failed = append(execution.Failed, TransactionResult{Transaction: &tx, Error: err})
+			continue
+		}
+		execution.Transactions = append(execution.Transactions, tx)
+		execution.Fees = append(execution.Fees, fees)
+		execution.Changes = append(execution.Changes, changes...)
+	}
+	return execution
+}
+
+// ApplyBlock settles all transactions of a block, or none of them if any fails or
+// they do not lead to the state root the block commits to
+func (a *TokenStateApplier) ApplyBlock(block Block) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	store, ok := a.token.(StateChangeStore)
+	if !ok {
+		return fmt.Errorf("token system cannot apply state changes atomically")
+	}
+
+	execution, err := a.prepare(block)
+	if err != nil {
+		return err
+	}
+	if err := store.ApplyBlockChanges(block.Hash, execution.Changes); err != nil {
+		return err
+	}
+	a.paid[block.Hash] = execution.Fees
+	return nil
+}
+
+// PrepareBlock settles all transactions of a block without applying them, failing
+// if any of them fails, so that their changes can be written later
+func (a *TokenStateApplier) PrepareBlock(block Block) (*Execution, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.prepare(block)
+}
+
+// prepare settles all transactions of a block on an overlay and checks that blocks
+// committing to a state root lead to that root. Callers must hold a.mu.
+func (a *TokenStateApplier) prepare(block Block) (*Execution, error) {
+	execution := a.execute(block.Data, block.Index)
+	if len(execution.Failed) > 0 {
+		failed := execution.Failed[0]
+		return nil, fmt.Errorf("failed to apply transaction %s: %v", failed.Transaction.ID, failed.Error)
+	}
+
+	if block.Version >= StateRootEncodingVersion {
+		root, err := CalculateStateRootAt(block.Index, append([]StateProvider{execution.State}, a.providers...)...)
+		if err != nil {
+			return nil, err
+		}
+		if root != block.StateRoot {
+			return nil, fmt.Errorf("state root mismatch at block #%d: local %s, block %s", block.Index, root, block.StateRoot)
+		}
+	}
+	return execution, nil
+}
+
+// RevertBlock undoes the changes a block applied
+func (a *TokenStateApplier) RevertBlock(block Block) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	store, ok := a.token.(StateChangeStore)
+	if !ok {
+		return fmt.Errorf("token system cannot revert state changes")
+	}
+	if err := store.RevertBlockChanges(block.Hash); err != nil {
+		return err
+	}
+	delete(a.paid, block.Hash)
+	return nil
+}
+
+// TakePaidFees returns the fee splits paid for the transactions of an applied block
+// in order, and forgets them
+func (a *TokenStateApplier) TakePaidFees(blockHash string) []FeeDistribution {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fees := a.paid[blockHash]
+	delete(a.paid, blockHash)
+	return fees
+}
+
+// switchState reverts the state changes of blocks leaving the canonical chain,
+// newest first, and applies those of the blocks joining it. If a block cannot be
+// reverted or applied, the previous state is restored.
+func switchState(applier StateApplier, oldBlocks, newBlocks []Block) error {
+	if applier == nil {
+		return nil
+	}
+
+	for i := len(oldBlocks) - 1; i >= 0; i-- {
+		if err := applier.RevertBlock(oldBlocks[i]); err != nil {
+			restoreState(applier, nil, oldBlocks[i+1:])
+			return fmt.Errorf("failed to revert block %d: %v", oldBlocks[i].Index, err)
+		}
+	}
+	for i, block := range newBlocks {
+		if err := applier.ApplyBlock(block); err != nil {
+			restoreState(applier, newBlocks[:i], oldBlocks)
+			return fmt.Errorf("failed to apply block %d: %v", block.Index, err)
+		}
+	}
+	return nil
+}
+
+// restoreState reverts the blocks applied by a failed switch and re-applies the
+// blocks it reverted
+func restoreState(applier StateApplier, applied, reverted []Block) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := applier.RevertBlock(applied[i]); err != nil {
+			fmt.Printf("Error restoring block #%d: %v\n", applied[i].Index, err)
+		}
+	}
+	for _, block := range reverted {
+		if err := applier.ApplyBlock(block); err != nil {
+			fmt.Printf("Error restoring block #%d: %v\n", block.Index, err)
+		}
+	}
+}
+
+// chainDivergence returns the blocks of two chains after their last common block
+func chainDivergence(current, replacement []Block) ([]Block, []Block) {
+	common := 0
+	for common < len(current) && common < len(replacement) && current[common].Hash == replacement[common].Hash {
+		common++
+	}
+
+	// Genesis blocks carry no transactions to settle
+	if common == 0 {
+		common = 1
+	}
+	if common > len(current) {
+		common = len(current)
+	}
+	if common > len(replacement) {
+		common = len(replacement)
+	}
+	return current[common:], replacement[common:]
+}
+
+// AdoptBranch offers a branch of blocks received from a peer, forking off the
+// canonical chain, to fork choice. If the branch is preferred over the canonical
+// blocks after its fork point, the chain is replaced and their state switched as
+// in any reorganization. It reports whether the branch was adopted. The branch's
+// blocks must have passed consensus validation; ReplaceChain validates their
+// contents before any state is switched.
+func AdoptBranch(blockchain BlockchainInterface, branch []Block) (bool, error) {
+	if len(branch) == 0 {
+		return false, nil
+	}
+	for i := 1; i < len(branch); i++ {
+		if branch[i].Index != branch[i-1].Index+1 || branch[i].PreviousHash != branch[i-1].Hash {
+			return false, fmt.Errorf("block %d does not follow block %d of the branch", branch[i].Index, branch[i-1].Index)
+		}
+	}
+
+	chain := blockchain.GetChain()
+	fork := branch[0].Index
+	if fork == 0 || fork > uint64(len(chain)) || chain[fork-1].Hash != branch[0].PreviousHash {
+		return false, fmt.Errorf("branch does not fork off the canonical chain")
+	}
+	if !preferBranch(branch, chain[fork:]) {
+		return false, nil
+	}
+
+	newChain := make([]Block, 0, int(fork)+len(branch))
+	newChain = append(newChain, chain[:fork]...)
+	newChain = append(newChain, branch...)
+	if err := blockchain.ReplaceChain(newChain); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// preferBranch reports whether a competing branch should replace the canonical blocks
+// after their common ancestor. DPoS delegates produce in turn, so the branch built by
+// more distinct producers wins and length only breaks ties. On a full tie the branch
+// seen first is kept.
+func preferBranch(candidate, current []Block) bool {
+	candidateProducers := distinctValidators(candidate)
+	currentProducers := distinctValidators(current)
+	if candidateProducers != currentProducers {
+		return candidateProducers > currentProducers
+	}
+	return len(candidate) > len(current)
+}
+
+// distinctValidators counts the distinct producers of a list of blocks
+func distinctValidators(blocks []Block) int {
+	validators := make(map[string]bool)
+	for _, block := range blocks {
+		validators[block.Validator] = true
+	}
+	return len(validators)
+}
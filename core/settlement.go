@@ -0,0 +1,339 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/igo-used/binomena/bnm"
+)
+
+// TreasuryAddress holds the undistributed supply and collects transaction fees
+// before they are paid out
+const TreasuryAddress = "treasury"
+
+// StateChangeType names a kind of token state change
+type StateChangeType string
+
+const (
+	// TransferChange moves an amount from one address to another
+	TransferChange StateChangeType = "transfer"
+	// BurnChange removes an amount from the circulating supply
+	BurnChange StateChangeType = "burn"
+	// NonceChange consumes the nonce of an address
+	NonceChange StateChangeType = "nonce"
+)
+
+// StateChange is a single effect of settling a transaction on token state
+type StateChange struct {
+	Type   StateChangeType `json:"type"`
+	From   string          `json:"from,omitempty"` // Sender of a transfer, or the address whose nonce is used
+	To     string          `json:"to,omitempty"`
+	Amount bnm.Amount      `json:"amount,omitempty"`
+	Nonce  uint64          `json:"nonce,omitempty"`
+}
+
+// StateChangeStore is a token system that applies state changes all at once or
+// not at all. The changes applied for a block are kept with the token state, so
+// the block can be reverted after a restart.
+type StateChangeStore interface {
+	ApplyStateChanges(changes []StateChange) error
+	ApplyBlockChanges(blockHash string, changes []StateChange) error
+	RevertBlockChanges(blockHash string) error
+}
+
+// StateBatch reads and writes token state within a single atomic batch
+type StateBatch interface {
+	Balance(address string) (bnm.Amount, error)
+	SetBalance(address string, balance bnm.Amount) error
+	Nonce(address string) (uint64, error)
+	SetNonce(address string, nonce uint64) error
+	Supply() (bnm.Amount, error)
+	SetSupply(supply bnm.Amount) error
+}
+
+// ApplyStateChanges applies state changes to a batch in order, failing on the
+// first change that cannot be applied
+func ApplyStateChanges(batch StateBatch, changes []StateChange) error {
+	for _, change := range changes {
+		var err error
+		switch change.Type {
+		case TransferChange:
+			err = moveBalance(batch, change.From, change.To, change.Amount)
+		case BurnChange:
+			err = adjustSupply(batch, -change.Amount)
+		case NonceChange:
+			err = moveNonce(batch, change.From, change.Nonce, change.Nonce+1)
+		default:
+			err = fmt.Errorf("unknown state change %q", change.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevertStateChanges undoes state changes applied to a batch, newest first
+func RevertStateChanges(batch StateBatch, changes []StateChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		var err error
+		switch change.Type {
+		case TransferChange:
+			err = moveBalance(batch, change.To, change.From, change.Amount)
+		case BurnChange:
+			err = adjustSupply(batch, change.Amount)
+		case NonceChange:
+			err = moveNonce(batch, change.From, change.Nonce+1, change.Nonce)
+		default:
+			err = fmt.Errorf("unknown state change %q", change.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveBalance moves an amount between two balances of a batch
+func moveBalance(batch StateBatch, from, to string, amount bnm.Amount) error {
+	fromBalance, err := batch.Balance(from)
+	if err != nil {
+		return err
+	}
+	if fromBalance < amount {
+		return fmt.Errorf("insufficient balance")
+	}
+	if err := batch.SetBalance(from, fromBalance-amount); err != nil {
+		return err
+	}
+
+	// Read the receiver after the sender for self-transfers
+	toBalance, err := batch.Balance(to)
+	if err != nil {
+		return err
+	}
+	return batch.SetBalance(to, toBalance+amount)
+}
+
+// adjustSupply adds a positive or negative amount to the circulating supply
+func adjustSupply(batch StateBatch, amount bnm.Amount) error {
+	supply, err := batch.Supply()
+	if err != nil {
+		return err
+	}
+	if supply+amount < 0 {
+		return fmt.Errorf("burning would exceed the circulating supply")
+	}
+	return batch.SetSupply(supply + amount)
+}
+
+// moveNonce moves the nonce of an address from an expected value to the next one
+func moveNonce(batch StateBatch, address string, expected, next uint64) error {
+	nonce, err := batch.Nonce(address)
+	if err != nil {
+		return err
+	}
+	if nonce != expected {
+		return fmt.Errorf("invalid nonce for %s: expected %d, got %d", address, nonce, expected)
+	}
+	return batch.SetNonce(address, next)
+}
+
+// FeePayout is a share of a transaction fee paid out of the treasury
+type FeePayout struct {
+	Address string     `json:"address"`
+	Amount  bnm.Amount `json:"amount"`
+}
+
+// FeeSchedule decides how the fee of a transaction included at a block height is
+// paid out of the treasury and how much of it is burned
+type FeeSchedule interface {
+	FeePayouts(fee bnm.Amount, height uint64) ([]FeePayout, FeeDistribution)
+}
+
+// FeeChanges returns the changes paying fee shares out of the treasury and burning
+// the rest
+func FeeChanges(payouts []FeePayout, burned bnm.Amount) []StateChange {
+	changes := []StateChange{}
+	for _, payout := range payouts {
+		if payout.Amount > 0 {
+			changes = append(changes, StateChange{Type: TransferChange, From: TreasuryAddress, To: payout.Address, Amount: payout.Amount})
+		}
+	}
+	if burned > 0 {
+		changes = append(changes, StateChange{Type: BurnChange, Amount: burned})
+	}
+	return changes
+}
+
+// AccountState is read-only token state that settlement is computed against
+type AccountState interface {
+	GetBalance(address string) bnm.Amount
+	GetNonce(address string) uint64
+	GetCirculatingSupply() bnm.Amount
+}
+
+// StateOverlay is a batch of changes on top of token state that is never written
+// back, used to settle transactions before a block applies them for real
+type StateOverlay struct {
+	base     AccountState
+	balances map[string]bnm.Amount
+	nonces   map[string]uint64
+	supply   *bnm.Amount
+}
+
+// NewStateOverlay creates an empty overlay on top of token state
+func NewStateOverlay(base AccountState) *StateOverlay {
+	return &StateOverlay{
+		base:     base,
+		balances: make(map[string]bnm.Amount),
+		nonces:   make(map[string]uint64),
+	}
+}
+
+// Balance returns the balance of an address as changed by the overlay
+func (o *StateOverlay) Balance(address string) (bnm.Amount, error) {
+	if balance, ok := o.balances[address]; ok {
+		return balance, nil
+	}
+	return o.base.GetBalance(address), nil
+}
+
+// SetBalance changes the balance of an address in the overlay
+func (o *StateOverlay) SetBalance(address string, balance bnm.Amount) error {
+	o.balances[address] = balance
+	return nil
+}
+
+// Nonce returns the next nonce of an address as changed by the overlay
+func (o *StateOverlay) Nonce(address string) (uint64, error) {
+	if nonce, ok := o.nonces[address]; ok {
+		return nonce, nil
+	}
+	return o.base.GetNonce(address), nil
+}
+
+// SetNonce changes the next nonce of an address in the overlay
+func (o *StateOverlay) SetNonce(address string, nonce uint64) error {
+	o.nonces[address] = nonce
+	return nil
+}
+
+// Supply returns the circulating supply as changed by the overlay
+func (o *StateOverlay) Supply() (bnm.Amount, error) {
+	if o.supply != nil {
+		return *o.supply, nil
+	}
+	return o.base.GetCirculatingSupply(), nil
+}
+
+// SetSupply changes the circulating supply in the overlay
+func (o *StateOverlay) SetSupply(supply bnm.Amount) error {
+	o.supply = &supply
+	return nil
+}
+
+// TransferChanges returns the changes moving the amount of a transaction together
+// with consuming the sender's nonce, the only way a nonce is ever used
+func TransferChanges(tx Transaction) []StateChange {
+	changes := []StateChange{}
+	// Legacy transactions predate nonces
+	if tx.Version != LegacyEncodingVersion {
+		changes = append(changes, StateChange{Type: NonceChange, From: tx.From, Nonce: tx.Nonce})
+	}
+	return append(changes, StateChange{Type: TransferChange, From: tx.From, To: tx.To, Amount: tx.Amount})
+}
+
+// StateEntries returns the state root entries of the token state as changed by the
+// overlay
+func (o *StateOverlay) StateEntries() (map[string][]byte, error) {
+	provider, ok := o.base.(StateProvider)
+	if !ok {
+		return nil, fmt.Errorf("token state is not committed to by the state root")
+	}
+	entries, err := provider.StateEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	for address, balance := range o.balances {
+		if balance == 0 {
+			delete(entries, BalanceStateKey(address))
+		} else {
+			entries[BalanceStateKey(address)] = balance.Bytes()
+		}
+	}
+	return entries, nil
+}
+
+// SettleTransaction settles a transaction included at a block height: the sender's
+// nonce is consumed, the amount moves to the recipient and the fee to the treasury,
+// which pays it out and burns its share as the fee schedule decides. The changes
+// are applied to the batch and returned along with the fee split, or the batch is
+// left as it was if the transaction cannot be settled.
+func SettleTransaction(batch *StateOverlay, tx Transaction, height uint64, fees FeeSchedule) ([]StateChange, FeeDistribution, error) {
+	if tx.Amount <= 0 {
+		return nil, FeeDistribution{}, ErrNonPositiveAmount
+	}
+	fee := tx.CalculateFee()
+
+	changes := append(TransferChanges(tx),
+		StateChange{Type: TransferChange, From: tx.From, To: TreasuryAddress, Amount: fee},
+	)
+
+	distribution := FeeDistribution{Burned: fee}
+	var payouts []FeePayout
+	if fees != nil {
+		payouts, distribution = fees.FeePayouts(fee, height)
+	}
+	changes = append(changes, FeeChanges(payouts, distribution.Burned)...)
+
+	// Check the sender covers the amount and fee before touching the batch
+	balance, err := batch.Balance(tx.From)
+	if err != nil {
+		return nil, FeeDistribution{}, err
+	}
+	if balance < tx.Amount+fee {
+		return nil, FeeDistribution{}, fmt.Errorf("insufficient balance: %s required, %s available", tx.Amount+fee, balance)
+	}
+
+	// Apply the changes on top of the batch so a failure leaves it untouched
+	settled := NewStateOverlay(batch)
+	if err := ApplyStateChanges(settled, changes); err != nil {
+		return nil, FeeDistribution{}, err
+	}
+	batch.merge(settled)
+	return changes, distribution, nil
+}
+
+// merge takes over the changes of an overlay on top of this one
+func (o *StateOverlay) merge(child *StateOverlay) {
+	for address, balance := range child.balances {
+		o.balances[address] = balance
+	}
+	for address, nonce := range child.nonces {
+		o.nonces[address] = nonce
+	}
+	if child.supply != nil {
+		o.supply = child.supply
+	}
+}
+
+// GetBalance returns the balance of an address as changed by the overlay, so
+// overlays can be stacked
+func (o *StateOverlay) GetBalance(address string) bnm.Amount {
+	balance, _ := o.Balance(address)
+	return balance
+}
+
+// GetNonce returns the next nonce of an address as changed by the overlay
+func (o *StateOverlay) GetNonce(address string) uint64 {
+	nonce, _ := o.Nonce(address)
+	return nonce
+}
+
+// GetCirculatingSupply returns the circulating supply as changed by the overlay
+func (o *StateOverlay) GetCirculatingSupply() bnm.Amount {
+	supply, _ := o.Supply()
+	return supply
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// StateProvider is implemented by components whose state is committed to by the
+// state root. Keys must be unique across providers, so each provider prefixes them
+// with its own namespace, such as "balance/" or "delegate/".
+type StateProvider interface {
+	StateEntries() (map[string][]byte, error)
+}
+
+// HeightStateProvider is implemented by state providers whose committed state depends
+// on the block height, such as the delegate set in force at each block
+type HeightStateProvider interface {
+	StateEntriesAt(height uint64) (map[string][]byte, error)
+}
+
+// BalanceStateKey returns the state root key of an address's balance
+func BalanceStateKey(address string) string {
+	return "balance/" + address
+}
+
+// heightState provides the entries of a provider as committed to by the block at a height
+type heightState struct {
+	provider StateProvider
+	height   uint64
+}
+
+// StateEntries returns the provider's entries at the height when they depend on it
+func (s heightState) StateEntries() (map[string][]byte, error) {
+	if provider, ok := s.provider.(HeightStateProvider); ok {
+		return provider.StateEntriesAt(s.height)
+	}
+	return s.provider.StateEntries()
+}
+
+// CalculateStateRootAt returns the state root the block at a height commits to, with
+// providers that depend on the height giving their entries at that height
+func CalculateStateRootAt(height uint64, providers ...StateProvider) (string, error) {
+	atHeight := make([]StateProvider, len(providers))
+	for i, provider := range providers {
+		atHeight[i] = heightState{provider: provider, height: height}
+	}
+	return CalculateStateRoot(atHeight...)
+}
+
+// CalculateStateRoot returns the hex Merkle root over the state entries of all
+// providers. Entries are sorted by key and each leaf hashes the length-prefixed key
+// and value, so the root only depends on the state itself.
+func CalculateStateRoot(providers ...StateProvider) (string, error) {
+	entries := make(map[string][]byte)
+	for _, provider := range providers {
+		providerEntries, err := provider.StateEntries()
+		if err != nil {
+			return "", fmt.Errorf("failed to read state entries: %v", err)
+		}
+		for key, value := range providerEntries {
+			if _, exists := entries[key]; exists {
+				return "", fmt.Errorf("duplicate state key %s", key)
+			}
+			entries[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	leaves := make([][]byte, len(keys))
+	for i, key := range keys {
+		var buf bytes.Buffer
+		writeString(&buf, key)
+		writeString(&buf, string(entries[key]))
+		leaves[i] = hashMerkleLeaf(buf.Bytes())
+	}
+
+	return hex.EncodeToString(merkleRoot(leaves)), nil
+}
+
+// CheckStateRoot compares the state of the providers with the state root committed
+// by a block, as it was after the block was applied. Blocks without a state root are
+// not checked.
+func CheckStateRoot(block Block, providers ...StateProvider) error {
+	if block.StateRoot == "" {
+		return nil
+	}
+
+	root, err := CalculateStateRootAt(block.Index, providers...)
+	if err != nil {
+		return err
+	}
+	if root != block.StateRoot {
+		return fmt.Errorf("state root mismatch at block #%d: local %s, block %s", block.Index, root, block.StateRoot)
+	}
+	return nil
+}
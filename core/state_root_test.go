@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+)
+
+type stateMap map[string][]byte
+
+func (s stateMap) StateEntries() (map[string][]byte, error) {
+	return s, nil
+}
+
+func TestStateRoot(t *testing.T) {
+	balances := stateMap{"balance/AdNeA": []byte{1}, "balance/AdNeB": []byte{2}}
+	delegates := stateMap{"delegate/AdNeA": []byte{3}}
+
+	root, err := CalculateStateRoot(balances, delegates)
+	if err != nil {
+		t.Fatalf("Failed to calculate state root: %v", err)
+	}
+
+	// The root depends on the state, not on how it is split across providers
+	merged := stateMap{"balance/AdNeA": []byte{1}, "balance/AdNeB": []byte{2}, "delegate/AdNeA": []byte{3}}
+	if other, _ := CalculateStateRoot(merged); other != root {
+		t.Errorf("Expected the same state to give the same root, got %s and %s", root, other)
+	}
+
+	balances["balance/AdNeB"] = []byte{4}
+	changed, _ := CalculateStateRoot(balances, delegates)
+	if changed == root {
+		t.Error("Expected a balance change to change the state root")
+	}
+
+	if _, err := CalculateStateRoot(balances, stateMap{"balance/AdNeA": []byte{1}}); err == nil {
+		t.Error("Expected duplicate state keys to be rejected")
+	}
+
+	block := Block{Index: 7, StateRoot: changed}
+	if err := CheckStateRoot(block, balances, delegates); err != nil {
+		t.Errorf("Expected matching state to pass: %v", err)
+	}
+	block.StateRoot = root
+	if err := CheckStateRoot(block, balances, delegates); err == nil {
+		t.Error("Expected diverged state to be detected")
+	}
+}
+
+func TestBlockHashCoversStateRoot(t *testing.T) {
+	block := Block{Index: 1, PreviousHash: "prev", Timestamp: 1700000000, Validator: "AdNeA", Version: StateRootEncodingVersion}
+	block.MerkleRoot = CalculateMerkleRoot(block.Data)
+	block.StateRoot = "aa"
+	hash := CalculateHash(block)
+
+	block.StateRoot = "bb"
+	if CalculateHash(block) == hash {
+		t.Error("Expected the block hash to change with the state root")
+	}
+}
@@ -7,21 +7,25 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/wallet"
 )
 
 // Transaction represents a transaction in the blockchain
 type Transaction struct {
-	ID        string  `json:"id"`
-	From      string  `json:"from"`
-	To        string  `json:"to"`
-	Amount    float64 `json:"amount"`
-	Timestamp int64   `json:"timestamp"`
-	Signature string  `json:"signature"`
+	ID        string     `json:"id"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Amount    bnm.Amount `json:"amount"`
+	Nonce     uint64     `json:"nonce"`
+	Timestamp int64      `json:"timestamp"`
+	Signature string     `json:"signature"`
+	PublicKey string     `json:"publicKey,omitempty"` // Signer's uncompressed hex public key
+	Version   uint32     `json:"version,omitempty"`   // Hash encoding version, 0 for legacy transactions
 }
 
-// NewTransaction creates a new transaction
-func NewTransaction(from, to string, amount float64, senderWallet *wallet.Wallet) (*Transaction, error) {
+// NewTransaction creates a new transaction using the sender's next account nonce
+func NewTransaction(from, to string, amount bnm.Amount, nonce uint64, senderWallet *wallet.Wallet) (*Transaction, error) {
 	// Validate addresses
 	if from[:4] != "AdNe" || to[:4] != "AdNe" {
 		return nil, fmt.Errorf("addresses must start with 'AdNe'")
@@ -32,12 +36,14 @@ func NewTransaction(from, to string, amount float64, senderWallet *wallet.Wallet
 		From:      from,
 		To:        to,
 		Amount:    amount,
+		Nonce:     nonce,
 		Timestamp: time.Now().Unix(),
+		PublicKey: senderWallet.ExportPublicKey(),
+		Version:   CurrentEncodingVersion,
 	}
 
 	// Generate transaction ID with "AdNe" prefix
-	txHash := sha256.Sum256([]byte(fmt.Sprintf("%s%s%f%d", from, to, amount, tx.Timestamp)))
-	tx.ID = "AdNe" + hex.EncodeToString(txHash[:])[:60]
+	tx.ID = CalculateTransactionID(tx)
 
 	// Sign the transaction
 	signature, err := senderWallet.Sign([]byte(tx.ID))
@@ -61,7 +67,79 @@ func VerifyTransaction(tx *Transaction, publicKey *ecdsa.PublicKey) bool {
 	return wallet.VerifySignature(publicKey, []byte(tx.ID), signature)
 }
 
-// CalculateFee calculates the transaction fee (0.1% of the amount)
-func (tx *Transaction) CalculateFee() float64 {
-	return tx.Amount * 0.001
+// CalculateTransactionID derives the "AdNe" transaction ID from the transaction contents
+// using the encoding named by its version. The nonce is part of the hashed (and
+// therefore signed) payload so a transaction cannot be replayed once the sender's
+// nonce has moved on.
+func CalculateTransactionID(tx *Transaction) string {
+	if tx.Version == LegacyEncodingVersion {
+		return legacyTransactionID(tx)
+	}
+	txHash := sha256.Sum256(EncodeTransactionBody(tx))
+	return "AdNe" + hex.EncodeToString(txHash[:])[:60]
+}
+
+// VerifySignedTransaction checks a transaction signed outside the node: the ID must
+// match the transaction contents, the public key must hash to the From address and
+// the signature must verify against that key
+func VerifySignedTransaction(tx *Transaction, publicKey *ecdsa.PublicKey) error {
+	if err := checkEncodingVersion(tx.Version); err != nil {
+		return err
+	}
+	if tx.ID != CalculateTransactionID(tx) {
+		return fmt.Errorf("transaction ID does not match transaction contents")
+	}
+
+	address, err := wallet.AddressFromPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %v", err)
+	}
+	if address != tx.From {
+		return fmt.Errorf("public key does not match sender address")
+	}
+
+	if !VerifyTransaction(tx, publicKey) {
+		return fmt.Errorf("invalid transaction signature")
+	}
+
+	return nil
+}
+
+// VerifyTransactionSignature verifies a transaction against the public key it carries,
+// so that anyone holding the block can check it was signed by the From address
+func VerifyTransactionSignature(tx *Transaction) error {
+	if tx.PublicKey == "" {
+		return fmt.Errorf("transaction %s has no public key", tx.ID)
+	}
+
+	publicKey, err := wallet.DecodePublicKey(tx.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignedTransaction(tx, publicKey)
+}
+
+// VerifyBlockTransaction checks a transaction carried by a block. Legacy transactions
+// were signed by keys the chain never recorded, so only their ID is checked against
+// their contents, and only inside legacy blocks at or below the legacy cutoff height.
+func VerifyBlockTransaction(block Block, tx *Transaction) error {
+	if tx.Version != LegacyEncodingVersion {
+		return VerifyTransactionSignature(tx)
+	}
+	if block.Version != LegacyEncodingVersion {
+		return fmt.Errorf("legacy transaction in a version %d block", block.Version)
+	}
+	if err := checkBlockVersion(block); err != nil {
+		return err
+	}
+	if tx.ID != CalculateTransactionID(tx) {
+		return fmt.Errorf("transaction ID does not match transaction contents")
+	}
+	return nil
+}
+
+// CalculateFee calculates the transaction fee (0.1% of the amount, rounded down)
+func (tx *Transaction) CalculateFee() bnm.Amount {
+	return tx.Amount.MulDiv(1, 1000)
 }
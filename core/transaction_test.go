@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/wallet"
 )
 
@@ -16,7 +17,8 @@ func TestTransactionCreation(t *testing.T) {
 	tx, err := NewTransaction(
 		senderWallet.Address,
 		receiverWallet.Address,
-		100.0,
+		bnm.FromBNM(100),
+		0,
 		senderWallet,
 	)
 
@@ -39,8 +41,8 @@ func TestTransactionCreation(t *testing.T) {
 			receiverWallet.Address, tx.To)
 	}
 
-	if tx.Amount != 100.0 {
-		t.Errorf("Amount mismatch: expected=%.2f, got=%.2f", 100.0, tx.Amount)
+	if tx.Amount != bnm.FromBNM(100) {
+		t.Errorf("Amount mismatch: expected=%s, got=%s", bnm.FromBNM(100), tx.Amount)
 	}
 
 	// Verify signature
@@ -59,16 +61,57 @@ func TestTransactionFee(t *testing.T) {
 	tx, _ := NewTransaction(
 		senderWallet.Address,
 		receiverWallet.Address,
-		1000.0,
+		bnm.FromBNM(1000),
+		0,
 		senderWallet,
 	)
 
 	// Calculate fee
 	fee := tx.CalculateFee()
-	expectedFee := 1000.0 * 0.001 // 0.1% fee
+	expectedFee := bnm.FromBNM(1) // 0.1% fee
 
 	if fee != expectedFee {
-		t.Errorf("Fee calculation incorrect: expected=%.4f, got=%.4f",
+		t.Errorf("Fee calculation incorrect: expected=%s, got=%s",
 			expectedFee, fee)
 	}
 }
+
+func TestVerifySignedTransaction(t *testing.T) {
+	senderWallet, _ := wallet.NewWallet()
+	receiverWallet, _ := wallet.NewWallet()
+
+	tx, err := NewTransaction(senderWallet.Address, receiverWallet.Address, bnm.FromBNM(25), 0, senderWallet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	// Round-trip the public key the way a client would send it
+	publicKey, err := wallet.DecodePublicKey(senderWallet.ExportPublicKey())
+	if err != nil {
+		t.Fatalf("Failed to decode public key: %v", err)
+	}
+
+	if err := VerifySignedTransaction(tx, publicKey); err != nil {
+		t.Errorf("Valid signed transaction rejected: %v", err)
+	}
+
+	// Public key belonging to someone else must not match the sender address
+	if err := VerifySignedTransaction(tx, receiverWallet.PublicKey); err == nil {
+		t.Error("Transaction accepted with a public key that does not match the sender")
+	}
+
+	// Tampered amount no longer matches the signed ID
+	tampered := *tx
+	tampered.Amount = bnm.FromBNM(2500)
+	if err := VerifySignedTransaction(&tampered, publicKey); err == nil {
+		t.Error("Transaction accepted after the amount was changed")
+	}
+
+	// Recomputed ID with a foreign signature must fail signature verification
+	forged := *tx
+	forged.Amount = bnm.FromBNM(2500)
+	forged.ID = CalculateTransactionID(&forged)
+	if err := VerifySignedTransaction(&forged, publicKey); err == nil {
+		t.Error("Transaction accepted with a signature over a different ID")
+	}
+}
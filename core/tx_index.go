@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultTxPageSize is the number of transactions returned when no limit is given
+	DefaultTxPageSize = 20
+
+	// MaxTxPageSize is the largest page of transactions a query may request
+	MaxTxPageSize = 100
+)
+
+// ErrTransactionNotFound is returned for transactions that are not in the canonical chain
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// TxDirection selects an address's transactions by the side the address is on
+type TxDirection string
+
+const (
+	// TxDirectionAll selects transactions sent or received by the address
+	TxDirectionAll TxDirection = ""
+	// TxDirectionSent selects transactions sent by the address
+	TxDirectionSent TxDirection = "sent"
+	// TxDirectionReceived selects transactions received by the address
+	TxDirectionReceived TxDirection = "received"
+)
+
+// ParseTxDirection parses a direction filter, accepting an empty string for both directions
+func ParseTxDirection(direction string) (TxDirection, error) {
+	switch TxDirection(direction) {
+	case TxDirectionAll, TxDirectionSent, TxDirectionReceived:
+		return TxDirection(direction), nil
+	default:
+		return "", fmt.Errorf("invalid direction %q: expected sent or received", direction)
+	}
+}
+
+// TxQuery selects a page of an address's transactions, newest first
+type TxQuery struct {
+	Direction TxDirection
+	Offset    int
+	Limit     int
+}
+
+// matches reports whether a transaction involves an address in the queried direction
+func (q TxQuery) matches(tx Transaction, address string) bool {
+	switch q.Direction {
+	case TxDirectionSent:
+		return tx.From == address
+	case TxDirectionReceived:
+		return tx.To == address
+	default:
+		return tx.From == address || tx.To == address
+	}
+}
+
+// limit returns the page size, applying the default and maximum
+func (q TxQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultTxPageSize
+	}
+	if q.Limit > MaxTxPageSize {
+		return MaxTxPageSize
+	}
+	return q.Limit
+}
+
+// IndexedTransaction is a transaction with its place in the canonical chain
+type IndexedTransaction struct {
+	Transaction
+	BlockIndex uint64 `json:"blockIndex"`
+	BlockHash  string `json:"blockHash"`
+	Position   int    `json:"position"`
+}
+
+// newIndexedTransaction returns the transaction at a position of a block
+func newIndexedTransaction(block Block, position int) IndexedTransaction {
+	return IndexedTransaction{
+		Transaction: block.Data[position],
+		BlockIndex:  block.Index,
+		BlockHash:   block.Hash,
+		Position:    position,
+	}
+}
+
+// TransactionIndexer looks up transactions included in the canonical chain
+type TransactionIndexer interface {
+	// GetTransaction returns an included transaction by ID
+	GetTransaction(id string) (IndexedTransaction, error)
+	// GetAddressTransactions returns a page of an address's transactions, newest
+	// first, and the number of transactions matching the query
+	GetAddressTransactions(address string, query TxQuery) ([]IndexedTransaction, int, error)
+}
+
+// TxLocation is the block height and position of an included transaction
+type TxLocation struct {
+	BlockIndex uint64
+	Position   int
+}
+
+// TxIndex is an in-memory index of the canonical chain's transactions by ID and by
+// address. Blocks must be added in chain order and removed from the tip.
+type TxIndex struct {
+	byID      map[string]TxLocation
+	byAddress map[string][]TxLocation // In chain order
+}
+
+// NewTxIndex creates an empty transaction index
+func NewTxIndex() *TxIndex {
+	return &TxIndex{
+		byID:      make(map[string]TxLocation),
+		byAddress: make(map[string][]TxLocation),
+	}
+}
+
+// AddBlock indexes the transactions of a block appended to the chain
+func (ti *TxIndex) AddBlock(block Block) {
+	for position, tx := range block.Data {
+		location := TxLocation{BlockIndex: block.Index, Position: position}
+		ti.byID[tx.ID] = location
+		ti.byAddress[tx.From] = append(ti.byAddress[tx.From], location)
+		if tx.To != tx.From {
+			ti.byAddress[tx.To] = append(ti.byAddress[tx.To], location)
+		}
+	}
+}
+
+// RemoveBlock drops the transactions of the block at the tip of the chain
+func (ti *TxIndex) RemoveBlock(block Block) {
+	for _, tx := range block.Data {
+		delete(ti.byID, tx.ID)
+		for _, address := range []string{tx.From, tx.To} {
+			locations := ti.byAddress[address]
+			for len(locations) > 0 && locations[len(locations)-1].BlockIndex >= block.Index {
+				locations = locations[:len(locations)-1]
+			}
+			if len(locations) == 0 {
+				delete(ti.byAddress, address)
+			} else {
+				ti.byAddress[address] = locations
+			}
+		}
+	}
+}
+
+// Lookup returns where a transaction was included
+func (ti *TxIndex) Lookup(id string) (TxLocation, bool) {
+	location, ok := ti.byID[id]
+	return location, ok
+}
+
+// AddressLocations returns where an address's transactions were included, in chain order
+func (ti *TxIndex) AddressLocations(address string) []TxLocation {
+	return ti.byAddress[address]
+}
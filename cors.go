@@ -4,10 +4,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// allowedOrigin is the web app allowed to call the API from browsers
+const allowedOrigin = "https://www.binomchainapp.fyi"
+
 // corsMiddleware adds CORS headers to allow cross-origin requests
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "https://www.binomchainapp.fyi")
+		c.Writer.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
@@ -4,59 +4,96 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/igo-used/binomena/bnm"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 var DB *gorm.DB
 
-// Block model for PostgreSQL
+// JSON is a column holding a JSON document, stored as jsonb on PostgreSQL and as
+// text on SQLite
+type JSON string
+
+// GormDBDataType returns the column type of a JSON field for the connected engine
+func (JSON) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+// Block model
 type Block struct {
 	ID           uint   `gorm:"primaryKey"`
 	Index        uint64 `gorm:"uniqueIndex;not null"`
 	PreviousHash string `gorm:"size:64;not null"`
 	Timestamp    int64  `gorm:"not null"`
-	Data         string `gorm:"type:jsonb"` // Store transactions as JSON
+	Data         JSON   // Store transactions as JSON
 	Hash         string `gorm:"size:64;uniqueIndex;not null"`
 	Validator    string `gorm:"size:66;not null"`
 	Signature    string `gorm:"size:144;not null"`
+	PublicKey    string `gorm:"size:130"`           // Producer's public key
+	Version      uint32 `gorm:"not null;default:0"` // Hash encoding version, 0 for legacy blocks
+	MerkleRoot   string `gorm:"size:64"`            // Merkle root of the block's transactions
+	StateRoot    string `gorm:"size:64"`            // State root after applying the block
 }
 
-// Wallet model for PostgreSQL
+// Wallet model
 type Wallet struct {
-	ID      uint    `gorm:"primaryKey"`
-	Address string  `gorm:"size:66;uniqueIndex;not null"`
-	Balance float64 `gorm:"type:decimal(20,8);default:0"`
+	ID      uint       `gorm:"primaryKey"`
+	Address string     `gorm:"size:66;uniqueIndex;not null"`
+	Balance bnm.Amount `gorm:"type:decimal(20,8);default:0"`
 }
 
-// Transaction model for PostgreSQL
+// Transaction model
 type Transaction struct {
-	ID        uint    `gorm:"primaryKey"`
-	TxID      string  `gorm:"size:66;uniqueIndex;not null"`
-	FromAddr  string  `gorm:"size:66;not null;index"`
-	ToAddr    string  `gorm:"size:66;not null;index"`
-	Amount    float64 `gorm:"type:decimal(20,8);not null"`
-	Timestamp int64   `gorm:"not null"`
-	Signature string  `gorm:"size:144;not null"`
-	BlockID   *uint   `gorm:"index"` // Reference to block
+	ID        uint       `gorm:"primaryKey"`
+	TxID      string     `gorm:"size:66;uniqueIndex;not null"`
+	FromAddr  string     `gorm:"size:66;not null;index"`
+	ToAddr    string     `gorm:"size:66;not null;index"`
+	Amount    bnm.Amount `gorm:"type:decimal(20,8);not null"`
+	Nonce     uint64     `gorm:"not null;default:0"`
+	Timestamp int64      `gorm:"not null"`
+	Signature string     `gorm:"size:144;not null"`
+	PublicKey string     `gorm:"size:130"`           // Signer's public key for signature verification
+	Version   uint32     `gorm:"not null;default:0"` // Hash encoding version, 0 for legacy transactions
+	BlockID   *uint      `gorm:"index"`              // Reference to block
 }
 
-// Contract model for PostgreSQL
+// Receipt model for the outcome of submitted transactions
+type Receipt struct {
+	ID              uint       `gorm:"primaryKey"`
+	TxID            string     `gorm:"size:66;uniqueIndex;not null"`
+	Status          string     `gorm:"size:16;not null;index"`
+	BlockIndex      uint64     `gorm:"default:0"`
+	BlockHash       string     `gorm:"size:64"`
+	Position        int        `gorm:"default:0"`
+	Fee             bnm.Amount `gorm:"type:decimal(20,8);not null;default:0"`
+	FeeDistribution JSON       // Fee split of included transactions
+	Error           string     `gorm:"type:text"`
+	Timestamp       int64      `gorm:"not null"`
+}
+
+// Contract model
 type Contract struct {
-	ID             uint    `gorm:"primaryKey"`
-	ContractID     string  `gorm:"size:66;uniqueIndex;not null"`
-	Owner          string  `gorm:"size:66;not null;index"`
-	Name           string  `gorm:"size:100;not null"`
-	Code           []byte  `gorm:"type:bytea;not null"`
-	DeployedAt     int64   `gorm:"not null"`
-	LastExecuted   int64   `gorm:"default:0"`
-	ExecutionCount uint64  `gorm:"default:0"`
-	TotalGasUsed   float64 `gorm:"type:decimal(20,8);default:0"`
+	ID             uint       `gorm:"primaryKey"`
+	ContractID     string     `gorm:"size:66;uniqueIndex;not null"`
+	Owner          string     `gorm:"size:66;not null;index"`
+	Name           string     `gorm:"size:100;not null"`
+	Code           []byte     `gorm:"not null"`
+	DeployedAt     int64      `gorm:"not null"`
+	LastExecuted   int64      `gorm:"default:0"`
+	ExecutionCount uint64     `gorm:"default:0"`
+	TotalGasUsed   bnm.Amount `gorm:"type:decimal(20,8);default:0"`
 }
 
-// AuditEvent model for PostgreSQL
+// AuditEvent model
 type AuditEvent struct {
 	ID        uint   `gorm:"primaryKey"`
 	EventID   string `gorm:"size:66;uniqueIndex;not null"`
@@ -64,14 +101,23 @@ type AuditEvent struct {
 	Level     int    `gorm:"not null;index"`
 	Type      string `gorm:"size:50;not null;index"`
 	Message   string `gorm:"type:text;not null"`
-	Data      string `gorm:"type:jsonb"` // Store additional data as JSON
+	Data      JSON   // Store additional data as JSON
 }
 
 // TokenBalance model for tracking token balances
 type TokenBalance struct {
-	ID      uint    `gorm:"primaryKey"`
-	Address string  `gorm:"size:66;uniqueIndex;not null"`
-	Balance float64 `gorm:"type:decimal(20,8);not null;default:0"`
+	ID      uint       `gorm:"primaryKey"`
+	Address string     `gorm:"size:66;uniqueIndex;not null"`
+	Balance bnm.Amount `gorm:"type:decimal(20,8);not null;default:0"`
+	Nonce   uint64     `gorm:"not null;default:0"` // Next expected transaction nonce
+}
+
+// BlockStateChanges model for the token state changes applied by a block, kept
+// to revert the block when it leaves the canonical chain
+type BlockStateChanges struct {
+	ID        uint   `gorm:"primaryKey"`
+	BlockHash string `gorm:"size:64;uniqueIndex;not null"`
+	Changes   JSON   // State changes in the order they were applied
 }
 
 // SystemState model for storing system-wide state
@@ -82,7 +128,22 @@ type SystemState struct {
 	LastUpdated int64  `gorm:"not null"`
 }
 
-// ConnectDatabase connects to PostgreSQL database
+// openDialector picks the database engine from the URL scheme: sqlite:// and file:
+// URLs open a SQLite database, anything else is handed to PostgreSQL
+func openDialector(databaseURL string) gorm.Dialector {
+	switch {
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		return sqlite.Open(strings.TrimPrefix(databaseURL, "sqlite://"))
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		return sqlite.Open(strings.TrimPrefix(databaseURL, "sqlite:"))
+	case strings.HasPrefix(databaseURL, "file:"):
+		return sqlite.Open(databaseURL)
+	default:
+		return postgres.Open(databaseURL)
+	}
+}
+
+// ConnectDatabase connects to the PostgreSQL or SQLite database named by DATABASE_URL
 func ConnectDatabase() error {
 	// Get database URL from environment
 	databaseURL := os.Getenv("DATABASE_URL")
@@ -97,15 +158,26 @@ func ConnectDatabase() error {
 	}
 
 	// Connect to database
-	var err error
-	DB, err = gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+	dialector := openDialector(databaseURL)
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL database")
+	if dialector.Name() == "sqlite" {
+		// SQLite allows a single writer, and each connection to :memory: is a
+		// separate database, so share one connection
+		sqlDB, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("failed to configure database: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	DB = db
+	log.Printf("Successfully connected to %s database", dialector.Name())
 	return nil
 }
 
@@ -115,10 +187,12 @@ func MigrateDatabase() error {
 		&Block{},
 		&Wallet{},
 		&Transaction{},
+		&Receipt{},
 		&Contract{},
 		&AuditEvent{},
 		&TokenBalance{},
 		&SystemState{},
+		&BlockStateChanges{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
@@ -150,7 +224,7 @@ func InitializeSystemState() error {
 	if result.Error == gorm.ErrRecordNotFound {
 		treasury = TokenBalance{
 			Address: "treasury",
-			Balance: 1000000000.0, // All tokens start in treasury
+			Balance: bnm.FromBNM(1000000000), // All tokens start in treasury
 		}
 		if err := DB.Create(&treasury).Error; err != nil {
 			return fmt.Errorf("failed to initialize treasury: %v", err)
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	bolt "go.etcd.io/bbolt"
+)
+
+// KV is the embedded key-value store used by the KV storage backend
+var KV *bolt.DB
+
+// Buckets of the embedded key-value store
+var (
+	// BlocksBucket maps big-endian block heights to JSON blocks
+	BlocksBucket = []byte("blocks")
+	// BlockHashesBucket maps block hashes to big-endian block heights
+	BlockHashesBucket = []byte("block_hashes")
+	// TxIndexBucket maps transaction IDs to the big-endian height and position of
+	// their block
+	TxIndexBucket = []byte("tx_index")
+	// AddressTxsBucket maps an address, a zero byte, the big-endian height and the
+	// position of each of its transactions to the transaction ID
+	AddressTxsBucket = []byte("address_txs")
+	// ReceiptsBucket maps transaction IDs to JSON receipts
+	ReceiptsBucket = []byte("receipts")
+	// PendingBucket maps transaction IDs to JSON pending transactions
+	PendingBucket = []byte("pending")
+	// BalancesBucket maps addresses to big-endian balances in base units
+	BalancesBucket = []byte("balances")
+	// NoncesBucket maps addresses to the next expected big-endian transaction nonce
+	NoncesBucket = []byte("nonces")
+	// SystemBucket holds system-wide values such as the circulating supply
+	SystemBucket = []byte("system")
+	// StateChangesBucket maps block hashes to the JSON token state changes the
+	// block applied
+	StateChangesBucket = []byte("state_changes")
+)
+
+// CirculatingSupplyKey is the system bucket key of the circulating supply
+var CirculatingSupplyKey = []byte("circulating_supply")
+
+// OpenKV opens or creates the embedded key-value store at path
+func OpenKV(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open key-value store: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{BlocksBucket, BlockHashesBucket, TxIndexBucket, AddressTxsBucket, ReceiptsBucket, PendingBucket, BalancesBucket, NoncesBucket, SystemBucket, StateChangesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create buckets: %v", err)
+	}
+
+	KV = db
+	log.Printf("Opened key-value store at %s", path)
+	return nil
+}
+
+// InitializeKVSystemState initializes the circulating supply and treasury of a new store
+func InitializeKVSystemState() error {
+	return KV.Update(func(tx *bolt.Tx) error {
+		system := tx.Bucket(SystemBucket)
+		if system.Get(CirculatingSupplyKey) != nil {
+			return nil
+		}
+
+		supply := bnm.FromBNM(1000000000) // 1 billion BNM, all in the treasury
+		if err := system.Put(CirculatingSupplyKey, supply.Bytes()); err != nil {
+			return err
+		}
+		return tx.Bucket(BalancesBucket).Put([]byte("treasury"), supply.Bytes())
+	})
+}
+
+// CloseKV closes the embedded key-value store
+func CloseKV() error {
+	if KV == nil {
+		return nil
+	}
+	return KV.Close()
+}
+
+// HeightKey returns the blocks bucket key of a block height
+func HeightKey(index uint64) []byte {
+	return EncodeUint64(index)
+}
+
+// EncodeUint64 encodes a value such as a height or nonce as 8 big-endian bytes,
+// which keeps keys in numeric order
+func EncodeUint64(value uint64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, value)
+	return encoded
+}
+
+// DecodeUint64 decodes a big-endian value such as a height key or nonce, treating
+// missing values as zero
+func DecodeUint64(value []byte) uint64 {
+	if len(value) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// DecodeAmount decodes an amount stored as big-endian base units
+func DecodeAmount(value []byte) bnm.Amount {
+	return bnm.Amount(DecodeUint64(value))
+}
@@ -0,0 +1,288 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+)
+
+// Topic names a stream of events clients can subscribe to
+type Topic string
+
+const (
+	// TopicBlocks streams the headers of blocks joining the canonical chain
+	TopicBlocks Topic = "blocks"
+	// TopicPendingTransactions streams transactions accepted into the mempool
+	TopicPendingTransactions Topic = "pendingTransactions"
+	// TopicAddress streams pending and included transactions touching an address
+	TopicAddress Topic = "address"
+	// TopicDelegates streams delegate registrations and votes
+	TopicDelegates Topic = "delegates"
+	// TopicAudit streams audit events at or above a security level
+	TopicAudit Topic = "audit"
+)
+
+// DefaultBufferSize is the number of messages queued for a subscriber before it is
+// considered too slow and dropped
+const DefaultBufferSize = 256
+
+// Filter selects the events a subscription receives
+type Filter struct {
+	Topic   Topic               `json:"topic"`
+	Address string              `json:"address,omitempty"` // Address of the address topic
+	Level   audit.SecurityLevel `json:"level,omitempty"`   // Minimum level of the audit topic
+}
+
+// validate checks that a filter names a known topic and has the fields it needs
+func (f Filter) validate() error {
+	switch f.Topic {
+	case TopicBlocks, TopicPendingTransactions, TopicDelegates:
+		return nil
+	case TopicAddress:
+		if !strings.HasPrefix(f.Address, "AdNe") {
+			return fmt.Errorf("address must start with 'AdNe'")
+		}
+		return nil
+	case TopicAudit:
+		if f.Level < audit.InfoLevel || f.Level > audit.CriticalLevel {
+			return fmt.Errorf("invalid security level %d", f.Level)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown topic %q", f.Topic)
+	}
+}
+
+// Event is something that happened on the node, published to every subscription
+// whose filter matches it
+type Event struct {
+	Topic     Topic
+	Data      interface{}
+	addresses []string            // Addresses touched, matched by address filters
+	level     audit.SecurityLevel // Level of audit events
+}
+
+// matches reports whether an event passes a filter
+func (f Filter) matches(event Event) bool {
+	if f.Topic != event.Topic {
+		return false
+	}
+	switch f.Topic {
+	case TopicAddress:
+		for _, address := range event.addresses {
+			if address == f.Address {
+				return true
+			}
+		}
+		return false
+	case TopicAudit:
+		return event.level >= f.Level
+	}
+	return true
+}
+
+// AddressEvent reports a transaction touching a subscribed address
+type AddressEvent struct {
+	Address     string             `json:"address"`
+	Status      core.ReceiptStatus `json:"status"`
+	Transaction core.Transaction   `json:"transaction"`
+	BlockIndex  uint64             `json:"blockIndex,omitempty"`
+	BlockHash   string             `json:"blockHash,omitempty"`
+	Balance     *bnm.Amount        `json:"balance,omitempty"` // Balance of the address when the event is sent
+}
+
+// Message is an event delivered to one subscription
+type Message struct {
+	Subscription string      `json:"subscription"`
+	Topic        Topic       `json:"topic"`
+	Data         interface{} `json:"data"`
+}
+
+// BalanceSource looks up the balances reported with address events
+type BalanceSource interface {
+	GetBalance(address string) bnm.Amount
+}
+
+// Subscriber receives the events matching any of its subscriptions through a
+// bounded queue. A subscriber whose queue fills up is dropped rather than slowing
+// down the node.
+type Subscriber struct {
+	hub           *Hub
+	subscriptions map[string]Filter
+	messages      chan Message
+	dropped       chan struct{}
+	dropOnce      sync.Once
+}
+
+// Messages returns the queue of messages for the subscriber
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Dropped is closed when the subscriber is dropped for falling behind
+func (s *Subscriber) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Subscribe adds a subscription and returns its ID
+func (s *Subscriber) Subscribe(filter Filter) (string, error) {
+	if err := filter.validate(); err != nil {
+		return "", err
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to create subscription ID: %v", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.subscriptions[id] = filter
+	return id, nil
+}
+
+// Unsubscribe removes a subscription, reporting whether it existed
+func (s *Subscriber) Unsubscribe(id string) bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return false
+	}
+	delete(s.subscriptions, id)
+	return true
+}
+
+// Close removes the subscriber from its hub
+func (s *Subscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	delete(s.hub.subscribers, s)
+}
+
+// Hub fans node events out to subscribers. It observes the blockchain, the DPoS
+// consensus and the audit service.
+type Hub struct {
+	subscribers map[*Subscriber]bool
+	balances    BalanceSource
+	bufferSize  int
+	mu          sync.RWMutex
+}
+
+// NewHub creates an event hub reporting balances from a token system
+func NewHub(balances BalanceSource) *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscriber]bool),
+		balances:    balances,
+		bufferSize:  DefaultBufferSize,
+	}
+}
+
+// SetBufferSize sets the queue length of subscribers created afterwards
+func (h *Hub) SetBufferSize(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.bufferSize = size
+}
+
+// NewSubscriber creates a subscriber with no subscriptions
+func (h *Hub) NewSubscriber() *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscriber := &Subscriber{
+		hub:           h,
+		subscriptions: make(map[string]Filter),
+		messages:      make(chan Message, h.bufferSize),
+		dropped:       make(chan struct{}),
+	}
+	h.subscribers[subscriber] = true
+	return subscriber
+}
+
+// SubscriberCount returns the number of connected subscribers
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscribers)
+}
+
+// Publish queues an event for every matching subscription without blocking.
+// Subscribers with a full queue are dropped.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+subscribers:
+	for subscriber := range h.subscribers {
+		for id, filter := range subscriber.subscriptions {
+			if !filter.matches(event) {
+				continue
+			}
+			data := event.Data
+			if addressEvent, ok := data.(AddressEvent); ok {
+				addressEvent.Address = filter.Address
+				data = addressEvent
+			}
+			select {
+			case subscriber.messages <- Message{Subscription: id, Topic: event.Topic, Data: data}:
+			default:
+				delete(h.subscribers, subscriber)
+				subscriber.dropOnce.Do(func() { close(subscriber.dropped) })
+				continue subscribers
+			}
+		}
+	}
+}
+
+// Balance returns the current balance of an address, if the hub has a token system
+func (h *Hub) Balance(address string) *bnm.Amount {
+	if h.balances == nil {
+		return nil
+	}
+	balance := h.balances.GetBalance(address)
+	return &balance
+}
+
+// BlockAdded publishes a block joining the canonical chain and its transactions
+func (h *Hub) BlockAdded(block core.Block) {
+	h.Publish(Event{Topic: TopicBlocks, Data: block.Header()})
+	for _, tx := range block.Data {
+		h.Publish(Event{
+			Topic:     TopicAddress,
+			Data:      AddressEvent{Status: core.ReceiptIncluded, Transaction: tx, BlockIndex: block.Index, BlockHash: block.Hash},
+			addresses: []string{tx.From, tx.To},
+		})
+	}
+}
+
+// TransactionAdded publishes a transaction accepted into the mempool
+func (h *Hub) TransactionAdded(tx core.Transaction) {
+	h.Publish(Event{Topic: TopicPendingTransactions, Data: tx})
+	h.Publish(Event{
+		Topic:     TopicAddress,
+		Data:      AddressEvent{Status: core.ReceiptPending, Transaction: tx},
+		addresses: []string{tx.From, tx.To},
+	})
+}
+
+// DelegateChanged publishes a delegate registration or vote
+func (h *Hub) DelegateChanged(change consensus.DelegateChange) {
+	h.Publish(Event{Topic: TopicDelegates, Data: change})
+}
+
+// AuditEventLogged publishes an audit event
+func (h *Hub) AuditEventLogged(event audit.AuditEvent) {
+	h.Publish(Event{Topic: TopicAudit, Data: event, level: event.Level})
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+)
+
+func TestHub_FiltersEvents(t *testing.T) {
+	hub := NewHub(nil)
+	subscriber := hub.NewSubscriber()
+	blocks, _ := subscriber.Subscribe(Filter{Topic: TopicBlocks})
+	alice, _ := subscriber.Subscribe(Filter{Topic: TopicAddress, Address: "AdNe-alice"})
+	warnings, _ := subscriber.Subscribe(Filter{Topic: TopicAudit, Level: audit.WarningLevel})
+
+	hub.TransactionAdded(core.Transaction{ID: "AdNe-tx1", From: "AdNe-bob", To: "AdNe-carol", Amount: bnm.FromBNM(1)})
+	hub.TransactionAdded(core.Transaction{ID: "AdNe-tx2", From: "AdNe-bob", To: "AdNe-alice", Amount: bnm.FromBNM(1)})
+	hub.AuditEventLogged(audit.AuditEvent{Level: audit.InfoLevel, Type: "WalletCreated"})
+	hub.AuditEventLogged(audit.AuditEvent{Level: audit.CriticalLevel, Type: "BlockHashMismatch"})
+	hub.BlockAdded(core.Block{Index: 1, Hash: "block1"})
+
+	expected := []string{alice, warnings, blocks}
+	for _, subscription := range expected {
+		select {
+		case message := <-subscriber.Messages():
+			if message.Subscription != subscription {
+				t.Fatalf("Expected a message for %s, got %+v", subscription, message)
+			}
+			if event, ok := message.Data.(AddressEvent); ok && (event.Address != "AdNe-alice" || event.Status != core.ReceiptPending) {
+				t.Errorf("Unexpected address event: %+v", event)
+			}
+		default:
+			t.Fatalf("Expected a message for %s", subscription)
+		}
+	}
+	select {
+	case message := <-subscriber.Messages():
+		t.Errorf("Unexpected message: %+v", message)
+	default:
+	}
+
+	if _, err := subscriber.Subscribe(Filter{Topic: TopicAddress, Address: "0x1234"}); err == nil {
+		t.Error("Expected an address without the AdNe prefix to be refused")
+	}
+	if _, err := subscriber.Subscribe(Filter{Topic: "prices"}); err == nil {
+		t.Error("Expected an unknown topic to be refused")
+	}
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetBufferSize(2)
+	slow := hub.NewSubscriber()
+	slow.Subscribe(Filter{Topic: TopicBlocks})
+
+	for i := uint64(1); i <= 3; i++ {
+		hub.BlockAdded(core.Block{Index: i})
+	}
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatal("Expected the subscriber to be dropped when its queue overflowed")
+	}
+	if count := hub.SubscriberCount(); count != 0 {
+		t.Errorf("Expected no subscribers after dropping, got %d", count)
+	}
+}
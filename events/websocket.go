@@ -0,0 +1,187 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WriteTimeout bounds each write, so a stalled client cannot hold a connection.
+	// It is shared by every WebSocket endpoint of the node.
+	WriteTimeout = 10 * time.Second
+	// PongTimeout is how long a connection may stay silent before it is closed
+	PongTimeout = 60 * time.Second
+	// PingInterval is how often connections are pinged, within PongTimeout
+	PingInterval = PongTimeout * 9 / 10
+	// maxRequestSize bounds the requests clients send
+	maxRequestSize = 4096
+)
+
+// Request is sent by clients to manage their subscriptions
+type Request struct {
+	ID           interface{} `json:"id,omitempty"`
+	Action       string      `json:"action"`                 // subscribe or unsubscribe
+	Filter       Filter      `json:"filter"`                 // Filter of a new subscription
+	Subscription string      `json:"subscription,omitempty"` // Subscription to cancel
+}
+
+// Response answers a client request
+type Response struct {
+	ID           interface{} `json:"id,omitempty"`
+	Subscription string      `json:"subscription,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// WebSocketHandler serves event subscriptions over WebSocket connections
+type WebSocketHandler struct {
+	hub      *Hub
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates a WebSocket handler for a hub. Browsers may connect
+// from the node's own host or from one of the allowed origins.
+func NewWebSocketHandler(hub *Hub, allowedOrigins ...string) *WebSocketHandler {
+	return &WebSocketHandler{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin:     CheckOrigin(allowedOrigins...),
+		},
+	}
+}
+
+// CheckOrigin accepts WebSocket clients that send no origin, such as non-browser
+// clients, and browsers on the node's host or an allowed origin
+func CheckOrigin(allowedOrigins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+}
+
+// ServeHTTP upgrades a request to a WebSocket connection and serves its
+// subscriptions until either side closes it
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an error
+		return
+	}
+
+	subscriber := h.hub.NewSubscriber()
+	responses := make(chan Response, 16)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go h.readRequests(conn, subscriber, responses, done, stopped)
+	h.writeMessages(conn, subscriber, responses, done)
+
+	close(stopped)
+	subscriber.Close()
+	conn.Close()
+}
+
+// readRequests handles subscribe and unsubscribe requests until the connection
+// fails, then closes done. Responses are abandoned once the writer has stopped.
+func (h *WebSocketHandler) readRequests(conn *websocket.Conn, subscriber *Subscriber, responses chan<- Response, done chan<- struct{}, stopped <-chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(maxRequestSize)
+	conn.SetReadDeadline(time.Now().Add(PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(PongTimeout))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(PongTimeout))
+
+		var request Request
+		response := Response{}
+		if err := json.Unmarshal(data, &request); err != nil {
+			request.Action = ""
+			response.Error = "invalid request: " + err.Error()
+		}
+		response.ID = request.ID
+		switch request.Action {
+		case "subscribe":
+			id, err := subscriber.Subscribe(request.Filter)
+			if err != nil {
+				response.Error = err.Error()
+			}
+			response.Subscription = id
+		case "unsubscribe":
+			if !subscriber.Unsubscribe(request.Subscription) {
+				response.Error = "unknown subscription"
+			}
+			response.Subscription = request.Subscription
+		default:
+			if response.Error == "" {
+				response.Error = "unknown action: expected subscribe or unsubscribe"
+			}
+		}
+
+		select {
+		case responses <- response:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// writeMessages writes responses, subscribed events and pings until the client
+// disconnects or falls too far behind
+func (h *WebSocketHandler) writeMessages(conn *websocket.Conn, subscriber *Subscriber, responses <-chan Response, done <-chan struct{}) {
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-subscriber.Dropped():
+			log.Printf("Dropping WebSocket client %s: too slow to keep up with events", conn.RemoteAddr())
+			closing := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow")
+			conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(WriteTimeout))
+			return
+		case response := <-responses:
+			err = h.write(conn, response)
+		case message := <-subscriber.Messages():
+			if addressEvent, ok := message.Data.(AddressEvent); ok {
+				addressEvent.Balance = h.hub.Balance(addressEvent.Address)
+				message.Data = addressEvent
+			}
+			err = h.write(conn, message)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write sends a JSON message within the write timeout
+func (h *WebSocketHandler) write(conn *websocket.Conn, value interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return conn.WriteJSON(value)
+}
@@ -5,9 +5,11 @@ import (
 	"log"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/consensus"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
 )
 
 func main() {
@@ -28,7 +30,7 @@ func main() {
 	log.Println("✓ DPoS consensus initialized")
 
 	// Register founder as the first delegate
-	if err := dposConsensus.RegisterDelegate(founderAddress, 400000000.0); err != nil {
+	if err := dposConsensus.RegisterDelegate(founderAddress, bnm.FromBNM(400000000)); err != nil {
 		log.Printf("Warning: Failed to register founder as delegate: %v", err)
 	} else {
 		log.Println("✓ Founder registered as first delegate")
@@ -101,11 +103,11 @@ func main() {
 	}
 
 	for i, delegateAddr := range additionalDelegates {
-		stake := 10000.0 + float64(i)*1000.0 // Varying stake amounts
+		stake := bnm.FromBNM(10000 + int64(i)*1000) // Varying stake amounts
 		if err := dposConsensus.RegisterDelegate(delegateAddr, stake); err != nil {
 			log.Printf("Failed to register delegate %s: %v", delegateAddr, err)
 		} else {
-			log.Printf("✓ Registered delegate %d: %s (stake: %.0f)", i+2, delegateAddr, stake)
+			log.Printf("✓ Registered delegate %d: %s (stake: %s)", i+2, delegateAddr, stake)
 		}
 	}
 
@@ -145,8 +147,12 @@ func main() {
 		blockchain.AddTransaction(tx)
 	}
 
-	// Create a block using the protocol
-	block, err := protocol.CreateBlock(founderAddress, "demo_signature")
+	// Create a block signed by a demo producer key
+	producerWallet, err := wallet.NewWallet()
+	if err != nil {
+		log.Fatalf("Failed to create producer wallet: %v", err)
+	}
+	block, err := protocol.CreateBlock(producerWallet)
 	if err != nil {
 		log.Printf("Error creating block: %v", err)
 	} else {
@@ -181,7 +187,7 @@ func createSampleTransactions(count int) []core.Transaction {
 			ID:        txID,
 			From:      fromAddress,
 			To:        toAddress,
-			Amount:    float64(10 + i*5), // Varying amounts
+			Amount:    bnm.FromBNM(int64(10 + i*5)), // Varying amounts
 			Timestamp: time.Now().Unix(),
 			Signature: fmt.Sprintf("signature_%d", i),
 		}
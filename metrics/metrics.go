@@ -0,0 +1,208 @@
+// Package metrics exports node internals to Prometheus: the chain and mempool,
+// peers, API traffic, transaction execution, contracts, delegates and audits.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/api"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric
+const namespace = "binomena"
+
+// auditLevels names the audit levels exported as labels
+var auditLevels = map[audit.SecurityLevel]string{
+	audit.InfoLevel:     "info",
+	audit.WarningLevel:  "warning",
+	audit.ErrorLevel:    "error",
+	audit.CriticalLevel: "critical",
+}
+
+// Config holds the components whose state is exported. Components other than the
+// blockchain are optional and their metrics are left out when unset.
+type Config struct {
+	Blockchain   core.BlockchainInterface
+	P2PNode      *p2p.P2PNode
+	Engine       *core.ExecutionEngine
+	VM           *smartcontract.WasmVM
+	Consensus    *consensus.DPoSConsensus
+	Audit        audit.Auditor
+	RateLimiters map[string]*api.RateLimiter // By limiter name
+}
+
+// Metrics collects the node's metrics and serves them to Prometheus. Component
+// state is read when scraped; block production and API requests are recorded as
+// they happen.
+type Metrics struct {
+	registry        *prometheus.Registry
+	blockProduction prometheus.Histogram
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics of a node's components
+func New(config Config) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		blockProduction: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "block_production_seconds",
+			Help:      "Time taken to produce a block, from selecting its transactions to adding it to the chain.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route and status.",
+		}, []string{"method", "path", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "path"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.blockProduction,
+		m.requests,
+		m.requestDuration,
+		newNodeCollector(config),
+	)
+	return m
+}
+
+// BlockProduced records how long the node took to produce a block
+func (m *Metrics) BlockProduced(block core.Block, elapsed time.Duration) {
+	m.blockProduction.Observe(elapsed.Seconds())
+}
+
+// Middleware returns middleware counting requests and their latency by route.
+// Requests matching no route share a single label to bound the label values.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = "unmatched"
+		}
+		m.requests.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(c.Request.Method, path).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler returns the handler serving the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// nodeCollector reads the state of a node's components when scraped
+type nodeCollector struct {
+	config Config
+
+	blockHeight         *prometheus.Desc
+	mempoolSize         *prometheus.Desc
+	peers               *prometheus.Desc
+	rateLimitRejections *prometheus.Desc
+	executionMode       *prometheus.Desc
+	executionTPS        *prometheus.Desc
+	executionErrorRate  *prometheus.Desc
+	executedTxs         *prometheus.Desc
+	contracts           *prometheus.Desc
+	contractExecutions  *prometheus.Desc
+	contractGas         *prometheus.Desc
+	activeDelegates     *prometheus.Desc
+	auditEvents         *prometheus.Desc
+}
+
+// newNodeCollector creates a collector for a node's components
+func newNodeCollector(config Config) *nodeCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+	}
+
+	return &nodeCollector{
+		config:              config,
+		blockHeight:         desc("block_height", "Index of the last block of the canonical chain."),
+		mempoolSize:         desc("mempool_transactions", "Transactions waiting in the mempool."),
+		peers:               desc("peers", "Connected P2P peers."),
+		rateLimitRejections: desc("rate_limit_rejections_total", "Requests refused for exceeding a rate limit, by limiter.", "limiter"),
+		executionMode:       desc("execution_mode", "Execution mode of the transaction execution engine, set to 1 for the current mode.", "mode"),
+		executionTPS:        desc("execution_tps", "Estimated transactions per second of the execution engine."),
+		executionErrorRate:  desc("execution_error_rate", "Share of executed transactions that failed."),
+		executedTxs:         desc("execution_transactions_total", "Transactions run by the execution engine."),
+		contracts:           desc("contracts", "Smart contracts deployed on the VM."),
+		contractExecutions:  desc("contract_executions_total", "Smart contract executions."),
+		contractGas:         desc("contract_gas_used_bnm_total", "Gas used by smart contract executions, in BNM."),
+		activeDelegates:     desc("active_delegates", "Active DPoS delegates."),
+		auditEvents:         desc("audit_events", "Audit events logged, by level.", "level"),
+	}
+}
+
+// Describe sends the descriptors of the node metrics
+func (nc *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		nc.blockHeight, nc.mempoolSize, nc.peers, nc.rateLimitRejections,
+		nc.executionMode, nc.executionTPS, nc.executionErrorRate, nc.executedTxs,
+		nc.contracts, nc.contractExecutions, nc.contractGas, nc.activeDelegates, nc.auditEvents,
+	} {
+		ch <- d
+	}
+}
+
+// Collect reads the current state of each configured component
+func (nc *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	config := nc.config
+
+	ch <- prometheus.MustNewConstMetric(nc.blockHeight, prometheus.GaugeValue, float64(config.Blockchain.GetLastBlock().Index))
+	ch <- prometheus.MustNewConstMetric(nc.mempoolSize, prometheus.GaugeValue, float64(config.Blockchain.Mempool().Size()))
+
+	if config.P2PNode != nil {
+		ch <- prometheus.MustNewConstMetric(nc.peers, prometheus.GaugeValue, float64(config.P2PNode.GetPeerCount()))
+	}
+
+	for name, limiter := range config.RateLimiters {
+		ch <- prometheus.MustNewConstMetric(nc.rateLimitRejections, prometheus.CounterValue, float64(limiter.Rejected()), name)
+	}
+
+	if config.Engine != nil {
+		execution := config.Engine.Metrics()
+		ch <- prometheus.MustNewConstMetric(nc.executionMode, prometheus.GaugeValue, 1, execution.Mode)
+		ch <- prometheus.MustNewConstMetric(nc.executionTPS, prometheus.GaugeValue, execution.TPS)
+		ch <- prometheus.MustNewConstMetric(nc.executionErrorRate, prometheus.GaugeValue, execution.ErrorRate)
+		ch <- prometheus.MustNewConstMetric(nc.executedTxs, prometheus.CounterValue, float64(execution.Transactions))
+	}
+
+	if config.VM != nil {
+		stats := config.VM.Stats()
+		ch <- prometheus.MustNewConstMetric(nc.contracts, prometheus.GaugeValue, float64(stats.Contracts))
+		ch <- prometheus.MustNewConstMetric(nc.contractExecutions, prometheus.CounterValue, float64(stats.Executions))
+		ch <- prometheus.MustNewConstMetric(nc.contractGas, prometheus.CounterValue, stats.GasUsed.Float64())
+	}
+
+	if config.Consensus != nil {
+		ch <- prometheus.MustNewConstMetric(nc.activeDelegates, prometheus.GaugeValue, float64(config.Consensus.GetActiveDelegateCount()))
+	}
+
+	if config.Audit != nil {
+		for level, name := range auditLevels {
+			ch <- prometheus.MustNewConstMetric(nc.auditEvents, prometheus.GaugeValue, float64(len(config.Audit.GetEventsByLevel(level))), name)
+		}
+	}
+}
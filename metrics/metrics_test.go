@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/api"
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/token"
+)
+
+// scrape returns the metrics served in the Prometheus text format
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return string(body)
+}
+
+// expectMetrics checks that scraped metrics contain each of the given samples
+func expectMetrics(t *testing.T, scraped string, samples ...string) {
+	t.Helper()
+	for _, sample := range samples {
+		if !strings.Contains(scraped, sample+"\n") {
+			t.Errorf("Expected sample %q in:\n%s", sample, scraped)
+		}
+	}
+}
+
+func TestMetrics_ExportsNodeState(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	engine := core.NewExecutionEngine(nil)
+	defer engine.Shutdown()
+	vm, err := smartcontract.NewWasmVM(binomToken, blockchain)
+	if err != nil {
+		t.Fatalf("Failed to create WASM VM: %v", err)
+	}
+
+	auditService := audit.NewAuditService(blockchain)
+	auditService.LogEvent(audit.WarningLevel, "Test", "warning", nil)
+
+	limiter := api.NewRateLimiter(1, time.Minute)
+	limiter.Allow("client")
+	limiter.Allow("client")
+
+	m := New(Config{
+		Blockchain:   blockchain,
+		Engine:       engine,
+		VM:           vm,
+		Consensus:    consensus.NewDPoSConsensus("AdNe6c3ce54e4371d056c7c566675ba16909eb2e9534", "AdNebaefd75d426056bffbc622bd9f334ed89450efae"),
+		Audit:        auditService,
+		RateLimiters: map[string]*api.RateLimiter{"faucet": limiter},
+	})
+	m.BlockProduced(blockchain.GetLastBlock(), 20*time.Millisecond)
+
+	expectMetrics(t, scrape(t, m),
+		"binomena_block_height 0",
+		"binomena_mempool_transactions 0",
+		"binomena_block_production_seconds_count 1",
+		`binomena_rate_limit_rejections_total{limiter="faucet"} 1`,
+		`binomena_execution_mode{mode="Single-Threaded"} 1`,
+		"binomena_execution_error_rate 0",
+		"binomena_contracts 0",
+		"binomena_contract_executions_total 0",
+		"binomena_active_delegates 1",
+		`binomena_audit_events{level="warning"} 1`,
+		`binomena_audit_events{level="critical"} 0`,
+	)
+}
+
+func TestMetrics_CountsRequestsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New(Config{Blockchain: core.NewBlockchain()})
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/blocks/:index", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for _, path := range []string{"/blocks/1", "/blocks/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	scraped := scrape(t, m)
+	expectMetrics(t, scraped,
+		`binomena_http_requests_total{method="GET",path="/blocks/:index",status="200"} 2`,
+		`binomena_http_requests_total{method="GET",path="unmatched",status="404"} 1`,
+		`binomena_http_request_duration_seconds_count{method="GET",path="/blocks/:index"} 2`,
+	)
+	if strings.Contains(scraped, "binomena_peers") {
+		t.Error("Expected no peer metrics without a P2P node")
+	}
+}
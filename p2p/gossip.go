@@ -0,0 +1,366 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// gossipProtocolID carries topic messages between neighbours over one
+	// long-lived stream per peer, each message prefixed by its uint32 length
+	gossipProtocolID = "/binomena/gossip/2.0.0"
+	// legacyGossipProtocolID carries a single message per stream, as sent by peers
+	// that predate long-lived gossip streams
+	legacyGossipProtocolID = "/binomena/gossip/1.0.0"
+
+	// Gossip topics
+	TransactionTopic = "binomena/tx/1"
+	BlockTopic       = "binomena/block/1"
+
+	// seenMessageTTL is how long a message ID is remembered to drop duplicates
+	seenMessageTTL = 2 * time.Minute
+	// maxSeenMessages bounds the seen-message cache
+	maxSeenMessages = 100000
+	// gossipSendTimeout bounds opening a stream to a peer or writing a message to it
+	gossipSendTimeout = 10 * time.Second
+	// gossipIdleTimeout closes inbound gossip streams that carry no message for this
+	// long; senders open a new one with their next message
+	gossipIdleTimeout = 10 * time.Minute
+	// gossipQueueSize bounds the messages waiting to be written to a single peer.
+	// Messages for a peer whose queue is full are dropped.
+	gossipQueueSize = 256
+)
+
+// ValidationResult decides what happens to a gossip message after validation
+type ValidationResult int
+
+const (
+	// ValidationAccept delivers the message and forwards it to other peers
+	ValidationAccept ValidationResult = iota
+	// ValidationReject drops an invalid message without forwarding it
+	ValidationReject
+	// ValidationIgnore drops a valid but useless message, such as one already known
+	ValidationIgnore
+)
+
+// GossipValidator checks a message received on a topic before it is re-gossiped
+type GossipValidator func(from peer.ID, data []byte) ValidationResult
+
+// GossipMessage is a message published on a topic. Its ID is the hash of the topic
+// and payload, so the same payload is only propagated once.
+type GossipMessage struct {
+	ID    string          `json:"id"`
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Gossip propagates topic messages through the network. Each node validates a
+// message once, then forwards it to all of its connected peers except the sender.
+// Every peer is written to over a single long-lived stream fed by its own queue,
+// so a slow peer holds up no other and messages are not paying for a new stream each.
+type Gossip struct {
+	host       host.Host
+	scorer     *PeerScorer
+	validators map[string]GossipValidator
+	outbound   map[peer.ID]*gossipPeer
+	seen       map[string]time.Time
+	seenOrder  []seenMessage // Seen message IDs oldest first, from seenHead on
+	seenHead   int
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// gossipPeer queues the messages written to a peer over its outbound stream
+type gossipPeer struct {
+	queue chan []byte
+}
+
+// seenMessage is a message ID in the order it was seen
+type seenMessage struct {
+	id     string
+	seenAt time.Time
+}
+
+// NewGossip creates a gossip router on a libp2p host. The scorer limits inbound
+// messages and keeps banned peers out of the mesh.
+func NewGossip(host host.Host, scorer *PeerScorer) *Gossip {
+	if scorer == nil {
+		scorer = NewPeerScorer(nil)
+	}
+
+	g := &Gossip{
+		host:       host,
+		scorer:     scorer,
+		validators: make(map[string]GossipValidator),
+		outbound:   make(map[peer.ID]*gossipPeer),
+		seen:       make(map[string]time.Time),
+		now:        time.Now,
+	}
+
+	host.SetStreamHandler(protocol.ID(gossipProtocolID), g.handleStream)
+	host.SetStreamHandler(protocol.ID(legacyGossipProtocolID), g.handleLegacyStream)
+	host.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(_ network.Network, conn network.Conn) {
+			g.dropPeer(conn.RemotePeer())
+		},
+	})
+	return g
+}
+
+// RegisterValidator sets the validator for a topic. Messages on topics without a
+// validator are dropped.
+func (g *Gossip) RegisterValidator(topic string, validator GossipValidator) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.validators[topic] = validator
+}
+
+// Publish sends a payload on a topic to all connected peers in the background, so
+// callers never wait on the network
+func (g *Gossip) Publish(topic string, data []byte) error {
+	message := GossipMessage{
+		ID:    gossipMessageID(topic, data),
+		Topic: topic,
+		Data:  data,
+	}
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	g.markSeen(message.ID)
+
+	g.forward(encoded, "")
+	return nil
+}
+
+// handleStream receives messages from a neighbour over its long-lived stream until
+// the stream closes, stays idle or the neighbour misbehaves
+func (g *Gossip) handleStream(stream network.Stream) {
+	defer stream.Close()
+
+	from := stream.Conn().RemotePeer()
+	reader := bufio.NewReader(stream)
+	for {
+		data, ok := g.readFrame(stream, reader)
+		if !ok {
+			return
+		}
+		g.receive(from, data)
+	}
+}
+
+// handleLegacyStream receives the single message of a stream from a neighbour that
+// predates long-lived gossip streams
+func (g *Gossip) handleLegacyStream(stream network.Stream) {
+	defer stream.Close()
+
+	data, ok := g.scorer.ReadMessage(stream)
+	if !ok {
+		return
+	}
+	g.receive(stream.Conn().RemotePeer(), data)
+}
+
+// readFrame reads the next length-prefixed message from a gossip stream, refusing
+// banned and rate limited peers and penalizing messages over the size limit
+func (g *Gossip) readFrame(stream network.Stream, reader *bufio.Reader) ([]byte, bool) {
+	from := stream.Conn().RemotePeer()
+
+	stream.SetReadDeadline(time.Now().Add(gossipIdleTimeout))
+	var length [4]byte
+	if _, err := io.ReadFull(reader, length[:]); err != nil {
+		return nil, false
+	}
+	size := binary.BigEndian.Uint32(length[:])
+
+	if !g.scorer.Allow(from, gossipProtocolID) {
+		stream.Reset()
+		return nil, false
+	}
+	if limit, _ := g.scorer.Limit(gossipProtocolID); limit.MaxMessageSize > 0 && int64(size) > limit.MaxMessageSize {
+		g.scorer.Penalize(from, PenaltyOversizeMessage)
+		stream.Reset()
+		return nil, false
+	}
+
+	stream.SetReadDeadline(time.Now().Add(messageReadTimeout))
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// receive validates a message from a neighbour and re-gossips it
+func (g *Gossip) receive(from peer.ID, data []byte) {
+	var message GossipMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		log.Printf("Error decoding gossip message: %v", err)
+		g.scorer.Penalize(from, PenaltyMalformedMessage)
+		return
+	}
+
+	// The ID is derived from the content so it cannot be used to shadow other messages
+	if message.ID != gossipMessageID(message.Topic, message.Data) {
+		log.Printf("Dropped gossip message with mismatched ID from peer %s", from.String())
+		g.scorer.Penalize(from, PenaltyMalformedMessage)
+		return
+	}
+	if !g.markSeen(message.ID) {
+		return
+	}
+
+	g.mu.Lock()
+	validator := g.validators[message.Topic]
+	g.mu.Unlock()
+	if validator == nil {
+		return
+	}
+
+	switch validator(from, message.Data) {
+	case ValidationAccept:
+		g.forward(data, from)
+	case ValidationReject:
+		log.Printf("Rejected gossip message %s on %s from peer %s", message.ID, message.Topic, from.String())
+	}
+}
+
+// forward queues an encoded message for every connected peer except the one it
+// came from, without waiting for any of them
+func (g *Gossip) forward(data []byte, from peer.ID) {
+	for _, peerID := range g.host.Network().Peers() {
+		if peerID == from || g.scorer.IsBanned(peerID) {
+			continue
+		}
+
+		g.mu.Lock()
+		outbound, ok := g.outbound[peerID]
+		if !ok {
+			outbound = &gossipPeer{queue: make(chan []byte, gossipQueueSize)}
+			g.outbound[peerID] = outbound
+			go g.writeLoop(peerID, outbound)
+		}
+		select {
+		case outbound.queue <- data:
+		default:
+			log.Printf("Dropped gossip message for slow peer %s", peerID.String())
+		}
+		g.mu.Unlock()
+	}
+}
+
+// dropPeer stops writing to a peer once it has no connection left
+func (g *Gossip) dropPeer(peerID peer.ID) {
+	if g.host.Network().Connectedness(peerID) == network.Connected {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if outbound, ok := g.outbound[peerID]; ok {
+		close(outbound.queue)
+		delete(g.outbound, peerID)
+	}
+}
+
+// writeLoop writes the messages queued for a peer over one long-lived stream,
+// opening a new stream when there is none or the last one failed, until the
+// peer's queue is closed
+func (g *Gossip) writeLoop(peerID peer.ID, outbound *gossipPeer) {
+	var stream network.Stream
+	defer func() {
+		if stream != nil {
+			stream.Close()
+		}
+	}()
+
+	for data := range outbound.queue {
+		if stream == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), gossipSendTimeout)
+			opened, err := g.host.NewStream(ctx, peerID, protocol.ID(gossipProtocolID))
+			cancel()
+			if err != nil {
+				log.Printf("Error opening gossip stream to peer %s: %v", peerID.String(), err)
+				g.dropPeer(peerID)
+				continue
+			}
+			stream = opened
+		}
+
+		if err := writeFrame(stream, data); err != nil {
+			log.Printf("Error writing gossip message to peer %s: %v", peerID.String(), err)
+			stream.Reset()
+			stream = nil
+		}
+	}
+}
+
+// writeFrame writes a length-prefixed message to a gossip stream
+func writeFrame(stream network.Stream, data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+
+	stream.SetWriteDeadline(time.Now().Add(gossipSendTimeout))
+	_, err := stream.Write(frame)
+	return err
+}
+
+// markSeen records a message ID and reports whether it was new. IDs are forgotten
+// oldest first once they expire or the cache is full.
+func (g *Gossip) markSeen(id string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if seenAt, exists := g.seen[id]; exists && now.Sub(seenAt) < seenMessageTTL {
+		return false
+	}
+
+	for g.seenHead < len(g.seenOrder) {
+		oldest := g.seenOrder[g.seenHead]
+		if len(g.seen) < maxSeenMessages && now.Sub(oldest.seenAt) < seenMessageTTL {
+			break
+		}
+		// An ID seen again since is kept under its later entry
+		if g.seen[oldest.id] == oldest.seenAt {
+			delete(g.seen, oldest.id)
+		}
+		g.seenOrder[g.seenHead] = seenMessage{}
+		g.seenHead++
+	}
+
+	// Drop the forgotten entries once they make up half of the order
+	if g.seenHead > len(g.seenOrder)/2 {
+		g.seenOrder = append([]seenMessage(nil), g.seenOrder[g.seenHead:]...)
+		g.seenHead = 0
+	}
+
+	g.seen[id] = now
+	g.seenOrder = append(g.seenOrder, seenMessage{id: id, seenAt: now})
+	return true
+}
+
+// gossipMessageID returns the content hash identifying a message
+func gossipMessageID(topic string, data []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(topic))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -3,9 +3,13 @@ package p2p
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/igo-used/binomena/core"
 	"github.com/libp2p/go-libp2p"
@@ -17,6 +21,9 @@ import (
 	"github.com/multiformats/go-multiaddr"
 )
 
+// errConsensusRejected is returned for peer blocks that fail consensus validation
+var errConsensusRejected = errors.New("failed consensus validation")
+
 const (
 	// Protocol IDs
 	transactionProtocolID = "/binomena/tx/1.0.0"
@@ -30,9 +37,17 @@ const (
 // P2PNode represents a P2P node in the Binomena network
 type P2PNode struct {
 	host         host.Host
-	blockchain   *core.Blockchain
+	blockchain   core.BlockchainInterface
 	knownPeers   map[peer.ID]peer.AddrInfo
-	knownWallets map[string]string // address -> peer ID
+	knownWallets map[string]WalletAnnouncement
+	walletTTL    time.Duration
+	walletDir    string
+	consensus    core.Consensus
+	accounts     core.AccountState
+	gossip       *Gossip
+	scorer       *PeerScorer
+	syncing      bool
+	stopChan     chan struct{}
 	mu           sync.RWMutex
 }
 
@@ -44,6 +59,9 @@ type discoveryNotifee struct {
 // HandlePeerFound connects to peers discovered via mDNS
 func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	log.Printf("Discovered new peer %s\n", pi.ID.String())
+	if n.node.scorer.IsBanned(pi.ID) {
+		return
+	}
 	err := n.node.host.Connect(context.Background(), pi)
 	if err != nil {
 		log.Printf("Error connecting to peer %s: %v\n", pi.ID.String(), err)
@@ -54,10 +72,13 @@ func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	n.node.mu.Lock()
 	n.node.knownPeers[pi.ID] = pi
 	n.node.mu.Unlock()
+
+	// Catch up if the new peer is ahead of us
+	n.node.requestSync(pi.ID)
 }
 
 // NewP2PNode creates a new P2P node
-func NewP2PNode(blockchain *core.Blockchain, listenAddr string) (*P2PNode, error) {
+func NewP2PNode(blockchain core.BlockchainInterface, listenAddr string) (*P2PNode, error) {
 	// Parse the multiaddress
 	addr, err := multiaddr.NewMultiaddr(listenAddr)
 	if err != nil {
@@ -77,13 +98,23 @@ func NewP2PNode(blockchain *core.Blockchain, listenAddr string) (*P2PNode, error
 		host:         host,
 		blockchain:   blockchain,
 		knownPeers:   make(map[peer.ID]peer.AddrInfo),
-		knownWallets: make(map[string]string),
+		knownWallets: make(map[string]WalletAnnouncement),
+		walletTTL:    DefaultWalletAnnouncementTTL,
+		scorer:       NewPeerScorer(nil),
+		stopChan:     make(chan struct{}),
 	}
+	node.scorer.onBan = node.disconnectPeer
 
 	// Set up protocol handlers
 	host.SetStreamHandler(protocol.ID(transactionProtocolID), node.handleTransactionStream)
 	host.SetStreamHandler(protocol.ID(blockProtocolID), node.handleBlockStream)
 	host.SetStreamHandler(protocol.ID(walletDiscoveryID), node.handleWalletDiscoveryStream)
+	host.SetStreamHandler(protocol.ID(syncProtocolID), node.handleSyncStream)
+
+	// Gossip transactions and blocks, re-gossiping only what passes validation
+	node.gossip = NewGossip(host, node.scorer)
+	node.gossip.RegisterValidator(TransactionTopic, node.validateTransactionMessage)
+	node.gossip.RegisterValidator(BlockTopic, node.validateBlockMessage)
 
 	// Setup local mDNS discovery
 	notifee := &discoveryNotifee{node: node}
@@ -95,77 +126,73 @@ func NewP2PNode(blockchain *core.Blockchain, listenAddr string) (*P2PNode, error
 	log.Printf("P2P node started with ID: %s", host.ID().String())
 	log.Printf("Listening on: %s", host.Addrs()[0].String())
 
+	// Keep up with peers that get ahead of us
+	go node.syncLoop()
+
 	return node, nil
 }
 
-// handleTransactionStream handles incoming transaction streams
+// handleTransactionStream handles transactions sent directly by peers that predate gossip
 func (n *P2PNode) handleTransactionStream(stream network.Stream) {
 	defer stream.Close()
 
-	// Decode the transaction
-	var tx core.Transaction
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&tx); err != nil {
-		log.Printf("Error decoding transaction: %v", err)
+	data, ok := n.scorer.ReadMessage(stream)
+	if !ok {
 		return
 	}
+	n.validateTransactionMessage(stream.Conn().RemotePeer(), data)
+}
 
-	// Validate the transaction prefix
-	if tx.ID[:4] != "AdNe" {
-		log.Printf("Invalid transaction prefix: %s", tx.ID)
-		return
-	}
+// SetConsensus sets the consensus mechanism used to validate blocks received from peers
+func (n *P2PNode) SetConsensus(consensus core.Consensus) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-	// Add the transaction to the blockchain
-	n.blockchain.AddTransaction(tx)
+	n.consensus = consensus
+}
+
+// SetAccountState sets the token state that transactions received from peers are
+// admitted against. Until it is set, peer transactions are neither accepted nor
+// relayed.
+func (n *P2PNode) SetAccountState(accounts core.AccountState) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-	log.Printf("Received transaction %s from peer %s", tx.ID, stream.Conn().RemotePeer().String())
+	n.accounts = accounts
 }
 
-// handleBlockStream handles incoming block streams
+// handleBlockStream handles blocks sent directly by peers that predate gossip
 func (n *P2PNode) handleBlockStream(stream network.Stream) {
 	defer stream.Close()
 
-	// Decode the block
-	var block core.Block
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&block); err != nil {
-		log.Printf("Error decoding block: %v", err)
+	data, ok := n.scorer.ReadMessage(stream)
+	if !ok {
 		return
 	}
+	n.validateBlockMessage(stream.Conn().RemotePeer(), data)
+}
 
-	// Add the block to the blockchain
-	if err := n.blockchain.AddBlock(block); err != nil {
-		log.Printf("Error adding block: %v", err)
-		return
+// importBlock validates a block received from a peer and adds it to the blockchain,
+// which settles its transactions
+func (n *P2PNode) importBlock(block core.Block) error {
+	if err := n.checkConsensus(block); err != nil {
+		return err
 	}
-
-	log.Printf("Received block %d from peer %s", block.Index, stream.Conn().RemotePeer().String())
+	return n.blockchain.AddBlock(block)
 }
 
-// handleWalletDiscoveryStream handles wallet discovery streams
-func (n *P2PNode) handleWalletDiscoveryStream(stream network.Stream) {
-	defer stream.Close()
-
-	// Decode the wallet address
-	var walletInfo struct {
-		Address string `json:"address"`
-	}
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&walletInfo); err != nil {
-		log.Printf("Error decoding wallet info: %v", err)
-		return
+// checkConsensus rejects blocks that fail consensus validation
+func (n *P2PNode) checkConsensus(block core.Block) error {
+	n.mu.RLock()
+	consensus := n.consensus
+	n.mu.RUnlock()
+	if consensus != nil && !consensus.ValidateBlock(block) {
+		return errConsensusRejected
 	}
-
-	// Store the wallet address and peer ID
-	n.mu.Lock()
-	n.knownWallets[walletInfo.Address] = stream.Conn().RemotePeer().String()
-	n.mu.Unlock()
-
-	log.Printf("Discovered wallet %s from peer %s", walletInfo.Address, stream.Conn().RemotePeer().String())
+	return nil
 }
 
-// BroadcastTransaction broadcasts a transaction to all known peers
+// BroadcastTransaction gossips a transaction to the network
 func (n *P2PNode) BroadcastTransaction(tx core.Transaction) error {
 	// Ensure transaction has the correct prefix
 	if tx.ID[:4] != "AdNe" {
@@ -178,37 +205,10 @@ func (n *P2PNode) BroadcastTransaction(tx core.Transaction) error {
 		return err
 	}
 
-	// Broadcast to all known peers
-	n.mu.RLock()
-	peers := make([]peer.ID, 0, len(n.knownPeers))
-	for id := range n.knownPeers {
-		peers = append(peers, id)
-	}
-	n.mu.RUnlock()
-
-	for _, peerID := range peers {
-		// Open a stream to the peer
-		stream, err := n.host.NewStream(context.Background(), peerID, protocol.ID(transactionProtocolID))
-		if err != nil {
-			log.Printf("Error opening stream to peer %s: %v", peerID.String(), err)
-			continue
-		}
-
-		// Write the transaction to the stream
-		_, err = stream.Write(txJSON)
-		if err != nil {
-			log.Printf("Error writing to stream: %v", err)
-			stream.Close()
-			continue
-		}
-
-		stream.Close()
-	}
-
-	return nil
+	return n.gossip.Publish(TransactionTopic, txJSON)
 }
 
-// BroadcastBlock broadcasts a block to all known peers
+// BroadcastBlock gossips a block to the network
 func (n *P2PNode) BroadcastBlock(block core.Block) error {
 	// Marshal the block to JSON
 	blockJSON, err := json.Marshal(block)
@@ -216,93 +216,100 @@ func (n *P2PNode) BroadcastBlock(block core.Block) error {
 		return err
 	}
 
-	// Broadcast to all known peers
-	n.mu.RLock()
-	peers := make([]peer.ID, 0, len(n.knownPeers))
-	for id := range n.knownPeers {
-		peers = append(peers, id)
+	return n.gossip.Publish(BlockTopic, blockJSON)
+}
+
+// validateTransactionMessage adds a gossiped transaction to the pending pool,
+// rejecting it when it is malformed, its signature does not check out or it fails
+// the same admission checks as transactions submitted through the API
+func (n *P2PNode) validateTransactionMessage(from peer.ID, data []byte) ValidationResult {
+	var tx core.Transaction
+	if err := json.Unmarshal(data, &tx); err != nil || !strings.HasPrefix(tx.ID, "AdNe") {
+		n.scorer.Penalize(from, PenaltyMalformedMessage)
+		return ValidationReject
+	}
+	if err := core.VerifyTransactionSignature(&tx); err != nil {
+		log.Printf("Rejected transaction %s from peer %s: %v", tx.ID, from.String(), err)
+		n.scorer.Penalize(from, PenaltyInvalidTransaction)
+		return ValidationReject
 	}
-	n.mu.RUnlock()
 
-	for _, peerID := range peers {
-		// Open a stream to the peer
-		stream, err := n.host.NewStream(context.Background(), peerID, protocol.ID(blockProtocolID))
-		if err != nil {
-			log.Printf("Error opening stream to peer %s: %v", peerID.String(), err)
-			continue
+	n.mu.RLock()
+	accounts := n.accounts
+	n.mu.RUnlock()
+	if accounts == nil {
+		return ValidationIgnore
+	}
+	if err := core.CheckAdmission(n.blockchain.Mempool(), accounts, tx); err != nil {
+		log.Printf("Rejected transaction %s from peer %s: %v", tx.ID, from.String(), err)
+
+		// A peer at another tip may see different nonces and balances, so only
+		// transactions that can never be valid count against it
+		var nonceErr *core.NonceError
+		var balanceErr *core.BalanceError
+		if errors.As(err, &nonceErr) || errors.As(err, &balanceErr) {
+			return ValidationIgnore
 		}
+		n.scorer.Penalize(from, PenaltyInvalidTransaction)
+		return ValidationReject
+	}
 
-		// Write the block to the stream
-		_, err = stream.Write(blockJSON)
-		if err != nil {
-			log.Printf("Error writing to stream: %v", err)
-			stream.Close()
-			continue
+	if err := n.blockchain.AddTransaction(tx); err != nil {
+		if errors.Is(err, core.ErrDuplicateTransaction) || errors.Is(err, core.ErrMempoolFull) {
+			return ValidationIgnore
 		}
-
-		stream.Close()
+		log.Printf("Rejected transaction %s from peer %s: %v", tx.ID, from.String(), err)
+		n.scorer.Penalize(from, PenaltyInvalidTransaction)
+		return ValidationReject
 	}
 
-	return nil
+	log.Printf("Received transaction %s from peer %s", tx.ID, from.String())
+	n.scorer.Reward(from)
+	return ValidationAccept
 }
 
-// AnnounceWallet announces a wallet address to the network
-func (n *P2PNode) AnnounceWallet(address string) error {
-	// Ensure wallet address has the correct prefix
-	if address[:4] != "AdNe" {
-		return fmt.Errorf("wallet address must start with 'AdNe'")
-	}
-
-	// Create wallet info
-	walletInfo := struct {
-		Address string `json:"address"`
-	}{
-		Address: address,
+// validateBlockMessage imports a gossiped block, rejecting it when it fails
+// validation and catching up first when it is beyond our tip
+func (n *P2PNode) validateBlockMessage(from peer.ID, data []byte) ValidationResult {
+	var block core.Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		n.scorer.Penalize(from, PenaltyMalformedMessage)
+		return ValidationReject
 	}
 
-	// Marshal the wallet info to JSON
-	walletJSON, err := json.Marshal(walletInfo)
-	if err != nil {
-		return err
+	// Blocks that are not properly signed can only come from a misbehaving peer
+	if err := core.VerifyBlockSignature(block); err != nil {
+		log.Printf("Rejected block %d from peer %s: %v", block.Index, from.String(), err)
+		n.scorer.Penalize(from, PenaltyInvalidBlock)
+		return ValidationReject
 	}
 
-	// Broadcast to all known peers
-	n.mu.RLock()
-	peers := make([]peer.ID, 0, len(n.knownPeers))
-	for id := range n.knownPeers {
-		peers = append(peers, id)
+	if block.Index > n.blockchain.GetLastBlock().Index+1 {
+		n.requestSync(from)
+		return ValidationIgnore
 	}
-	n.mu.RUnlock()
 
-	for _, peerID := range peers {
-		// Open a stream to the peer
-		stream, err := n.host.NewStream(context.Background(), peerID, protocol.ID(walletDiscoveryID))
-		if err != nil {
-			log.Printf("Error opening stream to peer %s: %v", peerID.String(), err)
-			continue
+	// Other import failures may be forks we cannot place yet, so only consensus
+	// violations count against the sender. Blocks forking off below our tip are
+	// settled by syncing with the sender's chain, which fork choice may prefer.
+	if err := n.importBlock(block); err != nil {
+		if errors.Is(err, core.ErrKnownBlock) {
+			return ValidationIgnore
 		}
-
-		// Write the wallet info to the stream
-		_, err = stream.Write(walletJSON)
-		if err != nil {
-			log.Printf("Error writing to stream: %v", err)
-			stream.Close()
-			continue
+		if !errors.Is(err, errConsensusRejected) && block.PreviousHash != n.blockchain.GetLastBlock().Hash {
+			n.requestSync(from)
+			return ValidationIgnore
 		}
-
-		stream.Close()
+		log.Printf("Rejected block %d from peer %s: %v", block.Index, from.String(), err)
+		if errors.Is(err, errConsensusRejected) {
+			n.scorer.Penalize(from, PenaltyInvalidBlock)
+		}
+		return ValidationReject
 	}
 
-	return nil
-}
-
-// FindWalletPeer finds the peer ID for a wallet address
-func (n *P2PNode) FindWalletPeer(address string) (string, bool) {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-
-	peerID, found := n.knownWallets[address]
-	return peerID, found
+	log.Printf("Received block %d from peer %s", block.Index, from.String())
+	n.scorer.Reward(from)
+	return ValidationAccept
 }
 
 // GetPeerCount returns the number of known peers
@@ -313,16 +320,47 @@ func (n *P2PNode) GetPeerCount() int {
 	return len(n.knownPeers)
 }
 
-// GetWalletCount returns the number of known wallets
-func (n *P2PNode) GetWalletCount() int {
+// GetPeerScores returns the score of every known or scored peer
+func (n *P2PNode) GetPeerScores() []PeerScore {
+	scores := n.scorer.Scores()
+	scored := make(map[string]bool, len(scores))
+	for _, score := range scores {
+		scored[score.PeerID] = true
+	}
+
 	n.mu.RLock()
-	defer n.mu.RUnlock()
+	for id := range n.knownPeers {
+		if !scored[id.String()] {
+			scores = append(scores, n.scorer.Score(id))
+		}
+	}
+	n.mu.RUnlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].PeerID < scores[j].PeerID
+	})
+	return scores
+}
 
-	return len(n.knownWallets)
+// SetPeerScoreConfig changes the peer scoring and message limit configuration
+func (n *P2PNode) SetPeerScoreConfig(config *PeerScoreConfig) {
+	n.scorer.SetConfig(config)
+}
+
+// disconnectPeer drops a banned peer
+func (n *P2PNode) disconnectPeer(peerID peer.ID) {
+	log.Printf("Banned peer %s", peerID.String())
+
+	n.mu.Lock()
+	delete(n.knownPeers, peerID)
+	n.mu.Unlock()
+
+	n.host.Network().ClosePeer(peerID)
 }
 
 // Stop stops the P2P node
 func (n *P2PNode) Stop() error {
+	close(n.stopChan)
 	return n.host.Close()
 }
 
@@ -339,6 +377,9 @@ func (n *P2PNode) ConnectToPeer(peerAddr string) error {
 	if err != nil {
 		return fmt.Errorf("invalid peer info: %v", err)
 	}
+	if n.scorer.IsBanned(info.ID) {
+		return fmt.Errorf("peer %s is banned", info.ID.String())
+	}
 
 	// Connect to the peer
 	if err := n.host.Connect(context.Background(), *info); err != nil {
@@ -351,6 +392,9 @@ func (n *P2PNode) ConnectToPeer(peerAddr string) error {
 	n.mu.Unlock()
 
 	log.Printf("Connected to peer: %s", info.ID.String())
+
+	// Catch up if the new peer is ahead of us
+	n.requestSync(info.ID)
 	return nil
 }
 
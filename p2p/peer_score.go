@@ -0,0 +1,289 @@
+package p2p
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Score changes applied for peer behaviour
+const (
+	PenaltyInvalidBlock       = -20.0
+	PenaltyInvalidTransaction = -10.0
+	PenaltyMalformedMessage   = -10.0
+	PenaltyOversizeMessage    = -20.0
+	PenaltyRateLimited        = -5.0
+	RewardValidMessage        = 1.0
+
+	// maxPeerScore caps how much credit good behaviour can build up
+	maxPeerScore = 100.0
+	// messageReadTimeout bounds reading a single inbound message
+	messageReadTimeout = 30 * time.Second
+)
+
+// ProtocolLimit caps the size and inbound rate of messages on one protocol
+type ProtocolLimit struct {
+	// MaxMessageSize is the largest accepted message in bytes
+	MaxMessageSize int64
+	// Rate is the sustained number of messages per second allowed from one peer
+	Rate float64
+	// Burst is the number of messages a peer may send at once
+	Burst float64
+}
+
+// PeerScoreConfig holds configuration for peer scoring
+type PeerScoreConfig struct {
+	// BanThreshold is the score at or below which a peer is banned
+	BanThreshold float64
+	// BanDuration is how long a banned peer stays banned
+	BanDuration time.Duration
+	// Limits holds the message limits of each protocol ID
+	Limits map[string]ProtocolLimit
+}
+
+// DefaultPeerScoreConfig returns default peer scoring configuration
+func DefaultPeerScoreConfig() *PeerScoreConfig {
+	return &PeerScoreConfig{
+		BanThreshold: -100,
+		BanDuration:  time.Hour,
+		Limits: map[string]ProtocolLimit{
+			transactionProtocolID:  {MaxMessageSize: 64 << 10, Rate: 20, Burst: 100},
+			blockProtocolID:        {MaxMessageSize: 4 << 20, Rate: 2, Burst: 10},
+			walletDiscoveryID:      {MaxMessageSize: 4 << 10, Rate: 5, Burst: 20},
+			syncProtocolID:         {MaxMessageSize: 4 << 10, Rate: 10, Burst: 50},
+			gossipProtocolID:       {MaxMessageSize: 4 << 20, Rate: 50, Burst: 200},
+			legacyGossipProtocolID: {MaxMessageSize: 4 << 20, Rate: 50, Burst: 200},
+		},
+	}
+}
+
+// PeerScore is the reported standing of a peer
+type PeerScore struct {
+	PeerID      string    `json:"peerId"`
+	Score       float64   `json:"score"`
+	Banned      bool      `json:"banned"`
+	BannedUntil time.Time `json:"bannedUntil,omitempty"`
+}
+
+// peerState tracks the score, ban and rate limits of one peer
+type peerState struct {
+	score       float64
+	bannedUntil time.Time
+	buckets     map[string]*tokenBucket
+}
+
+// tokenBucket limits the rate of messages on one protocol
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// PeerScorer scores peers by their behaviour, rate limits their messages and bans
+// peers whose score drops to the ban threshold
+type PeerScorer struct {
+	config PeerScoreConfig
+	peers  map[peer.ID]*peerState
+	onBan  func(peer.ID)
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewPeerScorer creates a new peer scorer
+func NewPeerScorer(config *PeerScoreConfig) *PeerScorer {
+	if config == nil {
+		config = DefaultPeerScoreConfig()
+	}
+
+	return &PeerScorer{
+		config: *config,
+		peers:  make(map[peer.ID]*peerState),
+		now:    time.Now,
+	}
+}
+
+// SetConfig changes the scoring configuration
+func (s *PeerScorer) SetConfig(config *PeerScoreConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = *config
+}
+
+// Limit returns the message limits of a protocol
+func (s *PeerScorer) Limit(protocolID string) (ProtocolLimit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit, exists := s.config.Limits[protocolID]
+	return limit, exists
+}
+
+// Allow reports whether a peer may send another message on a protocol. Banned
+// peers are refused and peers over the rate limit are penalized.
+func (s *PeerScorer) Allow(peerID peer.ID, protocolID string) bool {
+	s.mu.Lock()
+	state := s.state(peerID)
+	now := s.now()
+	if now.Before(state.bannedUntil) {
+		s.mu.Unlock()
+		return false
+	}
+
+	limit, exists := s.config.Limits[protocolID]
+	if !exists || limit.Rate <= 0 {
+		s.mu.Unlock()
+		return true
+	}
+
+	bucket, exists := state.buckets[protocolID]
+	if !exists {
+		bucket = &tokenBucket{tokens: limit.Burst, last: now}
+		state.buckets[protocolID] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * limit.Rate
+	if bucket.tokens > limit.Burst {
+		bucket.tokens = limit.Burst
+	}
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		s.mu.Unlock()
+		return true
+	}
+	s.mu.Unlock()
+
+	s.Penalize(peerID, PenaltyRateLimited)
+	return false
+}
+
+// Penalize lowers a peer's score and bans it once the score reaches the threshold.
+// It reports whether the peer got banned.
+func (s *PeerScorer) Penalize(peerID peer.ID, penalty float64) bool {
+	s.mu.Lock()
+	state := s.state(peerID)
+	now := s.now()
+	if now.Before(state.bannedUntil) {
+		s.mu.Unlock()
+		return false
+	}
+
+	state.score += penalty
+	if state.score > s.config.BanThreshold {
+		s.mu.Unlock()
+		return false
+	}
+
+	// Start over from zero once the ban runs out
+	state.bannedUntil = now.Add(s.config.BanDuration)
+	state.score = 0
+	state.buckets = make(map[string]*tokenBucket)
+	onBan := s.onBan
+	s.mu.Unlock()
+
+	if onBan != nil {
+		onBan(peerID)
+	}
+	return true
+}
+
+// Reward raises a peer's score for a valid message
+func (s *PeerScorer) Reward(peerID peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state(peerID)
+	state.score += RewardValidMessage
+	if state.score > maxPeerScore {
+		state.score = maxPeerScore
+	}
+}
+
+// IsBanned reports whether a peer is currently banned
+func (s *PeerScorer) IsBanned(peerID peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.peers[peerID]
+	return exists && s.now().Before(state.bannedUntil)
+}
+
+// Score returns the standing of a peer
+func (s *PeerScorer) Score(peerID peer.ID) PeerScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	score := PeerScore{PeerID: peerID.String()}
+	if state, exists := s.peers[peerID]; exists {
+		score.Score = state.score
+		if s.now().Before(state.bannedUntil) {
+			score.Banned = true
+			score.BannedUntil = state.bannedUntil
+		}
+	}
+	return score
+}
+
+// Scores returns the standing of every peer that has been scored, sorted by peer ID
+func (s *PeerScorer) Scores() []PeerScore {
+	s.mu.Lock()
+	ids := make([]peer.ID, 0, len(s.peers))
+	for id := range s.peers {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	scores := make([]PeerScore, len(ids))
+	for i, id := range ids {
+		scores[i] = s.Score(id)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].PeerID < scores[j].PeerID
+	})
+	return scores
+}
+
+// state returns the tracked state of a peer, creating it on first use. Callers
+// must hold s.mu.
+func (s *PeerScorer) state(peerID peer.ID) *peerState {
+	state, exists := s.peers[peerID]
+	if !exists {
+		state = &peerState{buckets: make(map[string]*tokenBucket)}
+		s.peers[peerID] = state
+	}
+	return state
+}
+
+// ReadMessage reads one message from an inbound stream, refusing banned and rate
+// limited peers and penalizing messages over the protocol's size limit
+func (s *PeerScorer) ReadMessage(stream network.Stream) ([]byte, bool) {
+	from := stream.Conn().RemotePeer()
+	protocolID := string(stream.Protocol())
+	if !s.Allow(from, protocolID) {
+		stream.Reset()
+		return nil, false
+	}
+
+	stream.SetReadDeadline(time.Now().Add(messageReadTimeout))
+	reader := io.Reader(stream)
+	limit, _ := s.Limit(protocolID)
+	if limit.MaxMessageSize > 0 {
+		reader = io.LimitReader(stream, limit.MaxMessageSize+1)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false
+	}
+	if limit.MaxMessageSize > 0 && int64(len(data)) > limit.MaxMessageSize {
+		s.Penalize(from, PenaltyOversizeMessage)
+		stream.Reset()
+		return nil, false
+	}
+
+	return data, true
+}
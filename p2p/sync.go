@@ -0,0 +1,470 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/igo-used/binomena/core"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// syncProtocolID is the request/response protocol used for chain sync
+	syncProtocolID = "/binomena/sync/1.0.0"
+
+	// Sync request types
+	syncRequestStatus  = "status"
+	syncRequestHeaders = "headers"
+	syncRequestBlocks  = "blocks"
+
+	// Limits on a single sync request
+	maxHeadersPerRequest = 512
+	maxBlocksPerRequest  = 64
+
+	// syncRequestTimeout bounds a single sync request/response round trip
+	syncRequestTimeout = 30 * time.Second
+	// maxSyncResponseSize caps the size of a sync response read from a peer
+	maxSyncResponseSize = 64 << 20
+	// syncInterval is how often peers are polled for a higher tip
+	syncInterval = 30 * time.Second
+)
+
+// ChainStatus describes the chain a node is on and how far it has got
+type ChainStatus struct {
+	Height      uint64 `json:"height"`
+	TipHash     string `json:"tipHash"`
+	GenesisHash string `json:"genesisHash"`
+}
+
+// SyncRequest is a request sent over the sync protocol. Headers and blocks requests
+// ask for Count consecutive blocks starting at index From.
+type SyncRequest struct {
+	Type   string       `json:"type"`
+	From   uint64       `json:"from,omitempty"`
+	Count  int          `json:"count,omitempty"`
+	Status *ChainStatus `json:"status,omitempty"` // The requester's own status
+}
+
+// SyncResponse is the answer to a SyncRequest
+type SyncResponse struct {
+	Status  *ChainStatus       `json:"status,omitempty"`
+	Headers []core.BlockHeader `json:"headers,omitempty"`
+	Blocks  []core.Block       `json:"blocks,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// ChainStatus returns the status of the local chain
+func (n *P2PNode) ChainStatus() (ChainStatus, error) {
+	genesis, err := n.blockchain.GetBlockByIndex(0)
+	if err != nil {
+		return ChainStatus{}, fmt.Errorf("failed to load genesis block: %v", err)
+	}
+
+	tip := n.blockchain.GetLastBlock()
+	return ChainStatus{
+		Height:      tip.Index,
+		TipHash:     tip.Hash,
+		GenesisHash: genesis.Hash,
+	}, nil
+}
+
+// handleSyncStream answers a single sync request
+func (n *P2PNode) handleSyncStream(stream network.Stream) {
+	defer stream.Close()
+
+	data, ok := n.scorer.ReadMessage(stream)
+	if !ok {
+		return
+	}
+	remotePeer := stream.Conn().RemotePeer()
+	stream.SetWriteDeadline(time.Now().Add(syncRequestTimeout))
+
+	var request SyncRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		log.Printf("Error decoding sync request: %v", err)
+		n.scorer.Penalize(remotePeer, PenaltyMalformedMessage)
+		return
+	}
+
+	response := n.answerSyncRequest(request)
+
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		log.Printf("Error writing sync response to peer %s: %v", remotePeer.String(), err)
+		return
+	}
+
+	// A peer announcing a higher tip is worth catching up with
+	if request.Status != nil && response.Status != nil &&
+		request.Status.GenesisHash == response.Status.GenesisHash &&
+		request.Status.Height > response.Status.Height {
+		n.requestSync(remotePeer)
+	}
+}
+
+// answerSyncRequest builds the response to a sync request from the local chain
+func (n *P2PNode) answerSyncRequest(request SyncRequest) SyncResponse {
+	var response SyncResponse
+
+	switch request.Type {
+	case syncRequestStatus:
+		status, err := n.ChainStatus()
+		if err != nil {
+			response.Error = err.Error()
+			break
+		}
+		response.Status = &status
+
+	case syncRequestHeaders:
+		blocks, err := n.blockRange(request.From, request.Count, maxHeadersPerRequest)
+		if err != nil {
+			response.Error = err.Error()
+			break
+		}
+		response.Headers = make([]core.BlockHeader, len(blocks))
+		for i, block := range blocks {
+			response.Headers[i] = block.Header()
+		}
+
+	case syncRequestBlocks:
+		blocks, err := n.blockRange(request.From, request.Count, maxBlocksPerRequest)
+		if err != nil {
+			response.Error = err.Error()
+			break
+		}
+		response.Blocks = blocks
+
+	default:
+		response.Error = fmt.Sprintf("unknown sync request type %q", request.Type)
+	}
+
+	return response
+}
+
+// blockRange returns up to count blocks of the main chain starting at index from,
+// capped at limit and at the local tip
+func (n *P2PNode) blockRange(from uint64, count, limit int) ([]core.Block, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("count must be positive")
+	}
+	if count > limit {
+		count = limit
+	}
+
+	height := n.blockchain.GetLastBlock().Index
+	blocks := make([]core.Block, 0, count)
+	for index := from; index <= height && len(blocks) < count; index++ {
+		block, err := n.blockchain.GetBlockByIndex(index)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// sendSyncRequest sends a sync request to a peer and waits for its response
+func (n *P2PNode) sendSyncRequest(peerID peer.ID, request SyncRequest) (*SyncResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncRequestTimeout)
+	defer cancel()
+
+	stream, err := n.host.NewStream(ctx, peerID, protocol.ID(syncProtocolID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync stream: %v", err)
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(syncRequestTimeout))
+
+	if err := json.NewEncoder(stream).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send sync request: %v", err)
+	}
+	stream.CloseWrite()
+
+	var response SyncResponse
+	if err := json.NewDecoder(io.LimitReader(stream, maxSyncResponseSize)).Decode(&response); err != nil {
+		n.scorer.Penalize(peerID, PenaltyMalformedMessage)
+		return nil, fmt.Errorf("failed to read sync response: %v", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("peer rejected sync request: %s", response.Error)
+	}
+
+	return &response, nil
+}
+
+// RequestStatus exchanges chain status with a peer
+func (n *P2PNode) RequestStatus(peerID peer.ID) (ChainStatus, error) {
+	local, err := n.ChainStatus()
+	if err != nil {
+		return ChainStatus{}, err
+	}
+
+	response, err := n.sendSyncRequest(peerID, SyncRequest{Type: syncRequestStatus, Status: &local})
+	if err != nil {
+		return ChainStatus{}, err
+	}
+	if response.Status == nil {
+		return ChainStatus{}, fmt.Errorf("peer returned no status")
+	}
+
+	return *response.Status, nil
+}
+
+// RequestHeaders requests up to count block headers starting at index from
+func (n *P2PNode) RequestHeaders(peerID peer.ID, from uint64, count int) ([]core.BlockHeader, error) {
+	response, err := n.sendSyncRequest(peerID, SyncRequest{Type: syncRequestHeaders, From: from, Count: count})
+	if err != nil {
+		return nil, err
+	}
+	return response.Headers, nil
+}
+
+// RequestBlocks requests up to count full blocks starting at index from
+func (n *P2PNode) RequestBlocks(peerID peer.ID, from uint64, count int) ([]core.Block, error) {
+	response, err := n.sendSyncRequest(peerID, SyncRequest{Type: syncRequestBlocks, From: from, Count: count})
+	if err != nil {
+		return nil, err
+	}
+	return response.Blocks, nil
+}
+
+// SyncWithPeer catches up with a peer reporting a higher tip, or the same height on a
+// different block. Headers are downloaded and checked first, then block bodies are
+// fetched in batches and matched against their headers. Blocks extending our tip are
+// imported one by one; a branch forking off below it is collected and offered to
+// fork choice as a whole, reorganizing onto it if it is preferred.
+func (n *P2PNode) SyncWithPeer(peerID peer.ID) error {
+	status, err := n.RequestStatus(peerID)
+	if err != nil {
+		return err
+	}
+
+	local, err := n.ChainStatus()
+	if err != nil {
+		return err
+	}
+	if status.GenesisHash != local.GenesisHash {
+		return fmt.Errorf("peer %s is on a different genesis block", peerID.String())
+	}
+	if status.Height < local.Height || status.TipHash == local.TipHash {
+		return nil
+	}
+
+	ancestor, err := n.findCommonAncestor(peerID, local.Height)
+	if err != nil {
+		return err
+	}
+	parent, err := n.blockchain.GetBlockByIndex(ancestor)
+	if err != nil {
+		return err
+	}
+
+	forked := ancestor < local.Height
+	var branch []core.Block
+	imported := 0
+	parentHeader := parent.Header()
+	for from := ancestor + 1; from <= status.Height; {
+		count := maxHeadersPerRequest
+		if remaining := status.Height - from + 1; remaining < uint64(count) {
+			count = int(remaining)
+		}
+
+		headers, err := n.RequestHeaders(peerID, from, count)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return fmt.Errorf("peer %s returned no headers from block %d", peerID.String(), from)
+		}
+		if err := checkHeaderChain(parentHeader, headers); err != nil {
+			n.scorer.Penalize(peerID, PenaltyInvalidBlock)
+			return fmt.Errorf("invalid headers from peer %s: %v", peerID.String(), err)
+		}
+
+		for start := 0; start < len(headers); start += maxBlocksPerRequest {
+			end := start + maxBlocksPerRequest
+			if end > len(headers) {
+				end = len(headers)
+			}
+
+			blocks, err := n.RequestBlocks(peerID, headers[start].Index, end-start)
+			if err != nil {
+				return err
+			}
+			if len(blocks) != end-start {
+				return fmt.Errorf("peer %s returned %d of %d blocks", peerID.String(), len(blocks), end-start)
+			}
+
+			for i, block := range blocks {
+				if block.Header() != headers[start+i] {
+					n.scorer.Penalize(peerID, PenaltyInvalidBlock)
+					return fmt.Errorf("block %d from peer %s does not match its header", block.Index, peerID.String())
+				}
+				if forked {
+					if err := n.checkConsensus(block); err != nil {
+						n.scorer.Penalize(peerID, PenaltyInvalidBlock)
+						return fmt.Errorf("failed to import block %d from peer %s: %v", block.Index, peerID.String(), err)
+					}
+					branch = append(branch, block)
+					continue
+				}
+				if err := n.importBlock(block); err != nil {
+					if errors.Is(err, core.ErrKnownBlock) {
+						continue
+					}
+					n.scorer.Penalize(peerID, PenaltyInvalidBlock)
+					return fmt.Errorf("failed to import block %d from peer %s: %v", block.Index, peerID.String(), err)
+				}
+				imported++
+			}
+		}
+
+		parentHeader = headers[len(headers)-1]
+		from = parentHeader.Index + 1
+	}
+
+	if forked {
+		adopted, err := core.AdoptBranch(n.blockchain, branch)
+		if err != nil {
+			return fmt.Errorf("failed to adopt branch of peer %s: %v", peerID.String(), err)
+		}
+		if !adopted {
+			log.Printf("Kept our chain over the branch of peer %s from block %d", peerID.String(), ancestor+1)
+			return nil
+		}
+		imported = len(branch)
+	}
+
+	log.Printf("Synced %d blocks from peer %s, height %d", imported, peerID.String(), n.blockchain.GetLastBlock().Index)
+	return nil
+}
+
+// findCommonAncestor returns the index of the highest block below height that the
+// peer has too, stepping back exponentially from height
+func (n *P2PNode) findCommonAncestor(peerID peer.ID, height uint64) (uint64, error) {
+	index, step := height, uint64(1)
+	for {
+		headers, err := n.RequestHeaders(peerID, index, 1)
+		if err != nil {
+			return 0, err
+		}
+
+		ours, err := n.blockchain.GetBlockByIndex(index)
+		if err == nil && len(headers) == 1 && headers[0].Hash == ours.Hash {
+			return index, nil
+		}
+		if index == 0 {
+			return 0, fmt.Errorf("no common block with peer %s", peerID.String())
+		}
+
+		if step > index {
+			index = 0
+		} else {
+			index -= step
+		}
+		step *= 2
+	}
+}
+
+// checkHeaderChain checks that headers follow on from parent one by one and carry
+// valid producer signatures
+func checkHeaderChain(parent core.BlockHeader, headers []core.BlockHeader) error {
+	for _, header := range headers {
+		if header.Index != parent.Index+1 {
+			return fmt.Errorf("header %d does not follow block %d", header.Index, parent.Index)
+		}
+		if header.PreviousHash != parent.Hash {
+			return fmt.Errorf("header %d does not link to block %d", header.Index, parent.Index)
+		}
+		if err := core.VerifyBlockHeader(header); err != nil {
+			return fmt.Errorf("header %d: %v", header.Index, err)
+		}
+		parent = header
+	}
+	return nil
+}
+
+// requestSync starts a background catch-up with a peer unless one is already running
+func (n *P2PNode) requestSync(peerID peer.ID) {
+	n.mu.Lock()
+	if n.syncing {
+		n.mu.Unlock()
+		return
+	}
+	n.syncing = true
+	n.mu.Unlock()
+
+	go func() {
+		defer func() {
+			n.mu.Lock()
+			n.syncing = false
+			n.mu.Unlock()
+		}()
+
+		if err := n.SyncWithPeer(peerID); err != nil {
+			log.Printf("Error syncing with peer %s: %v", peerID.String(), err)
+		}
+	}()
+}
+
+// IsSyncing reports whether a catch-up with a peer is in progress
+func (n *P2PNode) IsSyncing() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.syncing
+}
+
+// syncLoop periodically asks known peers for their status and catches up with the
+// highest one ahead of us
+func (n *P2PNode) syncLoop() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.syncWithBestPeer()
+		case <-n.stopChan:
+			return
+		}
+	}
+}
+
+// syncWithBestPeer starts a catch-up with the known peer reporting the highest tip
+func (n *P2PNode) syncWithBestPeer() {
+	local, err := n.ChainStatus()
+	if err != nil {
+		log.Printf("Error reading chain status: %v", err)
+		return
+	}
+
+	n.mu.RLock()
+	peers := make([]peer.ID, 0, len(n.knownPeers))
+	for id := range n.knownPeers {
+		peers = append(peers, id)
+	}
+	n.mu.RUnlock()
+
+	var best peer.ID
+	bestHeight := local.Height
+	for _, peerID := range peers {
+		status, err := n.RequestStatus(peerID)
+		if err != nil {
+			continue
+		}
+		if status.GenesisHash == local.GenesisHash && status.Height > bestHeight {
+			best, bestHeight = peerID, status.Height
+		}
+	}
+
+	if best != "" {
+		n.requestSync(best)
+	}
+}
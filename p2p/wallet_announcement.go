@@ -0,0 +1,327 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/igo-used/binomena/wallet"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// DefaultWalletAnnouncementTTL is how long a wallet announcement stays valid
+	DefaultWalletAnnouncementTTL = 24 * time.Hour
+
+	// maxAnnouncementClockSkew is how far in the future an announcement may be dated
+	maxAnnouncementClockSkew = 5 * time.Minute
+
+	// walletAnnouncementDomain separates announcement signatures from other signed data
+	walletAnnouncementDomain = "binomena-wallet-announcement"
+)
+
+// WalletAnnouncement is a wallet's signed claim that it can be reached at a peer
+type WalletAnnouncement struct {
+	Address   string `json:"address"`
+	PeerID    string `json:"peerId"`
+	Timestamp int64  `json:"timestamp"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// signedData returns the bytes covered by the announcement signature: the domain,
+// address and peer ID as length-prefixed strings followed by the int64 timestamp
+func (a *WalletAnnouncement) signedData() []byte {
+	var buf bytes.Buffer
+	for _, field := range []string{walletAnnouncementDomain, a.Address, a.PeerID} {
+		binary.Write(&buf, binary.BigEndian, uint32(len(field)))
+		buf.WriteString(field)
+	}
+	binary.Write(&buf, binary.BigEndian, a.Timestamp)
+	return buf.Bytes()
+}
+
+// Verify checks that the announcement was signed by the key behind its address
+func (a *WalletAnnouncement) Verify() error {
+	publicKey, err := wallet.DecodePublicKey(a.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	address, err := wallet.AddressFromPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if address != a.Address {
+		return fmt.Errorf("public key does not match wallet %s", a.Address)
+	}
+
+	signature, err := hex.DecodeString(a.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	if !wallet.VerifySignature(publicKey, a.signedData(), signature) {
+		return fmt.Errorf("invalid announcement signature")
+	}
+
+	return nil
+}
+
+// ExpiresAt returns when the announcement stops being valid
+func (a *WalletAnnouncement) ExpiresAt(ttl time.Duration) time.Time {
+	return time.Unix(a.Timestamp, 0).Add(ttl)
+}
+
+// NewWalletAnnouncement signs an announcement that a wallet is reachable at a peer
+func NewWalletAnnouncement(w *wallet.Wallet, peerID peer.ID) (*WalletAnnouncement, error) {
+	announcement := &WalletAnnouncement{
+		Address:   w.Address,
+		PeerID:    peerID.String(),
+		Timestamp: time.Now().Unix(),
+		PublicKey: w.ExportPublicKey(),
+	}
+
+	signature, err := w.Sign(announcement.signedData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign wallet announcement: %v", err)
+	}
+	announcement.Signature = hex.EncodeToString(signature)
+
+	return announcement, nil
+}
+
+// SetWalletAnnouncementTTL changes how long wallet announcements stay valid
+func (n *P2PNode) SetWalletAnnouncementTTL(ttl time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.walletTTL = ttl
+}
+
+// handleWalletDiscoveryStream accepts a wallet announcement from the peer it names
+func (n *P2PNode) handleWalletDiscoveryStream(stream network.Stream) {
+	defer stream.Close()
+
+	data, ok := n.scorer.ReadMessage(stream)
+	if !ok {
+		return
+	}
+	remotePeer := stream.Conn().RemotePeer()
+
+	var announcement WalletAnnouncement
+	if err := json.Unmarshal(data, &announcement); err != nil {
+		log.Printf("Malformed wallet announcement from peer %s", remotePeer.String())
+		n.scorer.Penalize(remotePeer, PenaltyMalformedMessage)
+		return
+	}
+
+	// Peers can only announce wallets reachable at themselves
+	if announcement.PeerID != remotePeer.String() {
+		log.Printf("Rejected wallet announcement for %s: peer %s announced %s", announcement.Address, remotePeer.String(), announcement.PeerID)
+		n.scorer.Penalize(remotePeer, PenaltyMalformedMessage)
+		return
+	}
+
+	if err := n.addWalletAnnouncement(announcement); err != nil {
+		log.Printf("Rejected wallet announcement for %s from peer %s: %v", announcement.Address, remotePeer.String(), err)
+		n.scorer.Penalize(remotePeer, PenaltyMalformedMessage)
+		return
+	}
+
+	log.Printf("Discovered wallet %s from peer %s", announcement.Address, remotePeer.String())
+}
+
+// addWalletAnnouncement verifies an announcement and records it unless a newer one
+// for the same wallet is already known
+func (n *P2PNode) addWalletAnnouncement(announcement WalletAnnouncement) error {
+	if err := announcement.Verify(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if time.Unix(announcement.Timestamp, 0).After(now.Add(maxAnnouncementClockSkew)) {
+		return fmt.Errorf("announcement is dated in the future")
+	}
+
+	n.mu.Lock()
+	if !now.Before(announcement.ExpiresAt(n.walletTTL)) {
+		n.mu.Unlock()
+		return fmt.Errorf("announcement has expired")
+	}
+	if known, exists := n.knownWallets[announcement.Address]; exists && known.Timestamp >= announcement.Timestamp {
+		n.mu.Unlock()
+		return nil
+	}
+	n.knownWallets[announcement.Address] = announcement
+	walletDir := n.walletDir
+	n.mu.Unlock()
+
+	// Keep the stored announcements current when persistence is enabled
+	if walletDir != "" {
+		if err := n.SaveWallets(walletDir); err != nil {
+			log.Printf("Failed to save wallet announcements: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// AnnounceWallet announces to the network that a wallet is reachable at this node
+func (n *P2PNode) AnnounceWallet(w *wallet.Wallet) error {
+	announcement, err := NewWalletAnnouncement(w, n.host.ID())
+	if err != nil {
+		return err
+	}
+
+	// Marshal the announcement to JSON
+	announcementJSON, err := json.Marshal(announcement)
+	if err != nil {
+		return err
+	}
+
+	// Broadcast to all known peers
+	n.mu.RLock()
+	peers := make([]peer.ID, 0, len(n.knownPeers))
+	for id := range n.knownPeers {
+		peers = append(peers, id)
+	}
+	n.mu.RUnlock()
+
+	for _, peerID := range peers {
+		// Open a stream to the peer
+		stream, err := n.host.NewStream(context.Background(), peerID, protocol.ID(walletDiscoveryID))
+		if err != nil {
+			log.Printf("Error opening stream to peer %s: %v", peerID.String(), err)
+			continue
+		}
+
+		// Write the announcement to the stream
+		_, err = stream.Write(announcementJSON)
+		if err != nil {
+			log.Printf("Error writing to stream: %v", err)
+			stream.Close()
+			continue
+		}
+
+		stream.Close()
+	}
+
+	return nil
+}
+
+// FindWalletPeer finds the peer ID for a wallet address from a verified, unexpired
+// announcement
+func (n *P2PNode) FindWalletPeer(address string) (string, bool) {
+	announcement, found := n.GetWalletAnnouncement(address)
+	if !found {
+		return "", false
+	}
+	return announcement.PeerID, true
+}
+
+// GetWalletAnnouncement returns the verified, unexpired announcement for a wallet
+func (n *P2PNode) GetWalletAnnouncement(address string) (WalletAnnouncement, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	announcement, found := n.knownWallets[address]
+	if !found || !time.Now().Before(announcement.ExpiresAt(n.walletTTL)) {
+		return WalletAnnouncement{}, false
+	}
+	return announcement, true
+}
+
+// GetWalletAnnouncementTTL returns how long wallet announcements stay valid
+func (n *P2PNode) GetWalletAnnouncementTTL() time.Duration {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.walletTTL
+}
+
+// GetWalletCount returns the number of wallets with unexpired announcements
+func (n *P2PNode) GetWalletCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, announcement := range n.knownWallets {
+		if now.Before(announcement.ExpiresAt(n.walletTTL)) {
+			count++
+		}
+	}
+	return count
+}
+
+// SaveWallets saves the unexpired wallet announcements to a JSON file
+func (n *P2PNode) SaveWallets(dataDir string) error {
+	n.mu.RLock()
+	now := time.Now()
+	announcements := make([]WalletAnnouncement, 0, len(n.knownWallets))
+	for _, announcement := range n.knownWallets {
+		if now.Before(announcement.ExpiresAt(n.walletTTL)) {
+			announcements = append(announcements, announcement)
+		}
+	}
+	n.mu.RUnlock()
+
+	// Create p2p directory if it doesn't exist
+	p2pDir := filepath.Join(dataDir, "p2p")
+	if err := os.MkdirAll(p2pDir, 0755); err != nil {
+		return fmt.Errorf("failed to create p2p directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(announcements, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal wallet announcements: %v", err)
+	}
+
+	walletsFile := filepath.Join(p2pDir, "wallet_announcements.json")
+	if err := os.WriteFile(walletsFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write wallet announcements file: %v", err)
+	}
+
+	return nil
+}
+
+// LoadWallets loads saved wallet announcements, re-verifying each and dropping
+// expired ones. Announcements accepted afterwards are saved to the same directory.
+func (n *P2PNode) LoadWallets(dataDir string) error {
+	walletsFile := filepath.Join(dataDir, "p2p", "wallet_announcements.json")
+
+	data, err := os.ReadFile(walletsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read wallet announcements file: %v", err)
+	}
+
+	var announcements []WalletAnnouncement
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &announcements); err != nil {
+			return fmt.Errorf("failed to unmarshal wallet announcements: %v", err)
+		}
+	}
+
+	loaded := 0
+	for _, announcement := range announcements {
+		if err := n.addWalletAnnouncement(announcement); err == nil {
+			loaded++
+		}
+	}
+
+	n.mu.Lock()
+	n.walletDir = dataDir
+	n.mu.Unlock()
+
+	log.Printf("Loaded %d wallet announcements", loaded)
+	return nil
+}
@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// DecodeParams decodes a method's parameters into a pointer to a struct. Parameters
+// are given by name in an object, or by position in an array following the order
+// of the struct's fields, including those of embedded structs. Fields tagged
+// binding:"required" must not be left empty.
+func DecodeParams(params json.RawMessage, v interface{}) error {
+	target := reflect.ValueOf(v).Elem()
+	params = bytes.TrimSpace(params)
+
+	switch {
+	case len(params) == 0 || bytes.Equal(params, []byte("null")):
+	case params[0] == '[':
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return InvalidParams("invalid params: %v", err)
+		}
+		fields := paramFields(target.Type())
+		if len(positional) > len(fields) {
+			return InvalidParams("expected at most %d params, got %d", len(fields), len(positional))
+		}
+		for i, value := range positional {
+			if err := json.Unmarshal(value, target.FieldByIndex(fields[i]).Addr().Interface()); err != nil {
+				return InvalidParams("invalid param %s: %v", paramName(target.Type().FieldByIndex(fields[i])), err)
+			}
+		}
+	case params[0] == '{':
+		if err := json.Unmarshal(params, v); err != nil {
+			return InvalidParams("invalid params: %v", err)
+		}
+	default:
+		return InvalidParams("params must be an array or an object")
+	}
+
+	for _, index := range paramFields(target.Type()) {
+		field := target.Type().FieldByIndex(index)
+		if strings.Contains(field.Tag.Get("binding"), "required") && target.FieldByIndex(index).IsZero() {
+			return InvalidParams("missing param %s", paramName(field))
+		}
+	}
+	return nil
+}
+
+// paramFields returns the index paths of the exported, serialized fields of a
+// struct, in order, flattening embedded structs as encoding/json does
+func paramFields(t reflect.Type) [][]int {
+	var fields [][]int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			for _, index := range paramFields(field.Type) {
+				fields = append(fields, append([]int{i}, index...))
+			}
+			continue
+		}
+		if field.IsExported() && field.Tag.Get("json") != "-" {
+			fields = append(fields, []int{i})
+		}
+	}
+	return fields
+}
+
+// paramName returns the JSON name of a struct field
+func paramName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// NoParams checks that a method taking no parameters was given none
+func NoParams(params json.RawMessage) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) || bytes.Equal(params, []byte("[]")) || bytes.Equal(params, []byte("{}")) {
+		return nil
+	}
+	return InvalidParams("method takes no params")
+}
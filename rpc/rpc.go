@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Version is the JSON-RPC version served
+const Version = "2.0"
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error codes of the node, within the range JSON-RPC reserves for servers
+const (
+	// CodeNotFound is returned when a block, transaction, delegate, contract or
+	// state key does not exist
+	CodeNotFound = -32001
+	// CodeRejected is returned when the node refuses a transaction or contract call
+	CodeRejected = -32002
+	// CodeInsufficientBalance is returned when a sender cannot pay an amount and its fee
+	CodeInsufficientBalance = -32003
+	// CodeNonceConflict is returned for stale or replayed nonces
+	CodeNonceConflict = -32004
+	// CodeRateLimited is returned when a client sends too many requests
+	CodeRateLimited = -32005
+)
+
+const (
+	// MaxBatchSize bounds the number of calls in a batch
+	MaxBatchSize = 100
+	// maxBodySize bounds HTTP request bodies
+	maxBodySize = 1 << 20
+)
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError creates an error with a code and message
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// InvalidParams reports parameters a method cannot use
+func InvalidParams(format string, args ...interface{}) *Error {
+	return NewError(CodeInvalidParams, fmt.Sprintf(format, args...))
+}
+
+// Request is a JSON-RPC request. Requests without an ID are notifications and get
+// no response.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response carrying either a result or an error
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Handler runs a method. Errors other than *Error are reported as internal errors.
+type Handler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Server dispatches JSON-RPC requests to registered methods
+type Server struct {
+	methods map[string]Handler
+	mu      sync.RWMutex
+}
+
+// NewServer creates a server with no methods
+func NewServer() *Server {
+	return &Server{methods: make(map[string]Handler)}
+}
+
+// Register adds a method, replacing any method with the same name
+func (s *Server) Register(method string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.methods[method] = handler
+}
+
+// Methods returns the names of the registered methods in order
+func (s *Server) Methods() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handle processes a request or batch and returns the encoded response, or nil
+// when only notifications were sent
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return s.handleBatch(ctx, data)
+	}
+
+	var request Request
+	if err := json.Unmarshal(data, &request); err != nil {
+		return encode(errorResponse(nil, NewError(CodeParseError, "parse error: "+err.Error())))
+	}
+	response := s.call(ctx, request)
+	if response == nil {
+		return nil
+	}
+	return encode(response)
+}
+
+// handleBatch runs the calls of a batch in order
+func (s *Server) handleBatch(ctx context.Context, data []byte) []byte {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return encode(errorResponse(nil, NewError(CodeParseError, "parse error: "+err.Error())))
+	}
+	if len(batch) == 0 {
+		return encode(errorResponse(nil, NewError(CodeInvalidRequest, "empty batch")))
+	}
+	if len(batch) > MaxBatchSize {
+		return encode(errorResponse(nil, NewError(CodeInvalidRequest, fmt.Sprintf("batch exceeds %d calls", MaxBatchSize))))
+	}
+
+	responses := []*Response{}
+	for _, item := range batch {
+		var request Request
+		if err := json.Unmarshal(item, &request); err != nil {
+			responses = append(responses, errorResponse(nil, NewError(CodeInvalidRequest, "invalid request: "+err.Error())))
+			continue
+		}
+		if response := s.call(ctx, request); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return encode(responses)
+}
+
+// call runs a single request, returning nil for notifications
+func (s *Server) call(ctx context.Context, request Request) *Response {
+	notification := len(request.ID) == 0
+	if request.Version != Version || request.Method == "" {
+		return errorResponse(request.ID, NewError(CodeInvalidRequest, "invalid request: expected jsonrpc 2.0 and a method"))
+	}
+
+	s.mu.RLock()
+	handler, ok := s.methods[request.Method]
+	s.mu.RUnlock()
+
+	var response *Response
+	if !ok {
+		response = errorResponse(request.ID, NewError(CodeMethodNotFound, "method not found: "+request.Method))
+	} else if result, err := handler(ctx, request.Params); err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			log.Printf("RPC method %s failed: %v", request.Method, err)
+			rpcErr = NewError(CodeInternalError, err.Error())
+		}
+		response = errorResponse(request.ID, rpcErr)
+	} else {
+		response = &Response{Version: Version, ID: request.ID, Result: result}
+	}
+
+	if notification {
+		return nil
+	}
+	return response
+}
+
+// errorResponse creates an error response, with a null ID when the request's is unknown
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{Version: Version, ID: id, Error: err}
+}
+
+// encode serializes a response or batch of responses
+func encode(value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(nil, NewError(CodeInternalError, "failed to encode response: "+err.Error())))
+	}
+	return data
+}
+
+// ServeHTTP serves JSON-RPC requests and batches sent with POST
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(encode(errorResponse(nil, NewError(CodeInvalidRequest, "request too large"))))
+		return
+	}
+
+	response := s.Handle(r.Context(), data)
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
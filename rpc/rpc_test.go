@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer serves an echo method taking named or positional params and a method
+// failing with an internal error
+func testServer() *Server {
+	server := NewServer()
+	server.Register("test_echo", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Name  string `json:"name" binding:"required"`
+			Count int    `json:"count"`
+		}
+		if err := DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		return request, nil
+	})
+	server.Register("test_fail", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("disk on fire")
+	})
+	return server
+}
+
+func TestServer_HandlesRequestsAndErrors(t *testing.T) {
+	server := testServer()
+	tests := []struct {
+		request string
+		result  string
+		code    int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":{"name":"alice","count":2}}`, `{"name":"alice","count":2}`, 0},
+		{`{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["bob",3]}`, `{"name":"bob","count":3}`, 0},
+		{`{"jsonrpc":"2.0","id":3,"method":"test_echo","params":{"count":1}}`, "", CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":4,"method":"test_echo","params":["a",1,"extra"]}`, "", CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":5,"method":"test_missing"}`, "", CodeMethodNotFound},
+		{`{"jsonrpc":"2.0","id":6,"method":"test_fail"}`, "", CodeInternalError},
+		{`{"jsonrpc":"1.0","id":7,"method":"test_echo"}`, "", CodeInvalidRequest},
+		{`{"jsonrpc":"2.0",`, "", CodeParseError},
+	}
+
+	for _, test := range tests {
+		var response struct {
+			ID     json.RawMessage
+			Result json.RawMessage
+			Error  *Error
+		}
+		if err := json.Unmarshal(server.Handle(context.Background(), []byte(test.request)), &response); err != nil {
+			t.Fatalf("Invalid response to %s: %v", test.request, err)
+		}
+		if test.code != 0 {
+			if response.Error == nil || response.Error.Code != test.code {
+				t.Errorf("Expected error %d for %s, got %+v", test.code, test.request, response.Error)
+			}
+			continue
+		}
+		if response.Error != nil || string(response.Result) != test.result {
+			t.Errorf("Expected %s for %s, got %s (%+v)", test.result, test.request, response.Result, response.Error)
+		}
+	}
+}
+
+func TestServer_HandlesBatches(t *testing.T) {
+	server := testServer()
+
+	// Notifications get no response, and a batch of only notifications gets none at all
+	batch := `[
+		{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["alice"]},
+		{"jsonrpc":"2.0","method":"test_echo","params":["ignored"]},
+		42,
+		{"jsonrpc":"2.0","id":"x","method":"test_missing"}
+	]`
+	var responses []struct {
+		ID    json.RawMessage
+		Error *Error
+	}
+	if err := json.Unmarshal(server.Handle(context.Background(), []byte(batch)), &responses); err != nil {
+		t.Fatalf("Invalid batch response: %v", err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(responses))
+	}
+	if string(responses[0].ID) != "1" || responses[0].Error != nil {
+		t.Errorf("Unexpected first response: %+v", responses[0])
+	}
+	if string(responses[1].ID) != "null" || responses[1].Error.Code != CodeInvalidRequest {
+		t.Errorf("Expected an invalid request for a non-object, got %+v", responses[1])
+	}
+	if string(responses[2].ID) != `"x"` || responses[2].Error.Code != CodeMethodNotFound {
+		t.Errorf("Unexpected third response: %+v", responses[2])
+	}
+
+	if response := server.Handle(context.Background(), []byte(`[{"jsonrpc":"2.0","method":"test_echo","params":["a"]}]`)); response != nil {
+		t.Errorf("Expected no response to notifications, got %s", response)
+	}
+	if response := string(server.Handle(context.Background(), []byte(`[]`))); !strings.Contains(response, "-32600") {
+		t.Errorf("Expected an empty batch to be invalid, got %s", response)
+	}
+}
+
+func TestServer_ServesHTTPAndWebSocket(t *testing.T) {
+	server := testServer()
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", server)
+	mux.Handle("/rpc/ws", NewWebSocketHandler(server, nil))
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":{"name":"alice"}}`
+	response, err := http.Post(httpServer.URL+"/rpc", "application/json", bytes.NewBufferString(request))
+	if err != nil {
+		t.Fatalf("Failed to post request: %v", err)
+	}
+	defer response.Body.Close()
+	var body Response
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Error != nil {
+		t.Errorf("Expected a result over HTTP, got %+v (%v)", body, err)
+	}
+
+	if response, err := http.Get(httpServer.URL + "/rpc"); err != nil || response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be refused, got %v (%v)", response.Status, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/rpc/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte(request))
+	var wsBody Response
+	if err := conn.ReadJSON(&wsBody); err != nil || wsBody.Error != nil || string(wsBody.ID) != "1" {
+		t.Errorf("Expected a result over WebSocket, got %+v (%v)", wsBody, err)
+	}
+}
+
+func TestDecodeParams_FlattensEmbeddedStructs(t *testing.T) {
+	type inner struct {
+		Caller string `json:"caller" binding:"required"`
+		Fee    int    `json:"fee"`
+	}
+	var request struct {
+		ContractID string `json:"contractId" binding:"required"`
+		inner
+	}
+
+	if err := DecodeParams(json.RawMessage(`["AdNeContract","AdNeCaller",5]`), &request); err != nil {
+		t.Fatalf("Failed to decode positional params: %v", err)
+	}
+	if request.ContractID != "AdNeContract" || request.Caller != "AdNeCaller" || request.Fee != 5 {
+		t.Errorf("Unexpected params: %+v", request)
+	}
+
+	request.Caller = ""
+	err := DecodeParams(json.RawMessage(`{"contractId":"AdNeContract"}`), &request)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != CodeInvalidParams || !strings.Contains(rpcErr.Message, "caller") {
+		t.Errorf("Expected a missing caller to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/igo-used/binomena/events"
+)
+
+// WebSocketHandler serves JSON-RPC over WebSocket connections. Each text message
+// holds a request or batch, answered in order on the same connection.
+type WebSocketHandler struct {
+	server   *Server
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates a WebSocket handler for a server, accepting the
+// connections whose origin passes checkOrigin
+func NewWebSocketHandler(server *Server, checkOrigin func(r *http.Request) bool) *WebSocketHandler {
+	return &WebSocketHandler{
+		server: server,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			CheckOrigin:     checkOrigin,
+		},
+	}
+}
+
+// ServeHTTP upgrades a request to a WebSocket connection and answers its requests
+// until either side closes it. Methods run with the context of the upgrade request.
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(maxBodySize)
+	conn.SetReadDeadline(time.Now().Add(events.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(events.PongTimeout))
+	})
+
+	// Keep the connection alive while the read loop waits for requests
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ping := time.NewTicker(events.PingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ping.C:
+				if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(events.WriteTimeout)) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(events.PongTimeout))
+
+		response := h.server.Handle(r.Context(), data)
+		if response == nil {
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(events.WriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, response); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/igo-used/binomena/api"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/rpc"
+	"github.com/igo-used/binomena/smartcontract"
+)
+
+// rpcClientIPKey carries the client IP of JSON-RPC calls in their context
+type rpcClientIPKey struct{}
+
+// withClientIP returns a context recording the IP of the client making calls
+func withClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, rpcClientIPKey{}, clientIP)
+}
+
+// clientIP returns the IP of the client making a call
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(rpcClientIPKey{}).(string)
+	return ip
+}
+
+// rpcNotFound converts a missing record into a JSON-RPC error
+func rpcNotFound(err error) error {
+	if errors.Is(err, core.ErrTransactionNotFound) || errors.Is(err, smartcontract.ErrStateNotFound) {
+		return rpc.NewError(rpc.CodeNotFound, err.Error())
+	}
+	return err
+}
+
+// rpcAPIError converts an error of the REST API into a JSON-RPC error
+func rpcAPIError(err error) error {
+	apiErr, ok := err.(*api.Error)
+	if !ok {
+		return err
+	}
+	code := rpc.CodeRejected
+	switch apiErr.Code {
+	case api.CodeInsufficientBalance:
+		code = rpc.CodeInsufficientBalance
+	case api.CodeConflict:
+		code = rpc.CodeNonceConflict
+	case api.CodeRateLimited:
+		code = rpc.CodeRateLimited
+	case api.CodeNotFound:
+		code = rpc.CodeNotFound
+	}
+	rpcErr := rpc.NewError(code, apiErr.Message)
+	if len(apiErr.Details) > 0 {
+		rpcErr.Data = apiErr.Details
+	}
+	return rpcErr
+}
+
+// rpcContractError converts a refused contract request into a JSON-RPC error
+func rpcContractError(err error) error {
+	requestErr, ok := err.(*smartcontract.RequestError)
+	if !ok {
+		return err
+	}
+	rpcErr := rpc.NewError(rpc.CodeRejected, requestErr.Message)
+	if len(requestErr.Details) > 0 {
+		rpcErr.Data = requestErr.Details
+	}
+	return rpcErr
+}
+
+// registerRPCMethods registers the JSON-RPC methods mirroring the REST API
+func registerRPCMethods(server *rpc.Server, blockchain core.BlockchainInterface, binomToken core.TokenInterface, dposConsensus *consensus.DPoSConsensus, contractAPI *smartcontract.ContractAPI, apiServer *api.Server) {
+	server.Register("rpc_methods", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if err := rpc.NoParams(params); err != nil {
+			return nil, err
+		}
+		return server.Methods(), nil
+	})
+
+	// Chain
+	server.Register("chain_getBlockCount", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if err := rpc.NoParams(params); err != nil {
+			return nil, err
+		}
+		return blockchain.GetBlockCount(), nil
+	})
+	server.Register("chain_getBlock", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Index *uint64 `json:"index" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		block, err := blockchain.GetBlockByIndex(*request.Index)
+		if err != nil {
+			return nil, rpc.NewError(rpc.CodeNotFound, err.Error())
+		}
+		return block, nil
+	})
+
+	// Accounts
+	server.Register("account_getBalance", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Address string `json:"address" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(request.Address, "AdNe") {
+			return nil, rpc.InvalidParams("Invalid address format")
+		}
+		return map[string]interface{}{
+			"address": request.Address,
+			"balance": binomToken.GetBalance(request.Address),
+			"nonce":   apiServer.NextNonce(request.Address),
+		}, nil
+	})
+
+	// Transactions
+	server.Register("tx_sendSignedTransaction", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request api.SignedTransactionRequest
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		tx, transactionFee, err := apiServer.SubmitSigned(request, clientIP(ctx))
+		if err != nil {
+			return nil, rpcAPIError(err)
+		}
+		return map[string]interface{}{
+			"status": "transaction submitted",
+			"txId":   tx.ID,
+			"amount": tx.Amount,
+			"fee":    transactionFee,
+		}, nil
+	})
+	server.Register("tx_getTransaction", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			ID string `json:"id" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		tx, err := blockchain.GetTransaction(request.ID)
+		if err != nil {
+			return nil, rpcNotFound(err)
+		}
+		return tx, nil
+	})
+	server.Register("tx_getReceipt", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			ID string `json:"id" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		receipt, err := blockchain.GetReceipt(request.ID)
+		if err != nil {
+			return nil, rpcNotFound(err)
+		}
+		return receipt, nil
+	})
+
+	// Delegates
+	server.Register("delegate_getDelegates", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if err := rpc.NoParams(params); err != nil {
+			return nil, err
+		}
+		delegates := dposConsensus.GetDelegates()
+		return map[string]interface{}{
+			"delegates":    delegates,
+			"count":        len(delegates),
+			"maxDelegates": consensus.MaxDelegates,
+		}, nil
+	})
+	server.Register("delegate_getDelegate", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Address string `json:"address" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		for _, delegate := range dposConsensus.GetDelegates() {
+			if delegate.Address == request.Address {
+				return delegate, nil
+			}
+		}
+		return nil, rpc.NewError(rpc.CodeNotFound, "Delegate not found")
+	})
+
+	// Contracts
+	server.Register("contract_deploy", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request smartcontract.DeployRequest
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		result, err := contractAPI.Deploy(request)
+		if err != nil {
+			return nil, rpcContractError(err)
+		}
+		return result, nil
+	})
+	server.Register("contract_call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			ContractID string `json:"contractId" binding:"required"`
+			smartcontract.ExecuteRequest
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		result, err := contractAPI.Execute(request.ContractID, request.ExecuteRequest)
+		if err != nil {
+			return nil, rpcContractError(err)
+		}
+		return result, nil
+	})
+	server.Register("contract_getState", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var request struct {
+			ContractID string `json:"contractId" binding:"required"`
+			Key        string `json:"key" binding:"required"`
+		}
+		if err := rpc.DecodeParams(params, &request); err != nil {
+			return nil, err
+		}
+		value, err := contractAPI.State(request.ContractID, request.Key)
+		if err != nil {
+			return nil, rpcNotFound(err)
+		}
+		return map[string]interface{}{
+			"contractId": request.ContractID,
+			"key":        request.Key,
+			"value":      value,
+		}, nil
+	})
+}
@@ -2,14 +2,15 @@ package smartcontract
 
 import (
 	"encoding/base64"
-	"net/http"
+	"errors"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/token"
 	"github.com/igo-used/binomena/wallet"
 )
 
-// ContractAPI handles API endpoints for smart contracts
+// ContractAPI deploys, calls and inspects smart contracts on behalf of API clients
 type ContractAPI struct {
 	vm      *WasmVM
 	storage *ContractStorage
@@ -27,154 +28,137 @@ func NewContractAPI(vm *WasmVM, storage *ContractStorage, state *ContractState,
 	}
 }
 
-// RegisterRoutes registers API routes for smart contracts
-func (api *ContractAPI) RegisterRoutes(router *gin.Engine) {
-	contracts := router.Group("/contracts")
-	{
-		// Deploy a new contract
-		contracts.POST("/deploy", api.DeployContract)
+// RequestError is a contract request refused because of its contents, as opposed
+// to a failure of the node
+type RequestError struct {
+	Message string
+	Details map[string]interface{} // Extra fields reported with the error
+}
 
-		// Execute a contract
-		contracts.POST("/:id/execute", api.ExecuteContract)
+func (e *RequestError) Error() string {
+	return e.Message
+}
 
-		// Get contract details
-		contracts.GET("/:id", api.GetContract)
+// ErrStateNotFound is returned when a contract has no value stored under a key
+var ErrStateNotFound = errors.New("state key not found")
 
-		// List all contracts
-		contracts.GET("", api.ListContracts)
+// ErrNotOwner is returned when someone other than a contract's owner sets its state
+var ErrNotOwner = errors.New("only contract owner can set state")
 
-		// Get contract state
-		contracts.GET("/:id/state/:key", api.GetContractState)
+// DeployRequest asks to deploy a contract, authorized by its owner's private key
+type DeployRequest struct {
+	Owner      string     `json:"owner" binding:"required"`
+	Name       string     `json:"name" binding:"required"`
+	Code       string     `json:"code" binding:"required"` // Base64 encoded WASM
+	Fee        bnm.Amount `json:"fee" binding:"required"`
+	PrivateKey string     `json:"privateKey" binding:"required"`
+}
 
-		// Set contract state (admin only)
-		contracts.POST("/:id/state", api.SetContractState)
-	}
+// DeployResult describes a deployed contract
+type DeployResult struct {
+	ContractID  string               `json:"contractId"`
+	Owner       string               `json:"owner"`
+	Name        string               `json:"name"`
+	DeployedAt  time.Time            `json:"deployedAt"`
+	Transaction *ContractTransaction `json:"transaction"`
 }
 
-// DeployContract handles contract deployment
-func (api *ContractAPI) DeployContract(c *gin.Context) {
-	var request struct {
-		Owner      string  `json:"owner" binding:"required"`
-		Name       string  `json:"name" binding:"required"`
-		Code       string  `json:"code" binding:"required"` // Base64 encoded WASM
-		Fee        float64 `json:"fee" binding:"required"`
-		PrivateKey string  `json:"privateKey" binding:"required"`
-	}
+// ExecuteRequest asks to call a contract function, authorized by the caller's
+// private key
+type ExecuteRequest struct {
+	Caller     string        `json:"caller" binding:"required"`
+	Function   string        `json:"function" binding:"required"`
+	Params     []interface{} `json:"params"`
+	Fee        bnm.Amount    `json:"fee" binding:"required"`
+	PrivateKey string        `json:"privateKey" binding:"required"`
+}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// SetStateRequest asks to set contract state, authorized by the owner's private key
+type SetStateRequest struct {
+	Key        string      `json:"key" binding:"required"`
+	Value      interface{} `json:"value"`
+	Caller     string      `json:"caller" binding:"required"`
+	PrivateKey string      `json:"privateKey" binding:"required"`
+}
 
-	// Decode WASM code
-	code, err := base64.StdEncoding.DecodeString(request.Code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid WASM code: " + err.Error()})
-		return
-	}
+// ExecuteResult is the outcome of a contract call
+type ExecuteResult struct {
+	Result      *ExecutionResult     `json:"result"`
+	Transaction *ContractTransaction `json:"transaction"`
+}
 
-	// Verify owner's wallet
-	ownerWallet, err := wallet.ImportPrivateKey(request.PrivateKey)
+// authorize checks that a private key belongs to an address able to pay a fee
+func (api *ContractAPI) authorize(address, privateKey string, fee bnm.Amount, role string) error {
+	// Verify the wallet
+	signer, err := wallet.ImportPrivateKey(privateKey)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid private key"})
-		return
+		return &RequestError{Message: "invalid private key"}
 	}
 
 	// Check if wallet address matches
-	if ownerWallet.Address != request.Owner {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "private key does not match owner address"})
-		return
+	if signer.Address != address {
+		return &RequestError{Message: "private key does not match " + role + " address"}
 	}
 
-	// Check if owner has enough balance
-	balance := api.token.GetBalance(request.Owner)
-	if balance < request.Fee {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":    "insufficient balance",
-			"balance":  balance,
-			"required": request.Fee,
-		})
-		return
+	// Check if the address has enough balance
+	balance := api.token.GetBalance(address)
+	if balance < fee {
+		return &RequestError{
+			Message: "insufficient balance",
+			Details: map[string]interface{}{"balance": balance, "required": fee},
+		}
+	}
+	return nil
+}
+
+// Deploy deploys and stores a contract
+func (api *ContractAPI) Deploy(request DeployRequest) (*DeployResult, error) {
+	// Decode WASM code
+	code, err := base64.StdEncoding.DecodeString(request.Code)
+	if err != nil {
+		return nil, &RequestError{Message: "invalid WASM code: " + err.Error()}
+	}
+
+	if err := api.authorize(request.Owner, request.PrivateKey, request.Fee, "owner"); err != nil {
+		return nil, err
 	}
 
 	// Deploy contract
 	contractID, err := api.vm.DeployContract(request.Owner, request.Name, code, request.Fee)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return nil, &RequestError{Message: err.Error()}
 	}
 
 	// Get contract
 	contract, err := api.vm.GetContract(contractID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	// Save contract to storage
 	if err := api.storage.SaveContract(contract); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
-	// Create transaction
-	tx := CreateDeployTransaction(contractID, request.Owner, request.Fee, 0, "success", nil)
-
-	c.JSON(http.StatusOK, gin.H{
-		"contractId":  contractID,
-		"owner":       request.Owner,
-		"name":        request.Name,
-		"deployedAt":  contract.DeployedAt,
-		"transaction": tx,
-	})
+	return &DeployResult{
+		ContractID:  contractID,
+		Owner:       request.Owner,
+		Name:        request.Name,
+		DeployedAt:  contract.DeployedAt,
+		Transaction: CreateDeployTransaction(contractID, request.Owner, request.Fee, 0, "success", nil),
+	}, nil
 }
 
-// ExecuteContract handles contract execution
-func (api *ContractAPI) ExecuteContract(c *gin.Context) {
-	contractID := c.Param("id")
-
-	var request struct {
-		Caller     string        `json:"caller" binding:"required"`
-		Function   string        `json:"function" binding:"required"`
-		Params     []interface{} `json:"params"`
-		Fee        float64       `json:"fee" binding:"required"`
-		PrivateKey string        `json:"privateKey" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Verify caller's wallet
-	callerWallet, err := wallet.ImportPrivateKey(request.PrivateKey)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid private key"})
-		return
-	}
-
-	// Check if wallet address matches
-	if callerWallet.Address != request.Caller {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "private key does not match caller address"})
-		return
-	}
-
-	// Check if caller has enough balance
-	balance := api.token.GetBalance(request.Caller)
-	if balance < request.Fee {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":    "insufficient balance",
-			"balance":  balance,
-			"required": request.Fee,
-		})
-		return
+// Execute calls a contract function
+func (api *ContractAPI) Execute(contractID string, request ExecuteRequest) (*ExecuteResult, error) {
+	if err := api.authorize(request.Caller, request.PrivateKey, request.Fee, "caller"); err != nil {
+		return nil, err
 	}
 
 	// Execute contract
 	result, err := api.vm.ExecuteContract(contractID, request.Function, request.Params, request.Caller, request.Fee)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return nil, &RequestError{Message: err.Error()}
 	}
 
 	// Create transaction
@@ -190,112 +174,54 @@ func (api *ContractAPI) ExecuteContract(c *gin.Context) {
 		nil,
 	)
 
-	c.JSON(http.StatusOK, gin.H{
-		"result":      result,
-		"transaction": tx,
-	})
-}
-
-// GetContract handles getting contract details
-func (api *ContractAPI) GetContract(c *gin.Context) {
-	contractID := c.Param("id")
-
-	// Get contract
-	contract, err := api.vm.GetContract(contractID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, contract)
-}
-
-// ListContracts handles listing all contracts
-func (api *ContractAPI) ListContracts(c *gin.Context) {
-	// List contracts
-	contracts := api.vm.ListContracts()
-
-	c.JSON(http.StatusOK, gin.H{
-		"contracts": contracts,
-		"count":     len(contracts),
-	})
+	return &ExecuteResult{Result: result, Transaction: tx}, nil
 }
 
-// GetContractState handles getting contract state
-func (api *ContractAPI) GetContractState(c *gin.Context) {
-	contractID := c.Param("id")
-	key := c.Param("key")
-
-	// Get state
+// State returns the value a contract stores under a key
+func (api *ContractAPI) State(contractID, key string) (interface{}, error) {
 	value, err := api.state.GetState(contractID, key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
-
 	if value == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "state key not found"})
-		return
+		return nil, ErrStateNotFound
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"contractId": contractID,
-		"key":        key,
-		"value":      value,
-	})
+	return value, nil
 }
 
-// SetContractState handles setting contract state (admin only)
-func (api *ContractAPI) SetContractState(c *gin.Context) {
-	contractID := c.Param("id")
-
-	var request struct {
-		Key        string      `json:"key" binding:"required"`
-		Value      interface{} `json:"value"`
-		Caller     string      `json:"caller" binding:"required"`
-		PrivateKey string      `json:"privateKey" binding:"required"`
-	}
+// Contract returns a deployed contract
+func (api *ContractAPI) Contract(contractID string) (*Contract, error) {
+	return api.vm.GetContract(contractID)
+}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// Contracts returns all deployed contracts
+func (api *ContractAPI) Contracts() []*Contract {
+	return api.vm.ListContracts()
+}
 
+// SetState stores a value under a key of a contract on behalf of its owner
+func (api *ContractAPI) SetState(contractID string, request SetStateRequest) error {
 	// Verify caller's wallet
 	callerWallet, err := wallet.ImportPrivateKey(request.PrivateKey)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid private key"})
-		return
+		return &RequestError{Message: "invalid private key"}
 	}
 
 	// Check if wallet address matches
 	if callerWallet.Address != request.Caller {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "private key does not match caller address"})
-		return
+		return &RequestError{Message: "private key does not match caller address"}
 	}
 
 	// Get contract
 	contract, err := api.vm.GetContract(contractID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
 	// Check if caller is contract owner
 	if contract.Owner != request.Caller {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only contract owner can set state"})
-		return
-	}
-
-	// Set state
-	if err := api.state.SetState(contractID, request.Key, request.Value); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return ErrNotOwner
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"contractId": contractID,
-		"key":        request.Key,
-		"value":      request.Value,
-	})
+	return api.state.SetState(contractID, request.Key, request.Value)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -106,6 +107,38 @@ func (s *ContractState) ClearState(contractID string) error {
 	return nil
 }
 
+// StateEntries returns the storage of all contracts committed to by the state root
+func (s *ContractState) StateEntries() (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Load contracts that have not been accessed since startup
+	files, err := filepath.Glob(filepath.Join(s.storagePath, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state files: %v", err)
+	}
+	for _, file := range files {
+		contractID := strings.TrimSuffix(filepath.Base(file), ".json")
+		if _, exists := s.states[contractID]; !exists {
+			if err := s.loadState(contractID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	entries := make(map[string][]byte)
+	for contractID, state := range s.states {
+		for key, value := range state {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal state: %v", err)
+			}
+			entries["contract/"+contractID+"/"+key] = data
+		}
+	}
+	return entries, nil
+}
+
 // loadState loads the state for a contract
 func (s *ContractState) loadState(contractID string) error {
 	// Load from file
@@ -3,8 +3,10 @@ package smartcontract
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/database"
 	"gorm.io/gorm"
 )
@@ -75,7 +77,7 @@ func (cs *ContractStorageDB) LoadContract(contractID string) (*Contract, error)
 	}
 
 	if contract.ExecutionCount > 0 {
-		contract.AverageGasUsed = contract.TotalGasUsed / float64(contract.ExecutionCount)
+		contract.AverageGasUsed = contract.TotalGasUsed / bnm.Amount(contract.ExecutionCount)
 	}
 
 	return contract, nil
@@ -103,7 +105,7 @@ func (cs *ContractStorageDB) LoadAllContracts() ([]*Contract, error) {
 		}
 
 		if contract.ExecutionCount > 0 {
-			contract.AverageGasUsed = contract.TotalGasUsed / float64(contract.ExecutionCount)
+			contract.AverageGasUsed = contract.TotalGasUsed / bnm.Amount(contract.ExecutionCount)
 		}
 
 		contracts[i] = contract
@@ -112,6 +114,15 @@ func (cs *ContractStorageDB) LoadAllContracts() ([]*Contract, error) {
 	return contracts, nil
 }
 
+// DeleteContract deletes a contract from the database
+func (cs *ContractStorageDB) DeleteContract(contractID string) error {
+	if err := database.DB.Where("contract_id = ?", contractID).Delete(&database.Contract{}).Error; err != nil {
+		return fmt.Errorf("failed to delete contract: %v", err)
+	}
+
+	return nil
+}
+
 // ContractStateDB represents database-backed contract state
 type ContractStateDB struct {
 }
@@ -191,3 +202,33 @@ func (cs *ContractStateDB) DeleteState(contractID, key string) error {
 
 	return nil
 }
+
+// StateEntries returns the storage of all contracts committed to by the state root
+func (cs *ContractStateDB) StateEntries() (map[string][]byte, error) {
+	var states []database.SystemState
+	// SQLite has no default LIKE escape character, so name it for both engines
+	if err := database.DB.Where(`key LIKE ? ESCAPE '\'`, "contract\\_%").Find(&states).Error; err != nil {
+		return nil, fmt.Errorf("failed to load contract state: %v", err)
+	}
+
+	entries := make(map[string][]byte, len(states))
+	for _, state := range states {
+		// Keys are contract_<contractID>_<key> and contract IDs never contain '_'
+		contractID, key, found := strings.Cut(strings.TrimPrefix(state.Key, "contract_"), "_")
+		if !found {
+			continue
+		}
+
+		// Re-encode values so they match the file-backed state
+		var value interface{}
+		if err := json.Unmarshal([]byte(state.Value), &value); err != nil {
+			value = state.Value
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal state: %v", err)
+		}
+		entries["contract/"+contractID+"/"+key] = data
+	}
+	return entries, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/wallet"
 )
 
@@ -15,8 +16,8 @@ type ContractTransaction struct {
 	Caller        string      `json:"caller"`
 	Function      string      `json:"function,omitempty"`
 	Params        interface{} `json:"params,omitempty"`
-	Fee           float64     `json:"fee"`
-	GasUsed       float64     `json:"gasUsed"`
+	Fee           bnm.Amount  `json:"fee"`
+	GasUsed       bnm.Amount  `json:"gasUsed"`
 	ExecutionTime int64       `json:"executionTime"`
 	Timestamp     int64       `json:"timestamp"`
 	Status        string      `json:"status"`
@@ -35,7 +36,7 @@ const (
 )
 
 // CreateDeployTransaction creates a transaction for contract deployment
-func CreateDeployTransaction(contractID, caller string, fee, gasUsed float64, status string, err error) *ContractTransaction {
+func CreateDeployTransaction(contractID, caller string, fee, gasUsed bnm.Amount, status string, err error) *ContractTransaction {
 	tx := &ContractTransaction{
 		ID:         generateTransactionID(),
 		Type:       string(DeployTransaction),
@@ -55,7 +56,7 @@ func CreateDeployTransaction(contractID, caller string, fee, gasUsed float64, st
 }
 
 // CreateExecuteTransaction creates a transaction for contract execution
-func CreateExecuteTransaction(contractID, caller, function string, params interface{}, fee, gasUsed float64, executionTime int64, status string, err error) *ContractTransaction {
+func CreateExecuteTransaction(contractID, caller, function string, params interface{}, fee, gasUsed bnm.Amount, executionTime int64, status string, err error) *ContractTransaction {
 	tx := &ContractTransaction{
 		ID:            generateTransactionID(),
 		Type:          string(ExecuteTransaction),
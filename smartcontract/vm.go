@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/token"
 	wasmer "github.com/wasmerio/wasmer-go/wasmer"
@@ -15,22 +16,25 @@ import (
 
 // Gas pricing constants
 const (
-	// Base fee for contract execution (in BNM)
-	BaseExecutionFee = 0.001
+	// Base fee for contract execution (0.001 BNM)
+	BaseExecutionFee = bnm.UnitsPerBNM / 1000
 
-	// Gas per instruction (in BNM)
-	GasPerInstruction = 0.0000001
+	// Gas per instruction (0.0000001 BNM)
+	GasPerInstruction = bnm.UnitsPerBNM / 10000000
 
 	// Gas limit per contract execution
 	DefaultGasLimit = 10000000
 
-	// Deployment fee per byte (in BNM)
-	DeploymentFeePerByte = 0.0000005
+	// Deployment fee per byte (0.0000005 BNM)
+	DeploymentFeePerByte = bnm.UnitsPerBNM / 2000000
 
-	// Minimum deployment fee (in BNM)
-	MinimumDeploymentFee = 0.1
+	// Minimum deployment fee (0.1 BNM)
+	MinimumDeploymentFee = bnm.UnitsPerBNM / 10
 )
 
+// ErrContractNotFound is returned when no contract is deployed under an ID
+var ErrContractNotFound = errors.New("contract not found")
+
 // SecurityLevel defines the security level for contract execution
 type SecurityLevel int
 
@@ -59,22 +63,22 @@ type WasmVM struct {
 
 // Contract represents a smart contract
 type Contract struct {
-	ID             string    `json:"id"`
-	Owner          string    `json:"owner"`
-	Code           []byte    `json:"code"`
-	Name           string    `json:"name"`
-	DeployedAt     time.Time `json:"deployedAt"`
-	LastExecuted   time.Time `json:"lastExecuted"`
-	ExecutionCount uint64    `json:"executionCount"`
-	TotalGasUsed   float64   `json:"totalGasUsed"`
-	AverageGasUsed float64   `json:"averageGasUsed"`
+	ID             string     `json:"id"`
+	Owner          string     `json:"owner"`
+	Code           []byte     `json:"code"`
+	Name           string     `json:"name"`
+	DeployedAt     time.Time  `json:"deployedAt"`
+	LastExecuted   time.Time  `json:"lastExecuted"`
+	ExecutionCount uint64     `json:"executionCount"`
+	TotalGasUsed   bnm.Amount `json:"totalGasUsed"`
+	AverageGasUsed bnm.Amount `json:"averageGasUsed"`
 }
 
 // ExecutionResult represents the result of a contract execution
 type ExecutionResult struct {
 	Success       bool          `json:"success"`
-	GasUsed       float64       `json:"gasUsed"`
-	ExecutionFee  float64       `json:"executionFee"`
+	GasUsed       bnm.Amount    `json:"gasUsed"`
+	ExecutionFee  bnm.Amount    `json:"executionFee"`
 	ReturnValue   interface{}   `json:"returnValue"`
 	Error         string        `json:"error,omitempty"`
 	ExecutionTime time.Duration `json:"executionTime"`
@@ -109,7 +113,7 @@ func (vm *WasmVM) AddContract(contract *Contract) error {
 }
 
 // DeployContract deploys a new smart contract
-func (vm *WasmVM) DeployContract(owner string, name string, code []byte, fee float64) (string, error) {
+func (vm *WasmVM) DeployContract(owner string, name string, code []byte, fee bnm.Amount) (string, error) {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 
@@ -118,7 +122,7 @@ func (vm *WasmVM) DeployContract(owner string, name string, code []byte, fee flo
 
 	// Check if fee is sufficient
 	if fee < requiredFee {
-		return "", fmt.Errorf("insufficient fee: required %.6f BNM, got %.6f BNM", requiredFee, fee)
+		return "", fmt.Errorf("insufficient fee: required %s BNM, got %s BNM", requiredFee, fee)
 	}
 
 	// Validate WASM code
@@ -159,19 +163,19 @@ func (vm *WasmVM) DeployContract(owner string, name string, code []byte, fee flo
 }
 
 // ExecuteContract executes a smart contract
-func (vm *WasmVM) ExecuteContract(contractID string, function string, params []interface{}, caller string, fee float64) (*ExecutionResult, error) {
+func (vm *WasmVM) ExecuteContract(contractID string, function string, params []interface{}, caller string, fee bnm.Amount) (*ExecutionResult, error) {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 
 	// Get contract
 	contract, exists := vm.contracts[contractID]
 	if !exists {
-		return nil, fmt.Errorf("contract not found: %s", contractID)
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, contractID)
 	}
 
 	// Check if fee is sufficient for base execution
 	if fee < BaseExecutionFee {
-		return nil, fmt.Errorf("insufficient fee: required at least %.6f BNM", BaseExecutionFee)
+		return nil, fmt.Errorf("insufficient fee: required at least %s BNM", BaseExecutionFee)
 	}
 
 	// Get or create instance
@@ -205,7 +209,7 @@ func (vm *WasmVM) ExecuteContract(contractID string, function string, params []i
 	contract.LastExecuted = time.Now()
 	contract.ExecutionCount++
 	contract.TotalGasUsed += gasUsed
-	contract.AverageGasUsed = contract.TotalGasUsed / float64(contract.ExecutionCount)
+	contract.AverageGasUsed = contract.TotalGasUsed / bnm.Amount(contract.ExecutionCount)
 
 	// Burn the fee
 	vm.binomToken.Burn(fee)
@@ -233,7 +237,7 @@ func (vm *WasmVM) GetContract(contractID string) (*Contract, error) {
 
 	contract, exists := vm.contracts[contractID]
 	if !exists {
-		return nil, fmt.Errorf("contract not found: %s", contractID)
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, contractID)
 	}
 
 	return contract, nil
@@ -252,6 +256,26 @@ func (vm *WasmVM) ListContracts() []*Contract {
 	return contracts
 }
 
+// VMStats summarizes the contracts deployed on a VM and their executions
+type VMStats struct {
+	Contracts  int
+	Executions uint64
+	GasUsed    bnm.Amount
+}
+
+// Stats returns the number of deployed contracts and their executions and gas
+func (vm *WasmVM) Stats() VMStats {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+
+	stats := VMStats{Contracts: len(vm.contracts)}
+	for _, contract := range vm.contracts {
+		stats.Executions += contract.ExecutionCount
+		stats.GasUsed += contract.TotalGasUsed
+	}
+	return stats
+}
+
 // SetSecurityLevel sets the security level for contract execution
 func (vm *WasmVM) SetSecurityLevel(level SecurityLevel) {
 	vm.mu.Lock()
@@ -303,8 +327,8 @@ func (vm *WasmVM) getContractInstance(contract *Contract) (*wasmer.Instance, err
 }
 
 // calculateDeploymentFee calculates the fee required to deploy a contract
-func calculateDeploymentFee(code []byte) float64 {
-	fee := float64(len(code)) * DeploymentFeePerByte
+func calculateDeploymentFee(code []byte) bnm.Amount {
+	fee := bnm.Amount(len(code)) * DeploymentFeePerByte
 	if fee < MinimumDeploymentFee {
 		fee = MinimumDeploymentFee
 	}
@@ -312,8 +336,8 @@ func calculateDeploymentFee(code []byte) float64 {
 }
 
 // calculateGasUsed calculates the gas used in BNM
-func calculateGasUsed(instructions uint64) float64 {
-	return float64(instructions) * GasPerInstruction
+func calculateGasUsed(instructions uint64) bnm.Amount {
+	return bnm.Amount(instructions) * GasPerInstruction
 }
 
 // validateWasmCode validates WASM code based on security level
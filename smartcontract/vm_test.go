@@ -3,6 +3,7 @@ package smartcontract
 import (
 	"testing"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/token"
 )
@@ -60,7 +61,7 @@ func TestContractDeployment(t *testing.T) {
 		"AdNeTestOwner123",
 		"TestContract",
 		simpleWasmBinary,
-		0.5, // fee
+		bnm.MustParse("0.5"), // fee
 	)
 
 	if err != nil {
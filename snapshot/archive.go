@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// manifestName is the first entry of an archive, describing the others
+const manifestName = "manifest.json"
+
+// Manifest describes a snapshot archive: its format version, the block it was
+// taken at and the SHA-256 checksum of every other entry
+type Manifest struct {
+	Version   int               `json:"version"`
+	Height    uint64            `json:"height"`
+	BlockHash string            `json:"blockHash"`
+	CreatedAt int64             `json:"createdAt"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// entries returns the archive entries of a snapshot, in the order they are written
+func (s *Snapshot) entries() ([]string, map[string]interface{}) {
+	return []string{"blocks.json", "token.json", "delegates.json", "contracts.json", "contract_state.json"},
+		map[string]interface{}{
+			"blocks.json":         &s.Blocks,
+			"token.json":          &s.Token,
+			"delegates.json":      &s.Delegates,
+			"contracts.json":      &s.Contracts,
+			"contract_state.json": &s.ContractState,
+		}
+}
+
+// Write writes a snapshot as a gzip-compressed tar archive whose manifest records
+// a checksum of each entry
+func Write(w io.Writer, s *Snapshot) error {
+	names, values := s.entries()
+
+	manifest := Manifest{
+		Version:   Version,
+		Height:    s.Height,
+		BlockHash: s.BlockHash,
+		CreatedAt: s.CreatedAt,
+		Checksums: make(map[string]string, len(names)),
+	}
+	contents := make(map[string][]byte, len(names))
+	for _, name := range names {
+		// Compact encoding keeps contract state values byte-for-byte as hashed
+		data, err := json.Marshal(values[name])
+		if err != nil {
+			return fmt.Errorf("failed to serialize %s: %v", name, err)
+		}
+		contents[name] = data
+		manifest.Checksums[name] = checksum(data)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %v", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	modified := time.Unix(s.CreatedAt, 0)
+	for _, name := range append([]string{manifestName}, names...) {
+		data := manifestData
+		if name != manifestName {
+			data = contents[name]
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modified}
+		if err := archive.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		if _, err := archive.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %v", err)
+	}
+	return gz.Close()
+}
+
+// Read reads a snapshot archive, checking its version and the checksum of every
+// entry. The blocks are not verified; call Verify before trusting them.
+func Read(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a snapshot archive: %v", err)
+	}
+	defer gz.Close()
+
+	contents := make(map[string][]byte)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", header.Name, err)
+		}
+		contents[header.Name] = data
+	}
+
+	manifestData, ok := contents[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", manifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", manifest.Version, Version)
+	}
+
+	s := &Snapshot{
+		Height:    manifest.Height,
+		BlockHash: manifest.BlockHash,
+		CreatedAt: manifest.CreatedAt,
+	}
+	names, values := s.entries()
+	for _, name := range names {
+		data, ok := contents[name]
+		if !ok {
+			return nil, fmt.Errorf("archive has no %s", name)
+		}
+		if sum := checksum(data); sum != manifest.Checksums[name] {
+			return nil, fmt.Errorf("checksum mismatch for %s: manifest %s, archive %s", name, manifest.Checksums[name], sum)
+		}
+		if err := json.Unmarshal(data, values[name]); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return s, nil
+}
+
+// checksum returns the hex SHA-256 of an archive entry
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,310 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/token"
+)
+
+// Version is the snapshot format written by this node. Archives with another
+// version are refused.
+const Version = 1
+
+// TokenStore is a token system whose full state can be exported and replaced
+type TokenStore interface {
+	ExportState() (token.State, error)
+	ImportState(state token.State) error
+}
+
+// ContractStore holds deployed contracts
+type ContractStore interface {
+	LoadAllContracts() ([]*smartcontract.Contract, error)
+	SaveContract(contract *smartcontract.Contract) error
+	DeleteContract(contractID string) error
+}
+
+// ContractStateStore holds contract storage
+type ContractStateStore interface {
+	core.StateProvider
+	SetState(contractID string, key string, value interface{}) error
+	DeleteState(contractID string, key string) error
+}
+
+// Stores are the components of a node that snapshots are taken from and restored into
+type Stores struct {
+	Blockchain    core.BlockchainInterface
+	Token         TokenStore
+	Consensus     *consensus.DPoSConsensus
+	Contracts     ContractStore
+	ContractState ContractStateStore
+}
+
+// Snapshot is the chain up to its tip together with the state at the tip
+type Snapshot struct {
+	Height        uint64
+	BlockHash     string
+	CreatedAt     int64
+	Blocks        []core.Block
+	Token         token.State
+	Delegates     []consensus.Delegate
+	Contracts     []*smartcontract.Contract
+	ContractState map[string]json.RawMessage // State root entries, keyed contract/<id>/<key>
+}
+
+// Take snapshots the chain and the state at its tip: balances after the tip's block
+// and the delegates in force at it. Balances cannot be rewound, so snapshots are
+// only taken at the tip.
+func Take(stores Stores) (*Snapshot, error) {
+	blocks := stores.Blockchain.GetChain()
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("the chain has no blocks")
+	}
+	tip := blocks[len(blocks)-1]
+	height := tip.Index
+
+	tokenState, err := stores.Token.ExportState()
+	if err != nil {
+		return nil, err
+	}
+
+	contracts, err := stores.Contracts.LoadAllContracts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contracts: %v", err)
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].ID < contracts[j].ID })
+
+	entries, err := stores.ContractState.StateEntries()
+	if err != nil {
+		return nil, err
+	}
+	contractState := make(map[string]json.RawMessage, len(entries))
+	for key, value := range entries {
+		contractState[key] = json.RawMessage(value)
+	}
+
+	return &Snapshot{
+		Height:        height,
+		BlockHash:     tip.Hash,
+		CreatedAt:     time.Now().Unix(),
+		Blocks:        blocks,
+		Token:         tokenState,
+		Delegates:     stores.Consensus.DelegatesAt(height),
+		Contracts:     contracts,
+		ContractState: contractState,
+	}, nil
+}
+
+// Verify checks the hashes and links of the snapshot's blocks and that they end at
+// the snapshot's height and block hash
+func (s *Snapshot) Verify() error {
+	if err := core.VerifyChain(s.Blocks); err != nil {
+		return fmt.Errorf("invalid chain: %v", err)
+	}
+
+	tip := s.Blocks[len(s.Blocks)-1]
+	if tip.Index != s.Height || tip.Hash != s.BlockHash {
+		return fmt.Errorf("chain ends at #%d %s, expected #%d %s", tip.Index, tip.Hash, s.Height, s.BlockHash)
+	}
+	return nil
+}
+
+// CheckState compares the snapshot's state with the state root committed by its
+// last block. Contract storage changed since that block was produced makes them
+// differ, as it does after a sync.
+func (s *Snapshot) CheckState() error {
+	return core.CheckStateRoot(s.Blocks[len(s.Blocks)-1], s.Token, delegateEntries(s.Delegates), contractStateEntries(s.ContractState))
+}
+
+// Restore imports a verified snapshot, replacing the chain and state of the stores.
+// The import is all or nothing: if any part fails, the parts already imported are
+// rolled back to the state the stores had before.
+func Restore(stores Stores, s *Snapshot) error {
+	if err := s.Verify(); err != nil {
+		return err
+	}
+	if len(s.Delegates) > consensus.MaxDelegates {
+		return fmt.Errorf("maximum delegates exceeded (%d)", consensus.MaxDelegates)
+	}
+	if _, err := decodeContractState(s.ContractState); err != nil {
+		return err
+	}
+
+	// Keep the current state to roll back to
+	chain := stores.Blockchain.GetChain()
+	tokenState, err := stores.Token.ExportState()
+	if err != nil {
+		return err
+	}
+	delegates := stores.Consensus.GetDelegates()
+	contracts, err := stores.Contracts.LoadAllContracts()
+	if err != nil {
+		return fmt.Errorf("failed to load contracts: %v", err)
+	}
+	entries, err := stores.ContractState.StateEntries()
+	if err != nil {
+		return err
+	}
+	contractState := make(map[string]json.RawMessage, len(entries))
+	for key, value := range entries {
+		contractState[key] = json.RawMessage(value)
+	}
+
+	steps := []restoreStep{
+		{
+			name:   "chain",
+			apply:  func() error { return stores.Blockchain.ReplaceChain(s.Blocks) },
+			revert: func() error { return stores.Blockchain.ReplaceChain(chain) },
+		},
+		{
+			name:   "balances",
+			apply:  func() error { return stores.Token.ImportState(s.Token) },
+			revert: func() error { return stores.Token.ImportState(tokenState) },
+		},
+		{
+			name:   "delegates",
+			apply:  func() error { return stores.Consensus.RestoreDelegates(s.Delegates) },
+			revert: func() error { return stores.Consensus.RestoreDelegates(delegates) },
+		},
+		{
+			name:   "contracts",
+			apply:  func() error { return replaceContracts(stores.Contracts, s.Contracts) },
+			revert: func() error { return replaceContracts(stores.Contracts, contracts) },
+		},
+		{
+			name:   "contract state",
+			apply:  func() error { return replaceContractState(stores.ContractState, s.ContractState) },
+			revert: func() error { return replaceContractState(stores.ContractState, contractState) },
+		},
+	}
+	// A failed step is rolled back too, as contracts and their storage are written
+	// one by one
+	for i, step := range steps {
+		if err := step.apply(); err != nil {
+			err = fmt.Errorf("failed to import %s: %v", step.name, err)
+			for j := i; j >= 0; j-- {
+				if revertErr := steps[j].revert(); revertErr != nil {
+					return fmt.Errorf("%v; rolling back %s failed: %v", err, steps[j].name, revertErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreStep imports one part of a snapshot and puts back what it replaced
+type restoreStep struct {
+	name   string
+	apply  func() error
+	revert func() error
+}
+
+// replaceContracts replaces the deployed contracts of a store
+func replaceContracts(store ContractStore, contracts []*smartcontract.Contract) error {
+	existing, err := store.LoadAllContracts()
+	if err != nil {
+		return fmt.Errorf("failed to load contracts: %v", err)
+	}
+	kept := make(map[string]bool, len(contracts))
+	for _, contract := range contracts {
+		kept[contract.ID] = true
+	}
+	for _, contract := range existing {
+		if !kept[contract.ID] {
+			if err := store.DeleteContract(contract.ID); err != nil {
+				return fmt.Errorf("failed to delete contract %s: %v", contract.ID, err)
+			}
+		}
+	}
+
+	for _, contract := range contracts {
+		if err := store.SaveContract(contract); err != nil {
+			return fmt.Errorf("failed to save contract %s: %v", contract.ID, err)
+		}
+	}
+	return nil
+}
+
+// contractStateValue is a decoded entry of contract storage
+type contractStateValue struct {
+	contractID string
+	key        string
+	value      interface{}
+}
+
+// decodeContractState decodes state root entries of contract storage
+func decodeContractState(entries map[string]json.RawMessage) ([]contractStateValue, error) {
+	values := make([]contractStateValue, 0, len(entries))
+	for entry, data := range entries {
+		contractID, key, ok := strings.Cut(strings.TrimPrefix(entry, "contract/"), "/")
+		if !ok || !strings.HasPrefix(entry, "contract/") {
+			return nil, fmt.Errorf("invalid contract state key %q", entry)
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("invalid contract state %q: %v", entry, err)
+		}
+		values = append(values, contractStateValue{contractID: contractID, key: key, value: value})
+	}
+	return values, nil
+}
+
+// replaceContractState replaces the contract storage of a store with state root entries
+func replaceContractState(store ContractStateStore, entries map[string]json.RawMessage) error {
+	values, err := decodeContractState(entries)
+	if err != nil {
+		return err
+	}
+
+	existing, err := store.StateEntries()
+	if err != nil {
+		return err
+	}
+	stale := make(map[string]json.RawMessage)
+	for entry, data := range existing {
+		if _, ok := entries[entry]; !ok {
+			stale[entry] = data
+		}
+	}
+	staleValues, err := decodeContractState(stale)
+	if err != nil {
+		return err
+	}
+	for _, stale := range staleValues {
+		if err := store.DeleteState(stale.contractID, stale.key); err != nil {
+			return fmt.Errorf("failed to delete contract state %s/%s: %v", stale.contractID, stale.key, err)
+		}
+	}
+
+	for _, value := range values {
+		if err := store.SetState(value.contractID, value.key, value.value); err != nil {
+			return fmt.Errorf("failed to save contract state %s/%s: %v", value.contractID, value.key, err)
+		}
+	}
+	return nil
+}
+
+// delegateEntries provides the state root entries of snapshot delegates
+type delegateEntries []consensus.Delegate
+
+func (d delegateEntries) StateEntries() (map[string][]byte, error) {
+	return consensus.DelegateStateEntries(d), nil
+}
+
+// contractStateEntries provides the state root entries of snapshot contract storage
+type contractStateEntries map[string]json.RawMessage
+
+func (c contractStateEntries) StateEntries() (map[string][]byte, error) {
+	entries := make(map[string][]byte, len(c))
+	for key, value := range c {
+		entries[key] = value
+	}
+	return entries, nil
+}
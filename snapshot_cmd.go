@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/database"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/snapshot"
+	"github.com/igo-used/binomena/token"
+)
+
+const snapshotUsage = `Usage:
+  binomena snapshot export --storage file|postgres|kv [--data-dir data] --out FILE
+  binomena snapshot import --storage file|postgres|kv [--data-dir data] [--force] --in FILE
+  binomena snapshot verify --in FILE`
+
+// runSnapshot runs a snapshot command: export the chain and state of a storage
+// backend at its tip to an archive, import an archive into a backend, or verify an
+// archive
+func runSnapshot(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, snapshotUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("snapshot "+args[0], flag.ExitOnError)
+	storage := flags.String("storage", "", "Storage backend: file, postgres or kv")
+	dataDir := flags.String("data-dir", "data", "Directory for node data kept across restarts")
+	out := flags.String("out", "", "Archive to write")
+	in := flags.String("in", "", "Archive to read")
+	force := flags.Bool("force", false, "Replace a chain that already has blocks past genesis")
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "export":
+		if *out == "" {
+			log.Fatalf("--out is required")
+		}
+		stores, closeStores := openSnapshotStores(*storage, *dataDir)
+		defer closeStores()
+
+		snap, err := snapshot.Take(stores)
+		if err != nil {
+			log.Fatalf("Failed to take snapshot: %v", err)
+		}
+
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create archive: %v", err)
+		}
+		if err := snapshot.Write(file, snap); err != nil {
+			file.Close()
+			log.Fatalf("Failed to write snapshot: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatalf("Failed to write snapshot: %v", err)
+		}
+		fmt.Printf("Exported %d blocks up to #%d (%s) to %s\n", len(snap.Blocks), snap.Height, snap.BlockHash, *out)
+
+	case "import":
+		snap := readSnapshot(*in)
+		stores, closeStores := openSnapshotStores(*storage, *dataDir)
+		defer closeStores()
+
+		if count := stores.Blockchain.GetBlockCount(); count > 1 && !*force {
+			log.Fatalf("The %s backend already holds %d blocks; pass --force to replace them", *storage, count)
+		}
+		if err := snapshot.Restore(stores, snap); err != nil {
+			log.Fatalf("Failed to import snapshot: %v", err)
+		}
+
+		// The file backend only persists when asked to
+		if fileBlockchain, ok := stores.Blockchain.(*core.Blockchain); ok {
+			if err := fileBlockchain.SaveChain(*dataDir); err != nil {
+				log.Fatalf("Failed to save blockchain: %v", err)
+			}
+		}
+		if fileToken, ok := stores.Token.(*token.BinomToken); ok {
+			if err := fileToken.SaveBalances(*dataDir); err != nil {
+				log.Fatalf("Failed to save balances: %v", err)
+			}
+		}
+		if database.DB == nil {
+			log.Println("Warning: delegates are only kept in memory by the file and kv backends and were not persisted")
+		}
+
+		fmt.Printf("Imported %d blocks up to #%d (%s) into the %s backend\n", len(snap.Blocks), snap.Height, snap.BlockHash, *storage)
+		reportSnapshotState(snap)
+
+	case "verify":
+		snap := readSnapshot(*in)
+		fmt.Printf("Snapshot of %d blocks up to #%d (%s) is valid\n", len(snap.Blocks), snap.Height, snap.BlockHash)
+		reportSnapshotState(snap)
+
+	default:
+		fmt.Fprintln(os.Stderr, snapshotUsage)
+		os.Exit(2)
+	}
+}
+
+// readSnapshot reads an archive, checking its checksums and the hashes of its blocks
+func readSnapshot(path string) *snapshot.Snapshot {
+	if path == "" {
+		log.Fatalf("--in is required")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	snap, err := snapshot.Read(file)
+	if err != nil {
+		log.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if err := snap.Verify(); err != nil {
+		log.Fatalf("Snapshot failed verification: %v", err)
+	}
+	return snap
+}
+
+// reportSnapshotState reports whether a snapshot's state matches the state root of
+// its last block
+func reportSnapshotState(snap *snapshot.Snapshot) {
+	if err := snap.CheckState(); err != nil {
+		log.Printf("Warning: snapshot state differs from the state root of its last block: %v", err)
+		return
+	}
+	fmt.Println("Snapshot state matches the state root of its last block")
+}
+
+// openSnapshotStores opens the chain, token, delegate and contract stores of a
+// storage backend, returning a function that closes them
+func openSnapshotStores(storage, dataDir string) (snapshot.Stores, func()) {
+	var stores snapshot.Stores
+	closeStores := func() {}
+
+	switch storage {
+	case "file", "kv":
+		if storage == "file" {
+			fileBlockchain := core.NewBlockchain()
+			if err := fileBlockchain.LoadChain(dataDir); err != nil {
+				log.Fatalf("Failed to load blockchain: %v", err)
+			}
+			fileToken := token.NewBinomToken()
+			if err := fileToken.LoadBalances(dataDir); err != nil {
+				log.Fatalf("Failed to load balances: %v", err)
+			}
+			stores.Blockchain, stores.Token = fileBlockchain, fileToken
+		} else {
+			if err := database.OpenKV(filepath.Join(dataDir, "chain.db")); err != nil {
+				log.Fatalf("Failed to open key-value store: %v", err)
+			}
+			if err := database.InitializeKVSystemState(); err != nil {
+				log.Fatalf("Failed to initialize system state: %v", err)
+			}
+			closeStores = func() { database.CloseKV() }
+			stores.Blockchain, stores.Token = core.NewBlockchainWithKV(), token.NewBinomTokenWithKV()
+		}
+
+		// Contracts live in the same directory the node uses
+		contractStorage, err := smartcontract.NewContractStorage("./contracts")
+		if err != nil {
+			log.Fatalf("Failed to initialize file contract storage: %v", err)
+		}
+		contractState, err := smartcontract.NewContractState("./contracts")
+		if err != nil {
+			log.Fatalf("Failed to initialize file contract state: %v", err)
+		}
+		stores.Contracts, stores.ContractState = contractStorage, contractState
+
+	case "postgres":
+		if err := database.ConnectDatabase(); err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		if err := database.MigrateDatabase(); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if err := database.InitializeSystemState(); err != nil {
+			log.Fatalf("Failed to initialize system state: %v", err)
+		}
+		closeStores = func() { database.CloseDatabase() }
+
+		contractStorage, err := smartcontract.NewContractStorageWithDB()
+		if err != nil {
+			log.Fatalf("Failed to initialize database contract storage: %v", err)
+		}
+		contractState, err := smartcontract.NewContractStateWithDB()
+		if err != nil {
+			log.Fatalf("Failed to initialize database contract state: %v", err)
+		}
+		stores.Blockchain, stores.Token = core.NewBlockchainWithDB(), token.NewBinomTokenWithDB()
+		stores.Contracts, stores.ContractState = contractStorage, contractState
+
+	default:
+		log.Fatalf("Unknown storage backend %q: expected file, postgres or kv", storage)
+	}
+
+	stores.Consensus = consensus.NewDPoSConsensus(founderAddress, communityAddress)
+	return stores, closeStores
+}
@@ -3,6 +3,8 @@ package tests
 import (
 	"testing"
 
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/token"
 )
 
@@ -11,27 +13,27 @@ func TestBinomToken(t *testing.T) {
 	binomToken := token.NewBinomToken()
 
 	// Check max supply
-	maxSupply := 1000000000.0 // 1 billion
+	maxSupply := bnm.FromBNM(1000000000) // 1 billion
 	circulatingSupply := binomToken.GetCirculatingSupply()
 	if circulatingSupply != maxSupply {
-		t.Errorf("Expected circulating supply to be %f, got %f", maxSupply, circulatingSupply)
+		t.Errorf("Expected circulating supply to be %s, got %s", maxSupply, circulatingSupply)
 	}
 
 	// Test token transfer
 	// First, allocate some tokens to alice
-	err := binomToken.Transfer("treasury", "alice", 1000.0)
+	err := binomToken.Transfer("treasury", "alice", bnm.FromBNM(1000))
 	if err != nil {
 		t.Errorf("Failed to transfer tokens: %v", err)
 	}
 
 	// Check alice's balance
 	aliceBalance := binomToken.GetBalance("alice")
-	if aliceBalance != 1000.0 {
-		t.Errorf("Expected alice's balance to be 1000.0, got %f", aliceBalance)
+	if aliceBalance != bnm.FromBNM(1000) {
+		t.Errorf("Expected alice's balance to be 1000, got %s", aliceBalance)
 	}
 
 	// Test token transfer from alice to bob
-	err = binomToken.Transfer("alice", "bob", 500.0)
+	err = binomToken.Transfer("alice", "bob", bnm.FromBNM(500))
 	if err != nil {
 		t.Errorf("Failed to transfer tokens: %v", err)
 	}
@@ -39,22 +41,68 @@ func TestBinomToken(t *testing.T) {
 	// Check balances
 	aliceBalance = binomToken.GetBalance("alice")
 	bobBalance := binomToken.GetBalance("bob")
-	if aliceBalance != 500.0 {
-		t.Errorf("Expected alice's balance to be 500.0, got %f", aliceBalance)
+	if aliceBalance != bnm.FromBNM(500) {
+		t.Errorf("Expected alice's balance to be 500, got %s", aliceBalance)
 	}
-	if bobBalance != 500.0 {
-		t.Errorf("Expected bob's balance to be 500.0, got %f", bobBalance)
+	if bobBalance != bnm.FromBNM(500) {
+		t.Errorf("Expected bob's balance to be 500, got %s", bobBalance)
 	}
 
 	// Test token burn
 	initialSupply := binomToken.GetCirculatingSupply()
-	burnAmount := 100.0
+	burnAmount := bnm.FromBNM(100)
 	binomToken.Burn(burnAmount)
 
 	// Check circulating supply after burn
 	newSupply := binomToken.GetCirculatingSupply()
 	expectedSupply := initialSupply - burnAmount
 	if newSupply != expectedSupply {
-		t.Errorf("Expected circulating supply to be %f, got %f", expectedSupply, newSupply)
+		t.Errorf("Expected circulating supply to be %s, got %s", expectedSupply, newSupply)
+	}
+}
+
+// useNonce consumes a nonce the way settling a transaction does
+func useNonce(store core.StateChangeStore, address string, nonce uint64) error {
+	return store.ApplyStateChanges([]core.StateChange{{Type: core.NonceChange, From: address, Nonce: nonce}})
+}
+
+func TestBinomTokenNonces(t *testing.T) {
+	binomToken := token.NewBinomToken()
+
+	// New accounts start at nonce 0
+	if nonce := binomToken.GetNonce("alice"); nonce != 0 {
+		t.Fatalf("Expected initial nonce 0, got %d", nonce)
+	}
+
+	if err := useNonce(binomToken, "alice", 0); err != nil {
+		t.Fatalf("Failed to use nonce 0: %v", err)
+	}
+
+	// Replaying the same nonce must fail
+	if err := useNonce(binomToken, "alice", 0); err == nil {
+		t.Error("Expected replayed nonce to be rejected")
+	}
+
+	// Skipping ahead must fail as well
+	if err := useNonce(binomToken, "alice", 5); err == nil {
+		t.Error("Expected future nonce to be rejected")
+	}
+
+	if nonce := binomToken.GetNonce("alice"); nonce != 1 {
+		t.Errorf("Expected next nonce 1, got %d", nonce)
+	}
+
+	// Nonces survive a save/load round trip
+	dataDir := t.TempDir()
+	if err := binomToken.SaveBalances(dataDir); err != nil {
+		t.Fatalf("Failed to save balances: %v", err)
+	}
+
+	reloaded := token.NewBinomToken()
+	if err := reloaded.LoadBalances(dataDir); err != nil {
+		t.Fatalf("Failed to load balances: %v", err)
+	}
+	if nonce := reloaded.GetNonce("alice"); nonce != 1 {
+		t.Errorf("Expected reloaded nonce 1, got %d", nonce)
 	}
 }
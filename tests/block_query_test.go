@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// checkBlockQueries extends a chain to five blocks and checks range reads and
+// lookups by hash
+func checkBlockQueries(t *testing.T, blockchain core.BlockchainInterface) {
+	producer, _ := wallet.NewWallet()
+	parent := blockchain.GetLastBlock()
+	for i := 0; i < 4; i++ {
+		block := signedBlock(t, parent, producer, []core.Transaction{})
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		parent = block
+	}
+
+	page, err := blockchain.GetBlocks(core.BlockQuery{From: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to get blocks: %v", err)
+	}
+	if len(page) != 2 || page[0].Index != 1 || page[1].Index != 2 {
+		t.Fatalf("Unexpected page: %+v", page)
+	}
+
+	// Pages stop at the tip and are empty past it
+	page, _ = blockchain.GetBlocks(core.BlockQuery{From: 3, Limit: 10})
+	if len(page) != 2 || page[1].Hash != parent.Hash {
+		t.Errorf("Expected the last two blocks, got %+v", page)
+	}
+	page, _ = blockchain.GetBlocks(core.BlockQuery{From: 5})
+	if len(page) != 0 {
+		t.Errorf("Expected no blocks past the tip, got %d", len(page))
+	}
+	page, _ = blockchain.GetBlocks(core.BlockQuery{})
+	if len(page) != 5 || page[0].Index != 0 {
+		t.Errorf("Expected the whole chain in the default page, got %d blocks", len(page))
+	}
+
+	block, err := blockchain.GetBlockByHash(parent.Hash)
+	if err != nil || block.Index != parent.Index {
+		t.Errorf("Expected block %d by hash, got %+v (%v)", parent.Index, block, err)
+	}
+	if _, err := blockchain.GetBlockByHash("missing"); err != core.ErrBlockNotFound {
+		t.Errorf("Expected ErrBlockNotFound, got %v", err)
+	}
+}
+
+func TestBlockQueriesFile(t *testing.T) {
+	checkBlockQueries(t, core.NewBlockchain())
+}
+
+func TestBlockQueriesKV(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	checkBlockQueries(t, core.NewBlockchainWithKV())
+}
+
+func TestBlockQueriesSQLite(t *testing.T) {
+	connectSQLite(t)
+	checkBlockQueries(t, core.NewBlockchainWithDB())
+}
+
+func TestBlockByHashSkipsSideBranches(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	genesis := blockchain.GetLastBlock()
+	a1 := signedBlock(t, genesis, producers[0], []core.Transaction{})
+	b1 := signedBlock(t, genesis, producers[1], []core.Transaction{})
+	b2 := signedBlock(t, b1, producers[2], []core.Transaction{})
+	for _, block := range []core.Block{a1, b1, b2} {
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	if _, err := blockchain.GetBlockByHash(a1.Hash); err != core.ErrBlockNotFound {
+		t.Errorf("Expected the orphaned block to be hidden, got %v", err)
+	}
+	if block, err := blockchain.GetBlockByHash(b1.Hash); err != nil || block.Hash != b1.Hash {
+		t.Errorf("Expected the canonical block, got %v", err)
+	}
+}
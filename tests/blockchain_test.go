@@ -4,7 +4,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
 )
 
 func TestBlockchain(t *testing.T) {
@@ -16,18 +19,16 @@ func TestBlockchain(t *testing.T) {
 		t.Errorf("Expected blockchain to have 1 block, got %d", blockchain.GetBlockCount())
 	}
 
-	// Create a transaction with valid addresses
-	tx := core.Transaction{
-		ID:        "AdNe1234567890abcdef1234567890abcdef1234567890abcdef1234567890",
-		From:      "AdNe6c3ce54e4371d056c7c566675ba16909eb2e9534000000000000000000",
-		To:        "AdNebaefd75d426056bffbc622bd9f334ed89450efae000000000000000000",
-		Amount:    100.0,
-		Timestamp: time.Now().Unix(),
-		Signature: "signature",
+	// Create a signed transaction with valid addresses
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+	tx, err := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(100), 0, sender)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
 	}
 
 	// Add transaction to blockchain
-	blockchain.AddTransaction(tx)
+	blockchain.AddTransaction(*tx)
 
 	// Check that the transaction was added
 	pendingTxs := blockchain.GetPendingTransactions()
@@ -44,13 +45,15 @@ func TestBlockchain(t *testing.T) {
 		Data:         pendingTxs,
 		Validator:    "validator",
 		Signature:    "signature",
+		Version:      core.CurrentBlockVersion,
+		MerkleRoot:   core.CalculateMerkleRoot(pendingTxs),
 	}
 
 	// Calculate hash for the new block using the proper function
 	newBlock.Hash = core.CalculateHash(newBlock)
 
 	// Add block to blockchain
-	err := blockchain.AddBlock(newBlock)
+	err = blockchain.AddBlock(newBlock)
 	if err != nil {
 		t.Errorf("Failed to add block: %v", err)
 	}
@@ -67,3 +70,108 @@ func TestBlockchain(t *testing.T) {
 	}
 }
 
+func TestBlockchainRejectsInvalidSignatures(t *testing.T) {
+	defer func(allow bool, cutoff uint64) {
+		core.AllowLegacyHashes, core.LegacyCutoffHeight = allow, cutoff
+	}(core.AllowLegacyHashes, core.LegacyCutoffHeight)
+
+	// Legacy transactions stay rejected outside legacy blocks even while the legacy
+	// encoding is allowed
+	core.AllowLegacyHashes = true
+	core.LegacyCutoffHeight = 10
+
+	blockchain := core.NewBlockchain()
+	producer, _ := wallet.NewWallet()
+
+	sender, _ := wallet.NewWallet()
+	attacker, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+
+	// Transaction claims to come from sender but is signed by the attacker's key
+	forged, err := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(100), 0, attacker)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	// Legacy transaction with an ID matching its contents but no signature the chain
+	// can check
+	unsigned := core.Transaction{
+		From:      sender.Address,
+		To:        receiver.Address,
+		Amount:    bnm.FromBNM(100),
+		Timestamp: time.Now().Unix(),
+		Signature: "signature",
+		Version:   core.LegacyEncodingVersion,
+	}
+	unsigned.ID = core.CalculateTransactionID(&unsigned)
+
+	for name, tx := range map[string]core.Transaction{"forged": *forged, "unsigned": unsigned} {
+		block := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{tx})
+		if err := blockchain.AddBlock(block); err == nil {
+			t.Errorf("Expected block with %s transaction to be rejected", name)
+		}
+	}
+
+	if blockchain.GetBlockCount() != 1 {
+		t.Errorf("Expected blockchain to have 1 block, got %d", blockchain.GetBlockCount())
+	}
+}
+
+func TestBlockchainRejectsReplayedTransactions(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	producer, _ := wallet.NewWallet()
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+
+	tx, err := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(100), 0, sender)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	twice := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx, *tx})
+	if err := blockchain.AddBlock(twice); err == nil {
+		t.Error("Expected block including a transaction twice to be rejected")
+	}
+
+	first := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(first); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	replay := signedBlock(t, first, producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(replay); err == nil {
+		t.Error("Expected block replaying an included transaction to be rejected")
+	}
+
+	if blockchain.GetBlockCount() != 2 {
+		t.Errorf("Expected blockchain to have 2 blocks, got %d", blockchain.GetBlockCount())
+	}
+}
+
+func TestBlockchainRejectsNonPositiveAmounts(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	producer, _ := wallet.NewWallet()
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+
+	binomToken := token.NewBinomToken()
+	if err := binomToken.Transfer("treasury", receiver.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund receiver: %v", err)
+	}
+
+	for _, amount := range []bnm.Amount{0, -bnm.FromBNM(100)} {
+		tx, err := core.NewTransaction(sender.Address, receiver.Address, amount, 0, sender)
+		if err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+
+		block := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx})
+		if err := blockchain.AddBlock(block); err == nil {
+			t.Errorf("Expected block transferring %s to be rejected", amount)
+		}
+
+		// A negative transfer would otherwise pull the receiver's funds to the sender
+		if _, _, err := core.SettleTransaction(core.NewStateOverlay(binomToken), *tx, 1, nil); err == nil {
+			t.Errorf("Expected settling a transfer of %s to fail", amount)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+func TestDPoSValidateSignedBlock(t *testing.T) {
+	founderWallet, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("Failed to create founder wallet: %v", err)
+	}
+	delegateWallet, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("Failed to create delegate wallet: %v", err)
+	}
+
+	dpos := consensus.NewDPoSConsensus(founderWallet.Address, "community")
+	if err := dpos.RegisterDelegate(delegateWallet.Address, bnm.FromBNM(10000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+
+	// Find a slot for each producer
+	founderSlot := int64(0)
+	for dpos.GetScheduledProducer(founderSlot) != founderWallet.Address {
+		founderSlot += consensus.BlockTime
+	}
+	delegateSlot := int64(0)
+	for dpos.GetScheduledProducer(delegateSlot) != delegateWallet.Address {
+		delegateSlot += consensus.BlockTime
+	}
+
+	// A block signed by the scheduled producer is accepted
+	block := core.Block{Index: 1, PreviousHash: "prev", Timestamp: founderSlot}
+	if err := core.SignBlock(&block, founderWallet); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if !dpos.ValidateBlock(block) {
+		t.Error("Expected block from scheduled producer to be valid")
+	}
+
+	// A valid signature in someone else's slot is rejected
+	outOfTurn := core.Block{Index: 1, PreviousHash: "prev", Timestamp: delegateSlot}
+	if err := core.SignBlock(&outOfTurn, founderWallet); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if dpos.ValidateBlock(outOfTurn) {
+		t.Error("Expected block produced outside the producer's slot to be rejected")
+	}
+
+	// Claiming another validator without its key is rejected
+	forged := block
+	forged.Validator = delegateWallet.Address
+	forged.Timestamp = delegateSlot
+	forged.Hash = core.CalculateHash(forged)
+	if dpos.ValidateBlock(forged) {
+		t.Error("Expected block with forged validator to be rejected")
+	}
+
+	// Tampering with the signed block is detected
+	tampered := block
+	tampered.PreviousHash = "other"
+	if dpos.ValidateBlock(tampered) {
+		t.Error("Expected tampered block to be rejected")
+	}
+}
+
+func TestDPoSValidatesAgainstHistoricalDelegates(t *testing.T) {
+	founderWallet, _ := wallet.NewWallet()
+	delegateWallet, _ := wallet.NewWallet()
+
+	blockchain := core.NewBlockchain()
+	dpos := consensus.NewDPoSConsensus(founderWallet.Address, "community")
+	dpos.SetChain(blockchain)
+
+	// The founder produces block 1 while it is the only delegate
+	if producer := dpos.GetScheduledProducer(0); producer != founderWallet.Address {
+		t.Fatalf("Expected the founder to produce every slot, got %s", producer)
+	}
+	slot := int64(0)
+	block := core.Block{Index: 1, PreviousHash: blockchain.GetLastBlock().Hash, Timestamp: slot, Data: []core.Transaction{}, Version: core.CurrentBlockVersion, MerkleRoot: core.CalculateMerkleRoot(nil)}
+	if err := core.SignBlock(&block, founderWallet); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// A delegate joining afterwards takes slots from block 2 on
+	if err := dpos.RegisterDelegate(delegateWallet.Address, bnm.FromBNM(10000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+	for dpos.GetScheduledProducer(slot) != delegateWallet.Address {
+		slot += consensus.BlockTime
+	}
+	block.Timestamp = slot
+	if err := core.SignBlock(&block, founderWallet); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if !dpos.ValidateBlock(block) {
+		t.Error("Expected block 1 to be checked against the delegates in force at its height")
+	}
+
+	next := core.Block{Index: 2, PreviousHash: block.Hash, Timestamp: slot}
+	if err := core.SignBlock(&next, founderWallet); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if dpos.ValidateBlock(next) {
+		t.Error("Expected block 2 in the new delegate's slot to be rejected")
+	}
+}
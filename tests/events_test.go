@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/events"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// recordingObserver records the blocks and transactions a blockchain reports
+type recordingObserver struct {
+	blocks       []string
+	transactions []string
+}
+
+func (r *recordingObserver) BlockAdded(block core.Block) {
+	r.blocks = append(r.blocks, block.Hash)
+}
+
+func (r *recordingObserver) TransactionAdded(tx core.Transaction) {
+	r.transactions = append(r.transactions, tx.ID)
+}
+
+// checkChainObserver checks that a blockchain reports accepted transactions, new
+// blocks and only the new blocks of a replacement chain
+func checkChainObserver(t *testing.T, blockchain core.BlockchainInterface) {
+	observer := &recordingObserver{}
+	blockchain.(interface{ SetChainObserver(core.ChainObserver) }).SetChainObserver(observer)
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(5), 0, alice)
+	if err := blockchain.AddTransaction(*tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	blockchain.AddTransaction(*tx)
+	if len(observer.transactions) != 1 || observer.transactions[0] != tx.ID {
+		t.Errorf("Expected one accepted transaction, got %v", observer.transactions)
+	}
+
+	first := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(first); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	second := signedBlock(t, first, producer, []core.Transaction{})
+	chain := append(blockchain.GetChain(), second)
+	if err := blockchain.ReplaceChain(chain); err != nil {
+		t.Fatalf("Failed to replace chain: %v", err)
+	}
+
+	if len(observer.blocks) != 2 || observer.blocks[0] != first.Hash || observer.blocks[1] != second.Hash {
+		t.Errorf("Expected blocks %s and %s, got %v", first.Hash, second.Hash, observer.blocks)
+	}
+}
+
+func TestChainObserverFile(t *testing.T) {
+	checkChainObserver(t, core.NewBlockchain())
+}
+
+func TestChainObserverKV(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	checkChainObserver(t, core.NewBlockchainWithKV())
+}
+
+func TestChainObserverSQLite(t *testing.T) {
+	connectSQLite(t)
+	checkChainObserver(t, core.NewBlockchainWithDB())
+}
+
+func TestChainObserverReportsReorganization(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	observer := &recordingObserver{}
+	blockchain.SetChainObserver(observer)
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	genesis := blockchain.GetLastBlock()
+	a1 := signedBlock(t, genesis, producers[0], []core.Transaction{})
+	b1 := signedBlock(t, genesis, producers[1], []core.Transaction{})
+	b2 := signedBlock(t, b1, producers[2], []core.Transaction{})
+	for _, block := range []core.Block{a1, b1, b2} {
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	// The side block is only reported once its branch takes over
+	expected := []string{a1.Hash, b1.Hash, b2.Hash}
+	if strings.Join(observer.blocks, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected blocks %v, got %v", expected, observer.blocks)
+	}
+}
+
+func TestWebSocketSubscriptions(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	hub := events.NewHub(binomToken)
+	blockchain.SetChainObserver(hub)
+	server := httptest.NewServer(events.NewWebSocketHandler(hub))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	binomToken.Transfer("treasury", bob.Address, bnm.FromBNM(25))
+
+	subscribe := func(filter events.Filter) events.Response {
+		conn.WriteJSON(events.Request{ID: 1, Action: "subscribe", Filter: filter})
+		var response events.Response
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return response
+	}
+	if response := subscribe(events.Filter{Topic: "prices"}); response.Error == "" {
+		t.Errorf("Expected an unknown topic to be refused, got %+v", response)
+	}
+	blocks := subscribe(events.Filter{Topic: events.TopicBlocks}).Subscription
+	bobs := subscribe(events.Filter{Topic: events.TopicAddress, Address: bob.Address}).Subscription
+	if blocks == "" || bobs == "" {
+		t.Fatal("Expected subscription IDs")
+	}
+
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(5), 0, alice)
+	blockchain.AddTransaction(*tx)
+	block := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// Bob sees the transaction pending, then the block and its inclusion follow
+	var pending, included struct {
+		Subscription string
+		Data         events.AddressEvent
+	}
+	var header struct {
+		Subscription string
+		Data         core.BlockHeader
+	}
+	for _, message := range []interface{}{&pending, &header, &included} {
+		if err := conn.ReadJSON(message); err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+	}
+	if pending.Subscription != bobs || pending.Data.Status != core.ReceiptPending || pending.Data.Transaction.ID != tx.ID {
+		t.Errorf("Unexpected pending event: %+v", pending)
+	}
+	if pending.Data.Balance == nil || *pending.Data.Balance != bnm.FromBNM(25) {
+		t.Errorf("Expected bob's balance with the event, got %v", pending.Data.Balance)
+	}
+	if included.Data.Status != core.ReceiptIncluded || included.Data.BlockHash != block.Hash {
+		t.Errorf("Unexpected included event: %+v", included)
+	}
+	if header.Subscription != blocks || header.Data.Hash != block.Hash {
+		t.Errorf("Unexpected block event: %+v", header)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+func TestDPoSIrreversibleBlocks(t *testing.T) {
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	// Three producers: a block is irreversible once all three have built on it
+	dpos := consensus.NewDPoSConsensus(producers[0].Address, "community")
+	for _, delegate := range producers[1:] {
+		if err := dpos.RegisterDelegate(delegate.Address, bnm.FromBNM(10000)); err != nil {
+			t.Fatalf("Failed to register delegate: %v", err)
+		}
+	}
+
+	blockchain := core.NewBlockchain()
+	blockchain.SetFinalityGadget(dpos)
+	genesis := blockchain.GetLastBlock()
+
+	chain := []core.Block{genesis}
+	for i := 0; i < 3; i++ {
+		block := signedBlock(t, chain[len(chain)-1], producers[i], []core.Transaction{})
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block %d: %v", block.Index, err)
+		}
+		chain = append(chain, block)
+	}
+
+	// Blocks 2 and 3 are only confirmed by two producers so far
+	if irreversible := dpos.GetLastIrreversibleBlock(); irreversible.Index != 0 {
+		t.Fatalf("Expected no irreversible block yet, got #%d", irreversible.Index)
+	}
+
+	block := signedBlock(t, chain[len(chain)-1], producers[0], []core.Transaction{})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block %d: %v", block.Index, err)
+	}
+	chain = append(chain, block)
+
+	irreversible := dpos.GetLastIrreversibleBlock()
+	if irreversible.Index != 1 || irreversible.Hash != chain[1].Hash {
+		t.Fatalf("Expected block #1 to be irreversible, got #%d", irreversible.Index)
+	}
+
+	// Forks below the irreversible block are refused however strong they are
+	fork := signedBlock(t, genesis, producers[1], []core.Transaction{})
+	if err := blockchain.AddBlock(fork); err == nil {
+		t.Error("Expected fork below the irreversible block to be rejected")
+	}
+	if err := blockchain.ReplaceChain([]core.Block{genesis, fork}); err == nil {
+		t.Error("Expected replacement chain without the irreversible block to be rejected")
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != chain[len(chain)-1].Hash {
+		t.Error("Expected the canonical chain to be unchanged")
+	}
+
+	// Forks above it can still take over
+	fork = signedBlock(t, chain[1], producers[2], []core.Transaction{})
+	if err := blockchain.AddBlock(fork); err != nil {
+		t.Errorf("Expected fork above the irreversible block to be accepted: %v", err)
+	}
+}
+
+func TestDPoSIrreversibilityUsesDelegatesAtEachHeight(t *testing.T) {
+	founder, _ := wallet.NewWallet()
+	joined := make([]*wallet.Wallet, 2)
+	for i := range joined {
+		joined[i], _ = wallet.NewWallet()
+	}
+
+	blockchain := core.NewBlockchain()
+	dpos := consensus.NewDPoSConsensus(founder.Address, "community")
+	dpos.SetChain(blockchain)
+	blockchain.SetFinalityGadget(dpos)
+
+	// The founder produces blocks 1 and 2 while it is the only delegate
+	chain := []core.Block{blockchain.GetLastBlock()}
+	for i := 0; i < 2; i++ {
+		block := signedBlock(t, chain[len(chain)-1], founder, []core.Transaction{})
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block %d: %v", block.Index, err)
+		}
+		chain = append(chain, block)
+	}
+	if irreversible := dpos.GetLastIrreversibleBlock(); irreversible.Index != 1 {
+		t.Fatalf("Expected block #1 to be irreversible, got #%d", irreversible.Index)
+	}
+
+	// Two delegates join from block 3 on, and an outsider's block confirms nothing
+	for _, delegate := range joined {
+		if err := dpos.RegisterDelegate(delegate.Address, bnm.FromBNM(10000)); err != nil {
+			t.Fatalf("Failed to register delegate: %v", err)
+		}
+	}
+	outsider, _ := wallet.NewWallet()
+	block := signedBlock(t, chain[len(chain)-1], outsider, []core.Transaction{})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block %d: %v", block.Index, err)
+	}
+	chain = append(chain, block)
+	if irreversible := dpos.GetLastIrreversibleBlock(); irreversible.Index != 1 {
+		t.Fatalf("Expected an outsider's block not to confirm block #2, got #%d", irreversible.Index)
+	}
+
+	// Block 2 only needs the founder, who was the whole set at its height, while
+	// block 3 needs all three delegates
+	block = signedBlock(t, chain[len(chain)-1], founder, []core.Transaction{})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block %d: %v", block.Index, err)
+	}
+	if irreversible := dpos.GetLastIrreversibleBlock(); irreversible.Index != 2 || irreversible.Hash != chain[2].Hash {
+		t.Fatalf("Expected block #2 to be irreversible, got #%d", irreversible.Index)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"testing"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/smartcontract"
 	"github.com/igo-used/binomena/token"
@@ -25,7 +26,8 @@ func TestWalletAndTransactions(t *testing.T) {
 	tx, err := core.NewTransaction(
 		alice.Address,
 		bob.Address,
-		50.0,
+		bnm.FromBNM(50),
+		0,
 		alice,
 	)
 
@@ -41,8 +43,8 @@ func TestWalletAndTransactions(t *testing.T) {
 
 	// Calculate fee
 	fee := tx.CalculateFee()
-	if fee != 0.05 { // 50.0 * 0.001
-		t.Errorf("Fee calculation incorrect: expected=0.05, got=%.4f", fee)
+	if fee != bnm.MustParse("0.05") { // 50 * 0.001
+		t.Errorf("Fee calculation incorrect: expected=0.05, got=%s", fee)
 	}
 }
 
@@ -65,7 +67,7 @@ func TestSmartContractAndWallet(t *testing.T) {
 		ownerWallet.Address,
 		"IntegrationTestContract",
 		testWasmBinary,
-		0.5, // fee
+		bnm.MustParse("0.5"), // fee
 	)
 
 	if err != nil {
@@ -78,7 +80,7 @@ func TestSmartContractAndWallet(t *testing.T) {
 		"test_function",
 		[]interface{}{123, "test"},
 		ownerWallet.Address,
-		0.01, // fee
+		bnm.MustParse("0.01"), // fee
 	)
 
 	// This will likely fail without a real WASM binary, but we're testing the integration
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/database"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// openKV opens a fresh key-value store that is closed when the test ends
+func openKV(t *testing.T, path string) {
+	if err := database.OpenKV(path); err != nil {
+		t.Fatalf("Failed to open key-value store: %v", err)
+	}
+	if err := database.InitializeKVSystemState(); err != nil {
+		t.Fatalf("Failed to initialize system state: %v", err)
+	}
+	t.Cleanup(func() { database.CloseKV() })
+}
+
+func TestKVBlockchainPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	openKV(t, path)
+	blockchain := core.NewBlockchainWithKV()
+
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	included, _ := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(10), 0, sender)
+	waiting, _ := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(20), 1, sender)
+	for _, tx := range []*core.Transaction{included, waiting} {
+		if err := blockchain.AddTransaction(*tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	block := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*included})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if err := blockchain.AddBlock(block); err == nil {
+		t.Error("Expected a repeated block to be rejected")
+	}
+	if blockchain.Mempool().Contains(included.ID) || !blockchain.Mempool().Contains(waiting.ID) {
+		t.Error("Expected only the included transaction to leave the mempool")
+	}
+	if blockchain.GetBlockCount() != 2 {
+		t.Errorf("Expected 2 blocks, got %d", blockchain.GetBlockCount())
+	}
+
+	// Reopening the store restores the blocks and the remaining pending transaction
+	database.CloseKV()
+	openKV(t, path)
+	restored := core.NewBlockchainWithKV()
+
+	if restored.GetBlockCount() != 2 {
+		t.Fatalf("Expected 2 blocks after reopening, got %d", restored.GetBlockCount())
+	}
+	if restored.GetLastBlock().Hash != block.Hash {
+		t.Error("Expected the added block to be the tip after reopening")
+	}
+	if byHash, err := restored.GetBlockByHash(block.Hash); err != nil || byHash.Index != block.Index {
+		t.Errorf("Expected block %d by hash, got %d (%v)", block.Index, byHash.Index, err)
+	}
+	pending := restored.GetPendingTransactions()
+	if len(pending) != 1 || pending[0].ID != waiting.ID {
+		t.Errorf("Expected only the waiting transaction to be pending, got %d", len(pending))
+	}
+}
+
+func TestGenesisIsTheSameOnEveryBackend(t *testing.T) {
+	want := core.GenesisBlock()
+	if want.Timestamp != core.GenesisTimestamp {
+		t.Fatalf("Expected genesis timestamp %d, got %d", core.GenesisTimestamp, want.Timestamp)
+	}
+
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	connectSQLite(t)
+
+	backends := map[string]core.BlockchainInterface{
+		"memory": core.NewBlockchain(),
+		"kv":     core.NewBlockchainWithKV(),
+		"sqlite": core.NewBlockchainWithDB(),
+	}
+	for name, blockchain := range backends {
+		genesis, err := blockchain.GetBlockByIndex(0)
+		if err != nil {
+			t.Fatalf("%s: failed to load genesis block: %v", name, err)
+		}
+		if genesis.Hash != want.Hash || genesis.Timestamp != want.Timestamp {
+			t.Errorf("%s: expected genesis %s at %d, got %s at %d", name, want.Hash, want.Timestamp, genesis.Hash, genesis.Timestamp)
+		}
+	}
+}
+
+func TestKVChainSettlesBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	openKV(t, path)
+	blockchain := core.NewBlockchainWithKV()
+	binomToken := token.NewBinomTokenWithKV()
+	blockchain.SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	carol, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+
+	toBob, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	toCarol, _ := core.NewTransaction(alice.Address, carol.Address, bnm.FromBNM(50), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	block := newBranchState(binomToken, nil).block(t, genesis, producer, []core.Transaction{*toBob})
+	replacement := newBranchState(binomToken, nil).block(t, genesis, producer, []core.Transaction{*toCarol})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected bob's balance to be 100 BNM, got %s", balance)
+	}
+	if nonce := binomToken.GetNonce(alice.Address); nonce != 1 {
+		t.Errorf("Expected alice's nonce to be consumed, got %d", nonce)
+	}
+
+	// A block that cannot be settled is rejected without touching state
+	overdraft, _ := core.NewTransaction(bob.Address, carol.Address, bnm.FromBNM(500), 0, bob)
+	rejected := signedBlock(t, block, producer, []core.Transaction{*overdraft})
+	if err := blockchain.AddBlock(rejected); err == nil {
+		t.Error("Expected a block with an unpayable transaction to be rejected")
+	}
+	if blockchain.GetBlockCount() != 2 || binomToken.GetBalance(carol.Address) != 0 {
+		t.Error("Expected the rejected block to leave chain and balances alone")
+	}
+
+	// A block the store cannot index is rolled back in the same batch as its
+	// settlement: the recipient fits a balance key but not an address index key
+	unindexable, _ := core.NewTransaction(alice.Address, "AdNe"+strings.Repeat("0", 32756), bnm.FromBNM(10), 1, alice)
+	unstored := newBranchState(binomToken, nil).block(t, block, producer, []core.Transaction{*unindexable})
+	aliceBalance := binomToken.GetBalance(alice.Address)
+	if err := blockchain.AddBlock(unstored); err == nil {
+		t.Error("Expected a block that cannot be stored to be rejected")
+	}
+	if binomToken.GetBalance(alice.Address) != aliceBalance || binomToken.GetNonce(alice.Address) != 1 {
+		t.Error("Expected the unstored block to leave alice's balance and nonce alone")
+	}
+	if blockchain.GetBlockCount() != 2 {
+		t.Errorf("Expected 2 blocks, got %d", blockchain.GetBlockCount())
+	}
+
+	// The changes of each block outlive a restart, so replacing the chain reverts them
+	database.CloseKV()
+	openKV(t, path)
+	blockchain = core.NewBlockchainWithKV()
+	binomToken = token.NewBinomTokenWithKV()
+	blockchain.SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+
+	if err := blockchain.ReplaceChain([]core.Block{genesis, replacement}); err != nil {
+		t.Fatalf("Failed to replace chain: %v", err)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != 0 {
+		t.Errorf("Expected bob's balance to be reverted to 0, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(carol.Address); balance != bnm.FromBNM(50) {
+		t.Errorf("Expected carol's balance to be 50 BNM, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(alice.Address); balance != bnm.FromBNM(950)-toCarol.CalculateFee() {
+		t.Errorf("Expected alice to pay carol and the fee, got %s", balance)
+	}
+}
+
+func TestKVTokenBalancesAndNonces(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	binomToken := token.NewBinomTokenWithKV()
+
+	if err := binomToken.Transfer("treasury", "AdNe-alice", bnm.FromBNM(100)); err != nil {
+		t.Fatalf("Failed to transfer: %v", err)
+	}
+	if balance := binomToken.GetBalance("AdNe-alice"); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected balance 100 BNM, got %s", balance)
+	}
+	if err := binomToken.Transfer("AdNe-alice", "AdNe-bob", bnm.FromBNM(101)); err == nil {
+		t.Error("Expected an overdraft to be rejected")
+	}
+	if balance := binomToken.GetBalance("AdNe-alice"); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected failed transfer to leave 100 BNM, got %s", balance)
+	}
+
+	binomToken.Burn(bnm.FromBNM(10))
+	if supply := binomToken.GetCirculatingSupply(); supply != bnm.FromBNM(1000000000-10) {
+		t.Errorf("Expected burn to reduce the supply, got %s", supply)
+	}
+
+	if err := useNonce(binomToken, "AdNe-alice", 0); err != nil {
+		t.Fatalf("Failed to use nonce: %v", err)
+	}
+	if err := useNonce(binomToken, "AdNe-alice", 0); err == nil {
+		t.Error("Expected a reused nonce to be rejected")
+	}
+	if nonce := binomToken.GetNonce("AdNe-alice"); nonce != 1 {
+		t.Errorf("Expected next nonce 1, got %d", nonce)
+	}
+}
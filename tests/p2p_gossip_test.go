@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// startGossipLine starts nodes connected in a line, so messages from the first node
+// only reach the last one by being re-gossiped
+func startGossipLine(t *testing.T, chains []*core.Blockchain) []*p2p.P2PNode {
+	nodes := make([]*p2p.P2PNode, len(chains))
+	for i, chain := range chains {
+		node, err := p2p.NewP2PNode(chain, "/ip4/127.0.0.1/tcp/0")
+		if err != nil {
+			t.Fatalf("Failed to start node: %v", err)
+		}
+		t.Cleanup(func() { node.Stop() })
+		nodes[i] = node
+	}
+	for i := 1; i < len(nodes); i++ {
+		if err := nodes[i-1].ConnectToPeer(nodes[i].GetAddress()); err != nil {
+			t.Fatalf("Failed to connect nodes: %v", err)
+		}
+	}
+	return nodes
+}
+
+// waitFor polls a condition until it holds or the timeout passes
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return condition()
+}
+
+func TestGossipPropagation(t *testing.T) {
+	first := core.NewBlockchain()
+	chains := []*core.Blockchain{
+		first,
+		core.NewBlockchainWithGenesis(first.GetLastBlock()),
+		core.NewBlockchainWithGenesis(first.GetLastBlock()),
+	}
+	nodes := startGossipLine(t, chains)
+	last := chains[len(chains)-1]
+
+	// Every node admits transactions against the same funded sender
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+	for _, node := range nodes {
+		binomToken := token.NewBinomToken()
+		if err := binomToken.Transfer("treasury", sender.Address, bnm.FromBNM(100)); err != nil {
+			t.Fatalf("Failed to fund sender: %v", err)
+		}
+		node.SetAccountState(binomToken)
+	}
+	tx, err := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(5), 0, sender)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	// A valid transaction travels across the whole line
+	if err := nodes[0].BroadcastTransaction(*tx); err != nil {
+		t.Fatalf("Failed to broadcast transaction: %v", err)
+	}
+	if !waitFor(5*time.Second, func() bool { return last.Mempool().Contains(tx.ID) }) {
+		t.Fatal("Expected transaction to reach the last node")
+	}
+
+	// A tampered transaction is rejected by the first hop and never re-gossiped
+	tampered := *tx
+	tampered.Nonce = 1
+	tampered.ID = core.CalculateTransactionID(&tampered)
+	if err := nodes[0].BroadcastTransaction(tampered); err != nil {
+		t.Fatalf("Failed to broadcast transaction: %v", err)
+	}
+
+	// Transactions failing admission are neither accepted nor re-gossiped
+	zero, _ := core.NewTransaction(sender.Address, receiver.Address, 0, 1, sender)
+	skipped, _ := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(5), 5, sender)
+	overdrawn, _ := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(99), 1, sender)
+	unadmitted := []*core.Transaction{zero, skipped, overdrawn}
+	for _, tx := range unadmitted {
+		if err := nodes[0].BroadcastTransaction(*tx); err != nil {
+			t.Fatalf("Failed to broadcast transaction: %v", err)
+		}
+	}
+
+	// Blocks propagate the same way
+	producer, _ := wallet.NewWallet()
+	block := signedBlock(t, first.GetLastBlock(), producer, []core.Transaction{})
+	if err := first.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if err := nodes[0].BroadcastBlock(block); err != nil {
+		t.Fatalf("Failed to broadcast block: %v", err)
+	}
+	if !waitFor(5*time.Second, func() bool { return last.GetLastBlock().Hash == block.Hash }) {
+		t.Fatal("Expected block to reach the last node")
+	}
+
+	for i, chain := range chains[1:] {
+		if chain.Mempool().Contains(tampered.ID) {
+			t.Errorf("Node %d accepted a transaction with an invalid signature", i+1)
+		}
+		for _, tx := range unadmitted {
+			if chain.Mempool().Contains(tx.ID) {
+				t.Errorf("Node %d accepted transaction %s that fails admission", i+1, tx.ID)
+			}
+		}
+	}
+}
+
+func TestGossipReusesOneStreamPerPeer(t *testing.T) {
+	hosts := make([]host.Host, 2)
+	for i := range hosts {
+		h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Fatalf("Failed to start host: %v", err)
+		}
+		t.Cleanup(func() { h.Close() })
+		hosts[i] = h
+	}
+	sender := p2p.NewGossip(hosts[0], nil)
+	receiver := p2p.NewGossip(hosts[1], nil)
+
+	var mu sync.Mutex
+	received := 0
+	receiver.RegisterValidator(p2p.TransactionTopic, func(peer.ID, []byte) p2p.ValidationResult {
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+		return p2p.ValidationAccept
+	})
+
+	if err := hosts[0].Connect(context.Background(), peer.AddrInfo{ID: hosts[1].ID(), Addrs: hosts[1].Addrs()}); err != nil {
+		t.Fatalf("Failed to connect hosts: %v", err)
+	}
+
+	const messages = 20
+	for i := 0; i < messages; i++ {
+		if err := sender.Publish(p2p.TransactionTopic, []byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+	if !waitFor(5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received == messages
+	}) {
+		t.Fatalf("Expected %d messages to arrive, got %d", messages, received)
+	}
+
+	streams := 0
+	for _, conn := range hosts[0].Network().ConnsToPeer(hosts[1].ID()) {
+		for _, stream := range conn.GetStreams() {
+			if stream.Protocol() == "/binomena/gossip/2.0.0" {
+				streams++
+			}
+		}
+	}
+	if streams != 1 {
+		t.Errorf("Expected every message to travel over one gossip stream, found %d", streams)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/wallet"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPeerScorerRateLimitAndBan(t *testing.T) {
+	config := p2p.DefaultPeerScoreConfig()
+	config.BanThreshold = -10
+	config.BanDuration = 100 * time.Millisecond
+	config.Limits = map[string]p2p.ProtocolLimit{
+		"/test/1.0.0": {MaxMessageSize: 1024, Rate: 1, Burst: 2},
+	}
+	scorer := p2p.NewPeerScorer(config)
+	peerID := peer.ID("flooder")
+
+	// The burst is allowed, then each message over the rate costs score
+	for i := 0; i < 2; i++ {
+		if !scorer.Allow(peerID, "/test/1.0.0") {
+			t.Fatalf("Expected message %d within the burst to be allowed", i)
+		}
+	}
+	if scorer.Allow(peerID, "/test/1.0.0") {
+		t.Fatal("Expected message over the rate limit to be refused")
+	}
+	if score := scorer.Score(peerID); score.Score != p2p.PenaltyRateLimited {
+		t.Errorf("Expected score %v after flooding, got %v", p2p.PenaltyRateLimited, score.Score)
+	}
+
+	// Falling to the threshold bans the peer for the configured period
+	if !scorer.Penalize(peerID, p2p.PenaltyInvalidBlock) {
+		t.Fatal("Expected peer to be banned at the threshold")
+	}
+	if !scorer.IsBanned(peerID) || !scorer.Score(peerID).Banned {
+		t.Fatal("Expected peer to be reported as banned")
+	}
+	if scorer.Allow(peerID, "/test/1.0.0") {
+		t.Error("Expected banned peer to be refused")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if scorer.IsBanned(peerID) {
+		t.Error("Expected ban to expire")
+	}
+	if !scorer.Allow(peerID, "/test/1.0.0") {
+		t.Error("Expected peer to be allowed again after the ban")
+	}
+}
+
+func TestPeerBannedForInvalidTransactions(t *testing.T) {
+	first := core.NewBlockchain()
+	chains := []*core.Blockchain{first, core.NewBlockchainWithGenesis(first.GetLastBlock())}
+	nodes := startGossipLine(t, chains)
+
+	config := p2p.DefaultPeerScoreConfig()
+	config.BanThreshold = -30
+	nodes[1].SetPeerScoreConfig(config)
+
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+
+	// Each tampered transaction fails signature checks on the receiving node
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, err := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(1), nonce, sender)
+		if err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+		tx.Amount = bnm.FromBNM(1000)
+		tx.ID = core.CalculateTransactionID(tx)
+		if err := nodes[0].BroadcastTransaction(*tx); err != nil {
+			t.Fatalf("Failed to broadcast transaction: %v", err)
+		}
+	}
+
+	banned := waitFor(5*time.Second, func() bool {
+		for _, score := range nodes[1].GetPeerScores() {
+			if score.Banned {
+				return true
+			}
+		}
+		return false
+	})
+	if !banned {
+		t.Fatalf("Expected the sending peer to be banned, scores: %+v", nodes[1].GetPeerScores())
+	}
+	if chains[1].Mempool().Size() != 0 {
+		t.Error("Expected no tampered transaction to be accepted")
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/wallet"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestHeaderFirstSync(t *testing.T) {
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	ahead := core.NewBlockchain()
+	behind := core.NewBlockchainWithGenesis(ahead.GetLastBlock())
+
+	// Both nodes share two blocks, then the lagging node has a block of its own
+	for i := 0; i < 2; i++ {
+		block := signedBlock(t, ahead.GetLastBlock(), producers[0], []core.Transaction{})
+		if err := ahead.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		if err := behind.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	stale := signedBlock(t, behind.GetLastBlock(), producers[0], []core.Transaction{})
+	stale.Timestamp++
+	if err := core.SignBlock(&stale, producers[0]); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := behind.AddBlock(stale); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		block := signedBlock(t, ahead.GetLastBlock(), producers[i%len(producers)], []core.Transaction{})
+		if err := ahead.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	aheadNode, err := p2p.NewP2PNode(ahead, "/ip4/127.0.0.1/tcp/0")
+	if err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer aheadNode.Stop()
+	behindNode, err := p2p.NewP2PNode(behind, "/ip4/127.0.0.1/tcp/0")
+	if err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer behindNode.Stop()
+
+	addr, err := multiaddr.NewMultiaddr(aheadNode.GetAddress())
+	if err != nil {
+		t.Fatalf("Invalid node address: %v", err)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(addr)
+	if err != nil {
+		t.Fatalf("Invalid peer info: %v", err)
+	}
+	if err := behindNode.ConnectToPeer(aheadNode.GetAddress()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	status, err := behindNode.RequestStatus(info.ID)
+	if err != nil {
+		t.Fatalf("Failed to exchange status: %v", err)
+	}
+	if status.Height != ahead.GetLastBlock().Index || status.TipHash != ahead.GetLastBlock().Hash {
+		t.Errorf("Unexpected peer status %+v", status)
+	}
+
+	if err := behindNode.SyncWithPeer(info.ID); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if behind.GetLastBlock().Hash != ahead.GetLastBlock().Hash {
+		t.Errorf("Expected lagging node to reach the peer's tip %d, got block %d", ahead.GetLastBlock().Index, behind.GetLastBlock().Index)
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/p2p"
+	"github.com/igo-used/binomena/wallet"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestWalletAnnouncementSignature(t *testing.T) {
+	owner, _ := wallet.NewWallet()
+	other, _ := wallet.NewWallet()
+
+	announcement, err := p2p.NewWalletAnnouncement(owner, peer.ID("announcer"))
+	if err != nil {
+		t.Fatalf("Failed to create announcement: %v", err)
+	}
+	if err := announcement.Verify(); err != nil {
+		t.Fatalf("Valid announcement rejected: %v", err)
+	}
+
+	// Pointing the wallet at another peer invalidates the signature
+	redirected := *announcement
+	redirected.PeerID = "attacker"
+	if err := redirected.Verify(); err == nil {
+		t.Error("Expected announcement with a changed peer ID to be rejected")
+	}
+
+	// Claiming someone else's wallet with our own key fails the address check
+	claimed := *announcement
+	claimed.Address = other.Address
+	if err := claimed.Verify(); err == nil {
+		t.Error("Expected announcement for another wallet to be rejected")
+	}
+}
+
+func TestWalletAnnouncementDiscoveryAndPersistence(t *testing.T) {
+	dataDir := t.TempDir()
+	first := core.NewBlockchain()
+	chains := []*core.Blockchain{first, core.NewBlockchainWithGenesis(first.GetLastBlock())}
+	nodes := startGossipLine(t, chains)
+	if err := nodes[1].LoadWallets(dataDir); err != nil {
+		t.Fatalf("Failed to load wallets: %v", err)
+	}
+
+	addr, _ := multiaddr.NewMultiaddr(nodes[0].GetAddress())
+	info, _ := peer.AddrInfoFromP2pAddr(addr)
+
+	owner, _ := wallet.NewWallet()
+	if err := nodes[0].AnnounceWallet(owner); err != nil {
+		t.Fatalf("Failed to announce wallet: %v", err)
+	}
+	if !waitFor(5*time.Second, func() bool { _, found := nodes[1].FindWalletPeer(owner.Address); return found }) {
+		t.Fatal("Expected announced wallet to be discovered")
+	}
+	if peerID, _ := nodes[1].FindWalletPeer(owner.Address); peerID != info.ID.String() {
+		t.Errorf("Expected wallet at peer %s, got %s", info.ID, peerID)
+	}
+
+	// A restarted node picks the verified announcement back up
+	genesis, _ := first.GetBlockByIndex(0)
+	restarted, err := p2p.NewP2PNode(core.NewBlockchainWithGenesis(genesis), "/ip4/127.0.0.1/tcp/0")
+	if err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer restarted.Stop()
+	if err := restarted.LoadWallets(dataDir); err != nil {
+		t.Fatalf("Failed to load wallets: %v", err)
+	}
+	if peerID, found := restarted.FindWalletPeer(owner.Address); !found || peerID != info.ID.String() {
+		t.Errorf("Expected saved announcement to be restored, got %q", peerID)
+	}
+
+	// Announcements past the TTL are no longer served
+	restarted.SetWalletAnnouncementTTL(time.Nanosecond)
+	if _, found := restarted.FindWalletPeer(owner.Address); found {
+		t.Error("Expected expired announcement to be dropped")
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// receiptDelegate is paid the delegates' share of fees by halfFees
+const receiptDelegate = "AdNe-receipt-delegate"
+
+// halfFees pays half of every fee to a delegate and burns the rest
+type halfFees struct{}
+
+func (halfFees) FeePayouts(fee bnm.Amount, height uint64) ([]core.FeePayout, core.FeeDistribution) {
+	payouts := []core.FeePayout{{Address: receiptDelegate, Amount: fee / 2}}
+	return payouts, core.FeeDistribution{Delegates: fee / 2, Burned: fee - fee/2}
+}
+
+// checkReceipts follows transactions through the pending, included, dropped and
+// failed states on a chain settling blocks on a token system
+func checkReceipts(t *testing.T, blockchain core.BlockchainInterface, binomToken core.TokenInterface) {
+	blockchain.(interface{ SetStateApplier(core.StateApplier) }).SetStateApplier(core.NewTokenStateApplier(binomToken, halfFees{}))
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	if err := blockchain.AddTransaction(*tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	receipt, err := blockchain.GetReceipt(tx.ID)
+	if err != nil || receipt.Status != core.ReceiptPending || receipt.Fee != tx.CalculateFee() {
+		t.Fatalf("Expected a pending receipt, got %+v (%v)", receipt, err)
+	}
+
+	// Inclusion records the block and fee split, and confirmations grow with the chain
+	first := newBranchState(binomToken, halfFees{}).block(t, blockchain.GetLastBlock(), producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(first); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	second := newBranchState(binomToken, halfFees{}).block(t, first, producer, []core.Transaction{})
+	if err := blockchain.AddBlock(second); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	receipt, err = blockchain.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatalf("Failed to get receipt: %v", err)
+	}
+	if receipt.Status != core.ReceiptIncluded || receipt.BlockIndex != first.Index || receipt.BlockHash != first.Hash || receipt.Confirmations != 2 {
+		t.Errorf("Unexpected included receipt: %+v", receipt)
+	}
+	if receipt.FeeDistribution == nil || receipt.FeeDistribution.Delegates+receipt.FeeDistribution.Burned != receipt.Fee {
+		t.Fatalf("Expected the fee split to be recorded, got %+v", receipt.FeeDistribution)
+	}
+	if paid := binomToken.GetBalance(receiptDelegate); paid != receipt.FeeDistribution.Delegates {
+		t.Errorf("Expected the receipt to record the %s paid to delegates, got %s", paid, receipt.FeeDistribution.Delegates)
+	}
+
+	// A full mempool evicts the cheaper transaction
+	blockchain.Mempool().SetConfig(&core.MempoolConfig{MaxSize: 1})
+	cheap, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(1), 1, alice)
+	rich, _ := core.NewTransaction(bob.Address, alice.Address, bnm.FromBNM(50), 0, bob)
+	for _, pending := range []*core.Transaction{cheap, rich} {
+		if err := blockchain.AddTransaction(*pending); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	receipt, err = blockchain.GetReceipt(cheap.ID)
+	if err != nil || receipt.Status != core.ReceiptDropped || receipt.Error == "" {
+		t.Errorf("Expected a dropped receipt with its reason, got %+v (%v)", receipt, err)
+	}
+
+	// Execution failures are recorded, but never over an included receipt
+	failure := core.TransactionResult{Transaction: rich, Error: errors.New("insufficient balance")}
+	blockchain.Mempool().RemoveIncluded([]core.Transaction{*rich})
+	if err := blockchain.RecordReceipt(core.NewFailedReceipt(failure)); err != nil {
+		t.Fatalf("Failed to record receipt: %v", err)
+	}
+	receipt, _ = blockchain.GetReceipt(rich.ID)
+	if receipt.Status != core.ReceiptFailed || receipt.Error != "insufficient balance" {
+		t.Errorf("Expected a failed receipt, got %+v", receipt)
+	}
+	blockchain.RecordReceipt(core.NewFailedReceipt(core.TransactionResult{Transaction: tx, Error: errors.New("late")}))
+	if receipt, _ = blockchain.GetReceipt(tx.ID); receipt.Status != core.ReceiptIncluded {
+		t.Errorf("Expected the included receipt to be kept, got %+v", receipt)
+	}
+
+	if _, err := blockchain.GetReceipt("AdNe-missing"); err != core.ErrTransactionNotFound {
+		t.Errorf("Expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+func TestReceiptsFile(t *testing.T) {
+	checkReceipts(t, core.NewBlockchain(), token.NewBinomToken())
+}
+
+func TestReceiptsKV(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	checkReceipts(t, core.NewBlockchainWithKV(), token.NewBinomTokenWithKV())
+}
+
+func TestReceiptsSQLite(t *testing.T) {
+	connectSQLite(t)
+	checkReceipts(t, core.NewBlockchainWithDB(), token.NewBinomTokenWithDB())
+}
+
+func TestReceiptsFollowReorganization(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	orphaned, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(1), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	a1 := signedBlock(t, genesis, producers[0], []core.Transaction{*orphaned})
+	if err := blockchain.AddBlock(a1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// The transaction goes back to the mempool when a longer branch takes over
+	b1 := signedBlock(t, genesis, producers[1], []core.Transaction{})
+	b2 := signedBlock(t, b1, producers[2], []core.Transaction{})
+	for _, block := range []core.Block{b1, b2} {
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	if receipt, err := blockchain.GetReceipt(orphaned.ID); err != nil || receipt.Status != core.ReceiptPending {
+		t.Errorf("Expected the orphaned transaction to be pending again, got %+v (%v)", receipt, err)
+	}
+}
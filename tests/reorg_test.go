@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// signedBlock builds a block on top of parent signed by producer
+func signedBlock(t *testing.T, parent core.Block, producer *wallet.Wallet, transactions []core.Transaction) core.Block {
+	block := core.Block{
+		Index:        parent.Index + 1,
+		PreviousHash: parent.Hash,
+		Timestamp:    parent.Timestamp + 1,
+		Data:         transactions,
+		Version:      core.CurrentBlockVersion,
+		MerkleRoot:   core.CalculateMerkleRoot(transactions),
+	}
+	if err := core.SignBlock(&block, producer); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	return block
+}
+
+// branchState executes the blocks of a branch on an overlay of the state the branch
+// forks off from, so that each block commits to the state root it leaves behind
+// without touching the chain. The base state must not change while the branch is built.
+type branchState struct {
+	state *core.StateOverlay
+	fees  core.FeeSchedule
+}
+
+// newBranchState starts a branch on top of a token system's current state, paying
+// out fees as the chain's applier does
+func newBranchState(base core.AccountState, fees core.FeeSchedule) *branchState {
+	return &branchState{state: core.NewStateOverlay(base), fees: fees}
+}
+
+// block builds the next block of the branch on top of parent, signed by producer.
+// Transactions that cannot be settled are kept in the block but leave the state alone.
+func (b *branchState) block(t *testing.T, parent core.Block, producer *wallet.Wallet, transactions []core.Transaction) core.Block {
+	height := parent.Index + 1
+	for _, tx := range transactions {
+		core.SettleTransaction(b.state, tx, height, b.fees)
+	}
+	stateRoot, err := core.CalculateStateRootAt(height, b.state)
+	if err != nil {
+		t.Fatalf("Failed to calculate state root: %v", err)
+	}
+
+	block := core.Block{
+		Index:        height,
+		PreviousHash: parent.Hash,
+		Timestamp:    parent.Timestamp + 1,
+		Data:         transactions,
+		Version:      core.CurrentBlockVersion,
+		MerkleRoot:   core.CalculateMerkleRoot(transactions),
+		StateRoot:    stateRoot,
+	}
+	if err := core.SignBlock(&block, producer); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	return block
+}
+
+func TestChainReorganization(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	blockchain.SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	carol, _ := wallet.NewWallet()
+
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+	maxSupply := binomToken.GetCirculatingSupply()
+
+	toBob, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	toCarol, _ := core.NewTransaction(alice.Address, carol.Address, bnm.FromBNM(50), 0, alice)
+	genesis := blockchain.GetLastBlock()
+
+	// Both branches commit to the state they leave on top of genesis
+	a1 := newBranchState(binomToken, nil).block(t, genesis, producers[0], []core.Transaction{*toBob})
+	branchB := newBranchState(binomToken, nil)
+	b1 := branchB.block(t, genesis, producers[1], []core.Transaction{*toCarol})
+	b2 := branchB.block(t, b1, producers[2], []core.Transaction{})
+
+	// Branch A: one block paying bob, settled as it extends the tip
+	if err := blockchain.AddBlock(a1); err != nil {
+		t.Fatalf("Failed to add block A1: %v", err)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected bob's balance to be 100 BNM after A1, got %s", balance)
+	}
+
+	// Branch B ties with A at first, so the branch seen first stays canonical
+	if err := blockchain.AddBlock(b1); err != nil {
+		t.Fatalf("Failed to add block B1: %v", err)
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != a1.Hash {
+		t.Fatalf("Expected A1 to stay canonical on a tie, got block %s", tip.Hash)
+	}
+	if err := blockchain.AddBlock(b1); err != core.ErrKnownBlock {
+		t.Errorf("Expected re-adding B1 to return ErrKnownBlock, got %v", err)
+	}
+
+	// A second producer on branch B makes it preferred
+	if err := blockchain.AddBlock(b2); err != nil {
+		t.Fatalf("Failed to add block B2: %v", err)
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != b2.Hash {
+		t.Fatalf("Expected reorganization onto B2, got block %s", tip.Hash)
+	}
+	if block, _ := blockchain.GetBlockByIndex(1); block.Hash != b1.Hash {
+		t.Errorf("Expected B1 at height 1 after reorganization, got %s", block.Hash)
+	}
+
+	// Balances follow branch B: bob's payment is undone and carol's applied, with
+	// alice paying the fee, which is burned without a fee schedule
+	carolFee := toCarol.CalculateFee()
+	if balance := binomToken.GetBalance(bob.Address); balance != 0 {
+		t.Errorf("Expected bob's balance to be reverted to 0, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(carol.Address); balance != bnm.FromBNM(50) {
+		t.Errorf("Expected carol's balance to be 50 BNM, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(alice.Address); balance != bnm.FromBNM(950)-carolFee {
+		t.Errorf("Expected alice's balance to be %s, got %s", bnm.FromBNM(950)-carolFee, balance)
+	}
+	if supply := binomToken.GetCirculatingSupply(); supply != maxSupply-carolFee {
+		t.Errorf("Expected circulating supply %s, got %s", maxSupply-carolFee, supply)
+	}
+
+	// The transaction dropped with branch A is pending again
+	pending := blockchain.GetPendingTransactions()
+	if len(pending) != 1 || pending[0].ID != toBob.ID {
+		t.Errorf("Expected bob's transaction to return to the pending pool, got %d transactions", len(pending))
+	}
+
+	// Unknown parents are rejected
+	orphan := signedBlock(t, core.Block{Index: 5, Hash: "unknown"}, producers[0], []core.Transaction{})
+	if err := blockchain.AddBlock(orphan); err == nil {
+		t.Error("Expected block with an unknown parent to be rejected")
+	}
+}
+
+// checkAdoptBranch offers a peer's branch to a chain settling blocks on a token
+// system, as sync does for branches forking off below the tip
+func checkAdoptBranch(t *testing.T, blockchain core.BlockchainInterface, binomToken core.TokenInterface) {
+	blockchain.(interface{ SetStateApplier(core.StateApplier) }).SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	carol, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+
+	toBob, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	toCarol, _ := core.NewTransaction(alice.Address, carol.Address, bnm.FromBNM(50), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	a1 := newBranchState(binomToken, nil).block(t, genesis, producers[0], []core.Transaction{*toBob})
+	branchB := newBranchState(binomToken, nil)
+	b1 := branchB.block(t, genesis, producers[1], []core.Transaction{*toCarol})
+	b2 := branchB.block(t, b1, producers[2], []core.Transaction{})
+	if err := blockchain.AddBlock(a1); err != nil {
+		t.Fatalf("Failed to add block A1: %v", err)
+	}
+
+	// A branch by a single producer ties and is kept out
+	if adopted, err := core.AdoptBranch(blockchain, []core.Block{b1}); err != nil || adopted {
+		t.Fatalf("Expected the tying branch to be kept out, got %v (%v)", adopted, err)
+	}
+
+	// A branch by more producers replaces A1 and switches the state onto it
+	if adopted, err := core.AdoptBranch(blockchain, []core.Block{b1, b2}); err != nil || !adopted {
+		t.Fatalf("Expected the preferred branch to be adopted, got %v (%v)", adopted, err)
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != b2.Hash {
+		t.Fatalf("Expected B2 at the tip, got block %s", tip.Hash)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != 0 {
+		t.Errorf("Expected bob's balance to be reverted to 0, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(carol.Address); balance != bnm.FromBNM(50) {
+		t.Errorf("Expected carol's balance to be 50 BNM, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(alice.Address); balance != bnm.FromBNM(950)-toCarol.CalculateFee() {
+		t.Errorf("Expected alice to pay for carol's transfer only, got %s", balance)
+	}
+
+	// A preferred branch carrying a transaction alice never signed is refused before
+	// any state is switched onto it
+	forged, _ := core.NewTransaction(alice.Address, producers[0].Address, bnm.FromBNM(500), 0, producers[0])
+	c1 := signedBlock(t, genesis, producers[0], []core.Transaction{*forged})
+	c2 := signedBlock(t, c1, producers[1], []core.Transaction{})
+	c3 := signedBlock(t, c2, producers[2], []core.Transaction{})
+	if _, err := core.AdoptBranch(blockchain, []core.Block{c1, c2, c3}); err == nil {
+		t.Error("Expected a branch with a forged transaction to be refused")
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != b2.Hash {
+		t.Errorf("Expected B2 to stay at the tip, got block %s", tip.Hash)
+	}
+	if balance := binomToken.GetBalance(producers[0].Address); balance != 0 {
+		t.Errorf("Expected the forged transfer to move nothing, got %s", balance)
+	}
+
+	// Branches that do not fork off the chain are refused
+	orphan := signedBlock(t, core.Block{Index: 5, Hash: "unknown"}, producers[0], []core.Transaction{})
+	if _, err := core.AdoptBranch(blockchain, []core.Block{orphan}); err == nil {
+		t.Error("Expected a branch with an unknown parent to be refused")
+	}
+}
+
+func TestAdoptBranchFile(t *testing.T) {
+	checkAdoptBranch(t, core.NewBlockchain(), token.NewBinomToken())
+}
+
+func TestAdoptBranchKV(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	checkAdoptBranch(t, core.NewBlockchainWithKV(), token.NewBinomTokenWithKV())
+}
+
+func TestAdoptBranchSQLite(t *testing.T) {
+	connectSQLite(t)
+	checkAdoptBranch(t, core.NewBlockchainWithDB(), token.NewBinomTokenWithDB())
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/snapshot"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// fileSnapshotSource builds a file-backed node with a contract and contract storage
+// and a block settling a transfer, whose state root its producer computed
+func fileSnapshotSource(t *testing.T) snapshot.Stores {
+	contractDir := t.TempDir()
+	contractStorage, _ := smartcontract.NewContractStorage(contractDir)
+	contractState, _ := smartcontract.NewContractState(contractDir)
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	dpos := consensus.NewDPoSConsensus("AdNe-founder", "AdNe-community")
+	stores := snapshot.Stores{
+		Blockchain:    blockchain,
+		Token:         binomToken,
+		Consensus:     dpos,
+		Contracts:     contractStorage,
+		ContractState: contractState,
+	}
+	applier := core.NewTokenStateApplier(binomToken, dpos)
+	applier.SetStateProviders(dpos, contractState)
+	blockchain.SetStateApplier(applier)
+
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(100))
+
+	contract := &smartcontract.Contract{ID: "AdNeSnapshotContract", Owner: alice.Address, Name: "counter", Code: []byte{0, 'a', 's', 'm'}}
+	if err := contractStorage.SaveContract(contract); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+	contractState.SetState(contract.ID, "count", 7)
+
+	// Produce the block as a node does: execute its transactions, then commit to
+	// the state they leave behind
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(40), 0, alice)
+	parent := blockchain.GetLastBlock()
+	execution := applier.ExecuteTransactions([]core.Transaction{*tx}, parent.Index+1)
+	stateRoot, err := applier.StateRoot(execution, parent.Index+1)
+	if err != nil {
+		t.Fatalf("Failed to calculate state root: %v", err)
+	}
+	block := core.Block{
+		Index:        parent.Index + 1,
+		PreviousHash: parent.Hash,
+		Timestamp:    parent.Timestamp + 1,
+		Data:         execution.Transactions,
+		Version:      core.CurrentBlockVersion,
+		MerkleRoot:   core.CalculateMerkleRoot(execution.Transactions),
+		StateRoot:    stateRoot,
+	}
+	if err := core.SignBlock(&block, producer); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// The applied block leaves the live state at its root
+	if err := core.CheckStateRoot(block, binomToken, dpos, contractState); err != nil {
+		t.Fatalf("Expected the live state to match the produced state root: %v", err)
+	}
+	return stores
+}
+
+func TestSnapshotExportToSQLite(t *testing.T) {
+	source := fileSnapshotSource(t)
+	tip := source.Blockchain.GetLastBlock()
+
+	snap, err := snapshot.Take(source)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := snapshot.Write(&archive, snap); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	read, err := snapshot.Read(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if err := read.CheckState(); err != nil {
+		t.Errorf("Expected the snapshot state to match the tip's state root: %v", err)
+	}
+
+	// Import into a database node, whose contracts are replaced
+	connectSQLite(t)
+	contractStorage, _ := smartcontract.NewContractStorageWithDB()
+	contractState, _ := smartcontract.NewContractStateWithDB()
+	stale := &smartcontract.Contract{ID: "AdNeStaleContract", Owner: "AdNe-owner", Name: "stale", Code: []byte{0, 'a', 's', 'm'}}
+	if err := contractStorage.SaveContract(stale); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+	contractState.SetState(stale.ID, "count", 1)
+	target := snapshot.Stores{
+		Blockchain:    core.NewBlockchainWithDB(),
+		Token:         token.NewBinomTokenWithDB(),
+		Consensus:     consensus.NewDPoSConsensus("AdNe-founder", "AdNe-community"),
+		Contracts:     contractStorage,
+		ContractState: contractState,
+	}
+	if err := snapshot.Restore(target, read); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	if last := target.Blockchain.GetLastBlock(); last.Hash != tip.Hash {
+		t.Errorf("Expected tip %s after import, got %s", tip.Hash, last.Hash)
+	}
+	if err := core.CheckStateRoot(tip, target.Token.(*token.BinomTokenDB), target.Consensus, contractState); err != nil {
+		t.Errorf("Expected the imported state to match the tip's state root: %v", err)
+	}
+	for address, nonce := range snap.Token.Nonces {
+		if got := target.Token.(*token.BinomTokenDB).GetNonce(address); got != nonce {
+			t.Errorf("Expected nonce %d for %s, got %d", nonce, address, got)
+		}
+	}
+	contract, err := contractStorage.LoadContract("AdNeSnapshotContract")
+	if err != nil || string(contract.Code) != "\x00asm" {
+		t.Errorf("Expected the contract to be imported, got %v (%v)", contract, err)
+	}
+	if _, err := contractStorage.LoadContract(stale.ID); err == nil {
+		t.Error("Expected a contract missing from the snapshot to be removed")
+	}
+}
+
+// failingContractState refuses to store contract state
+type failingContractState struct {
+	*smartcontract.ContractState
+}
+
+func (failingContractState) SetState(contractID string, key string, value interface{}) error {
+	return errors.New("disk full")
+}
+
+func TestSnapshotRestoreIsAllOrNothing(t *testing.T) {
+	snap, err := snapshot.Take(fileSnapshotSource(t))
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+
+	// A node with state of its own, whose contract storage fails to import
+	contractDir := t.TempDir()
+	contractStorage, _ := smartcontract.NewContractStorage(contractDir)
+	contractState, _ := smartcontract.NewContractState(contractDir)
+	target := snapshot.Stores{
+		Blockchain:    core.NewBlockchain(),
+		Token:         token.NewBinomToken(),
+		Consensus:     consensus.NewDPoSConsensus("AdNe-founder", "AdNe-community"),
+		Contracts:     contractStorage,
+		ContractState: failingContractState{contractState},
+	}
+	own := &smartcontract.Contract{ID: "AdNeOwnContract", Owner: "AdNe-owner", Name: "own", Code: []byte{0, 'a', 's', 'm'}}
+	if err := contractStorage.SaveContract(own); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+	target.Token.(*token.BinomToken).Transfer("treasury", "AdNe-holder", bnm.FromBNM(5))
+	genesis := target.Blockchain.GetLastBlock()
+	before, _ := target.Token.ExportState()
+
+	if err := snapshot.Restore(target, snap); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Expected the import to fail on contract state, got %v", err)
+	}
+
+	// Everything imported before the failure is rolled back
+	if tip := target.Blockchain.GetLastBlock(); tip.Hash != genesis.Hash {
+		t.Errorf("Expected the chain to be rolled back to genesis, got block %s", tip.Hash)
+	}
+	if after, _ := target.Token.ExportState(); after.Balances["AdNe-holder"] != before.Balances["AdNe-holder"] || after.CirculatingSupply != before.CirculatingSupply {
+		t.Errorf("Expected balances to be rolled back, got %+v", after)
+	}
+	contracts, _ := contractStorage.LoadAllContracts()
+	if len(contracts) != 1 || contracts[0].ID != own.ID {
+		t.Errorf("Expected only the node's own contract after rollback, got %d contracts", len(contracts))
+	}
+}
+
+func TestSnapshotRejectsTampering(t *testing.T) {
+	source := fileSnapshotSource(t)
+	snap, err := snapshot.Take(source)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+
+	// A changed transaction amount breaks the Merkle root and block hash
+	snap.Blocks[1].Data[0].Amount = bnm.FromBNM(1)
+	var archive bytes.Buffer
+	if err := snapshot.Write(&archive, snap); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	read, err := snapshot.Read(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if err := read.Verify(); err == nil {
+		t.Error("Expected a tampered block to fail verification")
+	}
+
+	// Entries that do not match the manifest checksums are refused on read
+	snap.Blocks[1].Data[0].Amount = bnm.FromBNM(40)
+	archive.Reset()
+	snapshot.Write(&archive, snap)
+	tampered := tamperArchiveEntry(t, archive.Bytes(), "token.json")
+	if _, err := snapshot.Read(bytes.NewReader(tampered)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+// tamperArchiveEntry rewrites one entry of a snapshot archive without updating
+// the manifest
+func tamperArchiveEntry(t *testing.T, archive []byte, name string) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	var out bytes.Buffer
+	gzOut := gzip.NewWriter(&out)
+	tarOut := tar.NewWriter(gzOut)
+	tarIn := tar.NewReader(gz)
+	for {
+		header, err := tarIn.Next()
+		if err == io.EOF {
+			break
+		}
+		data, _ := io.ReadAll(tarIn)
+		if header.Name == name {
+			data = bytes.Replace(data, []byte("treasury"), []byte("attacker"), 1)
+		}
+		header.Size = int64(len(data))
+		tarOut.WriteHeader(header)
+		tarOut.Write(data)
+	}
+	tarOut.Close()
+	gzOut.Close()
+	return out.Bytes()
+}
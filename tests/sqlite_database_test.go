@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/igo-used/binomena/audit"
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/database"
+	"github.com/igo-used/binomena/smartcontract"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// connectSQLite runs the database mode against a fresh SQLite file
+func connectSQLite(t *testing.T) {
+	t.Setenv("DATABASE_URL", "sqlite://"+filepath.Join(t.TempDir(), "binomena.db"))
+	if err := database.ConnectDatabase(); err != nil {
+		t.Fatalf("Failed to connect to SQLite: %v", err)
+	}
+	t.Cleanup(func() {
+		database.CloseDatabase()
+		database.DB = nil
+	})
+
+	if err := database.MigrateDatabase(); err != nil {
+		t.Fatalf("Failed to migrate SQLite database: %v", err)
+	}
+	if err := database.InitializeSystemState(); err != nil {
+		t.Fatalf("Failed to initialize system state: %v", err)
+	}
+}
+
+func TestSQLiteBlockchainAndToken(t *testing.T) {
+	connectSQLite(t)
+	blockchain := core.NewBlockchainWithDB()
+	binomToken := token.NewBinomTokenWithDB()
+
+	sender, _ := wallet.NewWallet()
+	receiver, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	tx, _ := core.NewTransaction(sender.Address, receiver.Address, bnm.FromBNM(10), 0, sender)
+	if err := blockchain.AddTransaction(*tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	block := signedBlock(t, blockchain.GetLastBlock(), producer, blockchain.GetPendingTransactions())
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if blockchain.GetBlockCount() != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", blockchain.GetBlockCount())
+	}
+	stored, err := blockchain.GetBlockByIndex(1)
+	if err != nil || stored.Hash != block.Hash || len(stored.Data) != 1 {
+		t.Fatalf("Expected block 1 with its transaction to round-trip, got %+v (%v)", stored, err)
+	}
+
+	// Amounts with more digits than a float holds keep every base unit
+	amount, _ := bnm.Parse("123456789.12345678")
+	if err := binomToken.Transfer("treasury", sender.Address, amount); err != nil {
+		t.Fatalf("Failed to transfer: %v", err)
+	}
+	if balance := binomToken.GetBalance(sender.Address); balance != amount {
+		t.Errorf("Expected balance %s, got %s", amount, balance)
+	}
+	if err := useNonce(binomToken, sender.Address, 0); err != nil {
+		t.Fatalf("Failed to use nonce: %v", err)
+	}
+	if nonce := binomToken.GetNonce(sender.Address); nonce != 1 {
+		t.Errorf("Expected next nonce 1, got %d", nonce)
+	}
+
+	// Replacing the chain rewrites blocks and transactions on SQLite too
+	genesis, _ := blockchain.GetBlockByIndex(0)
+	if err := blockchain.ReplaceChain([]core.Block{genesis}); err != nil {
+		t.Fatalf("Failed to replace chain: %v", err)
+	}
+	if blockchain.GetBlockCount() != 1 {
+		t.Errorf("Expected 1 block after replacement, got %d", blockchain.GetBlockCount())
+	}
+}
+
+func TestSQLiteChainSettlesBlocks(t *testing.T) {
+	connectSQLite(t)
+	blockchain := core.NewBlockchainWithDB()
+	binomToken := token.NewBinomTokenWithDB()
+	blockchain.SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+	supply := binomToken.GetCirculatingSupply()
+
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	block := newBranchState(binomToken, nil).block(t, genesis, producer, []core.Transaction{*tx})
+	if err := blockchain.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected bob's balance to be 100 BNM, got %s", balance)
+	}
+	if burned := supply - binomToken.GetCirculatingSupply(); burned != tx.CalculateFee() {
+		t.Errorf("Expected the fee to be burned, got %s", burned)
+	}
+
+	// Dropping the block reverts its recorded changes
+	if err := blockchain.ReplaceChain([]core.Block{genesis}); err != nil {
+		t.Fatalf("Failed to replace chain: %v", err)
+	}
+	if balance := binomToken.GetBalance(alice.Address); balance != bnm.FromBNM(1000) {
+		t.Errorf("Expected alice's balance to be restored, got %s", balance)
+	}
+	if nonce := binomToken.GetNonce(alice.Address); nonce != 0 {
+		t.Errorf("Expected alice's nonce to be restored, got %d", nonce)
+	}
+	if binomToken.GetCirculatingSupply() != supply {
+		t.Errorf("Expected the burned fee to return to the supply")
+	}
+}
+
+func TestSQLiteContractsAuditAndDelegates(t *testing.T) {
+	connectSQLite(t)
+
+	storage, _ := smartcontract.NewContractStorageWithDB()
+	contract := &smartcontract.Contract{ID: "AdNe-contract", Owner: "AdNe-owner", Name: "test", Code: []byte{0, 'a', 's', 'm'}}
+	if err := storage.SaveContract(contract); err != nil {
+		t.Fatalf("Failed to save contract: %v", err)
+	}
+	loaded, err := storage.LoadContract(contract.ID)
+	if err != nil || string(loaded.Code) != string(contract.Code) {
+		t.Fatalf("Expected contract code to round-trip, got %v (%v)", loaded, err)
+	}
+
+	state, _ := smartcontract.NewContractStateWithDB()
+	if err := state.SetState("AdNeState", "counter", 1); err != nil {
+		t.Fatalf("Failed to set contract state: %v", err)
+	}
+	entries, err := state.StateEntries()
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected 1 contract state entry, got %d (%v)", len(entries), err)
+	}
+
+	auditService := audit.NewAuditServiceWithDB(nil)
+	auditService.LogEvent(audit.WarningLevel, "Test", "stored as JSON", map[string]int{"height": 1})
+	if events := auditService.GetEvents(); len(events) != 1 {
+		t.Errorf("Expected 1 audit event, got %d", len(events))
+	}
+
+	dpos := consensus.NewDPoSConsensus("AdNe-founder", "AdNe-community")
+	if err := dpos.RegisterDelegate("AdNe-delegate", bnm.FromBNM(10000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+	if delegates := consensus.NewDPoSConsensus("AdNe-founder", "AdNe-community").GetDelegates(); len(delegates) != 1 {
+		t.Errorf("Expected the delegate to be reloaded, got %d", len(delegates))
+	}
+}
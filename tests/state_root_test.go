@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/token"
+	"github.com/igo-used/binomena/wallet"
+)
+
+func TestStateRootTracksBalancesAndStakes(t *testing.T) {
+	// Two nodes applying the same changes agree on the state root
+	nodeState := func() (*token.BinomToken, *consensus.DPoSConsensus) {
+		binomToken := token.NewBinomToken()
+		dpos := consensus.NewDPoSConsensus("founder", "community")
+		if err := binomToken.Transfer("treasury", "alice", bnm.FromBNM(1000)); err != nil {
+			t.Fatalf("Failed to fund alice: %v", err)
+		}
+		return binomToken, dpos
+	}
+	tokenA, dposA := nodeState()
+	tokenB, dposB := nodeState()
+
+	rootA, err := core.CalculateStateRoot(tokenA, dposA)
+	if err != nil {
+		t.Fatalf("Failed to calculate state root: %v", err)
+	}
+	if rootB, _ := core.CalculateStateRoot(tokenB, dposB); rootA != rootB {
+		t.Fatalf("Expected identical state to give identical roots, got %s and %s", rootA, rootB)
+	}
+
+	// A diverging balance is detected
+	if err := tokenB.Transfer("alice", "bob", bnm.FromBNM(1)); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if rootB, _ := core.CalculateStateRoot(tokenB, dposB); rootA == rootB {
+		t.Error("Expected a balance change to change the state root")
+	}
+
+	// A new delegate stake is detected
+	if err := dposA.RegisterDelegate("delegate", bnm.FromBNM(5000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+	if root, _ := core.CalculateStateRoot(tokenA, dposA); root == rootA {
+		t.Error("Expected a delegate stake to change the state root")
+	}
+}
+
+func TestProducedStateRootMatchesAppliedBlock(t *testing.T) {
+	// The producer and a peer start from the same state
+	nodeState := func() (*core.Blockchain, *token.BinomToken, *consensus.DPoSConsensus, *core.TokenStateApplier) {
+		blockchain := core.NewBlockchain()
+		binomToken := token.NewBinomToken()
+		dpos := consensus.NewDPoSConsensus("founder", "community")
+		applier := core.NewTokenStateApplier(binomToken, dpos)
+		applier.SetStateProviders(dpos)
+		blockchain.SetStateApplier(applier)
+		dpos.SetChain(blockchain)
+		return blockchain, binomToken, dpos, applier
+	}
+	producerChain, producerToken, producerDPoS, producer := nodeState()
+	peerChain, peerToken, peerDPoS, _ := nodeState()
+
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	signer, _ := wallet.NewWallet()
+	for _, binomToken := range []*token.BinomToken{producerToken, peerToken} {
+		if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(100)); err != nil {
+			t.Fatalf("Failed to fund alice: %v", err)
+		}
+	}
+
+	// The producer commits to the state its transactions leave behind, without
+	// having applied them yet
+	tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(40), 0, alice)
+	parent := producerChain.GetLastBlock()
+	execution := producer.ExecuteTransactions([]core.Transaction{*tx}, parent.Index+1)
+	stateRoot, err := producer.StateRoot(execution, parent.Index+1)
+	if err != nil {
+		t.Fatalf("Failed to calculate state root: %v", err)
+	}
+	block := core.Block{
+		Index:        parent.Index + 1,
+		PreviousHash: parent.Hash,
+		Timestamp:    parent.Timestamp + 1,
+		Data:         execution.Transactions,
+		Version:      core.CurrentBlockVersion,
+		MerkleRoot:   core.CalculateMerkleRoot(execution.Transactions),
+		StateRoot:    stateRoot,
+	}
+	if err := core.SignBlock(&block, signer); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := core.CheckStateRoot(block, producerToken, producerDPoS); err == nil {
+		t.Fatal("Expected the root to differ from the state before the block")
+	}
+
+	// Both nodes applying the block reach the committed state
+	if err := producerChain.AddBlock(block); err != nil {
+		t.Fatalf("Producer failed to add block: %v", err)
+	}
+	if err := peerChain.AddBlock(block); err != nil {
+		t.Fatalf("Peer failed to add block: %v", err)
+	}
+	if err := core.CheckStateRoot(block, producerToken, producerDPoS); err != nil {
+		t.Errorf("Expected the producer's state to match the block: %v", err)
+	}
+	if err := core.CheckStateRoot(block, peerToken, peerDPoS); err != nil {
+		t.Errorf("Expected the peer's state to match the block: %v", err)
+	}
+
+	// Delegates registered later take effect at a later height, leaving the
+	// block's root intact
+	if err := peerDPoS.RegisterDelegate("delegate", bnm.FromBNM(5000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+	if err := core.CheckStateRoot(block, peerToken, peerDPoS); err != nil {
+		t.Errorf("Expected a later delegate change to leave the block's root intact: %v", err)
+	}
+}
+
+func TestBlocksMustMatchTheirStateRoot(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	binomToken := token.NewBinomToken()
+	blockchain.SetStateApplier(core.NewTokenStateApplier(binomToken, nil))
+
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	carol, _ := wallet.NewWallet()
+	if err := binomToken.Transfer("treasury", alice.Address, bnm.FromBNM(1000)); err != nil {
+		t.Fatalf("Failed to fund alice: %v", err)
+	}
+
+	toBob, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(100), 0, alice)
+	toCarol, _ := core.NewTransaction(alice.Address, carol.Address, bnm.FromBNM(50), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	a1 := newBranchState(binomToken, nil).block(t, genesis, producers[0], []core.Transaction{*toBob})
+	branchB := newBranchState(binomToken, nil)
+	b1 := branchB.block(t, genesis, producers[1], []core.Transaction{*toCarol})
+	b2 := branchB.block(t, b1, producers[2], []core.Transaction{})
+
+	// A block committing to another state is rejected without settling
+	wrongRoot := a1
+	wrongRoot.StateRoot = b1.StateRoot
+	if err := core.SignBlock(&wrongRoot, producers[0]); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := blockchain.AddBlock(wrongRoot); err == nil {
+		t.Error("Expected a block with the wrong state root to be rejected")
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != 0 {
+		t.Errorf("Expected the rejected block to leave bob's balance alone, got %s", balance)
+	}
+
+	if err := blockchain.AddBlock(a1); err != nil {
+		t.Fatalf("Failed to add block A1: %v", err)
+	}
+	if err := blockchain.AddBlock(b1); err != nil {
+		t.Fatalf("Failed to add block B1: %v", err)
+	}
+
+	// A preferred branch whose last block commits to another state is rolled back
+	forgedB2 := b2
+	forgedB2.StateRoot = a1.StateRoot
+	if err := core.SignBlock(&forgedB2, producers[2]); err != nil {
+		t.Fatalf("Failed to sign block: %v", err)
+	}
+	if err := blockchain.AddBlock(forgedB2); err == nil {
+		t.Error("Expected the reorganization onto a block with the wrong state root to fail")
+	}
+	if tip := blockchain.GetLastBlock(); tip.Hash != a1.Hash {
+		t.Errorf("Expected A1 to stay at the tip, got block %s", tip.Hash)
+	}
+	if balance := binomToken.GetBalance(bob.Address); balance != bnm.FromBNM(100) {
+		t.Errorf("Expected bob's balance to be restored to 100 BNM, got %s", balance)
+	}
+	if balance := binomToken.GetBalance(carol.Address); balance != 0 {
+		t.Errorf("Expected carol's balance to be rolled back, got %s", balance)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/consensus"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/token"
+)
+
+// TestSupplyConservation runs transfers and fee distributions and checks that no
+// base unit is created or lost along the way
+func TestSupplyConservation(t *testing.T) {
+	binomToken := token.NewBinomToken()
+	maxSupply := binomToken.GetCirculatingSupply()
+
+	dpos := consensus.NewDPoSConsensus("founder", "community")
+	for _, delegate := range []string{"delegate1", "delegate2"} {
+		if err := dpos.RegisterDelegate(delegate, bnm.FromBNM(5000)); err != nil {
+			t.Fatalf("Failed to register delegate: %v", err)
+		}
+	}
+
+	addresses := []string{"treasury", "founder", "community", "delegate1", "delegate2", "alice", "bob", "carol"}
+	users := addresses[5:]
+	for _, user := range users {
+		if err := binomToken.Transfer("treasury", user, bnm.FromBNM(1000)); err != nil {
+			t.Fatalf("Failed to fund %s: %v", user, err)
+		}
+	}
+
+	// Odd amounts make every 0.1% fee and 60/30/5/5 split round
+	totalBurned := bnm.Amount(0)
+	for i := 0; i < 500; i++ {
+		from := users[i%len(users)]
+		to := users[(i+1)%len(users)]
+		tx := core.Transaction{From: from, To: to, Amount: bnm.Amount(123456789 + i*7919)}
+		fee := tx.CalculateFee()
+
+		if err := binomToken.Transfer(from, to, tx.Amount); err != nil {
+			t.Fatalf("Transfer %d failed: %v", i, err)
+		}
+		if err := binomToken.Transfer(from, "treasury", fee); err != nil {
+			t.Fatalf("Fee payment %d failed: %v", i, err)
+		}
+
+		treasuryBefore := binomToken.GetBalance("treasury")
+		supplyBefore := binomToken.GetCirculatingSupply()
+		distribution, err := dpos.DistributeFees(fee, binomToken)
+		if err != nil {
+			t.Fatalf("Fee distribution %d failed: %v", i, err)
+		}
+		burned := supplyBefore - binomToken.GetCirculatingSupply()
+		if paidOut := treasuryBefore - binomToken.GetBalance("treasury"); paidOut+burned != fee {
+			t.Fatalf("Fee %s split into %s paid out and %s burned", fee, paidOut, burned)
+		}
+		if burned != distribution.Burned || distribution.Delegates+distribution.Community+distribution.Founder+burned != fee {
+			t.Fatalf("Fee %s distribution %+v does not match what was paid", fee, distribution)
+		}
+		totalBurned += burned
+	}
+
+	total := bnm.Amount(0)
+	for _, address := range addresses {
+		total += binomToken.GetBalance(address)
+	}
+	if total != maxSupply {
+		t.Errorf("Expected balances to sum to %s, got %s", maxSupply, total)
+	}
+	if totalBurned == 0 {
+		t.Error("Expected fees to be burned")
+	}
+	if supply := binomToken.GetCirculatingSupply(); supply != maxSupply-totalBurned {
+		t.Errorf("Expected circulating supply %s, got %s", maxSupply-totalBurned, supply)
+	}
+}
+
+// TestFeeDistributionIsAtomic checks that a fee distribution the treasury cannot
+// pay moves nothing
+func TestFeeDistributionIsAtomic(t *testing.T) {
+	binomToken := token.NewBinomToken()
+	dpos := consensus.NewDPoSConsensus("founder", "community")
+	if err := dpos.RegisterDelegate("delegate1", bnm.FromBNM(5000)); err != nil {
+		t.Fatalf("Failed to register delegate: %v", err)
+	}
+
+	// Empty the treasury so only the burn, which needs no balance, could succeed
+	treasury := binomToken.GetBalance("treasury")
+	if err := binomToken.Transfer("treasury", "alice", treasury); err != nil {
+		t.Fatalf("Failed to empty the treasury: %v", err)
+	}
+	supply := binomToken.GetCirculatingSupply()
+
+	if _, err := dpos.DistributeFees(bnm.FromBNM(10), binomToken); err == nil {
+		t.Fatal("Expected a distribution the treasury cannot pay to fail")
+	}
+	for _, address := range []string{"founder", "community", "delegate1"} {
+		if balance := binomToken.GetBalance(address); balance != 0 {
+			t.Errorf("Expected %s to be paid nothing, got %s", address, balance)
+		}
+	}
+	if binomToken.GetCirculatingSupply() != supply {
+		t.Error("Expected nothing to be burned")
+	}
+}
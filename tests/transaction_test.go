@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/igo-used/binomena/bnm"
 	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/wallet"
 )
@@ -22,7 +23,7 @@ func TestTransactionCreation(t *testing.T) {
 	}
 	
 	// Create transaction
-	tx, err := core.NewTransaction(senderWallet.Address, receiverWallet.Address, 100.0, senderWallet)
+	tx, err := core.NewTransaction(senderWallet.Address, receiverWallet.Address, bnm.FromBNM(100), 0, senderWallet)
 	if err != nil {
 		t.Fatalf("Failed to create transaction: %v", err)
 	}
@@ -41,15 +42,15 @@ func TestTransactionCreation(t *testing.T) {
 		t.Errorf("Expected receiver address to be %s, got %s", receiverWallet.Address, tx.To)
 	}
 	
-	if tx.Amount != 100.0 {
-		t.Errorf("Expected amount to be 100.0, got %f", tx.Amount)
+	if tx.Amount != bnm.FromBNM(100) {
+		t.Errorf("Expected amount to be 100, got %s", tx.Amount)
 	}
 	
 	// Check transaction fee
 	fee := tx.CalculateFee()
-	expectedFee := 0.1 // 0.1% of 100.0
+	expectedFee := bnm.MustParse("0.1") // 0.1% of 100
 	if fee != expectedFee {
-		t.Errorf("Expected fee to be %f, got %f", expectedFee, fee)
+		t.Errorf("Expected fee to be %s, got %s", expectedFee, fee)
 	}
 }
 
@@ -67,7 +68,7 @@ func TestTransactionVerification(t *testing.T) {
 	}
 	
 	// Create transaction
-	tx, err := core.NewTransaction(senderWallet.Address, receiverWallet.Address, 100.0, senderWallet)
+	tx, err := core.NewTransaction(senderWallet.Address, receiverWallet.Address, bnm.FromBNM(100), 0, senderWallet)
 	if err != nil {
 		t.Fatalf("Failed to create transaction: %v", err)
 	}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/wallet"
+)
+
+// checkTransactionIndex adds blocks between two wallets and checks lookups by ID,
+// address history pagination and direction filters
+func checkTransactionIndex(t *testing.T, blockchain core.BlockchainInterface) {
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producer, _ := wallet.NewWallet()
+
+	// Alice sends three transactions and receives one, over two blocks
+	var sent []*core.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(int64(nonce+1)), nonce, alice)
+		sent = append(sent, tx)
+	}
+	received, _ := core.NewTransaction(bob.Address, alice.Address, bnm.FromBNM(5), 0, bob)
+
+	first := signedBlock(t, blockchain.GetLastBlock(), producer, []core.Transaction{*sent[0], *sent[1]})
+	if err := blockchain.AddBlock(first); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	second := signedBlock(t, first, producer, []core.Transaction{*received, *sent[2]})
+	if err := blockchain.AddBlock(second); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	tx, err := blockchain.GetTransaction(sent[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to get transaction: %v", err)
+	}
+	if tx.BlockIndex != first.Index || tx.BlockHash != first.Hash || tx.Position != 1 || tx.Amount != sent[1].Amount {
+		t.Errorf("Unexpected indexed transaction: %+v", tx)
+	}
+	if _, err := blockchain.GetTransaction("AdNe-missing"); err != core.ErrTransactionNotFound {
+		t.Errorf("Expected ErrTransactionNotFound, got %v", err)
+	}
+
+	// History is newest first and paginated
+	page, total, err := blockchain.GetAddressTransactions(alice.Address, core.TxQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to get address transactions: %v", err)
+	}
+	if total != 4 || len(page) != 2 || page[0].ID != sent[2].ID || page[1].ID != received.ID {
+		t.Fatalf("Unexpected first page (total %d): %+v", total, page)
+	}
+	page, _, _ = blockchain.GetAddressTransactions(alice.Address, core.TxQuery{Offset: 2, Limit: 2})
+	if len(page) != 2 || page[0].ID != sent[1].ID || page[1].ID != sent[0].ID {
+		t.Errorf("Unexpected second page: %+v", page)
+	}
+
+	page, total, _ = blockchain.GetAddressTransactions(alice.Address, core.TxQuery{Direction: core.TxDirectionSent})
+	if total != 3 || len(page) != 3 {
+		t.Errorf("Expected 3 sent transactions, got %d", total)
+	}
+	page, total, _ = blockchain.GetAddressTransactions(alice.Address, core.TxQuery{Direction: core.TxDirectionReceived})
+	if total != 1 || len(page) != 1 || page[0].ID != received.ID {
+		t.Errorf("Expected 1 received transaction, got %d", total)
+	}
+}
+
+func TestTransactionIndexFile(t *testing.T) {
+	checkTransactionIndex(t, core.NewBlockchain())
+}
+
+func TestTransactionIndexKV(t *testing.T) {
+	openKV(t, filepath.Join(t.TempDir(), "chain.db"))
+	checkTransactionIndex(t, core.NewBlockchainWithKV())
+}
+
+func TestTransactionIndexSQLite(t *testing.T) {
+	connectSQLite(t)
+	checkTransactionIndex(t, core.NewBlockchainWithDB())
+}
+
+func TestTransactionIndexFollowsReorganization(t *testing.T) {
+	blockchain := core.NewBlockchain()
+	alice, _ := wallet.NewWallet()
+	bob, _ := wallet.NewWallet()
+	producers := make([]*wallet.Wallet, 3)
+	for i := range producers {
+		producers[i], _ = wallet.NewWallet()
+	}
+
+	orphaned, _ := core.NewTransaction(alice.Address, bob.Address, bnm.FromBNM(1), 0, alice)
+	genesis := blockchain.GetLastBlock()
+	a1 := signedBlock(t, genesis, producers[0], []core.Transaction{*orphaned})
+	if err := blockchain.AddBlock(a1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// A longer branch without the transaction takes over
+	b1 := signedBlock(t, genesis, producers[1], []core.Transaction{})
+	b2 := signedBlock(t, b1, producers[2], []core.Transaction{})
+	for _, block := range []core.Block{b1, b2} {
+		if err := blockchain.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	if _, err := blockchain.GetTransaction(orphaned.ID); err != core.ErrTransactionNotFound {
+		t.Errorf("Expected orphaned transaction to leave the index, got %v", err)
+	}
+	if _, total, _ := blockchain.GetAddressTransactions(alice.Address, core.TxQuery{}); total != 0 {
+		t.Errorf("Expected no history for alice after the reorganization, got %d", total)
+	}
+}
@@ -6,24 +6,31 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
 )
 
 // BinomToken represents the native Binom (BNM) token
 type BinomToken struct {
-	maxSupply         float64
-	circulatingSupply float64
-	balances          map[string]float64
+	maxSupply         bnm.Amount
+	circulatingSupply bnm.Amount
+	balances          map[string]bnm.Amount
+	nonces            map[string]uint64
+	blockChanges      map[string][]core.StateChange // State changes applied by each canonical block
 	mu                sync.RWMutex
 }
 
 // NewBinomToken creates a new Binom token with 1 billion max supply
 func NewBinomToken() *BinomToken {
-	maxSupply := 1000000000.0 // 1 billion
+	maxSupply := bnm.FromBNM(1000000000) // 1 billion
 
 	token := &BinomToken{
 		maxSupply:         maxSupply,
 		circulatingSupply: maxSupply,
-		balances:          make(map[string]float64),
+		balances:          make(map[string]bnm.Amount),
+		nonces:            make(map[string]uint64),
+		blockChanges:      make(map[string][]core.StateChange),
 	}
 
 	// Allocate initial supply to treasury
@@ -33,7 +40,7 @@ func NewBinomToken() *BinomToken {
 }
 
 // Transfer transfers tokens from one address to another
-func (bt *BinomToken) Transfer(from, to string, amount float64) error {
+func (bt *BinomToken) Transfer(from, to string, amount bnm.Amount) error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
@@ -50,7 +57,7 @@ func (bt *BinomToken) Transfer(from, to string, amount float64) error {
 }
 
 // GetBalance returns the balance of an address
-func (bt *BinomToken) GetBalance(address string) float64 {
+func (bt *BinomToken) GetBalance(address string) bnm.Amount {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
@@ -58,26 +65,236 @@ func (bt *BinomToken) GetBalance(address string) float64 {
 }
 
 // GetCirculatingSupply returns the circulating supply of tokens
-func (bt *BinomToken) GetCirculatingSupply() float64 {
+func (bt *BinomToken) GetCirculatingSupply() bnm.Amount {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
 	return bt.circulatingSupply
 }
 
+// GetNonce returns the next transaction nonce expected from an address
+func (bt *BinomToken) GetNonce(address string) uint64 {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	return bt.nonces[address]
+}
+
+// StateEntries returns the non-zero balances committed to by the state root
+func (bt *BinomToken) StateEntries() (map[string][]byte, error) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	return State{Balances: bt.balances}.StateEntries()
+}
+
+// ExportState returns a copy of every balance, nonce and the circulating supply
+func (bt *BinomToken) ExportState() (State, error) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	state := State{
+		CirculatingSupply: bt.circulatingSupply,
+		Balances:          make(map[string]bnm.Amount, len(bt.balances)),
+		Nonces:            make(map[string]uint64, len(bt.nonces)),
+	}
+	for address, balance := range bt.balances {
+		state.Balances[address] = balance
+	}
+	for address, nonce := range bt.nonces {
+		state.Nonces[address] = nonce
+	}
+	return state, nil
+}
+
+// ImportState replaces every balance, nonce and the circulating supply. The state
+// changes recorded for blocks are forgotten, as they no longer lead to this state.
+func (bt *BinomToken) ImportState(state State) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if state.CirculatingSupply > bt.maxSupply {
+		return fmt.Errorf("circulating supply %s exceeds max supply", state.CirculatingSupply)
+	}
+
+	bt.circulatingSupply = state.CirculatingSupply
+	bt.balances = make(map[string]bnm.Amount, len(state.Balances))
+	bt.nonces = make(map[string]uint64, len(state.Nonces))
+	for address, balance := range state.Balances {
+		bt.balances[address] = balance
+	}
+	for address, nonce := range state.Nonces {
+		bt.nonces[address] = nonce
+	}
+	bt.blockChanges = make(map[string][]core.StateChange)
+	return nil
+}
+
+// memoryBatch collects changes to the token's maps, which are only written back
+// once every change of the batch applies
+type memoryBatch struct {
+	bt       *BinomToken
+	balances map[string]bnm.Amount
+	nonces   map[string]uint64
+	supply   *bnm.Amount
+}
+
+// Balance returns the balance of an address as changed by the batch
+func (b *memoryBatch) Balance(address string) (bnm.Amount, error) {
+	if balance, ok := b.balances[address]; ok {
+		return balance, nil
+	}
+	return b.bt.balances[address], nil
+}
+
+// SetBalance changes the balance of an address in the batch
+func (b *memoryBatch) SetBalance(address string, balance bnm.Amount) error {
+	b.balances[address] = balance
+	return nil
+}
+
+// Nonce returns the next nonce of an address as changed by the batch
+func (b *memoryBatch) Nonce(address string) (uint64, error) {
+	if nonce, ok := b.nonces[address]; ok {
+		return nonce, nil
+	}
+	return b.bt.nonces[address], nil
+}
+
+// SetNonce changes the next nonce of an address in the batch
+func (b *memoryBatch) SetNonce(address string, nonce uint64) error {
+	b.nonces[address] = nonce
+	return nil
+}
+
+// Supply returns the circulating supply as changed by the batch
+func (b *memoryBatch) Supply() (bnm.Amount, error) {
+	if b.supply != nil {
+		return *b.supply, nil
+	}
+	return b.bt.circulatingSupply, nil
+}
+
+// SetSupply changes the circulating supply in the batch, up to the max supply
+func (b *memoryBatch) SetSupply(supply bnm.Amount) error {
+	if supply > b.bt.maxSupply {
+		return fmt.Errorf("circulating supply would exceed max supply")
+	}
+	b.supply = &supply
+	return nil
+}
+
+// commit writes the batch back to the token. Callers must hold bt.mu.
+func (b *memoryBatch) commit() {
+	if b.bt.nonces == nil {
+		b.bt.nonces = make(map[string]uint64)
+	}
+	for address, balance := range b.balances {
+		b.bt.balances[address] = balance
+	}
+	for address, nonce := range b.nonces {
+		b.bt.nonces[address] = nonce
+	}
+	if b.supply != nil {
+		b.bt.circulatingSupply = *b.supply
+	}
+}
+
+// settle applies or reverts state changes all at once or not at all. Callers must
+// hold bt.mu.
+func (bt *BinomToken) settle(changes []core.StateChange, revert bool) error {
+	batch := &memoryBatch{
+		bt:       bt,
+		balances: make(map[string]bnm.Amount),
+		nonces:   make(map[string]uint64),
+	}
+
+	var err error
+	if revert {
+		err = core.RevertStateChanges(batch, changes)
+	} else {
+		err = core.ApplyStateChanges(batch, changes)
+	}
+	if err != nil {
+		return err
+	}
+
+	batch.commit()
+	return nil
+}
+
+// ApplyStateChanges applies state changes all at once or not at all
+func (bt *BinomToken) ApplyStateChanges(changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return bt.settle(changes, false)
+}
+
+// ApplyBlockChanges applies the state changes of a block and keeps them so the
+// block can be reverted. A block whose changes are already applied is skipped.
+func (bt *BinomToken) ApplyBlockChanges(blockHash string, changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if _, ok := bt.blockChanges[blockHash]; ok {
+		return nil
+	}
+	if err := bt.settle(changes, false); err != nil {
+		return err
+	}
+
+	if bt.blockChanges == nil {
+		bt.blockChanges = make(map[string][]core.StateChange)
+	}
+	bt.blockChanges[blockHash] = changes
+	return nil
+}
+
+// RevertBlockChanges reverts the state changes applied by a block
+func (bt *BinomToken) RevertBlockChanges(blockHash string) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	changes, ok := bt.blockChanges[blockHash]
+	if !ok {
+		return fmt.Errorf("no state changes recorded for block %s", blockHash)
+	}
+	if err := bt.settle(changes, true); err != nil {
+		return err
+	}
+
+	delete(bt.blockChanges, blockHash)
+	return nil
+}
+
 // Burn burns tokens, reducing the circulating supply
-func (bt *BinomToken) Burn(amount float64) {
+func (bt *BinomToken) Burn(amount bnm.Amount) {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
 	bt.circulatingSupply -= amount
 
-	fmt.Printf("Burned %.2f BNM tokens. New circulating supply: %.2f\n", amount, bt.circulatingSupply)
+	fmt.Printf("Burned %s BNM tokens. New circulating supply: %s\n", amount, bt.circulatingSupply)
+}
+
+// Unburn returns previously burned tokens to the circulating supply, used when the
+// block that burned them leaves the canonical chain
+func (bt *BinomToken) Unburn(amount bnm.Amount) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if bt.circulatingSupply+amount > bt.maxSupply {
+		return fmt.Errorf("unburning would exceed max supply")
+	}
+
+	bt.circulatingSupply += amount
+	return nil
 }
 
 // Mint mints new tokens, increasing the circulating supply
 // This is restricted to not exceed the max supply
-func (bt *BinomToken) Mint(to string, amount float64) error {
+func (bt *BinomToken) Mint(to string, amount bnm.Amount) error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
@@ -116,8 +333,30 @@ func (bt *BinomToken) SaveBalances(dataDir string) error {
 		return fmt.Errorf("failed to write balances file: %v", err)
 	}
 
+	// Save account nonces so replayed transactions stay rejected after a restart
+	nonceData, err := json.MarshalIndent(bt.nonces, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal nonces: %v", err)
+	}
+
+	noncesFile := filepath.Join(balancesDir, "nonces.json")
+	if err := os.WriteFile(noncesFile, nonceData, 0644); err != nil {
+		return fmt.Errorf("failed to write nonces file: %v", err)
+	}
+
+	// Save the changes of each block so it can still be reverted after a restart
+	changesData, err := json.MarshalIndent(bt.blockChanges, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal block state changes: %v", err)
+	}
+
+	changesFile := filepath.Join(balancesDir, "block_changes.json")
+	if err := os.WriteFile(changesFile, changesData, 0644); err != nil {
+		return fmt.Errorf("failed to write block state changes file: %v", err)
+	}
+
 	// Also save circulating supply
-	supplyData := fmt.Sprintf("%.8f", bt.circulatingSupply)
+	supplyData := bt.circulatingSupply.String()
 	supplyFile := filepath.Join(balancesDir, "circulating_supply.txt")
 	if err := os.WriteFile(supplyFile, []byte(supplyData), 0644); err != nil {
 		return fmt.Errorf("failed to write supply file: %v", err)
@@ -151,12 +390,38 @@ func (bt *BinomToken) LoadBalances(dataDir string) error {
 		return fmt.Errorf("failed to unmarshal balances: %v", err)
 	}
 
+	// Read account nonces
+	noncesFile := filepath.Join(balancesDir, "nonces.json")
+	if nonceData, err := os.ReadFile(noncesFile); err == nil {
+		if err := json.Unmarshal(nonceData, &bt.nonces); err != nil {
+			bt.mu.Unlock()
+			return fmt.Errorf("failed to unmarshal nonces: %v", err)
+		}
+	}
+	if bt.nonces == nil {
+		bt.nonces = make(map[string]uint64)
+	}
+
+	// Read the state changes of each block
+	changesFile := filepath.Join(balancesDir, "block_changes.json")
+	if changesData, err := os.ReadFile(changesFile); err == nil {
+		if err := json.Unmarshal(changesData, &bt.blockChanges); err != nil {
+			bt.mu.Unlock()
+			return fmt.Errorf("failed to unmarshal block state changes: %v", err)
+		}
+	}
+	if bt.blockChanges == nil {
+		bt.blockChanges = make(map[string][]core.StateChange)
+	}
+
 	// Read circulating supply
 	supplyFile := filepath.Join(balancesDir, "circulating_supply.txt")
 	if _, err := os.Stat(supplyFile); !os.IsNotExist(err) {
 		supplyData, err := os.ReadFile(supplyFile)
 		if err == nil {
-			fmt.Sscanf(string(supplyData), "%f", &bt.circulatingSupply)
+			if supply, err := bnm.Parse(string(supplyData)); err == nil {
+				bt.circulatingSupply = supply
+			}
 		}
 	}
 
@@ -1,30 +1,32 @@
 package token
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
-	"strconv"
 	"sync"
 
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
 	"github.com/igo-used/binomena/database"
 	"gorm.io/gorm"
 )
 
 // BinomTokenDB represents the database-backed Binom (BNM) token
 type BinomTokenDB struct {
-	maxSupply float64
+	maxSupply bnm.Amount
 	mu        sync.RWMutex
 }
 
 // NewBinomTokenWithDB creates a new database-backed Binom token
 func NewBinomTokenWithDB() *BinomTokenDB {
 	return &BinomTokenDB{
-		maxSupply: 1000000000.0, // 1 billion
+		maxSupply: bnm.FromBNM(1000000000), // 1 billion
 	}
 }
 
 // Transfer transfers tokens from one address to another using database
-func (bt *BinomTokenDB) Transfer(from, to string, amount float64) error {
+func (bt *BinomTokenDB) Transfer(from, to string, amount bnm.Amount) error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
@@ -95,25 +97,43 @@ func (bt *BinomTokenDB) Transfer(from, to string, amount float64) error {
 }
 
 // GetBalance returns the balance of an address from database
-func (bt *BinomTokenDB) GetBalance(address string) float64 {
+func (bt *BinomTokenDB) GetBalance(address string) bnm.Amount {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
 	var balance database.TokenBalance
 	result := database.DB.Where("address = ?", address).First(&balance)
 	if result.Error == gorm.ErrRecordNotFound {
-		return 0.0
+		return 0
 	}
 	if result.Error != nil {
 		log.Printf("Error getting balance for %s: %v", address, result.Error)
-		return 0.0
+		return 0
 	}
 
 	return balance.Balance
 }
 
+// GetNonce returns the next transaction nonce expected from an address
+func (bt *BinomTokenDB) GetNonce(address string) uint64 {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	var balance database.TokenBalance
+	result := database.DB.Where("address = ?", address).First(&balance)
+	if result.Error == gorm.ErrRecordNotFound {
+		return 0
+	}
+	if result.Error != nil {
+		log.Printf("Error getting nonce for %s: %v", address, result.Error)
+		return 0
+	}
+
+	return balance.Nonce
+}
+
 // GetCirculatingSupply returns the circulating supply from database
-func (bt *BinomTokenDB) GetCirculatingSupply() float64 {
+func (bt *BinomTokenDB) GetCirculatingSupply() bnm.Amount {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
@@ -124,17 +144,259 @@ func (bt *BinomTokenDB) GetCirculatingSupply() float64 {
 		return bt.maxSupply
 	}
 
-	supplyFloat, err := strconv.ParseFloat(supply.Value, 64)
+	currentSupply, err := bnm.Parse(supply.Value)
 	if err != nil {
 		log.Printf("Error parsing circulating supply: %v", err)
 		return bt.maxSupply
 	}
 
-	return supplyFloat
+	return currentSupply
+}
+
+// StateEntries returns the non-zero balances committed to by the state root
+func (bt *BinomTokenDB) StateEntries() (map[string][]byte, error) {
+	var balances []database.TokenBalance
+	if err := database.DB.Where("balance <> 0").Find(&balances).Error; err != nil {
+		return nil, fmt.Errorf("failed to load balances: %v", err)
+	}
+
+	entries := make(map[string][]byte, len(balances))
+	for _, balance := range balances {
+		entries[core.BalanceStateKey(balance.Address)] = balance.Balance.Bytes()
+	}
+	return entries, nil
+}
+
+// ExportState returns every balance, nonce and the circulating supply in database
+func (bt *BinomTokenDB) ExportState() (State, error) {
+	var accounts []database.TokenBalance
+	if err := database.DB.Find(&accounts).Error; err != nil {
+		return State{}, fmt.Errorf("failed to load balances: %v", err)
+	}
+
+	state := State{
+		CirculatingSupply: bt.GetCirculatingSupply(),
+		Balances:          make(map[string]bnm.Amount, len(accounts)),
+		Nonces:            make(map[string]uint64, len(accounts)),
+	}
+	for _, account := range accounts {
+		state.Balances[account.Address] = account.Balance
+		if account.Nonce != 0 {
+			state.Nonces[account.Address] = account.Nonce
+		}
+	}
+	return state, nil
+}
+
+// ImportState replaces every balance, nonce and the circulating supply in one
+// database transaction. The state changes recorded for blocks are forgotten, as
+// they no longer lead to this state.
+func (bt *BinomTokenDB) ImportState(state State) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if state.CirculatingSupply > bt.maxSupply {
+		return fmt.Errorf("circulating supply %s exceeds max supply", state.CirculatingSupply)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM token_balances").Error; err != nil {
+			return fmt.Errorf("failed to clear balances: %v", err)
+		}
+		if err := tx.Exec("DELETE FROM block_state_changes").Error; err != nil {
+			return fmt.Errorf("failed to clear block state changes: %v", err)
+		}
+
+		// Addresses with a nonce but no balance still need an account row
+		accounts := make(map[string]*database.TokenBalance, len(state.Balances))
+		for address, balance := range state.Balances {
+			accounts[address] = &database.TokenBalance{Address: address, Balance: balance}
+		}
+		for address, nonce := range state.Nonces {
+			if _, ok := accounts[address]; !ok {
+				accounts[address] = &database.TokenBalance{Address: address}
+			}
+			accounts[address].Nonce = nonce
+		}
+		for _, account := range accounts {
+			if err := tx.Create(account).Error; err != nil {
+				return fmt.Errorf("failed to save balance: %v", err)
+			}
+		}
+
+		supply := database.SystemState{Key: "circulating_supply"}
+		if err := tx.Where(&supply).FirstOrCreate(&supply).Error; err != nil {
+			return fmt.Errorf("failed to get circulating supply: %v", err)
+		}
+		supply.Value = state.CirculatingSupply.String()
+		if err := tx.Save(&supply).Error; err != nil {
+			return fmt.Errorf("failed to save circulating supply: %v", err)
+		}
+		return nil
+	})
+}
+
+// dbBatch reads and writes token state within a database transaction
+type dbBatch struct {
+	tx        *gorm.DB
+	maxSupply bnm.Amount
+}
+
+// account returns the account row of an address, which is zero until saved
+func (b dbBatch) account(address string) (database.TokenBalance, error) {
+	var account database.TokenBalance
+	result := b.tx.Where("address = ?", address).First(&account)
+	if result.Error == gorm.ErrRecordNotFound {
+		return database.TokenBalance{Address: address}, nil
+	}
+	if result.Error != nil {
+		return account, fmt.Errorf("failed to get account %s: %v", address, result.Error)
+	}
+	return account, nil
+}
+
+// Balance returns the balance of an address in the transaction
+func (b dbBatch) Balance(address string) (bnm.Amount, error) {
+	account, err := b.account(address)
+	return account.Balance, err
+}
+
+// SetBalance changes the balance of an address in the transaction
+func (b dbBatch) SetBalance(address string, balance bnm.Amount) error {
+	account, err := b.account(address)
+	if err != nil {
+		return err
+	}
+	account.Balance = balance
+	if err := b.tx.Save(&account).Error; err != nil {
+		return fmt.Errorf("failed to update balance: %v", err)
+	}
+	return nil
+}
+
+// Nonce returns the next nonce of an address in the transaction
+func (b dbBatch) Nonce(address string) (uint64, error) {
+	account, err := b.account(address)
+	return account.Nonce, err
+}
+
+// SetNonce changes the next nonce of an address in the transaction
+func (b dbBatch) SetNonce(address string, nonce uint64) error {
+	account, err := b.account(address)
+	if err != nil {
+		return err
+	}
+	account.Nonce = nonce
+	if err := b.tx.Save(&account).Error; err != nil {
+		return fmt.Errorf("failed to update nonce: %v", err)
+	}
+	return nil
+}
+
+// Supply returns the circulating supply in the transaction
+func (b dbBatch) Supply() (bnm.Amount, error) {
+	var supply database.SystemState
+	if err := b.tx.Where("key = ?", "circulating_supply").First(&supply).Error; err != nil {
+		return 0, fmt.Errorf("failed to get circulating supply: %v", err)
+	}
+	currentSupply, err := bnm.Parse(supply.Value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse circulating supply: %v", err)
+	}
+	return currentSupply, nil
+}
+
+// SetSupply changes the circulating supply in the transaction, up to the max supply
+func (b dbBatch) SetSupply(newSupply bnm.Amount) error {
+	if newSupply > b.maxSupply {
+		return fmt.Errorf("circulating supply would exceed max supply")
+	}
+	var supply database.SystemState
+	if err := b.tx.Where("key = ?", "circulating_supply").First(&supply).Error; err != nil {
+		return fmt.Errorf("failed to get circulating supply: %v", err)
+	}
+	supply.Value = newSupply.String()
+	if err := b.tx.Save(&supply).Error; err != nil {
+		return fmt.Errorf("failed to save circulating supply: %v", err)
+	}
+	return nil
+}
+
+// ApplyStateChanges applies state changes in one database transaction
+func (bt *BinomTokenDB) ApplyStateChanges(changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return core.ApplyStateChanges(dbBatch{tx: tx, maxSupply: bt.maxSupply}, changes)
+	})
+}
+
+// ApplyBlockChanges applies the state changes of a block and saves them in the same
+// database transaction so the block can be reverted. A block whose changes are
+// already applied is skipped.
+func (bt *BinomTokenDB) ApplyBlockChanges(blockHash string, changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&database.BlockStateChanges{}).Where("block_hash = ?", blockHash).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check block state changes: %v", err)
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if err := core.ApplyStateChanges(dbBatch{tx: tx, maxSupply: bt.maxSupply}, changes); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(changes)
+		if err != nil {
+			return fmt.Errorf("failed to marshal block state changes: %v", err)
+		}
+		record := database.BlockStateChanges{BlockHash: blockHash, Changes: database.JSON(data)}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to save block state changes: %v", err)
+		}
+		return nil
+	})
+}
+
+// RevertBlockChanges reverts the state changes applied by a block in one database
+// transaction
+func (bt *BinomTokenDB) RevertBlockChanges(blockHash string) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var record database.BlockStateChanges
+		result := tx.Where("block_hash = ?", blockHash).First(&record)
+		if result.Error == gorm.ErrRecordNotFound {
+			return fmt.Errorf("no state changes recorded for block %s", blockHash)
+		}
+		if result.Error != nil {
+			return fmt.Errorf("failed to get block state changes: %v", result.Error)
+		}
+
+		var changes []core.StateChange
+		if err := json.Unmarshal([]byte(record.Changes), &changes); err != nil {
+			return fmt.Errorf("failed to unmarshal block state changes: %v", err)
+		}
+		if err := core.RevertStateChanges(dbBatch{tx: tx, maxSupply: bt.maxSupply}, changes); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&record).Error; err != nil {
+			return fmt.Errorf("failed to delete block state changes: %v", err)
+		}
+		return nil
+	})
 }
 
 // Burn burns tokens, reducing the circulating supply in database
-func (bt *BinomTokenDB) Burn(amount float64) {
+func (bt *BinomTokenDB) Burn(amount bnm.Amount) {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
@@ -146,15 +408,15 @@ func (bt *BinomTokenDB) Burn(amount float64) {
 		return
 	}
 
-	supplyFloat, err := strconv.ParseFloat(supply.Value, 64)
+	currentSupply, err := bnm.Parse(supply.Value)
 	if err != nil {
 		log.Printf("Error parsing circulating supply for burn: %v", err)
 		return
 	}
 
 	// Burn tokens
-	newSupply := supplyFloat - amount
-	supply.Value = fmt.Sprintf("%.8f", newSupply)
+	newSupply := currentSupply - amount
+	supply.Value = newSupply.String()
 
 	// Save updated supply
 	if err := database.DB.Save(&supply).Error; err != nil {
@@ -162,11 +424,41 @@ func (bt *BinomTokenDB) Burn(amount float64) {
 		return
 	}
 
-	log.Printf("Burned %.2f BNM tokens. New circulating supply: %.2f", amount, newSupply)
+	log.Printf("Burned %s BNM tokens. New circulating supply: %s", amount, newSupply)
+}
+
+// Unburn returns previously burned tokens to the circulating supply in database, used
+// when the block that burned them leaves the canonical chain
+func (bt *BinomTokenDB) Unburn(amount bnm.Amount) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	var supply database.SystemState
+	result := database.DB.Where("key = ?", "circulating_supply").First(&supply)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get circulating supply: %v", result.Error)
+	}
+
+	currentSupply, err := bnm.Parse(supply.Value)
+	if err != nil {
+		return fmt.Errorf("failed to parse circulating supply: %v", err)
+	}
+
+	newSupply := currentSupply + amount
+	if newSupply > bt.maxSupply {
+		return fmt.Errorf("unburning would exceed max supply")
+	}
+	supply.Value = newSupply.String()
+
+	if err := database.DB.Save(&supply).Error; err != nil {
+		return fmt.Errorf("failed to save circulating supply: %v", err)
+	}
+
+	return nil
 }
 
 // Mint mints new tokens, increasing the circulating supply
-func (bt *BinomTokenDB) Mint(to string, amount float64) error {
+func (bt *BinomTokenDB) Mint(to string, amount bnm.Amount) error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
@@ -177,13 +469,13 @@ func (bt *BinomTokenDB) Mint(to string, amount float64) error {
 		return fmt.Errorf("failed to get circulating supply: %v", result.Error)
 	}
 
-	supplyFloat, err := strconv.ParseFloat(supply.Value, 64)
+	currentSupply, err := bnm.Parse(supply.Value)
 	if err != nil {
 		return fmt.Errorf("failed to parse circulating supply: %v", err)
 	}
 
 	// Check if minting would exceed max supply
-	if supplyFloat+amount > bt.maxSupply {
+	if currentSupply+amount > bt.maxSupply {
 		return fmt.Errorf("minting would exceed max supply")
 	}
 
@@ -219,8 +511,8 @@ func (bt *BinomTokenDB) Mint(to string, amount float64) error {
 	}
 
 	// Update circulating supply
-	newSupply := supplyFloat + amount
-	supply.Value = fmt.Sprintf("%.8f", newSupply)
+	newSupply := currentSupply + amount
+	supply.Value = newSupply.String()
 	if err := tx.Save(&supply).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to update circulating supply: %v", err)
@@ -0,0 +1,401 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+	"github.com/igo-used/binomena/database"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BinomTokenKV represents the Binom (BNM) token stored in the embedded key-value store
+type BinomTokenKV struct {
+	maxSupply bnm.Amount
+	mu        sync.RWMutex
+}
+
+// NewBinomTokenWithKV creates a new key-value backed Binom token
+func NewBinomTokenWithKV() *BinomTokenKV {
+	return &BinomTokenKV{
+		maxSupply: bnm.FromBNM(1000000000), // 1 billion
+	}
+}
+
+// Transfer transfers tokens from one address to another in a single batch
+func (bt *BinomTokenKV) Transfer(from, to string, amount bnm.Amount) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		balances := tx.Bucket(database.BalancesBucket)
+
+		// Get sender balance
+		value := balances.Get([]byte(from))
+		if value == nil {
+			return fmt.Errorf("sender address not found")
+		}
+		fromBalance := database.DecodeAmount(value)
+
+		// Check if sender has enough balance
+		if fromBalance < amount {
+			return fmt.Errorf("insufficient balance")
+		}
+
+		// Update balances, reading the receiver after the sender for self-transfers
+		if err := balances.Put([]byte(from), (fromBalance - amount).Bytes()); err != nil {
+			return fmt.Errorf("failed to update sender balance: %v", err)
+		}
+		toBalance := database.DecodeAmount(balances.Get([]byte(to)))
+		if err := balances.Put([]byte(to), (toBalance + amount).Bytes()); err != nil {
+			return fmt.Errorf("failed to update receiver balance: %v", err)
+		}
+
+		return nil
+	})
+}
+
+// GetBalance returns the balance of an address from the store
+func (bt *BinomTokenKV) GetBalance(address string) bnm.Amount {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	var balance bnm.Amount
+	database.KV.View(func(tx *bolt.Tx) error {
+		balance = database.DecodeAmount(tx.Bucket(database.BalancesBucket).Get([]byte(address)))
+		return nil
+	})
+	return balance
+}
+
+// GetNonce returns the next transaction nonce expected from an address
+func (bt *BinomTokenKV) GetNonce(address string) uint64 {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	var nonce uint64
+	database.KV.View(func(tx *bolt.Tx) error {
+		nonce = database.DecodeUint64(tx.Bucket(database.NoncesBucket).Get([]byte(address)))
+		return nil
+	})
+	return nonce
+}
+
+// GetCirculatingSupply returns the circulating supply from the store
+func (bt *BinomTokenKV) GetCirculatingSupply() bnm.Amount {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	supply := bt.maxSupply
+	database.KV.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(database.SystemBucket).Get(database.CirculatingSupplyKey); value != nil {
+			supply = database.DecodeAmount(value)
+		}
+		return nil
+	})
+	return supply
+}
+
+// StateEntries returns the non-zero balances committed to by the state root
+func (bt *BinomTokenKV) StateEntries() (map[string][]byte, error) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	entries := make(map[string][]byte)
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(database.BalancesBucket).ForEach(func(address, value []byte) error {
+			if balance := database.DecodeAmount(value); balance != 0 {
+				entries[core.BalanceStateKey(string(address))] = balance.Bytes()
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balances: %v", err)
+	}
+	return entries, nil
+}
+
+// ExportState returns every balance, nonce and the circulating supply in the store
+func (bt *BinomTokenKV) ExportState() (State, error) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	state := State{
+		Balances: make(map[string]bnm.Amount),
+		Nonces:   make(map[string]uint64),
+	}
+	err := database.KV.View(func(tx *bolt.Tx) error {
+		state.CirculatingSupply = database.DecodeAmount(tx.Bucket(database.SystemBucket).Get(database.CirculatingSupplyKey))
+		tx.Bucket(database.BalancesBucket).ForEach(func(address, value []byte) error {
+			state.Balances[string(address)] = database.DecodeAmount(value)
+			return nil
+		})
+		return tx.Bucket(database.NoncesBucket).ForEach(func(address, value []byte) error {
+			state.Nonces[string(address)] = database.DecodeUint64(value)
+			return nil
+		})
+	})
+	if err != nil {
+		return State{}, fmt.Errorf("failed to export token state: %v", err)
+	}
+	return state, nil
+}
+
+// ImportState replaces every balance, nonce and the circulating supply in a single
+// batch. The state changes recorded for blocks are forgotten, as they no longer lead
+// to this state.
+func (bt *BinomTokenKV) ImportState(state State) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if state.CirculatingSupply > bt.maxSupply {
+		return fmt.Errorf("circulating supply %s exceeds max supply", state.CirculatingSupply)
+	}
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{database.BalancesBucket, database.NoncesBucket, database.StateChangesBucket} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return fmt.Errorf("failed to clear token state: %v", err)
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return fmt.Errorf("failed to clear token state: %v", err)
+			}
+		}
+
+		balances := tx.Bucket(database.BalancesBucket)
+		for address, balance := range state.Balances {
+			if err := balances.Put([]byte(address), balance.Bytes()); err != nil {
+				return fmt.Errorf("failed to save balance: %v", err)
+			}
+		}
+		nonces := tx.Bucket(database.NoncesBucket)
+		for address, nonce := range state.Nonces {
+			if err := nonces.Put([]byte(address), database.EncodeUint64(nonce)); err != nil {
+				return fmt.Errorf("failed to save nonce: %v", err)
+			}
+		}
+		if err := tx.Bucket(database.SystemBucket).Put(database.CirculatingSupplyKey, state.CirculatingSupply.Bytes()); err != nil {
+			return fmt.Errorf("failed to save circulating supply: %v", err)
+		}
+		return nil
+	})
+}
+
+// updateSupply applies a change to the circulating supply within a batch
+func updateSupply(tx *bolt.Tx, change func(bnm.Amount) (bnm.Amount, error)) (bnm.Amount, error) {
+	system := tx.Bucket(database.SystemBucket)
+
+	value := system.Get(database.CirculatingSupplyKey)
+	if value == nil {
+		return 0, fmt.Errorf("circulating supply not initialized")
+	}
+
+	newSupply, err := change(database.DecodeAmount(value))
+	if err != nil {
+		return 0, err
+	}
+	if err := system.Put(database.CirculatingSupplyKey, newSupply.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to save circulating supply: %v", err)
+	}
+	return newSupply, nil
+}
+
+// kvBatch reads and writes token state within a store batch
+type kvBatch struct {
+	tx        *bolt.Tx
+	maxSupply bnm.Amount
+}
+
+// Balance returns the balance of an address in the batch
+func (b kvBatch) Balance(address string) (bnm.Amount, error) {
+	return database.DecodeAmount(b.tx.Bucket(database.BalancesBucket).Get([]byte(address))), nil
+}
+
+// SetBalance changes the balance of an address in the batch
+func (b kvBatch) SetBalance(address string, balance bnm.Amount) error {
+	if err := b.tx.Bucket(database.BalancesBucket).Put([]byte(address), balance.Bytes()); err != nil {
+		return fmt.Errorf("failed to update balance: %v", err)
+	}
+	return nil
+}
+
+// Nonce returns the next nonce of an address in the batch
+func (b kvBatch) Nonce(address string) (uint64, error) {
+	return database.DecodeUint64(b.tx.Bucket(database.NoncesBucket).Get([]byte(address))), nil
+}
+
+// SetNonce changes the next nonce of an address in the batch
+func (b kvBatch) SetNonce(address string, nonce uint64) error {
+	if err := b.tx.Bucket(database.NoncesBucket).Put([]byte(address), database.EncodeUint64(nonce)); err != nil {
+		return fmt.Errorf("failed to update nonce: %v", err)
+	}
+	return nil
+}
+
+// Supply returns the circulating supply in the batch
+func (b kvBatch) Supply() (bnm.Amount, error) {
+	value := b.tx.Bucket(database.SystemBucket).Get(database.CirculatingSupplyKey)
+	if value == nil {
+		return 0, fmt.Errorf("circulating supply not initialized")
+	}
+	return database.DecodeAmount(value), nil
+}
+
+// SetSupply changes the circulating supply in the batch, up to the max supply
+func (b kvBatch) SetSupply(supply bnm.Amount) error {
+	if supply > b.maxSupply {
+		return fmt.Errorf("circulating supply would exceed max supply")
+	}
+	if err := b.tx.Bucket(database.SystemBucket).Put(database.CirculatingSupplyKey, supply.Bytes()); err != nil {
+		return fmt.Errorf("failed to save circulating supply: %v", err)
+	}
+	return nil
+}
+
+// ApplyStateChanges applies state changes in a single batch
+func (bt *BinomTokenKV) ApplyStateChanges(changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		return core.ApplyStateChanges(kvBatch{tx: tx, maxSupply: bt.maxSupply}, changes)
+	})
+}
+
+// ApplyBlockChanges applies the state changes of a block and stores them in the
+// same batch so the block can be reverted. A block whose changes are already
+// applied is skipped.
+func (bt *BinomTokenKV) ApplyBlockChanges(blockHash string, changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		return bt.applyBlockChanges(tx, blockHash, changes)
+	})
+}
+
+// ApplyBlockChangesTx applies and stores the state changes of a block within a
+// batch opened by the caller, such as the one storing the block itself
+func (bt *BinomTokenKV) ApplyBlockChangesTx(tx *bolt.Tx, blockHash string, changes []core.StateChange) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return bt.applyBlockChanges(tx, blockHash, changes)
+}
+
+// applyBlockChanges applies and stores the state changes of a block within a
+// batch. Callers must hold bt.mu.
+func (bt *BinomTokenKV) applyBlockChanges(tx *bolt.Tx, blockHash string, changes []core.StateChange) error {
+	journal := tx.Bucket(database.StateChangesBucket)
+	if journal.Get([]byte(blockHash)) != nil {
+		return nil
+	}
+
+	if err := core.ApplyStateChanges(kvBatch{tx: tx, maxSupply: bt.maxSupply}, changes); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block state changes: %v", err)
+	}
+	if err := journal.Put([]byte(blockHash), data); err != nil {
+		return fmt.Errorf("failed to save block state changes: %v", err)
+	}
+	return nil
+}
+
+// RevertBlockChanges reverts the state changes applied by a block in a single batch
+func (bt *BinomTokenKV) RevertBlockChanges(blockHash string) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		journal := tx.Bucket(database.StateChangesBucket)
+		data := journal.Get([]byte(blockHash))
+		if data == nil {
+			return fmt.Errorf("no state changes recorded for block %s", blockHash)
+		}
+
+		var changes []core.StateChange
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return fmt.Errorf("failed to unmarshal block state changes: %v", err)
+		}
+		if err := core.RevertStateChanges(kvBatch{tx: tx, maxSupply: bt.maxSupply}, changes); err != nil {
+			return err
+		}
+
+		if err := journal.Delete([]byte(blockHash)); err != nil {
+			return fmt.Errorf("failed to delete block state changes: %v", err)
+		}
+		return nil
+	})
+}
+
+// Burn burns tokens, reducing the circulating supply in the store
+func (bt *BinomTokenKV) Burn(amount bnm.Amount) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	var newSupply bnm.Amount
+	err := database.KV.Update(func(tx *bolt.Tx) error {
+		var err error
+		newSupply, err = updateSupply(tx, func(supply bnm.Amount) (bnm.Amount, error) {
+			return supply - amount, nil
+		})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error burning tokens: %v", err)
+		return
+	}
+
+	log.Printf("Burned %s BNM tokens. New circulating supply: %s", amount, newSupply)
+}
+
+// Unburn returns previously burned tokens to the circulating supply in the store, used
+// when the block that burned them leaves the canonical chain
+func (bt *BinomTokenKV) Unburn(amount bnm.Amount) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		_, err := updateSupply(tx, func(supply bnm.Amount) (bnm.Amount, error) {
+			if supply+amount > bt.maxSupply {
+				return 0, fmt.Errorf("unburning would exceed max supply")
+			}
+			return supply + amount, nil
+		})
+		return err
+	})
+}
+
+// Mint mints new tokens, increasing the circulating supply and the receiver's
+// balance in a single batch
+func (bt *BinomTokenKV) Mint(to string, amount bnm.Amount) error {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return database.KV.Update(func(tx *bolt.Tx) error {
+		_, err := updateSupply(tx, func(supply bnm.Amount) (bnm.Amount, error) {
+			if supply+amount > bt.maxSupply {
+				return 0, fmt.Errorf("minting would exceed max supply")
+			}
+			return supply + amount, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		balances := tx.Bucket(database.BalancesBucket)
+		toBalance := database.DecodeAmount(balances.Get([]byte(to)))
+		if err := balances.Put([]byte(to), (toBalance + amount).Bytes()); err != nil {
+			return fmt.Errorf("failed to update balance for minting: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,26 @@
+package token
+
+import (
+	"github.com/igo-used/binomena/bnm"
+	"github.com/igo-used/binomena/core"
+)
+
+// State is the full state of a token system: every known address's balance and
+// next nonce, and the circulating supply. Chain snapshots carry it between backends.
+type State struct {
+	CirculatingSupply bnm.Amount            `json:"circulatingSupply"`
+	Balances          map[string]bnm.Amount `json:"balances"`
+	Nonces            map[string]uint64     `json:"nonces"`
+}
+
+// StateEntries returns the non-zero balances committed to by the state root, as
+// the token systems holding this state would
+func (s State) StateEntries() (map[string][]byte, error) {
+	entries := make(map[string][]byte, len(s.Balances))
+	for address, balance := range s.Balances {
+		if balance != 0 {
+			entries[core.BalanceStateKey(address)] = balance.Bytes()
+		}
+	}
+	return entries, nil
+}
@@ -59,6 +59,34 @@ func generateAddress(publicKey *ecdsa.PublicKey) (string, error) {
 	return address, nil
 }
 
+// AddressFromPublicKey derives the "AdNe" wallet address for a public key
+func AddressFromPublicKey(publicKey *ecdsa.PublicKey) (string, error) {
+	if publicKey == nil || publicKey.X == nil || publicKey.Y == nil {
+		return "", fmt.Errorf("public key cannot be nil")
+	}
+	return generateAddress(publicKey)
+}
+
+// ExportPublicKey exports the public key as an uncompressed hex string
+func (w *Wallet) ExportPublicKey() string {
+	return hex.EncodeToString(elliptic.Marshal(w.PublicKey.Curve, w.PublicKey.X, w.PublicKey.Y))
+}
+
+// DecodePublicKey decodes an uncompressed hex-encoded P-256 public key
+func DecodePublicKey(publicKeyHex string) (*ecdsa.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %v", err)
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), pubKeyBytes)
+	if x == nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
 // Sign signs data with the wallet's private key
 func (w *Wallet) Sign(data []byte) ([]byte, error) {
 	// Hash the data
@@ -70,8 +98,10 @@ func (w *Wallet) Sign(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// Combine r and s into a single signature
-	signature := append(r.Bytes(), s.Bytes()...)
+	// Combine r and s into a single fixed-width signature
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
 	return signature, nil
 }
 